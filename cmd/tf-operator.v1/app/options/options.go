@@ -16,8 +16,13 @@ package options
 
 import (
 	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
 	v1 "k8s.io/api/core/v1"
 )
 
@@ -41,6 +46,403 @@ type ServerOption struct {
 	// Maximum burst for throttle.
 	// If it's zero, the created RESTClient will use DefaultBurst: 10.
 	Burst int
+	// TFConfigFile makes the operator deliver TF_CONFIG as a downward API file
+	// mounted into the tensorflow container instead of inlining it in env.
+	TFConfigFile bool
+	// TFConfigSecret makes the operator deliver the TF_CONFIG file through a
+	// Secret owned by the job, so that the cluster spec may carry credentials.
+	// Standby pods still get it through an annotation when they are promoted.
+	TFConfigSecret bool
+	// ActiveRequiresPodReady makes only running pods which pass their readiness
+	// checks count as active replicas.
+	ActiveRequiresPodReady bool
+	// CreateBatchSize limits the number of pods, and of services, created for a
+	// job in a single reconcile pass. Zero means no limit.
+	CreateBatchSize int
+	// FinalizerCleanupMaxRetries is the number of times the external cleanup of
+	// a deleted job is retried before its finalizer is removed anyway. Zero
+	// means no limit.
+	FinalizerCleanupMaxRetries int
+	// FinalizerCleanupTimeout is how long the external cleanup of a deleted job
+	// is retried before its finalizer is removed anyway. Zero means no limit.
+	FinalizerCleanupTimeout time.Duration
+	// DefaultRestartPolicies are the restart policies of the replica types
+	// whose spec leaves RestartPolicy empty.
+	DefaultRestartPolicies RestartPolicies
+	// WaitForPS makes the TF_CONFIG of workers list only the PS whose pods
+	// exist, so that workers do not start pointing at missing PS.
+	WaitForPS bool
+	// WatchReferencedConfig makes the operator watch the Secrets and ConfigMaps
+	// referenced by the pod templates of jobs, and sync the jobs when they change.
+	WatchReferencedConfig bool
+	// HorovodRankEnv sets HOROVOD_RANK and HOROVOD_SIZE in the chief, or
+	// master, and worker pods, so that Horovod can run inside a job.
+	HorovodRankEnv bool
+	// QuotaExceededBackoff is how long a job stops creating pods after their
+	// creation is refused because the namespace quota is exceeded.
+	QuotaExceededBackoff time.Duration
+	// ClusterSpecFormat is the format the cluster spec is set in the pods of
+	// jobs, as registered in the controller. Defaults to TF_CONFIG.
+	ClusterSpecFormat string
+	// RecreateEvictedPods makes evicted pods of jobs be deleted and recreated,
+	// instead of being counted as failed replicas and against the backoff limit.
+	RecreateEvictedPods bool
+	// GPUResourceName is the extended resource whose requests are copied to
+	// the limits of containers which do not limit it, as GPUs cannot be
+	// overcommitted. Empty disables the copy.
+	GPUResourceName string
+	// ScaleCooldown is how long a job with dynamic workers defers scaling its
+	// replicas after they were last scaled. Zero disables the cooldown.
+	ScaleCooldown time.Duration
+	// EnablePriorityQueue makes the controller reconcile the jobs with the
+	// highest priority class value first, instead of in the order they changed.
+	EnablePriorityQueue bool
+	// ReplicaEvaluationOrder is the order the replica types of a job are
+	// evaluated in to decide whether it succeeded or failed. The first replica
+	// type which settles the job wins. The replica types left out are
+	// evaluated after, in the default order.
+	ReplicaEvaluationOrder ReplicaTypes
+	// VolumeMountTimeout is how long a scheduled pod of a job may stay in
+	// ContainerCreating while failing to mount its volumes before the job gets
+	// the VolumeMountFailed condition. Zero disables the detection.
+	VolumeMountTimeout time.Duration
+	// VolumeMountRetries is the number of pods of a job recreated because they
+	// were stuck mounting their volumes. Zero only sets the condition.
+	VolumeMountRetries int
+	// RunningGate is the minimum number of active replicas of each replica
+	// type for a job to be considered running. Replica types left out, or
+	// missing from the job, are not required.
+	RunningGate RunningGate
+	// WaitForPSDNS injects in the worker pods of jobs an init container which
+	// waits for the DNS names of the PS to resolve, so that workers do not
+	// crash connecting to PS which are not there yet.
+	WaitForPSDNS bool
+	// WaitForPSDNSImage is the image of the init container injected by
+	// WaitForPSDNS. It must provide sh and nslookup.
+	WaitForPSDNSImage string
+	// ExitCodeContainers is which containers of the pods of jobs count when
+	// their exit codes are evaluated, ExitCodeContainersMain or
+	// ExitCodeContainersAll. Defaults to ExitCodeContainersMain.
+	ExitCodeContainers string
+	// TemplatedEnv is an environment variable set in every container of the
+	// pods of jobs, in the form "NAME=TEMPLATE". The template is rendered for
+	// every pod with the Namespace, Name and UID of its job, and its
+	// ReplicaType and ReplicaIndex. Empty disables it.
+	TemplatedEnv string
+	// PublishNotReadyAddresses makes the headless services of the replicas of
+	// jobs publish the addresses of their pods before the pods are ready.
+	PublishNotReadyAddresses bool
+	// ImagePullTimeout is how long the pods of a job may fail to pull their
+	// images before the job fails. Zero only sets the ImagePullFailed
+	// condition, and lets the kubelet retry forever.
+	ImagePullTimeout time.Duration
+	// ClusterSpecKeys maps replica types to their keys in the cluster spec of
+	// TF_CONFIG, for forks of TensorFlow which expect other keys than the
+	// lower case replica types. Replica types left out keep the default key.
+	ClusterSpecKeys ClusterSpecKeys
+	// ClusterMembersAnnotation sets the ClusterMembers annotation on the pods
+	// of jobs, and keeps it up to date when they are scaled.
+	ClusterMembersAnnotation bool
+	// FailedPodGracePeriod is how long after a pod of a job failed it is still
+	// counted as running, so that a pod failing only briefly, e.g. on a
+	// liveness blip, is not acted upon. Zero counts it as failed right away.
+	FailedPodGracePeriod time.Duration
+	// ReplicaTypeLabel and ReplicaIndexLabel are the label keys the replica
+	// type and index of the pods of jobs are read from, e.g. to interoperate
+	// with a system labeling pods its own way. The pods are labeled under
+	// them in addition to the default keys, which the services select the
+	// pods by. Empty uses the default keys.
+	ReplicaTypeLabel  string
+	ReplicaIndexLabel string
+	// DrainCordonedNodes makes the operator watch the nodes, and delete the
+	// pods of jobs on cordoned nodes, so that they are recreated elsewhere
+	// before the nodes are drained. Replicas which are never restarted are
+	// left alone.
+	DrainCordonedNodes bool
+	// JobMetadataEnv sets TFJOB_UID, TFJOB_NAME and TFJOB_NAMESPACE in every
+	// container of the pods of jobs.
+	JobMetadataEnv bool
+	// PatchTFConfig makes the operator patch the TF_CONFIG file of the
+	// existing pods of a job when its cluster spec changes, e.g. on scaling,
+	// for the training code which reads the file again. It requires the
+	// TFConfigFile, as the env of a running container cannot be changed.
+	PatchTFConfig bool
+	// RollingUpdate makes the operator label the pods of jobs with the hash
+	// of their replica template, and recreate the running pods whose template
+	// changed, a few at a time.
+	RollingUpdate bool
+	// RollingUpdateMaxUnavailable is how many replicas of a type may be
+	// unavailable during a rolling update. Defaults to 1.
+	RollingUpdateMaxUnavailable int
+	// ClusterSpecContainers are the containers of the pods of jobs the
+	// cluster spec is set in, in addition to the tensorflow container, e.g.
+	// helpers which need TF_CONFIG as well. AllContainers selects them all.
+	ClusterSpecContainers ContainerNames
+	// ReconcileOnGenerationChange makes the controller skip the updates of
+	// jobs which only change their status, once their generation has been
+	// reconciled. Pod and service events, and resyncs, still reconcile them.
+	ReconcileOnGenerationChange bool
+	// DefaultEnableDynamicWorker enables dynamic workers for the jobs whose
+	// spec does not set EnableDynamicWorker. An explicit false is kept.
+	DefaultEnableDynamicWorker bool
+	// MaxActiveJobsPerNamespace is the maximum number of jobs creating pods in
+	// a namespace. The jobs over it are queued until one finishes. Zero
+	// disables the limit.
+	MaxActiveJobsPerNamespace int
+	// FailureAggregationWindow is how long the failed replicas of a job are
+	// aggregated before failing it, starting with the first failure, so that a
+	// burst of failures which recover on reschedule does not fail it. Zero
+	// fails the job right away.
+	FailureAggregationWindow time.Duration
+	// FailureAggregationThreshold is the number of replicas which have to be
+	// failed still at the end of the FailureAggregationWindow for the job to
+	// fail. Defaults to 1.
+	FailureAggregationThreshold int
+	// StaticScaleDown is what happens to the pods of a job without dynamic
+	// workers past its replicas, when they are lowered, StaticScaleDownPermissive
+	// or StaticScaleDownStrict. Defaults to StaticScaleDownPermissive.
+	StaticScaleDown string
+	// WorkerStartDelay is how long the workers of a job wait to be created
+	// after the pods of all its PS are, so that the servers bind before the
+	// workers connect. Zero creates them right away.
+	WorkerStartDelay time.Duration
+	// ValidateExtendedResources makes the operator watch the nodes, and set a
+	// condition on the jobs requesting an extended resource, e.g. a GPU, which
+	// no schedulable node provides, instead of leaving their pods pending
+	// silently.
+	ValidateExtendedResources bool
+	// PodCreationTimeout is how long the creation of a pod may take before
+	// the operator gives up on it, lowers its expectation and requeues the
+	// job. Zero waits for the API server to time out.
+	PodCreationTimeout time.Duration
+	// DefaultSuccessPolicy is when the jobs whose spec does not set a
+	// SuccessPolicy succeed, SuccessPolicyWorker0 or SuccessPolicyAllWorkers.
+	// Defaults to SuccessPolicyWorker0.
+	DefaultSuccessPolicy string
+	// RecreateOnClusterSpecDrift makes the operator recreate all the pods of
+	// a replica type at once when the TF_CONFIG of one of them no longer
+	// matches the cluster spec of the job, e.g. when its members changed out
+	// of band, instead of leaving the replicas with diverging topologies.
+	RecreateOnClusterSpecDrift bool
+	// MinReadyPeriod is how long a running pod of a job has to be up, or
+	// ready if ActiveRequiresPodReady is set, before it counts as an active
+	// replica, so that a pod crashing right after it starts does not flip the
+	// job to running. Zero counts it right away.
+	MinReadyPeriod time.Duration
+	// RelabelLegacyPods relabels the pods of a job which still carry the
+	// legacy label keys, tf-job-name, tf-replica-type and tf-replica-index,
+	// with the current keys, so that they keep being selected and counted
+	// while the label scheme is migrated.
+	RelabelLegacyPods bool
+}
+
+// RestartPolicies maps replica types to restart policies. As a flag it is
+// given in the form "Worker=OnFailure,PS=Always".
+type RestartPolicies map[commonv1.ReplicaType]commonv1.RestartPolicy
+
+// String implements flag.Value.
+func (p RestartPolicies) String() string {
+	pairs := make([]string, 0, len(p))
+	for rtype, policy := range p {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", rtype, policy))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value.
+func (p *RestartPolicies) Set(value string) error {
+	policies := RestartPolicies{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid restart policy %q, expected <replica type>=<restart policy>", pair)
+		}
+		policy := commonv1.RestartPolicy(kv[1])
+		switch policy {
+		case commonv1.RestartPolicyAlways, commonv1.RestartPolicyOnFailure,
+			commonv1.RestartPolicyNever, commonv1.RestartPolicyExitCode:
+		default:
+			return fmt.Errorf("invalid restart policy %q of %s", kv[1], kv[0])
+		}
+		policies[commonv1.ReplicaType(kv[0])] = policy
+	}
+	*p = policies
+	return nil
+}
+
+// ClusterSpecKeys maps replica types to their keys in the cluster spec. As a
+// flag it is given in the form "PS=parameter_server,Worker=trainer".
+type ClusterSpecKeys map[commonv1.ReplicaType]string
+
+// String implements flag.Value.
+func (k ClusterSpecKeys) String() string {
+	pairs := make([]string, 0, len(k))
+	for rtype, key := range k {
+		pairs = append(pairs, fmt.Sprintf("%s=%s", rtype, key))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value.
+func (k *ClusterSpecKeys) Set(value string) error {
+	keys := ClusterSpecKeys{}
+	rtypes := map[string]commonv1.ReplicaType{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return fmt.Errorf("invalid cluster spec key %q, expected <replica type>=<key>", pair)
+		}
+		if rtype, ok := rtypes[kv[1]]; ok {
+			return fmt.Errorf("cluster spec key %q of %s is already the key of %s", kv[1], kv[0], rtype)
+		}
+		rtypes[kv[1]] = commonv1.ReplicaType(kv[0])
+		keys[commonv1.ReplicaType(kv[0])] = kv[1]
+	}
+	*k = keys
+	return nil
+}
+
+// ReplicaTypes is an ordered list of replica types. As a flag it is given in
+// the form "Chief,Master,Worker".
+type ReplicaTypes []commonv1.ReplicaType
+
+// String implements flag.Value.
+func (r ReplicaTypes) String() string {
+	types := make([]string, 0, len(r))
+	for _, rtype := range r {
+		types = append(types, string(rtype))
+	}
+	return strings.Join(types, ",")
+}
+
+// Set implements flag.Value.
+func (r *ReplicaTypes) Set(value string) error {
+	types := ReplicaTypes{}
+	for _, rtype := range strings.Split(value, ",") {
+		if rtype = strings.TrimSpace(rtype); rtype != "" {
+			types = append(types, commonv1.ReplicaType(rtype))
+		}
+	}
+	*r = types
+	return nil
+}
+
+// ContainerNames is a list of container names. As a flag it is given in the
+// form "exporter,uploader".
+type ContainerNames []string
+
+// AllContainers selects every container of a pod in ContainerNames.
+const AllContainers = "*"
+
+// String implements flag.Value.
+func (c ContainerNames) String() string {
+	return strings.Join(c, ",")
+}
+
+// Set implements flag.Value.
+func (c *ContainerNames) Set(value string) error {
+	names := ContainerNames{}
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	*c = names
+	return nil
+}
+
+// Has returns true if the container of the given name is selected.
+func (c ContainerNames) Has(name string) bool {
+	for _, n := range c {
+		if n == name || n == AllContainers {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	// ExitCodeContainersMain only counts the exit code of the tensorflow
+	// container, so that a failing sidecar does not fail the replica.
+	ExitCodeContainersMain = "main"
+	// ExitCodeContainersAll counts the exit codes of all the containers.
+	ExitCodeContainersAll = "all"
+)
+
+const (
+	// StaticScaleDownPermissive deletes the pods and services of a job without
+	// dynamic workers past its replicas, as for a job with dynamic workers.
+	StaticScaleDownPermissive = "permissive"
+	// StaticScaleDownStrict keeps the pods and services of a job without
+	// dynamic workers past its replicas, and warns that the replicas of the
+	// job cannot be lowered.
+	StaticScaleDownStrict = "strict"
+)
+
+const (
+	// SuccessPolicyWorker0 makes a job succeed once its worker 0 succeeds,
+	// the workers still running are cleaned up according to its
+	// CleanPodPolicy.
+	SuccessPolicyWorker0 = "worker0"
+	// SuccessPolicyAllWorkers makes a job succeed once all its workers
+	// succeed.
+	SuccessPolicyAllWorkers = "all-workers"
+)
+
+// AllReplicas requires every replica of a replica type to be active in a
+// RunningGate.
+const AllReplicas int32 = -1
+
+// RunningGate maps replica types to the minimum number of their replicas which
+// are active in a running job. As a flag it is given in the form
+// "PS=all,Worker=1".
+type RunningGate map[commonv1.ReplicaType]int32
+
+// String implements flag.Value.
+func (g RunningGate) String() string {
+	pairs := make([]string, 0, len(g))
+	for rtype, min := range g {
+		value := strconv.Itoa(int(min))
+		if min == AllReplicas {
+			value = "all"
+		}
+		pairs = append(pairs, fmt.Sprintf("%s=%s", rtype, value))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set implements flag.Value.
+func (g *RunningGate) Set(value string) error {
+	gate := RunningGate{}
+	for _, pair := range strings.Split(value, ",") {
+		if pair == "" {
+			continue
+		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return fmt.Errorf("invalid running gate %q, expected <replica type>=<replicas>", pair)
+		}
+		if kv[1] == "all" {
+			gate[commonv1.ReplicaType(kv[0])] = AllReplicas
+			continue
+		}
+		min, err := strconv.ParseInt(kv[1], 10, 32)
+		if err != nil || min < 0 {
+			return fmt.Errorf("invalid number of replicas %q of %s, expected a non negative integer or all", kv[1], kv[0])
+		}
+		gate[commonv1.ReplicaType(kv[0])] = int32(min)
+	}
+	*g = gate
+	return nil
 }
 
 // NewServerOption creates a new CMServer with a default config.
@@ -62,7 +464,7 @@ func (s *ServerOption) AddFlags(fs *flag.FlagSet) {
                 If set, it only monitors tfjobs in the given namespace.`)
 
 	fs.IntVar(&s.Threadiness, "threadiness", 1,
-		`How many threads to process the main logic`)
+		`How many threads to process the main logic, i.e. the maximum number of tfjobs reconciled concurrently`)
 
 	fs.BoolVar(&s.PrintVersion, "version", false, "Show version and quit")
 
@@ -80,4 +482,215 @@ It can be set to "0" to disable the metrics serving.`)
 
 	fs.IntVar(&s.QPS, "qps", 5, "QPS indicates the maximum QPS to the master from this client.")
 	fs.IntVar(&s.Burst, "burst", 10, "Maximum burst for throttle.")
+
+	fs.BoolVar(&s.TFConfigFile, "tf-config-file", false,
+		`Set true to mount TF_CONFIG as a file under /etc/tfjob and point TF_CONFIG_FILE at it,
+		 instead of setting TF_CONFIG in env. Useful for very large cluster specs.`)
+
+	fs.BoolVar(&s.TFConfigSecret, "tf-config-secret", false,
+		`Set true to write the TF_CONFIG file of every replica to a Secret owned by the tfjob and mount it
+		 under /etc/tfjob, instead of exposing it in a pod annotation. The Secrets are deleted when the tfjob finishes.`)
+
+	fs.BoolVar(&s.ActiveRequiresPodReady, "active-requires-pod-ready", false,
+		"Set true to count only running pods with the Ready condition as active replicas")
+
+	fs.IntVar(&s.CreateBatchSize, "create-batch-size", 0,
+		`The maximum number of pods, and of services, created for a tfjob in one reconcile pass.
+		 The rest are created in the following passes. Set 0 to create all of them at once.`)
+
+	fs.IntVar(&s.FinalizerCleanupMaxRetries, "finalizer-cleanup-max-retries", 0,
+		"The number of retries of the external cleanup of a deleted tfjob before its finalizer is removed anyway. Set 0 for no limit.")
+	fs.DurationVar(&s.FinalizerCleanupTimeout, "finalizer-cleanup-timeout", 10*time.Minute,
+		"How long the external cleanup of a deleted tfjob is retried before its finalizer is removed anyway. Set 0 for no limit.")
+
+	fs.Var(&s.DefaultRestartPolicies, "default-restart-policies",
+		`The restart policies of the replica types whose spec leaves restartPolicy empty,
+		 e.g. "Worker=OnFailure,PS=Always". Replica types not listed default to Never.`)
+
+	fs.BoolVar(&s.WaitForPS, "wait-for-ps", false,
+		"Set true to list in the TF_CONFIG of workers only the PS whose pods exist")
+
+	fs.BoolVar(&s.WatchReferencedConfig, "watch-referenced-config", false,
+		`Set true to sync tfjobs when a Secret or ConfigMap referenced by their pod templates changes.
+		 Replica types with recreateOnConfigChange then have their pods recreated one at a time.`)
+
+	fs.BoolVar(&s.HorovodRankEnv, "horovod-rank-env", false,
+		`Set true to set HOROVOD_RANK and HOROVOD_SIZE in the chief and worker pods. The chief has
+		 rank 0 and the workers follow in the order of their indices.`)
+
+	fs.DurationVar(&s.QuotaExceededBackoff, "quota-exceeded-backoff", time.Minute,
+		"How long a tfjob stops creating pods after a creation is refused because the namespace quota is exceeded.")
+
+	fs.StringVar(&s.ClusterSpecFormat, "cluster-spec-format", "tf-config",
+		`The format the cluster spec is set in the pods of tfjobs. "tf-config" sets TF_CONFIG, "host-list" sets
+		 PS_HOSTS, WORKER_HOSTS and the like, JOB_NAME and TASK_INDEX, "tf-config-and-host-list" sets both.`)
+
+	fs.BoolVar(&s.RecreateEvictedPods, "recreate-evicted-pods", false,
+		"Set true to recreate evicted pods instead of counting them as failed replicas and against the backoff limit")
+
+	fs.StringVar(&s.GPUResourceName, "gpu-resource-name", "",
+		"The GPU resource, e.g. nvidia.com/gpu, whose requests are copied to the limits of containers which do not set them. Disabled if empty.")
+
+	fs.DurationVar(&s.ScaleCooldown, "scale-cooldown", 0,
+		"How long a tfjob with dynamic workers defers scaling its replicas after they were last scaled. Set 0 to disable.")
+
+	fs.BoolVar(&s.EnablePriorityQueue, "enable-priority-queue", false,
+		`Set true to reconcile the tfjobs with the highest priority first. The priority of a tfjob is the value of
+		 the priority class of its scheduling policy, or the highest one of its replicas.`)
+
+	fs.Var(&s.ReplicaEvaluationOrder, "replica-evaluation-order",
+		`The order the replica types of a tfjob are evaluated in to decide whether it succeeded or failed, in the form
+		 "Chief,Master,Worker". The first replica type which settles the tfjob wins. Defaults to Chief,Evaluator,Master,PS,Worker.`)
+
+	fs.DurationVar(&s.VolumeMountTimeout, "volume-mount-timeout", 0,
+		"How long a pod may stay in ContainerCreating while failing to mount its volumes before its tfjob is reported stuck, e.g. 10m. Disabled if 0.")
+	fs.IntVar(&s.VolumeMountRetries, "volume-mount-retries", 0,
+		"The number of pods of a tfjob recreated because they were stuck mounting their volumes. Set 0 to never recreate them.")
+
+	fs.Var(&s.RunningGate, "running-gate",
+		`The minimum number of active replicas of each replica type for a tfjob to be running, in the form
+		 "PS=all,Worker=1". Replica types not listed are not required. Defaults to any active chief, master or worker.`)
+
+	fs.BoolVar(&s.WaitForPSDNS, "wait-for-ps-dns", false,
+		"Set true to inject in worker pods an init container which waits for the DNS names of the PS to resolve")
+	fs.StringVar(&s.WaitForPSDNSImage, "wait-for-ps-dns-image", "busybox:1.28",
+		"The image of the init container injected by --wait-for-ps-dns. It must provide sh and nslookup.")
+
+	fs.StringVar(&s.ExitCodeContainers, "exit-code-containers", ExitCodeContainersMain,
+		`Which containers count when the exit codes of the pods of tfjobs are evaluated. "main" only counts the
+		 tensorflow container, so that a failing sidecar does not fail its replica. "all" counts all of them.`)
+
+	fs.StringVar(&s.TemplatedEnv, "templated-env", "",
+		`An environment variable set in the containers of tfjob pods, in the form "NAME=TEMPLATE", e.g.
+		 "CHECKPOINT_DIR=/checkpoints/{{.Namespace}}/{{.Name}}". The template may use .Namespace, .Name and .UID of
+		 the tfjob, and .ReplicaType and .ReplicaIndex of the pod. Variables defined in the pod template are kept.`)
+
+	fs.BoolVar(&s.PublishNotReadyAddresses, "publish-not-ready-addresses", true,
+		`Set true to create the headless services of tfjob replicas with publishNotReadyAddresses, so that their DNS
+		 names resolve before the pods are ready, as replicas connect to their peers to establish the cluster.`)
+
+	fs.DurationVar(&s.ImagePullTimeout, "image-pull-timeout", 0,
+		"How long the pods of a tfjob may fail to pull their images before the tfjob fails. Set 0 to never fail it.")
+
+	fs.Var(&s.ClusterSpecKeys, "cluster-spec-keys",
+		`The keys of replica types in the cluster spec of TF_CONFIG, in the form "PS=parameter_server". Replica types
+		 not listed keep their lower case name, e.g. ps or worker. "Master=chief" lists the legacy Master replica under
+		 the chief key expected by TensorFlow 1.5 and later.`)
+
+	fs.BoolVar(&s.ClusterMembersAnnotation, "cluster-members-annotation", false,
+		`Set true to annotate the pods of tfjobs with the endpoints of all their replicas, kept up to date when the
+		 tfjobs are scaled, e.g. for sidecars to read them through the downward API.`)
+
+	fs.DurationVar(&s.FailedPodGracePeriod, "failed-pod-grace-period", 0,
+		"How long after a pod of a tfjob failed it is still counted as running, before it is checked again. Set 0 to count it as failed right away.")
+
+	fs.StringVar(&s.ReplicaTypeLabel, "replica-type-label", "",
+		"The label key the replica type of the pods of tfjobs is read from, in addition to replica-type. Defaults to replica-type.")
+	fs.StringVar(&s.ReplicaIndexLabel, "replica-index-label", "",
+		"The label key the replica index of the pods of tfjobs is read from, in addition to replica-index. Defaults to replica-index.")
+
+	fs.BoolVar(&s.DrainCordonedNodes, "drain-cordoned-nodes", false,
+		`Set true to recreate the pods of tfjobs on cordoned nodes elsewhere, instead of waiting for them to be evicted.
+		 Replicas whose restart policy is Never are left alone.`)
+
+	fs.BoolVar(&s.JobMetadataEnv, "job-metadata-env", false,
+		`Set true to set TFJOB_UID, TFJOB_NAME and TFJOB_NAMESPACE in every container of the pods of tfjobs, e.g.
+		 to key the telemetry of the training by the tfjob. Variables defined in the pod template are kept.`)
+
+	fs.BoolVar(&s.PatchTFConfig, "patch-tf-config", false,
+		`Set true to patch the TF_CONFIG file of the existing pods of tfjobs when their cluster spec changes, e.g.
+		 when they are scaled, instead of leaving it stale. Requires --tf-config-file.`)
+
+	fs.BoolVar(&s.RollingUpdate, "rolling-update", false,
+		`Set true to recreate the running pods of tfjobs whose replica template changed, e.g. to a new image.
+		 Pods created before it was set are left alone.`)
+	fs.IntVar(&s.RollingUpdateMaxUnavailable, "rolling-update-max-unavailable", 1,
+		"How many replicas of a type may be unavailable while their pods are recreated by a rolling update.")
+
+	fs.Var(&s.ClusterSpecContainers, "cluster-spec-containers",
+		`The containers of the pods of tfjobs the cluster spec is set in, e.g. TF_CONFIG, in addition to the tensorflow
+		 container, in the form "exporter,uploader". Set "*" to set it in every container.`)
+
+	fs.BoolVar(&s.ReconcileOnGenerationChange, "reconcile-on-generation-change", false,
+		`Set true to skip reconciling tfjobs whose update only changed their status, once their generation has been
+		 reconciled, to cut the load on large clusters. Pod and service events, and resyncs, still reconcile them.`)
+
+	fs.BoolVar(&s.DefaultEnableDynamicWorker, "default-enable-dynamic-worker", false,
+		`Set true to enable dynamic workers for the tfjobs whose spec does not set enableDynamicWorker.
+		 Tfjobs setting it to false keep static workers.`)
+
+	fs.IntVar(&s.MaxActiveJobsPerNamespace, "max-active-jobs-per-namespace", 0,
+		`The maximum number of tfjobs creating pods in a namespace. The tfjobs over it are queued, in the order
+		 they were created, until one finishes. 0 disables the limit.`)
+
+	fs.DurationVar(&s.FailureAggregationWindow, "failure-aggregation-window", 0,
+		`How long the failed replicas of a tfjob are aggregated, from the first failure, before failing it. A burst of
+		 failures which recover on reschedule within the window does not fail the tfjob. 0 fails it right away.`)
+
+	fs.IntVar(&s.FailureAggregationThreshold, "failure-aggregation-threshold", 1,
+		"The number of replicas of a tfjob which have to be failed still at the end of the failure aggregation window for it to fail.")
+
+	fs.StringVar(&s.StaticScaleDown, "static-scale-down", StaticScaleDownPermissive,
+		`What happens to the pods of a tfjob without dynamic workers past its replicas when they are lowered.
+		 "permissive" deletes them, "strict" keeps them and warns with an event.`)
+
+	fs.DurationVar(&s.WorkerStartDelay, "worker-start-delay", 0,
+		`How long the workers of a tfjob wait to be created after the pods of all its PS are, so that the servers
+		 bind before the workers connect. 0 creates them right away.`)
+
+	fs.BoolVar(&s.ValidateExtendedResources, "validate-extended-resources", false,
+		`Set true to warn on the tfjobs requesting an extended resource, e.g. nvidia.com/gpu, which no schedulable
+		 node provides, with the ResourcesUnavailable condition and an event. It adds a watch on the nodes.`)
+
+	fs.DurationVar(&s.PodCreationTimeout, "pod-creation-timeout", 0,
+		`How long the creation of a pod of a tfjob may take before it is given up on, and the tfjob is requeued
+		 to retry it. 0 waits for the API server to time out.`)
+
+	fs.StringVar(&s.DefaultSuccessPolicy, "default-success-policy", SuccessPolicyWorker0,
+		`When the tfjobs whose spec does not set successPolicy succeed. "worker0" succeeds them once worker 0
+		 succeeds, cleaning up the workers still running according to their cleanPodPolicy, "all-workers" once
+		 all their workers succeed. Tfjobs setting it keep their policy.`)
+
+	fs.BoolVar(&s.RecreateOnClusterSpecDrift, "recreate-on-cluster-spec-drift", false,
+		`Set true to recreate all the active pods of a replica type of a tfjob at once when the TF_CONFIG of one of
+		 them no longer matches the cluster spec of the tfjob, instead of leaving the replicas with diverging topologies.`)
+
+	fs.DurationVar(&s.MinReadyPeriod, "min-ready-period", 0,
+		`How long a running pod of a tfjob has to be up, or ready with --active-requires-pod-ready, before it counts
+		 as an active replica, e.g. for the Running condition. 0 counts it right away.`)
+
+	fs.BoolVar(&s.RelabelLegacyPods, "relabel-legacy-pods", false,
+		`Set true to patch the pods of a tfjob labeled with the legacy keys tf-job-name, tf-replica-type and
+		 tf-replica-index with the current keys, so that they keep being counted while the label scheme is migrated.`)
+}
+
+// Validate returns an error if an option is set to an unknown value, or if
+// options which cannot be combined are set together.
+func (s *ServerOption) Validate() error {
+	switch s.ExitCodeContainers {
+	case "", ExitCodeContainersMain, ExitCodeContainersAll:
+	default:
+		return fmt.Errorf("invalid exit code containers %q, expected %q or %q",
+			s.ExitCodeContainers, ExitCodeContainersMain, ExitCodeContainersAll)
+	}
+	switch s.StaticScaleDown {
+	case "", StaticScaleDownPermissive, StaticScaleDownStrict:
+	default:
+		return fmt.Errorf("invalid static scale down %q, expected %q or %q",
+			s.StaticScaleDown, StaticScaleDownPermissive, StaticScaleDownStrict)
+	}
+	switch s.DefaultSuccessPolicy {
+	case "", SuccessPolicyWorker0, SuccessPolicyAllWorkers:
+	default:
+		return fmt.Errorf("invalid default success policy %q, expected %q or %q",
+			s.DefaultSuccessPolicy, SuccessPolicyWorker0, SuccessPolicyAllWorkers)
+	}
+	tfConfigFormat := s.ClusterSpecFormat == "" || s.ClusterSpecFormat == "tf-config"
+	if s.TFConfigSecret && !tfConfigFormat {
+		return fmt.Errorf("TF_CONFIG can only be delivered through a Secret with the %q cluster spec format", "tf-config")
+	}
+	if s.PatchTFConfig && (!tfConfigFormat || !s.TFConfigFile || s.TFConfigSecret) {
+		return fmt.Errorf("TF_CONFIG can only be patched when it is delivered as a file with the %q cluster spec format, without a Secret", "tf-config")
+	}
+	return nil
 }
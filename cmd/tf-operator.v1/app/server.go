@@ -74,6 +74,9 @@ func Run(opt *options.ServerOption) error {
 	if opt.PrintVersion {
 		version.PrintVersionAndExit(apiVersion)
 	}
+	if err := opt.Validate(); err != nil {
+		return err
+	}
 
 	namespace := os.Getenv(common.EnvKubeflowNamespace)
 	if len(namespace) == 0 {
@@ -133,7 +136,10 @@ func Run(opt *options.ServerOption) error {
 		kcfg, opt.Namespace, opt.ResyncPeriod)
 
 	// Create tf controller.
-	tc := controller.NewTFController(unstructuredInformer, kubeClientSet, volcanoClientSet, tfJobClientSet, kubeInformerFactory, tfJobInformerFactory, *opt)
+	tc, err := controller.NewTFController(unstructuredInformer, kubeClientSet, volcanoClientSet, tfJobClientSet, kubeInformerFactory, tfJobInformerFactory, *opt)
+	if err != nil {
+		return err
+	}
 
 	// Start informer goroutines.
 	go kubeInformerFactory.Start(stopCh)
@@ -21,3 +21,34 @@ const (
 	SuccessPolicyDefault    SuccessPolicy = ""
 	SuccessPolicyAllWorkers SuccessPolicy = "AllWorkers"
 )
+
+// PSFailurePolicy is the policy applied when a PS fails.
+type PSFailurePolicy string
+
+const (
+	// PSFailurePolicyDefault handles a failed PS like the other replicas,
+	// according to its restart policy.
+	PSFailurePolicyDefault PSFailurePolicy = ""
+	// PSFailurePolicyFailJob fails the TFJob as soon as a PS fails, whatever
+	// its restart policy, e.g. for training which needs strong consistency.
+	PSFailurePolicyFailJob PSFailurePolicy = "FailJob"
+	// PSFailurePolicyRestart recreates a failed PS, whatever its restart
+	// policy and exit code.
+	PSFailurePolicyRestart PSFailurePolicy = "Restart"
+)
+
+// ChiefFailurePolicy is the policy applied when the chief, or master, fails.
+type ChiefFailurePolicy string
+
+const (
+	// ChiefFailurePolicyDefault handles a failed chief like the other
+	// replicas, according to its restart policy.
+	ChiefFailurePolicyDefault ChiefFailurePolicy = ""
+	// ChiefFailurePolicyFailJob fails the TFJob as soon as the chief fails,
+	// whatever its restart policy.
+	ChiefFailurePolicyFailJob ChiefFailurePolicy = "FailJob"
+	// ChiefFailurePolicyRestartAll recreates all the replicas of the TFJob
+	// when the chief fails, e.g. for chief-coordinated training which resumes
+	// from a checkpoint. The restarts count against the backoff limit.
+	ChiefFailurePolicyRestartAll ChiefFailurePolicy = "RestartAll"
+)
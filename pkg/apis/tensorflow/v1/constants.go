@@ -34,4 +34,62 @@ const (
 	Plural = "tfjobs"
 	// Singular is the singular for TFJob.
 	Singular = "tfjob"
+	// ResetDeadlineOnUpdateAnnotation, set to "true" on a TFJob, resets its
+	// start time when its spec changes, so that ActiveDeadlineSeconds is
+	// measured from the last edit instead of the original start.
+	ResetDeadlineOnUpdateAnnotation = "tf-operator.kubeflow.org/reset-deadline-on-update"
+	// LastScaleTimeAnnotation is set by the operator on a TFJob with dynamic
+	// workers to the RFC3339 time its replicas were last scaled.
+	LastScaleTimeAnnotation = "tf-operator.kubeflow.org/last-scale-time"
+	// ClusterSpecAnnotation is set on a TFJob to a JSON map of lower case
+	// replica types to endpoints, e.g. {"ps": ["ps-0.example.com:2222"]}. The
+	// endpoints are used verbatim in the cluster spec of the replicas, instead
+	// of the ones derived from the services of the TFJob, e.g. to join a PS
+	// fleet managed out of the cluster.
+	ClusterSpecAnnotation = "tf-operator.kubeflow.org/cluster-spec"
+	// VolumeMountRecreationsAnnotation is set by the operator on a TFJob to
+	// the number of its pods recreated because they were stuck mounting their
+	// volumes.
+	VolumeMountRecreationsAnnotation = "tf-operator.kubeflow.org/volume-mount-recreations"
+	// CleanPodPolicyAnnotation is set on a TFJob to All, Running or None to
+	// override the CleanPodPolicy of its RunPolicy when it finishes, e.g. to
+	// keep the pods of a running TFJob for debugging.
+	CleanPodPolicyAnnotation = "tf-operator.kubeflow.org/clean-pod-policy"
+	// ClusterMembersAnnotation is set by the operator on the pods of a TFJob
+	// to the JSON map of lower case replica types to the endpoints of their
+	// replicas, e.g. for sidecars to read the cluster through the downward
+	// API. It is kept up to date when the TFJob is scaled.
+	ClusterMembersAnnotation = "tf-operator.kubeflow.org/cluster-members"
+	// ChiefRestartsAnnotation is set by the operator on a TFJob to the number
+	// of times all its replicas were recreated because its chief failed,
+	// under the RestartAll chief failure policy.
+	ChiefRestartsAnnotation = "tf-operator.kubeflow.org/chief-restarts"
+	// ReplicaRecreationsAnnotation is set by the operator on a TFJob to the
+	// JSON map of lower case replica types to the number of times their pods
+	// were recreated after failing, for the replica types with a RestartLimit.
+	ReplicaRecreationsAnnotation = "tf-operator.kubeflow.org/replica-recreations"
+	// ReconcileNonceAnnotation is set on a TFJob to any new value to force an
+	// immediate reconcile of the TFJob, e.g. after fixing something out of the
+	// cluster it depends on, instead of waiting for the next resync. The
+	// backoff of the failed reconciles of the TFJob is reset as well.
+	ReconcileNonceAnnotation = "tf-operator.kubeflow.org/reconcile-nonce"
+	// ManagedAnnotation set to "false" on a TFJob makes this operator ignore
+	// it entirely, e.g. while another operator manages it during a migration.
+	ManagedAnnotation = "tf-operator.kubeflow.org/managed"
+	// ProgressAnnotation is set by the operator on a TFJob to the percentage
+	// of its replicas, except for PS and evaluators, which have succeeded. It
+	// is 100 once the TFJob succeeds.
+	ProgressAnnotation = "tf-operator.kubeflow.org/progress"
+	// ReplicaNodesAnnotation is set by the operator on a TFJob to the JSON map
+	// of lower case replica types to the names of the nodes the pods of their
+	// replicas are scheduled on, by index, e.g. {"worker": ["node-a", ""]}.
+	// The replicas whose pod is not scheduled have an empty name.
+	ReplicaNodesAnnotation = "tf-operator.kubeflow.org/replica-nodes"
+	// ReplicaRestartsAnnotation is set by the operator on a TFJob to the JSON
+	// map of lower case replica types to the total number of container
+	// restarts of their pods.
+	ReplicaRestartsAnnotation = "tf-operator.kubeflow.org/replica-restarts"
+	// ObservedGenerationAnnotation is set by the operator on a TFJob to the
+	// generation of the TFJob it last reconciled.
+	ObservedGenerationAnnotation = "tf-operator.kubeflow.org/observed-generation"
 )
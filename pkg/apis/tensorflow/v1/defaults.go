@@ -85,6 +85,14 @@ func setTypeNameToCamelCase(tfJob *TFJob, typ commonv1.ReplicaType) {
 			spec := tfJob.Spec.TFReplicaSpecs[t]
 			delete(tfJob.Spec.TFReplicaSpecs, t)
 			tfJob.Spec.TFReplicaSpecs[typ] = spec
+			break
+		}
+	}
+	for t := range tfJob.Spec.TFReplicaPolicies {
+		if strings.EqualFold(string(t), string(typ)) && t != typ {
+			policy := tfJob.Spec.TFReplicaPolicies[t]
+			delete(tfJob.Spec.TFReplicaPolicies, t)
+			tfJob.Spec.TFReplicaPolicies[typ] = policy
 			return
 		}
 	}
@@ -103,7 +111,7 @@ func SetDefaults_TFJob(tfjob *TFJob) {
 		tfjob.Spec.SuccessPolicy = &defaultPolicy
 	}
 
-	// Update the key of TFReplicaSpecs to camel case.
+	// Update the key of TFReplicaSpecs and TFReplicaPolicies to camel case.
 	setTypeNamesToCamelCase(tfjob)
 
 	for _, spec := range tfjob.Spec.TFReplicaSpecs {
@@ -16,6 +16,7 @@ package v1
 
 import (
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -57,6 +58,33 @@ type TFJobSpec struct {
 	// +optional
 	SuccessPolicy *SuccessPolicy `json:"successPolicy,omitempty"`
 
+	// PSFailurePolicy defines what happens when a PS fails: FailJob fails the
+	// TFJob immediately, Restart recreates the PS. Default to "", handling the
+	// PS according to its restart policy like the other replicas.
+	// +optional
+	PSFailurePolicy *PSFailurePolicy `json:"psFailurePolicy,omitempty"`
+
+	// PSMinAvailable is the minimum number of PS which have to be healthy for
+	// the TFJob to go on when some PS fail. As long as that many PS have not
+	// failed, the failed PS are left alone, and the PSFailurePolicy only
+	// applies below it. Default to nil, applying the PSFailurePolicy to any
+	// failed PS.
+	// +optional
+	PSMinAvailable *int32 `json:"psMinAvailable,omitempty"`
+
+	// ChiefFailurePolicy defines what happens when the pod of the chief, or
+	// master, fails: FailJob fails the TFJob immediately, RestartAll recreates
+	// all the replicas, within the backoff limit. Default to "", handling the
+	// chief according to its restart policy like the other replicas.
+	// +optional
+	ChiefFailurePolicy *ChiefFailurePolicy `json:"chiefFailurePolicy,omitempty"`
+
+	// ChiefNodePreference makes the pod of the chief, or master, or worker 0
+	// if the TFJob has neither, prefer the nodes it matches, e.g. the node
+	// holding the checkpoint volume. The other replicas are not constrained.
+	// +optional
+	ChiefNodePreference *NodePreference `json:"chiefNodePreference,omitempty"`
+
 	// A map of TFReplicaType (type) to ReplicaSpec (value). Specifies the TF cluster configuration.
 	// For example,
 	//   {
@@ -65,10 +93,173 @@ type TFJobSpec struct {
 	//   }
 	TFReplicaSpecs map[commonv1.ReplicaType]*commonv1.ReplicaSpec `json:"tfReplicaSpecs"`
 
+	// A map of TFReplicaType (type) to TFReplicaPolicy (value). Specifies the
+	// TensorFlow specific policies of the replica types in TFReplicaSpecs.
+	// +optional
+	TFReplicaPolicies map[commonv1.ReplicaType]*TFReplicaPolicy `json:"tfReplicaPolicies,omitempty"`
+
+	// List of environment variables to set in every container of every replica.
+	// Variables defined in the pod templates take precedence.
+	// +optional
+	CommonEnv []v1.EnvVar `json:"commonEnv,omitempty"`
+
+	// List of containers to append to the pods of every replica, e.g. metrics
+	// exporters, after the containers of the pod templates. Containers named
+	// as one of the template are skipped.
+	// +optional
+	CommonSidecars []v1.Container `json:"commonSidecars,omitempty"`
+
+	// DNSPolicy is set on the pods of every replica whose template does not
+	// specify one.
+	// +optional
+	DNSPolicy v1.DNSPolicy `json:"dnsPolicy,omitempty"`
+
+	// DNSConfig is set on the pods of every replica whose template does not
+	// specify one, e.g. to lower ndots so that the service names of the
+	// cluster spec are resolved quickly.
+	// +optional
+	DNSConfig *v1.PodDNSConfig `json:"dnsConfig,omitempty"`
+
+	// PortRange gives every replica a distinct port of the range, instead of
+	// the port of its replica type, so that several replicas can be packed on
+	// one node. The ports are numbered by replica type, in alphabetical order,
+	// then by index. They are set in the tensorflow container, the service and
+	// the cluster spec of the replicas.
+	// +optional
+	PortRange *PortRange `json:"portRange,omitempty"`
+
+	// AdditionalOwnerReferences are added to the pods and services of the
+	// TFJob, e.g. by an operator creating TFJobs which wants to garbage collect
+	// them with its own objects. They must not be controllers, the TFJob stays
+	// the controller of its pods and services.
+	// +optional
+	AdditionalOwnerReferences []metav1.OwnerReference `json:"additionalOwnerReferences,omitempty"`
+
 	// A switch to enable dynamic worker
 	EnableDynamicWorker bool `json:"enableDynamicWorker,omitempty"`
 }
 
+// NodePreference selects the nodes a pod prefers to be scheduled on.
+type NodePreference struct {
+	// TopologyKey is the label of the preferred nodes. Defaults to
+	// kubernetes.io/hostname, so that Value is the name of the node.
+	// +optional
+	TopologyKey string `json:"topologyKey,omitempty"`
+
+	// Value is the value of the label of the preferred nodes.
+	Value string `json:"value"`
+}
+
+// PortRange is a range of ports.
+type PortRange struct {
+	// Min is the first port of the range.
+	Min int32 `json:"min"`
+
+	// Max is the last port of the range, included.
+	Max int32 `json:"max"`
+}
+
+// TFReplicaPolicy holds the TensorFlow specific policies of a replica type
+// which are not covered by the common ReplicaSpec.
+type TFReplicaPolicy struct {
+	// KeepAliveAfterCompletion keeps the pods of the replica type running after
+	// the TFJob succeeds or fails, regardless of the CleanPodPolicy. The pods are
+	// removed together with the TFJob.
+	// +optional
+	KeepAliveAfterCompletion bool `json:"keepAliveAfterCompletion,omitempty"`
+
+	// Labels are added to the pods of the replica type, e.g. to be selected by
+	// monitoring. The labels of the template and the labels the controller
+	// sets, such as replica-type and replica-index, take precedence.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// ExitCodePodRestartPolicy is the pod level restart policy used when the
+	// replica type has the ExitCode restart policy. Never, the default, lets the
+	// controller recreate the pods failed with retryable exit codes. OnFailure
+	// lets the kubelet restart the containers in place, and the controller only
+	// acts on permanent failures.
+	// +optional
+	ExitCodePodRestartPolicy v1.RestartPolicy `json:"exitCodePodRestartPolicy,omitempty"`
+
+	// StandbyReplicas is the number of warm standby pods kept for the replica
+	// type. Standby pods are not part of the cluster spec. When a replica needs
+	// a new pod, a standby pod is promoted instead: it is relabeled with the
+	// replica index and its TF_CONFIG file is filled in. Standby pods always read
+	// TF_CONFIG from the file pointed to by TF_CONFIG_FILE, which is empty until
+	// the pod is promoted. Defaults to 0.
+	// +optional
+	StandbyReplicas *int32 `json:"standbyReplicas,omitempty"`
+
+	// SecurityContext is set on the pods of the replica type whose template
+	// does not specify one.
+	// +optional
+	SecurityContext *v1.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ContainerSecurityContext is set on the containers and init containers of
+	// the replica type which do not specify one.
+	// +optional
+	ContainerSecurityContext *v1.SecurityContext `json:"containerSecurityContext,omitempty"`
+
+	// ActiveDeadlineSeconds is the duration in seconds relative to the start of
+	// the TFJob the replica type may run. Once it has passed, the pods of the
+	// replica type which have not succeeded are deleted and not recreated.
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// FailJobOnDeadlineExceeded fails the TFJob when ActiveDeadlineSeconds of
+	// the replica type has passed. Otherwise the TFJob keeps running without
+	// the replica type.
+	// +optional
+	FailJobOnDeadlineExceeded bool `json:"failJobOnDeadlineExceeded,omitempty"`
+
+	// RecreateOnConfigChange recreates the running pods of the replica type one
+	// at a time when a Secret or ConfigMap referenced by their template changes,
+	// so that they pick up the new data. It requires the operator to watch the
+	// referenced Secrets and ConfigMaps.
+	// +optional
+	RecreateOnConfigChange bool `json:"recreateOnConfigChange,omitempty"`
+
+	// TerminationGracePeriodSeconds is set on the pods of the replica type whose
+	// template does not specify one. It also bounds the graceful shutdown of the
+	// pods deleted when the TFJob is cleaned up.
+	// +optional
+	TerminationGracePeriodSeconds *int64 `json:"terminationGracePeriodSeconds,omitempty"`
+
+	// PreStopSleepSeconds injects a preStop hook sleeping for the given seconds
+	// in the tensorflow container of the replica type, so that its gRPC server
+	// drains, and the training checkpoints, before the container is killed. A
+	// preStop hook of the template takes precedence.
+	// +optional
+	PreStopSleepSeconds *int32 `json:"preStopSleepSeconds,omitempty"`
+
+	// PreStopCommand injects a preStop hook running the given command in the
+	// tensorflow container of the replica type, instead of sleeping. A preStop
+	// hook of the template takes precedence.
+	// +optional
+	PreStopCommand []string `json:"preStopCommand,omitempty"`
+
+	// PodReadyDeadlineSeconds is the duration in seconds relative to the start
+	// of a pod of the replica type it may run without becoming ready, e.g.
+	// while the model loads. Past it, the PodsNotReady condition is set on the
+	// TFJob.
+	// +optional
+	PodReadyDeadlineSeconds *int64 `json:"podReadyDeadlineSeconds,omitempty"`
+
+	// FailJobOnPodReadyDeadlineExceeded fails the TFJob when a pod of the
+	// replica type is not ready past PodReadyDeadlineSeconds. Otherwise the
+	// TFJob keeps running with the PodsNotReady condition.
+	// +optional
+	FailJobOnPodReadyDeadlineExceeded bool `json:"failJobOnPodReadyDeadlineExceeded,omitempty"`
+
+	// RestartLimit is the number of times the pods of the replica type may be
+	// recreated after failing, with a retryable exit code or under the Restart
+	// PS failure policy. The next failure fails the TFJob, independently of
+	// the BackoffLimit and deadlines of its RunPolicy.
+	// +optional
+	RestartLimit *int32 `json:"restartLimit,omitempty"`
+}
+
 // TFReplicaType is the type for TFReplica. Can be one of: "Chief"/"Master" (semantically equivalent),
 // "Worker", "PS", or "Evaluator".
 
@@ -87,6 +278,10 @@ const (
 
 	// TFReplicaTypeMaster is the type for master worker of distributed TensorFlow.
 	// This is similar to chief, and kept just for backwards compatibility.
+	// A tfjob defines either Chief or Master, whichever takes the chief role:
+	// it gates the success of the tfjob, and is listed under "chief" or
+	// "master" in TF_CONFIG, after the lower case replica type unless the
+	// cluster spec keys of the operator rename it, e.g. "Master=chief".
 	TFReplicaTypeMaster commonv1.ReplicaType = "Master"
 
 	// TFReplicaTypeEval is the type for evaluation replica in TensorFlow.
@@ -15,7 +15,11 @@
 package v1
 
 import (
+	"fmt"
+	"strings"
+
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	v1 "k8s.io/api/core/v1"
 )
 
 // IsChieforMaster returns true if the type is Master or Chief.
@@ -32,3 +36,93 @@ func IsWorker(typ commonv1.ReplicaType) bool {
 func IsEvaluator(typ commonv1.ReplicaType) bool {
 	return typ == TFReplicaTypeEval
 }
+
+// GetCondition returns the condition of the given type in the job status, or
+// nil if there is none.
+func GetCondition(status commonv1.JobStatus, condType commonv1.JobConditionType) *commonv1.JobCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == condType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// IsConditionTrue returns true if the job status has a true condition of the
+// given type.
+func IsConditionTrue(status commonv1.JobStatus, condType commonv1.JobConditionType) bool {
+	condition := GetCondition(status, condType)
+	return condition != nil && condition.Status == v1.ConditionTrue
+}
+
+// GetLatestCondition returns the true condition of the job status which was
+// updated last, e.g. Running or Succeeded, or nil if there is none. Of the
+// conditions updated at the same time, the last one in the list is returned.
+func GetLatestCondition(status commonv1.JobStatus) *commonv1.JobCondition {
+	var latest *commonv1.JobCondition
+	for i := range status.Conditions {
+		condition := &status.Conditions[i]
+		if condition.Status != v1.ConditionTrue {
+			continue
+		}
+		if latest == nil || !condition.LastUpdateTime.Before(&latest.LastUpdateTime) {
+			latest = condition
+		}
+	}
+	return latest
+}
+
+// ReplicaOverride overrides the tensorflow container of a replica type, e.g.
+// to try another image without editing the TFJob. The empty fields are left
+// as they are.
+type ReplicaOverride struct {
+	// Image replaces the image of the tensorflow container.
+	Image string
+	// Command replaces the command of the tensorflow container.
+	Command []string
+	// Args replaces the args of the tensorflow container.
+	Args []string
+}
+
+// ApplyReplicaOverrides applies the overrides to the tensorflow container of
+// the replica types of the TFJob, before it is created. The replica types are
+// matched case insensitively. Nothing is changed if one of them is not in the
+// TFJob, or has no tensorflow container.
+func ApplyReplicaOverrides(job *TFJob, overrides map[commonv1.ReplicaType]ReplicaOverride) error {
+	containers := make(map[commonv1.ReplicaType]*v1.Container, len(overrides))
+	for rtype := range overrides {
+		var spec *commonv1.ReplicaSpec
+		for t, s := range job.Spec.TFReplicaSpecs {
+			if strings.EqualFold(string(t), string(rtype)) {
+				spec = s
+				break
+			}
+		}
+		if spec == nil {
+			return fmt.Errorf("replica type %s is not defined in TFJob %s", rtype, job.Name)
+		}
+		for i := range spec.Template.Spec.Containers {
+			if spec.Template.Spec.Containers[i].Name == DefaultContainerName {
+				containers[rtype] = &spec.Template.Spec.Containers[i]
+				break
+			}
+		}
+		if containers[rtype] == nil {
+			return fmt.Errorf("replica type %s of TFJob %s has no %s container", rtype, job.Name, DefaultContainerName)
+		}
+	}
+
+	for rtype, override := range overrides {
+		container := containers[rtype]
+		if override.Image != "" {
+			container.Image = override.Image
+		}
+		if override.Command != nil {
+			container.Command = append([]string(nil), override.Command...)
+		}
+		if override.Args != nil {
+			container.Args = append([]string(nil), override.Args...)
+		}
+	}
+	return nil
+}
@@ -15,9 +15,13 @@
 package v1
 
 import (
+	"reflect"
 	"testing"
+	"time"
 
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestIsChieforMaster(t *testing.T) {
@@ -46,3 +50,131 @@ func TestIsChieforMaster(t *testing.T) {
 		}
 	}
 }
+
+func TestGetCondition(t *testing.T) {
+	earlier := metav1.NewTime(time.Now().Add(-time.Minute))
+	now := metav1.Now()
+	created := commonv1.JobCondition{Type: commonv1.JobCreated, Status: v1.ConditionTrue, LastUpdateTime: earlier}
+	running := commonv1.JobCondition{Type: commonv1.JobRunning, Status: v1.ConditionFalse, LastUpdateTime: earlier}
+	succeeded := commonv1.JobCondition{Type: commonv1.JobSucceeded, Status: v1.ConditionTrue, LastUpdateTime: now}
+
+	tc := []struct {
+		Description    string
+		Conditions     []commonv1.JobCondition
+		Type           commonv1.JobConditionType
+		Expected       *commonv1.JobCondition
+		ExpectedTrue   bool
+		ExpectedLatest *commonv1.JobCondition
+	}{
+		{
+			Description:    "no conditions",
+			Conditions:     nil,
+			Type:           commonv1.JobSucceeded,
+			Expected:       nil,
+			ExpectedTrue:   false,
+			ExpectedLatest: nil,
+		},
+		{
+			Description:    "the condition is absent",
+			Conditions:     []commonv1.JobCondition{created},
+			Type:           commonv1.JobSucceeded,
+			Expected:       nil,
+			ExpectedTrue:   false,
+			ExpectedLatest: &created,
+		},
+		{
+			Description:    "the condition is present",
+			Conditions:     []commonv1.JobCondition{created, succeeded},
+			Type:           commonv1.JobSucceeded,
+			Expected:       &succeeded,
+			ExpectedTrue:   true,
+			ExpectedLatest: &succeeded,
+		},
+		{
+			Description:    "the condition is present but not true",
+			Conditions:     []commonv1.JobCondition{created, running, succeeded},
+			Type:           commonv1.JobRunning,
+			Expected:       &running,
+			ExpectedTrue:   false,
+			ExpectedLatest: &succeeded,
+		},
+		{
+			Description:    "the latest condition is not the last one",
+			Conditions:     []commonv1.JobCondition{succeeded, created},
+			Type:           commonv1.JobCreated,
+			Expected:       &created,
+			ExpectedTrue:   true,
+			ExpectedLatest: &succeeded,
+		},
+	}
+
+	for _, c := range tc {
+		status := commonv1.JobStatus{Conditions: c.Conditions}
+		if actual := GetCondition(status, c.Type); !reflect.DeepEqual(actual, c.Expected) {
+			t.Errorf("%s: expected condition %v; got %v", c.Description, c.Expected, actual)
+		}
+		if actual := IsConditionTrue(status, c.Type); actual != c.ExpectedTrue {
+			t.Errorf("%s: expected %s true %v; got %v", c.Description, c.Type, c.ExpectedTrue, actual)
+		}
+		if actual := GetLatestCondition(status); !reflect.DeepEqual(actual, c.ExpectedLatest) {
+			t.Errorf("%s: expected latest condition %v; got %v", c.Description, c.ExpectedLatest, actual)
+		}
+	}
+}
+
+func TestApplyReplicaOverrides(t *testing.T) {
+	newJob := func() *TFJob {
+		return &TFJob{
+			Spec: TFJobSpec{
+				TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+					TFReplicaTypeWorker: {
+						Template: v1.PodTemplateSpec{
+							Spec: v1.PodSpec{
+								Containers: []v1.Container{
+									{Name: "sidecar", Image: "sidecar"},
+									{Name: DefaultContainerName, Image: "tensorflow/tensorflow:old", Command: []string{"python", "train.py"}},
+								},
+							},
+						},
+					},
+					TFReplicaTypePS: {
+						Template: v1.PodTemplateSpec{
+							Spec: v1.PodSpec{
+								Containers: []v1.Container{{Name: DefaultContainerName, Image: "tensorflow/tensorflow:old"}},
+							},
+						},
+					},
+				},
+			},
+		}
+	}
+
+	// The image of the workers is overridden, matching the type case insensitively.
+	job := newJob()
+	err := ApplyReplicaOverrides(job, map[commonv1.ReplicaType]ReplicaOverride{
+		"worker": {Image: "tensorflow/tensorflow:new", Args: []string{"--steps=10"}},
+	})
+	if err != nil {
+		t.Fatalf("Expected the overrides to be applied, got error %v", err)
+	}
+	expected := newJob()
+	worker := &expected.Spec.TFReplicaSpecs[TFReplicaTypeWorker].Template.Spec.Containers[1]
+	worker.Image = "tensorflow/tensorflow:new"
+	worker.Args = []string{"--steps=10"}
+	if !reflect.DeepEqual(job, expected) {
+		t.Errorf("Expected the job %+v, got %+v", expected.Spec, job.Spec)
+	}
+
+	// Nothing is changed if a replica type is not defined.
+	job = newJob()
+	err = ApplyReplicaOverrides(job, map[commonv1.ReplicaType]ReplicaOverride{
+		TFReplicaTypeWorker: {Image: "tensorflow/tensorflow:new"},
+		TFReplicaTypeChief:  {Image: "tensorflow/tensorflow:new"},
+	})
+	if err == nil {
+		t.Errorf("Expected an error for the undefined replica type")
+	}
+	if !reflect.DeepEqual(job, newJob()) {
+		t.Errorf("Expected the job to be left unchanged, got %+v", job.Spec)
+	}
+}
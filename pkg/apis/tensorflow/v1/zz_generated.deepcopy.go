@@ -20,9 +20,66 @@ package v1
 
 import (
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 )
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NodePreference) DeepCopyInto(out *NodePreference) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NodePreference.
+func (in *NodePreference) DeepCopy() *NodePreference {
+	if in == nil {
+		return nil
+	}
+	out := new(NodePreference)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PortRange) DeepCopyInto(out *PortRange) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PortRange.
+func (in *PortRange) DeepCopy() *PortRange {
+	if in == nil {
+		return nil
+	}
+	out := new(PortRange)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ReplicaOverride) DeepCopyInto(out *ReplicaOverride) {
+	*out = *in
+	if in.Command != nil {
+		in, out := &in.Command, &out.Command
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Args != nil {
+		in, out := &in.Args, &out.Args
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ReplicaOverride.
+func (in *ReplicaOverride) DeepCopy() *ReplicaOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(ReplicaOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *TFJob) DeepCopyInto(out *TFJob) {
 	*out = *in
@@ -91,6 +148,26 @@ func (in *TFJobSpec) DeepCopyInto(out *TFJobSpec) {
 		*out = new(SuccessPolicy)
 		**out = **in
 	}
+	if in.PSFailurePolicy != nil {
+		in, out := &in.PSFailurePolicy, &out.PSFailurePolicy
+		*out = new(PSFailurePolicy)
+		**out = **in
+	}
+	if in.PSMinAvailable != nil {
+		in, out := &in.PSMinAvailable, &out.PSMinAvailable
+		*out = new(int32)
+		**out = **in
+	}
+	if in.ChiefFailurePolicy != nil {
+		in, out := &in.ChiefFailurePolicy, &out.ChiefFailurePolicy
+		*out = new(ChiefFailurePolicy)
+		**out = **in
+	}
+	if in.ChiefNodePreference != nil {
+		in, out := &in.ChiefNodePreference, &out.ChiefNodePreference
+		*out = new(NodePreference)
+		**out = **in
+	}
 	if in.TFReplicaSpecs != nil {
 		in, out := &in.TFReplicaSpecs, &out.TFReplicaSpecs
 		*out = make(map[commonv1.ReplicaType]*commonv1.ReplicaSpec, len(*in))
@@ -106,6 +183,52 @@ func (in *TFJobSpec) DeepCopyInto(out *TFJobSpec) {
 			(*out)[key] = outVal
 		}
 	}
+	if in.TFReplicaPolicies != nil {
+		in, out := &in.TFReplicaPolicies, &out.TFReplicaPolicies
+		*out = make(map[commonv1.ReplicaType]*TFReplicaPolicy, len(*in))
+		for key, val := range *in {
+			var outVal *TFReplicaPolicy
+			if val == nil {
+				(*out)[key] = nil
+			} else {
+				in, out := &val, &outVal
+				*out = new(TFReplicaPolicy)
+				(*in).DeepCopyInto(*out)
+			}
+			(*out)[key] = outVal
+		}
+	}
+	if in.CommonEnv != nil {
+		in, out := &in.CommonEnv, &out.CommonEnv
+		*out = make([]corev1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.CommonSidecars != nil {
+		in, out := &in.CommonSidecars, &out.CommonSidecars
+		*out = make([]corev1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.DNSConfig != nil {
+		in, out := &in.DNSConfig, &out.DNSConfig
+		*out = new(corev1.PodDNSConfig)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.PortRange != nil {
+		in, out := &in.PortRange, &out.PortRange
+		*out = new(PortRange)
+		**out = **in
+	}
+	if in.AdditionalOwnerReferences != nil {
+		in, out := &in.AdditionalOwnerReferences, &out.AdditionalOwnerReferences
+		*out = make([]metav1.OwnerReference, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TFJobSpec.
@@ -117,3 +240,70 @@ func (in *TFJobSpec) DeepCopy() *TFJobSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TFReplicaPolicy) DeepCopyInto(out *TFReplicaPolicy) {
+	*out = *in
+	if in.Labels != nil {
+		in, out := &in.Labels, &out.Labels
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.StandbyReplicas != nil {
+		in, out := &in.StandbyReplicas, &out.StandbyReplicas
+		*out = new(int32)
+		**out = **in
+	}
+	if in.SecurityContext != nil {
+		in, out := &in.SecurityContext, &out.SecurityContext
+		*out = new(corev1.PodSecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ContainerSecurityContext != nil {
+		in, out := &in.ContainerSecurityContext, &out.ContainerSecurityContext
+		*out = new(corev1.SecurityContext)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ActiveDeadlineSeconds != nil {
+		in, out := &in.ActiveDeadlineSeconds, &out.ActiveDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.TerminationGracePeriodSeconds != nil {
+		in, out := &in.TerminationGracePeriodSeconds, &out.TerminationGracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.PreStopSleepSeconds != nil {
+		in, out := &in.PreStopSleepSeconds, &out.PreStopSleepSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.PreStopCommand != nil {
+		in, out := &in.PreStopCommand, &out.PreStopCommand
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PodReadyDeadlineSeconds != nil {
+		in, out := &in.PodReadyDeadlineSeconds, &out.PodReadyDeadlineSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.RestartLimit != nil {
+		in, out := &in.RestartLimit, &out.RestartLimit
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TFReplicaPolicy.
+func (in *TFReplicaPolicy) DeepCopy() *TFReplicaPolicy {
+	if in == nil {
+		return nil
+	}
+	out := new(TFReplicaPolicy)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -21,24 +21,115 @@ import (
 
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
 )
 
 // ValidateV1TFJobSpec checks that the v1.TFJobSpec is valid.
 func ValidateV1TFJobSpec(c *tfv1.TFJobSpec) error {
-	return validateV1ReplicaSpecs(c.TFReplicaSpecs)
+	if err := validateV1ReplicaSpecs(c.TFReplicaSpecs); err != nil {
+		return err
+	}
+	for _, sidecar := range c.CommonSidecars {
+		if sidecar.Name == "" || sidecar.Image == "" {
+			return fmt.Errorf("TFJobSpec is not valid: Name and Image must be defined in CommonSidecars")
+		}
+		if sidecar.Name == tfv1.DefaultContainerName {
+			return fmt.Errorf("TFJobSpec is not valid: CommonSidecars must not define the %s container", tfv1.DefaultContainerName)
+		}
+	}
+	if c.ChiefNodePreference != nil && c.ChiefNodePreference.Value == "" {
+		return fmt.Errorf("TFJobSpec is not valid: Value must be defined in ChiefNodePreference")
+	}
+	if c.PortRange != nil {
+		if c.PortRange.Min <= 0 || c.PortRange.Max > 65535 || c.PortRange.Min > c.PortRange.Max {
+			return fmt.Errorf("TFJobSpec is not valid: PortRange %d-%d is not a valid port range",
+				c.PortRange.Min, c.PortRange.Max)
+		}
+	}
+	for _, ref := range c.AdditionalOwnerReferences {
+		if ref.Controller != nil && *ref.Controller {
+			return fmt.Errorf("TFJobSpec is not valid: AdditionalOwnerReferences must not be controllers, got %s %s",
+				ref.Kind, ref.Name)
+		}
+	}
+	if c.PSFailurePolicy != nil {
+		switch *c.PSFailurePolicy {
+		case tfv1.PSFailurePolicyDefault, tfv1.PSFailurePolicyFailJob, tfv1.PSFailurePolicyRestart:
+		default:
+			return fmt.Errorf("TFJobSpec is not valid: PSFailurePolicy %s is not supported, use %s or %s",
+				*c.PSFailurePolicy, tfv1.PSFailurePolicyFailJob, tfv1.PSFailurePolicyRestart)
+		}
+	}
+	if c.PSMinAvailable != nil {
+		if *c.PSMinAvailable < 0 {
+			return fmt.Errorf("TFJobSpec is not valid: PSMinAvailable must not be negative, got %d", *c.PSMinAvailable)
+		}
+		if ps, ok := c.TFReplicaSpecs[tfv1.TFReplicaTypePS]; ok && ps.Replicas != nil && *c.PSMinAvailable > *ps.Replicas {
+			return fmt.Errorf("TFJobSpec is not valid: PSMinAvailable %d is greater than the %d PS replicas",
+				*c.PSMinAvailable, *ps.Replicas)
+		}
+	}
+	if c.ChiefFailurePolicy != nil {
+		switch *c.ChiefFailurePolicy {
+		case tfv1.ChiefFailurePolicyDefault, tfv1.ChiefFailurePolicyFailJob, tfv1.ChiefFailurePolicyRestartAll:
+		default:
+			return fmt.Errorf("TFJobSpec is not valid: ChiefFailurePolicy %s is not supported, use %s or %s",
+				*c.ChiefFailurePolicy, tfv1.ChiefFailurePolicyFailJob, tfv1.ChiefFailurePolicyRestartAll)
+		}
+	}
+	return validateV1ReplicaPolicies(c.TFReplicaPolicies)
+}
+
+func validateV1ReplicaPolicies(policies map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy) error {
+	for rType, policy := range policies {
+		if policy == nil {
+			continue
+		}
+		switch policy.ExitCodePodRestartPolicy {
+		case "", v1.RestartPolicyNever, v1.RestartPolicyOnFailure:
+		default:
+			return fmt.Errorf("TFJobSpec is not valid: ExitCodePodRestartPolicy %s is not supported in %v, use %s or %s",
+				policy.ExitCodePodRestartPolicy, rType, v1.RestartPolicyNever, v1.RestartPolicyOnFailure)
+		}
+		if policy.StandbyReplicas != nil && *policy.StandbyReplicas < 0 {
+			return fmt.Errorf("TFJobSpec is not valid: StandbyReplicas must not be negative in %v", rType)
+		}
+		if policy.ActiveDeadlineSeconds != nil && *policy.ActiveDeadlineSeconds <= 0 {
+			return fmt.Errorf("TFJobSpec is not valid: ActiveDeadlineSeconds must be positive in %v", rType)
+		}
+		if policy.TerminationGracePeriodSeconds != nil && *policy.TerminationGracePeriodSeconds < 0 {
+			return fmt.Errorf("TFJobSpec is not valid: TerminationGracePeriodSeconds must not be negative in %v", rType)
+		}
+		if policy.PreStopSleepSeconds != nil && *policy.PreStopSleepSeconds < 0 {
+			return fmt.Errorf("TFJobSpec is not valid: PreStopSleepSeconds must not be negative in %v", rType)
+		}
+		if policy.PodReadyDeadlineSeconds != nil && *policy.PodReadyDeadlineSeconds <= 0 {
+			return fmt.Errorf("TFJobSpec is not valid: PodReadyDeadlineSeconds must be positive in %v", rType)
+		}
+		if policy.RestartLimit != nil && *policy.RestartLimit < 0 {
+			return fmt.Errorf("TFJobSpec is not valid: RestartLimit must not be negative in %v", rType)
+		}
+	}
+	return nil
 }
 
 func validateV1ReplicaSpecs(specs map[commonv1.ReplicaType]*commonv1.ReplicaSpec) error {
-	if specs == nil {
-		return fmt.Errorf("TFJobSpec is not valid")
+	if len(specs) == 0 {
+		return fmt.Errorf("TFJobSpec is not valid: TFReplicaSpecs must define at least one replica type")
+	}
+	// Master is the legacy name of Chief, and both take the chief role.
+	_, foundChief := specs[tfv1.TFReplicaTypeChief]
+	_, foundMaster := specs[tfv1.TFReplicaTypeMaster]
+	if foundChief && foundMaster {
+		return fmt.Errorf("TFJobSpec is not valid: %v and %v must not be both defined, %v is the legacy name of %v",
+			tfv1.TFReplicaTypeChief, tfv1.TFReplicaTypeMaster, tfv1.TFReplicaTypeMaster, tfv1.TFReplicaTypeChief)
 	}
-	foundChief := 0
 	for rType, value := range specs {
 		if value == nil || len(value.Template.Spec.Containers) == 0 {
 			return fmt.Errorf("TFJobSpec is not valid: containers definition expected in %v", rType)
 		}
-		if tfv1.IsChieforMaster(rType) {
-			foundChief++
+		if value.Replicas != nil && *value.Replicas < 0 {
+			return fmt.Errorf("TFJobSpec is not valid: Replicas must not be negative in %v", rType)
 		}
 		// Make sure the image is defined in the container.
 		numNamedTensorflow := 0
@@ -59,8 +150,5 @@ func validateV1ReplicaSpecs(specs map[commonv1.ReplicaType]*commonv1.ReplicaSpec
 			return fmt.Errorf(msg)
 		}
 	}
-	if foundChief > 1 {
-		return fmt.Errorf("TFJobSpec is not valid: more than 1 chief/master found")
-	}
 	return nil
 }
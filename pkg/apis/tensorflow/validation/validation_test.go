@@ -21,13 +21,29 @@ import (
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
 
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 func TestValidateV1TFJobSpec(t *testing.T) {
+	negativeReplicas := int32(-1)
+	negativeStandbyReplicas := int32(-1)
+	zeroActiveDeadlineSeconds := int64(0)
+	negativeTerminationGracePeriodSeconds := int64(-1)
+	negativePreStopSleepSeconds := int32(-1)
+	zeroPodReadyDeadlineSeconds := int64(0)
+	negativeRestartLimit := int32(-1)
+	controller := true
+	onePS := int32(1)
+	twoPSMinAvailable := int32(2)
+	unknownPSFailurePolicy := tfv1.PSFailurePolicy("Ignore")
+	unknownChiefFailurePolicy := tfv1.ChiefFailurePolicy("RestartChief")
 	testCases := []tfv1.TFJobSpec{
 		{
 			TFReplicaSpecs: nil,
 		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{},
+		},
 		{
 			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
 				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
@@ -88,6 +104,320 @@ func TestValidateV1TFJobSpec(t *testing.T) {
 				},
 			},
 		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			TFReplicaPolicies: map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+				tfv1.TFReplicaTypeWorker: &tfv1.TFReplicaPolicy{
+					ExitCodePodRestartPolicy: v1.RestartPolicyAlways,
+				},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Replicas: &negativeReplicas,
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			TFReplicaPolicies: map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+				tfv1.TFReplicaTypeWorker: &tfv1.TFReplicaPolicy{
+					StandbyReplicas: &negativeStandbyReplicas,
+				},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			TFReplicaPolicies: map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+				tfv1.TFReplicaTypeWorker: &tfv1.TFReplicaPolicy{
+					ActiveDeadlineSeconds: &zeroActiveDeadlineSeconds,
+				},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			TFReplicaPolicies: map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+				tfv1.TFReplicaTypeWorker: &tfv1.TFReplicaPolicy{
+					TerminationGracePeriodSeconds: &negativeTerminationGracePeriodSeconds,
+				},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			TFReplicaPolicies: map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+				tfv1.TFReplicaTypeWorker: &tfv1.TFReplicaPolicy{
+					PreStopSleepSeconds: &negativePreStopSleepSeconds,
+				},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			TFReplicaPolicies: map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+				tfv1.TFReplicaTypeWorker: &tfv1.TFReplicaPolicy{
+					PodReadyDeadlineSeconds: &zeroPodReadyDeadlineSeconds,
+				},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypePS: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			TFReplicaPolicies: map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+				tfv1.TFReplicaTypePS: &tfv1.TFReplicaPolicy{
+					RestartLimit: &negativeRestartLimit,
+				},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypePS: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			PSFailurePolicy: &unknownPSFailurePolicy,
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeChief: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			ChiefFailurePolicy: &unknownChiefFailurePolicy,
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeChief: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			CommonSidecars: []v1.Container{{Name: "tensorflow", Image: "metrics-exporter"}},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			PortRange: &tfv1.PortRange{Min: 3010, Max: 3000},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			ChiefNodePreference: &tfv1.NodePreference{TopologyKey: "topology.kubernetes.io/zone"},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			AdditionalOwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "v1", Kind: "ConfigMap", Name: "pipeline", UID: "pipeline-uid", Controller: &controller},
+			},
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypePS: &commonv1.ReplicaSpec{
+					Replicas: &onePS,
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+			PSMinAvailable: &twoPSMinAvailable,
+		},
+		{
+			TFReplicaSpecs: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeChief: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+				tfv1.TFReplicaTypeMaster: &commonv1.ReplicaSpec{
+					Template: v1.PodTemplateSpec{
+						Spec: v1.PodSpec{
+							Containers: []v1.Container{
+								v1.Container{
+									Name:  "tensorflow",
+									Image: "kubeflow/tf-dist-mnist-test:1.0",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
 	}
 	for _, c := range testCases {
 		err := ValidateV1TFJobSpec(&c)
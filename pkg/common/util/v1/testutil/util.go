@@ -86,10 +86,6 @@ func GetKey(tfJob *tfv1.TFJob, t *testing.T) string {
 }
 
 func CheckCondition(tfJob *tfv1.TFJob, condition common.JobConditionType, reason string) bool {
-	for _, v := range tfJob.Status.Conditions {
-		if v.Type == condition && v.Status == v1.ConditionTrue && v.Reason == reason {
-			return true
-		}
-	}
-	return false
+	v := tfv1.GetCondition(tfJob.Status, condition)
+	return v != nil && v.Status == v1.ConditionTrue && v.Reason == reason
 }
@@ -0,0 +1,128 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// tfJobQueued is the condition of a tfjob which does not create its pods
+	// because its namespace has reached the maximum number of active tfjobs.
+	tfJobQueued commonv1.JobConditionType = "Queued"
+	// maxActiveJobsReason is the reason of the Queued condition.
+	maxActiveJobsReason = "MaxActiveJobsReached"
+	// queuedJobRecheckInterval is how often a queued tfjob checks whether it
+	// can start creating its pods.
+	queuedJobRecheckInterval = 30 * time.Second
+)
+
+// isQueued returns true if the tfjob has to wait for other tfjobs of its
+// namespace before creating its pods. The tfjobs ahead of it are the ones
+// which are not finished, and are either active or were created before it, so
+// that the queued tfjobs start in the order they were created. A tfjob which
+// already has active pods is never queued.
+func (tc *TFController) isQueued(tfJob *tfv1.TFJob, activePods []*v1.Pod) bool {
+	if tc.option.MaxActiveJobsPerNamespace <= 0 || len(activePods) > 0 {
+		return false
+	}
+	ahead := 0
+	for _, un := range tc.tfJobInformer.GetIndexer().List() {
+		other, err := tfJobFromUnstructured(un)
+		if err != nil || other.Namespace != tfJob.Namespace || other.Name == tfJob.Name {
+			continue
+		}
+		if isSucceeded(other.Status) || isFailed(other.Status) {
+			continue
+		}
+		if isActiveJob(other) || createdBefore(other, tfJob) {
+			ahead++
+		}
+	}
+	return ahead >= tc.option.MaxActiveJobsPerNamespace
+}
+
+// isActiveJob returns true if the tfjob has started creating its pods.
+func isActiveJob(tfJob *tfv1.TFJob) bool {
+	if tfv1.IsConditionTrue(tfJob.Status, tfJobQueued) {
+		return false
+	}
+	if tfv1.IsConditionTrue(tfJob.Status, commonv1.JobRunning) {
+		return true
+	}
+	for _, status := range tfJob.Status.ReplicaStatuses {
+		if status.Active > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// createdBefore returns true if a was created before b. The names break the
+// ties, as the creation timestamps only have a precision of a second.
+func createdBefore(a, b *tfv1.TFJob) bool {
+	if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+		return a.CreationTimestamp.Before(&b.CreationTimestamp)
+	}
+	return a.Name < b.Name
+}
+
+// setQueued sets the Queued condition of the tfjob. The last transition time
+// is kept while the tfjob stays queued.
+func (tc *TFController) setQueued(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus) {
+	condition := tfv1.GetCondition(*jobStatus, tfJobQueued)
+	if condition != nil && condition.Status == v1.ConditionTrue {
+		return
+	}
+	msg := fmt.Sprintf("TFJob %s/%s is queued because its namespace has reached the maximum of %d active tfjobs",
+		tfJob.Namespace, tfJob.Name, tc.option.MaxActiveJobsPerNamespace)
+	commonutil.LoggerForJob(tfJob).Info(msg)
+
+	now := metav1.Now()
+	// The conditions are copied, as they may be shared with the tfjob.
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)+1)
+	for _, c := range jobStatus.Conditions {
+		if c.Type != tfJobQueued {
+			conditions = append(conditions, c)
+		}
+	}
+	jobStatus.Conditions = append(conditions, commonv1.JobCondition{
+		Type:               tfJobQueued,
+		Status:             v1.ConditionTrue,
+		Reason:             maxActiveJobsReason,
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	})
+}
+
+// clearQueued removes the Queued condition of the tfjob.
+func clearQueued(jobStatus *commonv1.JobStatus) {
+	for i, condition := range jobStatus.Conditions {
+		if condition.Type == tfJobQueued {
+			conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)-1)
+			conditions = append(conditions, jobStatus.Conditions[:i]...)
+			jobStatus.Conditions = append(conditions, jobStatus.Conditions[i+1:]...)
+			return
+		}
+	}
+}
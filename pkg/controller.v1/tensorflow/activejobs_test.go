@@ -0,0 +1,124 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"testing"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestMaxActiveJobsPerNamespace(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{MaxActiveJobsPerNamespace: 1})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	tfJob := testutil.NewTFJob(2, 0)
+	tfJob.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	// The running job is newer, it is ahead because it is active.
+	runningTFJob := testutil.NewTFJob(1, 0)
+	runningTFJob.Name = "running-tfjob"
+	runningTFJob.CreationTimestamp = metav1.Now()
+	if err := commonutil.UpdateJobConditions(&runningTFJob.Status, commonv1.JobRunning,
+		tfJobRunningReason, "TFJob is running."); err != nil {
+		t.Fatalf("Failed to set the running condition: %v", err)
+	}
+	for _, job := range []*tfv1.TFJob{tfJob, runningTFJob} {
+		unstructured, err := testutil.ConvertTFJobToUnstructured(job)
+		if err != nil {
+			t.Fatalf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+		if err := tfJobIndexer.Add(unstructured); err != nil {
+			t.Fatalf("Failed to add tfjob to tfJobIndexer: %v", err)
+		}
+	}
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if fakePodControl.CreateCallCount != 0 {
+		t.Errorf("Expected no pod creations while queued, got %d", fakePodControl.CreateCallCount)
+	}
+	if !tfv1.IsConditionTrue(tfJob.Status, tfJobQueued) {
+		t.Errorf("Expected condition %s, got %v", tfJobQueued, tfJob.Status.Conditions)
+	}
+
+	// The job starts once the running job finishes.
+	if err := commonutil.UpdateJobConditions(&runningTFJob.Status, commonv1.JobSucceeded,
+		tfJobSucceededReason, "TFJob succeeded."); err != nil {
+		t.Fatalf("Failed to set the succeeded condition: %v", err)
+	}
+	unstructured, err := testutil.ConvertTFJobToUnstructured(runningTFJob)
+	if err != nil {
+		t.Fatalf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Update(unstructured); err != nil {
+		t.Fatalf("Failed to update tfjob in tfJobIndexer: %v", err)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if fakePodControl.CreateCallCount != 2 {
+		t.Errorf("Expected 2 pod creations, got %d", fakePodControl.CreateCallCount)
+	}
+	if tfv1.GetCondition(tfJob.Status, tfJobQueued) != nil {
+		t.Errorf("Expected condition %s to be removed, got %v", tfJobQueued, tfJob.Status.Conditions)
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// restartingAllReason is the normal reason when the pods of a tfjob are
+// deleted, to be recreated under the RestartAll chief failure policy.
+const restartingAllReason = "RestartingAll"
+
+// getChiefFailurePolicy returns the chief failure policy of the tfjob.
+func getChiefFailurePolicy(tfJob *tfv1.TFJob) tfv1.ChiefFailurePolicy {
+	if tfJob.Spec.ChiefFailurePolicy == nil {
+		return tfv1.ChiefFailurePolicyDefault
+	}
+	return *tfJob.Spec.ChiefFailurePolicy
+}
+
+// isChiefPod returns true if the pod is the chief, or master, of its tfjob.
+func (tc *TFController) isChiefPod(pod *v1.Pod) bool {
+	rt := pod.Labels[tc.GetReplicaTypeLabelKey()]
+	return strings.EqualFold(rt, string(tfv1.TFReplicaTypeChief)) || strings.EqualFold(rt, string(tfv1.TFReplicaTypeMaster))
+}
+
+// getFailedChief returns the failed chief pod of the tfjob, or nil if it did
+// not fail. Chief pods being deleted do not count.
+func (tc *TFController) getFailedChief(pods []*v1.Pod) *v1.Pod {
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if tc.isChiefPod(pod) && tc.isFailedPod(pod) {
+			return pod
+		}
+	}
+	return nil
+}
+
+// getChiefRestarts returns the number of times all the replicas of the tfjob
+// were recreated because its chief failed.
+func getChiefRestarts(tfJob *tfv1.TFJob) int32 {
+	restarts, _ := strconv.Atoi(tfJob.Annotations[tfv1.ChiefRestartsAnnotation])
+	return int32(restarts)
+}
+
+// pastChiefRestartLimit returns true if all the replicas of the tfjob were
+// recreated as many times as its backoff limit allows.
+func pastChiefRestartLimit(tfJob *tfv1.TFJob, runPolicy *commonv1.RunPolicy) bool {
+	return runPolicy.BackoffLimit != nil && getChiefRestarts(tfJob) >= *runPolicy.BackoffLimit
+}
+
+// restartAllReplicas deletes all the pods of the tfjob because its chief
+// failed, to be recreated by the following reconciles, and sets the
+// Restarting condition of the tfjob.
+func (tc *TFController) restartAllReplicas(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, pods []*v1.Pod, chief *v1.Pod) error {
+	// The restart is recorded first, so that the backoff limit holds even if
+	// the deletion fails.
+	restarts := getChiefRestarts(tfJob) + 1
+	if err := tc.patchTFJobAnnotation(tfJob, tfv1.ChiefRestartsAnnotation, strconv.Itoa(int(restarts))); err != nil {
+		return err
+	}
+	cause := fmt.Sprintf("chief %s failed, to be recreated with all the replicas", chief.Name)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := tc.deletePod(tfJob, pod, restartingAllReason, cause); err != nil {
+			return err
+		}
+	}
+	msg := fmt.Sprintf("TFJob %s is restarting all its replicas because chief %s failed.", tfJob.Name, chief.Name)
+	tc.Recorder.Event(tfJob, v1.EventTypeWarning, tfJobRestartingReason, msg)
+	if err := commonutil.UpdateJobConditions(jobStatus, commonv1.JobRestarting, tfJobRestartingReason, msg); err != nil {
+		commonutil.LoggerForJob(tfJob).Infof("Append tfjob condition error: %v", err)
+		return err
+	}
+	tfJobsRestartCount.WithLabelValues(tfJob.Namespace).Inc()
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestChiefFailurePolicy(t *testing.T) {
+	testCases := []struct {
+		description string
+		policy      tfv1.ChiefFailurePolicy
+		// restarts is the number of times all the replicas were restarted.
+		restarts string
+
+		expectedFailed  string
+		expectedRestart bool
+	}{
+		{
+			description:    "A failed chief fails the tfjob",
+			policy:         tfv1.ChiefFailurePolicyFailJob,
+			expectedFailed: TFJobFailedReasonChiefFailure,
+		},
+		{
+			description:     "A failed chief restarts all the replicas",
+			policy:          tfv1.ChiefFailurePolicyRestartAll,
+			expectedRestart: true,
+		},
+		{
+			description:    "A failed chief fails the tfjob past the backoff limit",
+			policy:         tfv1.ChiefFailurePolicyRestartAll,
+			restarts:       "2",
+			expectedFailed: TFJobFailedReasonBackoff,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		tfJob := testutil.NewTFJobWithChief(2, 1)
+		policy := c.policy
+		tfJob.Spec.ChiefFailurePolicy = &policy
+		backoffLimit := int32(2)
+		tfJob.Spec.RunPolicy.BackoffLimit = &backoffLimit
+		if c.restarts != "" {
+			tfJob.Annotations = map[string]string{tfv1.ChiefRestartsAnnotation: c.restarts}
+		}
+		fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+		ctr.tfJobClientSet = fakeClientSet
+
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 2, 0, 0, nil, t)
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelPS, 0, 1, 0, 0, nil, t)
+		chief := testutil.NewPod(tfJob, testutil.LabelChief, 0)
+		chief.Status.Phase = v1.PodFailed
+		chief.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name:  tfv1.DefaultContainerName,
+			State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1}},
+		}}
+		if err := podIndexer.Add(chief); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+		}
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelChief, 1, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", c.description, err)
+		}
+		condition := tfv1.GetCondition(updated.Status, commonv1.JobFailed)
+		if failed := condition != nil && condition.Status == v1.ConditionTrue; failed != (c.expectedFailed != "") {
+			t.Errorf("%s: Expected failed %v, got %v", c.description, c.expectedFailed != "", updated.Status.Conditions)
+		} else if failed && condition.Reason != c.expectedFailed {
+			t.Errorf("%s: Expected reason %s, got %s", c.description, c.expectedFailed, condition.Reason)
+		}
+		if !c.expectedRestart {
+			continue
+		}
+
+		// All the pods are deleted, and recreated by the next reconcile.
+		if !tfv1.IsConditionTrue(updated.Status, commonv1.JobRestarting) {
+			t.Errorf("%s: Expected the tfjob to be restarting, got %v", c.description, updated.Status.Conditions)
+		}
+		if got := updated.Annotations[tfv1.ChiefRestartsAnnotation]; got != "1" {
+			t.Errorf("%s: Expected the %s annotation to be 1, got %q", c.description, tfv1.ChiefRestartsAnnotation, got)
+		}
+		expectedDeleted := []string{"chief-0", "ps-0", "worker-0", "worker-1"}
+		deleted := append([]string{}, fakePodControl.DeletePodName...)
+		sort.Strings(deleted)
+		if !reflect.DeepEqual(expectedDeleted, deleted) {
+			t.Errorf("%s: Expected pods %v to be deleted, got %v", c.description, expectedDeleted, deleted)
+		}
+		for _, obj := range podIndexer.List() {
+			if err := podIndexer.Delete(obj); err != nil {
+				t.Errorf("%s: unexpected error when deleting pod %v", c.description, err)
+			}
+		}
+		_ = ctr.ReconcileJobs(updated, updated.Spec.TFReplicaSpecs, updated.Status, &updated.Spec.RunPolicy)
+		if len(fakePodControl.Templates) != 4 {
+			t.Errorf("%s: Expected 4 pods to be recreated, got %d", c.description, len(fakePodControl.Templates))
+		}
+	}
+}
@@ -0,0 +1,215 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// ClusterSpecFormatTFConfig sets the cluster spec as TF_CONFIG, in env or
+	// in a file depending on the ServerOption. It is the default.
+	ClusterSpecFormatTFConfig = "tf-config"
+	// ClusterSpecFormatHostList sets the endpoints of every replica type in a
+	// comma separated <TYPE>_HOSTS env, e.g. PS_HOSTS and WORKER_HOSTS, and the
+	// task of the pod in JOB_NAME and TASK_INDEX.
+	ClusterSpecFormatHostList = "host-list"
+	// ClusterSpecFormatTFConfigAndHostList sets both TF_CONFIG and the env of
+	// the host list format, for training code which reads either.
+	ClusterSpecFormatTFConfigAndHostList = "tf-config-and-host-list"
+
+	// jobNameEnv and taskIndexEnv are the task of the pod in the host list format.
+	jobNameEnv   = "JOB_NAME"
+	taskIndexEnv = "TASK_INDEX"
+)
+
+// ClusterSpecEmitter sets the cluster spec of a tfjob in the pod template of
+// one of its replicas, in the shape the training code of the pod expects.
+type ClusterSpecEmitter interface {
+	EmitClusterSpec(tfjob *tfv1.TFJob, cluster ClusterSpec, podTemplate *v1.PodTemplateSpec, rtype, index string) error
+}
+
+var (
+	clusterSpecEmittersLock sync.RWMutex
+	clusterSpecEmitters     = map[string]ClusterSpecEmitter{
+		ClusterSpecFormatHostList: hostListEmitter{},
+	}
+)
+
+// RegisterClusterSpecEmitter makes the emitter available under the given
+// format, to be selected by the ClusterSpecFormat of the ServerOption. It
+// replaces the emitter registered under the same format, if any.
+func RegisterClusterSpecEmitter(format string, emitter ClusterSpecEmitter) {
+	clusterSpecEmittersLock.Lock()
+	defer clusterSpecEmittersLock.Unlock()
+	clusterSpecEmitters[format] = emitter
+}
+
+// GetClusterSpecEmitter returns the emitter registered under the given format.
+// The TF_CONFIG emitter is returned for ClusterSpecFormatTFConfig, or an empty
+// format, writing TF_CONFIG to a file if tfConfigFile is set, and renaming the
+// replica types of its cluster spec after keys. The same applies to the
+// TF_CONFIG set along the host list by ClusterSpecFormatTFConfigAndHostList.
+func GetClusterSpecEmitter(format string, tfConfigFile bool, keys options.ClusterSpecKeys) (ClusterSpecEmitter, error) {
+	switch format {
+	case "", ClusterSpecFormatTFConfig:
+		return tfConfigEmitter{file: tfConfigFile, keys: keys}, nil
+	case ClusterSpecFormatTFConfigAndHostList:
+		return tfConfigAndHostListEmitter{tfConfig: tfConfigEmitter{file: tfConfigFile, keys: keys}}, nil
+	}
+	clusterSpecEmittersLock.RLock()
+	defer clusterSpecEmittersLock.RUnlock()
+	emitter, ok := clusterSpecEmitters[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown cluster spec format %q", format)
+	}
+	return emitter, nil
+}
+
+// tfConfigEmitter sets TF_CONFIG in the tensorflow container, or mounts it as
+// a file if file is set. The replica types of its cluster spec are renamed
+// after keys.
+type tfConfigEmitter struct {
+	file bool
+	keys options.ClusterSpecKeys
+}
+
+func (e tfConfigEmitter) EmitClusterSpec(tfjob *tfv1.TFJob, cluster ClusterSpec, podTemplate *v1.PodTemplateSpec, rtype, index string) error {
+	tfConfigStr, err := genTFConfigJSONStrFromClusterSpec(tfjob, cluster, e.keys, rtype, index)
+	if err != nil {
+		return err
+	}
+	if tfConfigStr == "" {
+		return nil
+	}
+	if e.file {
+		setTFConfigFile(podTemplate, tfConfigStr)
+		return nil
+	}
+	setTensorflowEnv(podTemplate, v1.EnvVar{Name: tfConfig, Value: tfConfigStr})
+	return nil
+}
+
+// hostListEmitter sets the cluster spec in the env used by the distributed
+// TensorFlow scripts which take --ps_hosts, --worker_hosts, --job_name and
+// --task_index.
+type hostListEmitter struct{}
+
+func (hostListEmitter) EmitClusterSpec(tfjob *tfv1.TFJob, cluster ClusterSpec, podTemplate *v1.PodTemplateSpec, rtype, index string) error {
+	rts := make([]string, 0, len(cluster))
+	for rt := range cluster {
+		rts = append(rts, rt)
+	}
+	sort.Strings(rts)
+
+	env := make([]v1.EnvVar, 0, len(cluster)+2)
+	for _, rt := range rts {
+		env = append(env, v1.EnvVar{
+			Name:  strings.ToUpper(rt) + "_HOSTS",
+			Value: strings.Join(cluster[rt], ","),
+		})
+	}
+	env = append(env,
+		v1.EnvVar{Name: jobNameEnv, Value: strings.ToLower(rtype)},
+		v1.EnvVar{Name: taskIndexEnv, Value: index},
+	)
+	setTensorflowEnv(podTemplate, env...)
+	return nil
+}
+
+// tfConfigAndHostListEmitter sets TF_CONFIG, and the env of the host list
+// format from the same cluster spec.
+type tfConfigAndHostListEmitter struct {
+	tfConfig tfConfigEmitter
+}
+
+func (e tfConfigAndHostListEmitter) EmitClusterSpec(tfjob *tfv1.TFJob, cluster ClusterSpec, podTemplate *v1.PodTemplateSpec, rtype, index string) error {
+	if err := e.tfConfig.EmitClusterSpec(tfjob, cluster, podTemplate, rtype, index); err != nil {
+		return err
+	}
+	return hostListEmitter{}.EmitClusterSpec(tfjob, cluster, podTemplate, rtype, index)
+}
+
+// setTensorflowEnv appends the env to the tensorflow container of the pod template.
+func setTensorflowEnv(podTemplate *v1.PodTemplateSpec, env ...v1.EnvVar) {
+	for i := range podTemplate.Spec.Containers {
+		if podTemplate.Spec.Containers[i].Name == tfv1.DefaultContainerName {
+			podTemplate.Spec.Containers[i].Env = append(podTemplate.Spec.Containers[i].Env, env...)
+			break
+		}
+	}
+}
+
+// countTensorflowEnvAndMounts returns the number of env and volume mounts of
+// the tensorflow container of the pod template, to tell those set by the
+// cluster spec emitter apart.
+func countTensorflowEnvAndMounts(podTemplate *v1.PodTemplateSpec) (int, int) {
+	for _, container := range podTemplate.Spec.Containers {
+		if container.Name == tfv1.DefaultContainerName {
+			return len(container.Env), len(container.VolumeMounts)
+		}
+	}
+	return 0, 0
+}
+
+// shareClusterSpec copies the env and volume mounts the cluster spec emitter
+// set in the tensorflow container, past the given counts, to the selected
+// containers of the pod template. The env they define already is kept.
+func shareClusterSpec(podTemplate *v1.PodTemplateSpec, containers options.ContainerNames, envs, mounts int) {
+	if len(containers) == 0 {
+		return
+	}
+	var env []v1.EnvVar
+	var volumeMounts []v1.VolumeMount
+	for _, container := range podTemplate.Spec.Containers {
+		if container.Name == tfv1.DefaultContainerName {
+			env = container.Env[envs:]
+			volumeMounts = container.VolumeMounts[mounts:]
+			break
+		}
+	}
+
+	for i := range podTemplate.Spec.Containers {
+		container := &podTemplate.Spec.Containers[i]
+		if container.Name == tfv1.DefaultContainerName || !containers.Has(container.Name) {
+			continue
+		}
+		defined := make(map[string]bool, len(container.Env))
+		for _, e := range container.Env {
+			defined[e.Name] = true
+		}
+		for _, e := range env {
+			if !defined[e.Name] {
+				container.Env = append(container.Env, e)
+			}
+		}
+		mounted := make(map[string]bool, len(container.VolumeMounts))
+		for _, m := range container.VolumeMounts {
+			mounted[m.MountPath] = true
+		}
+		for _, m := range volumeMounts {
+			if !mounted[m.MountPath] {
+				container.VolumeMounts = append(container.VolumeMounts, m)
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	// configHashAnnotation is the pod annotation holding the hash of the
+	// versions of the Secrets and ConfigMaps the pod was created with.
+	configHashAnnotation = "tf-operator.kubeflow.org/config-hash"
+	// recreatedForConfigChangeReason is the normal reason when a pod is
+	// recreated because a Secret or ConfigMap it references changed.
+	recreatedForConfigChangeReason = "RecreatedForConfigChange"
+)
+
+// referencedConfig returns the names of the Secrets and of the ConfigMaps the
+// pod spec references in its volumes and in the env of its containers.
+func referencedConfig(spec *v1.PodSpec) (sets.String, sets.String) {
+	secrets, configMaps := sets.NewString(), sets.NewString()
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil {
+			secrets.Insert(volume.Secret.SecretName)
+		}
+		if volume.ConfigMap != nil {
+			configMaps.Insert(volume.ConfigMap.Name)
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil {
+					secrets.Insert(source.Secret.Name)
+				}
+				if source.ConfigMap != nil {
+					configMaps.Insert(source.ConfigMap.Name)
+				}
+			}
+		}
+	}
+	for _, containers := range [][]v1.Container{spec.InitContainers, spec.Containers} {
+		for _, container := range containers {
+			for _, envFrom := range container.EnvFrom {
+				if envFrom.SecretRef != nil {
+					secrets.Insert(envFrom.SecretRef.Name)
+				}
+				if envFrom.ConfigMapRef != nil {
+					configMaps.Insert(envFrom.ConfigMapRef.Name)
+				}
+			}
+			for _, env := range container.Env {
+				if env.ValueFrom == nil {
+					continue
+				}
+				if env.ValueFrom.SecretKeyRef != nil {
+					secrets.Insert(env.ValueFrom.SecretKeyRef.Name)
+				}
+				if env.ValueFrom.ConfigMapKeyRef != nil {
+					configMaps.Insert(env.ValueFrom.ConfigMapKeyRef.Name)
+				}
+			}
+		}
+	}
+	return secrets, configMaps
+}
+
+// referencesConfig returns true if a pod template of the tfjob references the
+// Secret, or the ConfigMap, of the given name.
+func referencesConfig(tfJob *tfv1.TFJob, secret bool, name string) bool {
+	for _, spec := range tfJob.Spec.TFReplicaSpecs {
+		if spec == nil {
+			continue
+		}
+		secrets, configMaps := referencedConfig(&spec.Template.Spec)
+		if (secret && secrets.Has(name)) || (!secret && configMaps.Has(name)) {
+			return true
+		}
+	}
+	return false
+}
+
+// enqueueTFJobsForConfig returns an event handler which enqueues the tfjobs
+// referencing the changed Secret, or ConfigMap.
+func (tc *TFController) enqueueTFJobsForConfig(secret bool) func(obj interface{}) {
+	return func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+		object, err := meta.Accessor(obj)
+		if err != nil {
+			return
+		}
+		for _, un := range tc.tfJobInformer.GetIndexer().List() {
+			tfJob, err := tfJobFromUnstructured(un)
+			if err != nil || tfJob.Namespace != object.GetNamespace() {
+				continue
+			}
+			if referencesConfig(tfJob, secret, object.GetName()) {
+				tc.enqueueTFJob(un)
+			}
+		}
+	}
+}
+
+// updateConfigFunc returns an update handler which enqueues the tfjobs
+// referencing the Secret, or ConfigMap, when it changes.
+func (tc *TFController) updateConfigFunc(secret bool) func(old, cur interface{}) {
+	enqueue := tc.enqueueTFJobsForConfig(secret)
+	return func(old, cur interface{}) {
+		oldObject, err := meta.Accessor(old)
+		if err != nil {
+			return
+		}
+		curObject, err := meta.Accessor(cur)
+		if err != nil {
+			return
+		}
+		// Periodic resyncs send the same version.
+		if oldObject.GetResourceVersion() == curObject.GetResourceVersion() {
+			return
+		}
+		enqueue(cur)
+	}
+}
+
+// genConfigHash returns a hash of the versions of the Secrets and ConfigMaps
+// the pod spec references. It returns "" if the spec references none, or if
+// the operator does not watch them.
+func (tc *TFController) genConfigHash(namespace string, spec *v1.PodSpec) string {
+	if tc.secretLister == nil || tc.configMapLister == nil {
+		return ""
+	}
+	secrets, configMaps := referencedConfig(spec)
+	if secrets.Len() == 0 && configMaps.Len() == 0 {
+		return ""
+	}
+
+	hasher := fnv.New32a()
+	for _, name := range secrets.List() {
+		version := ""
+		if secret, err := tc.secretLister.Secrets(namespace).Get(name); err == nil {
+			version = secret.ResourceVersion
+		}
+		fmt.Fprintf(hasher, "Secret/%s=%s;", name, version)
+	}
+	for _, name := range configMaps.List() {
+		version := ""
+		if configMap, err := tc.configMapLister.ConfigMaps(namespace).Get(name); err == nil {
+			version = configMap.ResourceVersion
+		}
+		fmt.Fprintf(hasher, "ConfigMap/%s=%s;", name, version)
+	}
+	return strconv.FormatUint(uint64(hasher.Sum32()), 16)
+}
+
+// recreateStaleConfigPod deletes a running pod of the replica type which was
+// created with older versions of the Secrets and ConfigMaps it references, if
+// the replica policy asks for it. The pod is recreated by the next reconcile.
+// Nothing is deleted while a pod of the type is pending or being deleted, so
+// that the pods are recreated one at a time.
+func (tc *TFController) recreateStaleConfigPod(tfJob *tfv1.TFJob, pods []*v1.Pod, rtype commonv1.ReplicaType, spec *commonv1.ReplicaSpec) error {
+	policy := getReplicaPolicy(tfJob, rtype)
+	if policy == nil || !policy.RecreateOnConfigChange {
+		return nil
+	}
+	hash := tc.genConfigHash(tfJob.Namespace, &spec.Template.Spec)
+	if hash == "" {
+		return nil
+	}
+
+	var stale *v1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodPending {
+			return nil
+		}
+		// Pods created before the operator watched the config have no hash.
+		podHash, ok := pod.Annotations[configHashAnnotation]
+		if stale == nil && ok && podHash != hash && pod.Status.Phase == v1.PodRunning {
+			stale = pod
+		}
+	}
+	if stale == nil {
+		return nil
+	}
+
+	commonutil.LoggerForJob(tfJob).Infof("Recreating pod %s/%s for the changed Secrets or ConfigMaps", stale.Namespace, stale.Name)
+	return tc.deletePod(tfJob, stale, recreatedForConfigChangeReason, "Secrets or ConfigMaps changed, to be recreated")
+}
@@ -0,0 +1,145 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestReferencedConfigChange(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{WatchReferencedConfig: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	secretIndexer := kubeInformerFactory.Core().V1().Secrets().Informer().GetIndexer()
+
+	// The workers of the first tfjob mount the secret, the second tfjob does not.
+	tfJob := testutil.NewTFJob(2, 0)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Template.Spec.Volumes = []v1.Volume{{
+		Name: "credentials",
+		VolumeSource: v1.VolumeSource{
+			Secret: &v1.SecretVolumeSource{SecretName: "credentials"},
+		},
+	}}
+	tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+		tfv1.TFReplicaTypeWorker: {RecreateOnConfigChange: true},
+	}
+	otherTFJob := testutil.NewTFJob(1, 0)
+	otherTFJob.Name = "other-tfjob"
+	for _, job := range []*tfv1.TFJob{tfJob, otherTFJob} {
+		unstructured, err := testutil.ConvertTFJobToUnstructured(job)
+		if err != nil {
+			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+		if err := tfJobIndexer.Add(unstructured); err != nil {
+			t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+		}
+	}
+
+	oldSecret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            "credentials",
+			Namespace:       tfJob.Namespace,
+			ResourceVersion: "1",
+		},
+	}
+	if err := secretIndexer.Add(oldSecret); err != nil {
+		t.Errorf("Failed to add secret to secretIndexer: %v", err)
+	}
+	oldHash := ctr.genConfigHash(tfJob.Namespace, &tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Template.Spec)
+	if oldHash == "" {
+		t.Fatalf("Expected a config hash for the worker template")
+	}
+
+	// Rotate the secret.
+	newSecret := oldSecret.DeepCopy()
+	newSecret.ResourceVersion = "2"
+	if err := secretIndexer.Update(newSecret); err != nil {
+		t.Errorf("Failed to update secret in secretIndexer: %v", err)
+	}
+	ctr.updateConfigFunc(true)(oldSecret, newSecret)
+
+	if got := ctr.WorkQueue.Len(); got != 1 {
+		t.Fatalf("Expected only the tfjob referencing the secret to be enqueued, got queue length %d", got)
+	}
+	key, _ := ctr.WorkQueue.Get()
+	if expected, _ := KeyFunc(tfJob); key != expected {
+		t.Errorf("Expected %s to be enqueued, got %v", expected, key)
+	}
+	ctr.WorkQueue.Done(key)
+
+	// The workers created with the old secret are recreated one at a time.
+	for i := 0; i < 2; i++ {
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, i)
+		pod.Status.Phase = v1.PodRunning
+		pod.Annotations = map[string]string{configHashAnnotation: oldHash}
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("unexpected error when adding pod %v", err)
+		}
+	}
+	testutil.SetServices(kubeInformerFactory.Core().V1().Services().Informer().GetIndexer(), tfJob, testutil.LabelWorker, 2, t)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if len(fakePodControl.DeletePodName) != 1 {
+		t.Errorf("Expected 1 pod to be recreated, got %v", fakePodControl.DeletePodName)
+	}
+}
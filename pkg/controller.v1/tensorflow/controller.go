@@ -26,11 +26,14 @@ import (
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
 	kubeclientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
+	corelisters "k8s.io/client-go/listers/core/v1"
 	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
 
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
 	"github.com/kubeflow/common/pkg/controller.v1/common"
@@ -54,6 +57,7 @@ const (
 	// labels for pods and servers.
 	tfReplicaTypeLabel  = "replica-type"
 	tfReplicaIndexLabel = "replica-index"
+	tfStandbyLabel      = "standby"
 	labelGroupName      = "group-name"
 	// Deprecated label for backwards compatibility. Has to be removed
 	labelTFJobName = "tf-job-name"
@@ -67,6 +71,11 @@ var (
 	// key function but it should be just fine for non delete events.
 	KeyFunc = cache.DeletionHandlingMetaNamespaceKeyFunc
 
+	// controllerLabels are the pod labels owned by the controller, which the
+	// labels of a replica policy may not set.
+	controllerLabels = sets.NewString(labelGroupName, commonv1.JobNameLabel, labelTFJobName,
+		tfReplicaTypeLabel, tfReplicaIndexLabel, tfStandbyLabel, commonv1.JobRoleLabel)
+
 	tfJobsDeletedCount = promauto.NewCounterVec(
 		prometheus.CounterOpts{
 			Name: "tf_operator_jobs_deleted_total",
@@ -86,6 +95,8 @@ type TFController struct {
 
 	// To allow injection of sync functions for testing.
 	syncHandler func(string) (bool, error)
+	// To allow tests to observe the context the pods are created with.
+	createPodHook func(ctx context.Context, podTemplate *v1.PodTemplateSpec)
 
 	// tfJobInformer is a temporary field for unstructured informer support.
 	tfJobInformer cache.SharedIndexInformer
@@ -94,11 +105,49 @@ type TFController struct {
 	// tfJobLister can list/get tfjobs from the shared informer's store.
 	tfJobLister tfjoblisters.TFJobLister
 
+	// secretLister and configMapLister get the Secrets and ConfigMaps referenced
+	// by the pod templates. They are only set if WatchReferencedConfig is.
+	secretLister    corelisters.SecretLister
+	configMapLister corelisters.ConfigMapLister
+
+	// nodeLister gets the nodes the pods run on. It is only set if
+	// DrainCordonedNodes or ValidateExtendedResources is.
+	nodeLister corelisters.NodeLister
+	// nodeInformerSynced returns true if the node store has been synced at
+	// least once. It is only set if ValidateExtendedResources is.
+	nodeInformerSynced cache.InformerSynced
+
 	// tfJobInformerSynced returns true if the tfjob store has been synced at least once.
 	tfJobInformerSynced cache.InformerSynced
+
+	// option is the server option the controller was created with.
+	option options.ServerOption
+
+	// ImageResolver, if set, rewrites the image of every container before the
+	// pod is created, e.g. to pin tags to digests. An error fails the reconcile.
+	ImageResolver func(string) (string, error)
+
+	// ExternalCleanup, if set, cleans up the resources of a tfjob outside of
+	// the cluster, e.g. in a cloud. Tfjobs keep a finalizer until it succeeds.
+	ExternalCleanup func(*tfv1.TFJob) error
+
+	// clusterSpecEmitter sets the cluster spec in the pod templates, in the
+	// ClusterSpecFormat of the ServerOption.
+	clusterSpecEmitter ClusterSpecEmitter
+
+	// evaluationOrder is the order UpdateJobStatus evaluates the replica types in.
+	evaluationOrder []commonv1.ReplicaType
+	// envTemplate is the env rendered for every pod, as set in the
+	// TemplatedEnv of the ServerOption, if any.
+	envTemplate *envTemplate
+
+	// reconcilePasses holds the state of the tfjobs being reconciled, which
+	// the hooks called back by JobController.ReconcileJobs share.
+	reconcilePasses reconcilePasses
 }
 
-// NewTFController returns a new TFJob controller.
+// NewTFController returns a new TFJob controller, or an error if it cannot be
+// built from the given options.
 func NewTFController(
 	// This variable is for unstructured informer.
 	tfJobInformer tfjobinformersv1.TFJobInformer,
@@ -109,17 +158,35 @@ func NewTFController(
 	// This field is not used now but we keep it since it will be used
 	// after we support CRD validation.
 	tfJobInformerFactory tfjobinformers.SharedInformerFactory,
-	option options.ServerOption) *TFController {
+	option options.ServerOption) (*TFController, error) {
 
 	err := tfjobscheme.AddToScheme(scheme.Scheme)
 	if err != nil {
-		log.Fatalf("Failed to add tfjob scheme: %v", err)
+		return nil, fmt.Errorf("failed to add tfjob scheme: %v", err)
 	}
 
 	log.Info("Creating TFJob controller")
 	// Create new TFController.
 	tc := &TFController{
 		tfJobClientSet: tfJobClientSet,
+		option:         option,
+	}
+	tc.clusterSpecEmitter, err = GetClusterSpecEmitter(option.ClusterSpecFormat, option.TFConfigFile, option.ClusterSpecKeys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get the cluster spec emitter: %v", err)
+	}
+	tc.evaluationOrder, err = getEvaluationOrder(option.ReplicaEvaluationOrder)
+	if err != nil {
+		return nil, fmt.Errorf("invalid replica evaluation order: %v", err)
+	}
+	if option.TemplatedEnv != "" {
+		tc.envTemplate, err = parseEnvTemplate(option.TemplatedEnv)
+		if err != nil {
+			return nil, fmt.Errorf("invalid templated env: %v", err)
+		}
+	}
+	if option.TFConfigSecret {
+		tc.clusterSpecEmitter = tfConfigSecretEmitter{tc: tc}
 	}
 
 	// Create base controller
@@ -127,6 +194,14 @@ func NewTFController(
 
 	jc := common.NewJobController(tc, metav1.Duration{Duration: 15 * time.Second},
 		option.EnableGangScheduling, kubeClientSet, volcanoClientSet, kubeInformerFactory, tfv1.Plural)
+	jc.Expectations = newMetricsExpectations(jc.Expectations)
+	jc.PodControl = newAdditionalOwnersPodControl(jc.PodControl)
+	jc.PodControl = newTimeoutPodControl(jc.PodControl, option.PodCreationTimeout)
+	jc.ServiceControl = newReplicaPortServiceControl(jc.ServiceControl)
+	jc.ServiceControl = newAdditionalOwnersServiceControl(jc.ServiceControl)
+	if option.PublishNotReadyAddresses {
+		jc.ServiceControl = newPublishNotReadyServiceControl(jc.ServiceControl)
+	}
 
 	// Set sync handler.
 	tc.syncHandler = tc.syncTFJob
@@ -157,8 +232,8 @@ func NewTFController(
 	// Set up an event handler for when pod resources change
 	podInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    jc.AddPod,
-		UpdateFunc: jc.UpdatePod,
-		DeleteFunc: jc.DeletePod,
+		UpdateFunc: tc.updatePod,
+		DeleteFunc: tc.observeStalePodDeletion,
 	})
 
 	// tc.PodLister = podInformer.Lister()
@@ -173,7 +248,7 @@ func NewTFController(
 	serviceInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
 		AddFunc:    jc.AddService,
 		UpdateFunc: jc.UpdateService,
-		DeleteFunc: jc.DeleteService,
+		DeleteFunc: tc.deleteService,
 	})
 
 	// tc.ServiceLister = serviceInformer.Lister()
@@ -181,16 +256,71 @@ func NewTFController(
 	jc.ServiceLister = serviceInformer.Lister()
 	jc.ServiceInformerSynced = serviceInformer.Informer().HasSynced
 
+	// Sync the tfjobs referencing a Secret or ConfigMap when it changes.
+	if option.WatchReferencedConfig {
+		secretInformer := kubeInformerFactory.Core().V1().Secrets()
+		secretInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    tc.enqueueTFJobsForConfig(true),
+			UpdateFunc: tc.updateConfigFunc(true),
+			DeleteFunc: tc.enqueueTFJobsForConfig(true),
+		})
+		tc.secretLister = secretInformer.Lister()
+
+		configMapInformer := kubeInformerFactory.Core().V1().ConfigMaps()
+		configMapInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+			AddFunc:    tc.enqueueTFJobsForConfig(false),
+			UpdateFunc: tc.updateConfigFunc(false),
+			DeleteFunc: tc.enqueueTFJobsForConfig(false),
+		})
+		tc.configMapLister = configMapInformer.Lister()
+	}
+
+	// Sync the tfjobs with pods on a node when it is cordoned, and the tfjobs
+	// waiting for an extended resource when a node is added or updated.
+	if option.DrainCordonedNodes || option.ValidateExtendedResources {
+		nodeInformer := kubeInformerFactory.Core().V1().Nodes()
+		if option.DrainCordonedNodes {
+			nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+				UpdateFunc: tc.updateNode,
+			})
+		}
+		if option.ValidateExtendedResources {
+			nodeInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+				AddFunc:    tc.enqueueTFJobsForNode,
+				UpdateFunc: func(old, cur interface{}) { tc.enqueueTFJobsForNode(cur) },
+			})
+			tc.nodeInformerSynced = nodeInformer.Informer().HasSynced
+		}
+		tc.nodeLister = nodeInformer.Lister()
+	}
+
+	// Reconcile the tfjobs with higher priority first.
+	if option.EnablePriorityQueue {
+		priorityClassInformer := kubeInformerFactory.Scheduling().V1beta1().PriorityClasses()
+		jc.PriorityClassLister = priorityClassInformer.Lister()
+		jc.PriorityClassInformerSynced = priorityClassInformer.Informer().HasSynced
+		jc.WorkQueue = newPriorityQueue(workqueue.DefaultControllerRateLimiter(), tfv1.Plural, tc.getTFJobPriority)
+	}
+
 	tc.JobController = jc
 
-	return tc
+	return tc, nil
 }
 
 // Run will set up the event handlers for types we are interested in, as well
 // as syncing informer caches and starting workers. It will block until stopCh
 // is closed, at which point it will shutdown the workqueue and wait for
 // workers to finish processing their current work items.
+// The number of workers is threadiness if it is positive, otherwise the
+// Threadiness of the ServerOption, and at least one.
 func (tc *TFController) Run(threadiness int, stopCh <-chan struct{}) error {
+	if threadiness <= 0 {
+		threadiness = tc.option.Threadiness
+	}
+	if threadiness <= 0 {
+		threadiness = 1
+	}
+
 	defer utilruntime.HandleCrash()
 	defer tc.WorkQueue.ShutDown()
 
@@ -204,6 +334,16 @@ func (tc *TFController) Run(threadiness int, stopCh <-chan struct{}) error {
 		tc.PodInformerSynced, tc.ServiceInformerSynced); !ok {
 		return fmt.Errorf("failed to wait for caches to sync")
 	}
+	if tc.PriorityClassInformerSynced != nil {
+		if ok := cache.WaitForCacheSync(stopCh, tc.PriorityClassInformerSynced); !ok {
+			return fmt.Errorf("failed to wait for caches to sync")
+		}
+	}
+	if tc.nodeInformerSynced != nil {
+		if ok := cache.WaitForCacheSync(stopCh, tc.nodeInformerSynced); !ok {
+			return fmt.Errorf("failed to wait for caches to sync")
+		}
+	}
 	log.Infof("Starting %v workers", threadiness)
 	// Launch workers to process TFJob resources.
 	for i := 0; i < threadiness; i++ {
@@ -250,6 +390,7 @@ func (tc *TFController) processNextWorkItem() bool {
 	if err != nil {
 		if err == errNotExists {
 			logger.Infof("TFJob has been deleted: %v", key)
+			tfJobPhases.delete(key)
 			namespace, _, keyerr := cache.SplitMetaNamespaceKey(key)
 			if keyerr == nil && len(namespace) != 0 {
 				tfJobsDeletedCount.WithLabelValues(namespace).Inc()
@@ -301,7 +442,8 @@ func (tc *TFController) enqueueTFJob(tfjob interface{}) {
 // This function is not meant to be invoked concurrently with the same key.
 func (tc *TFController) syncTFJob(key string) (bool, error) {
 	startTime := time.Now()
-	logger := tflogger.LoggerForKey(key)
+	ctx := newTraceContext(context.Background())
+	logger := traceLogger(ctx, tflogger.LoggerForKey(key))
 	defer func() {
 		logger.Infof("Finished syncing tfjob %q (%v)", key, time.Since(startTime))
 	}()
@@ -319,6 +461,7 @@ func (tc *TFController) syncTFJob(key string) (bool, error) {
 		if err == errNotExists {
 			logger.Infof("TFJob has been deleted: %v", key)
 			tfJobsDeletedCount.WithLabelValues(namespace).Inc()
+			tfJobPhases.delete(key)
 			return true, nil
 		}
 		return false, err
@@ -326,6 +469,20 @@ func (tc *TFController) syncTFJob(key string) (bool, error) {
 
 	tfjob := sharedTFJob.DeepCopy()
 
+	// A tfjob managed by another operator is left alone, even when deleted.
+	if tc.skipUnmanaged(tfjob) {
+		return true, nil
+	}
+
+	// A deleted tfjob is not reconciled, only cleaned up.
+	deleted, err := tc.syncFinalizer(tfjob, key)
+	if err != nil {
+		return false, err
+	}
+	if deleted {
+		return true, nil
+	}
+
 	// Sync tfjob every time if EnableDynamicWorker is true
 	jobKey, err := common.KeyFunc(tfjob)
 	if err != nil {
@@ -336,11 +493,12 @@ func (tc *TFController) syncTFJob(key string) (bool, error) {
 	tfjobNeedsSync := tfjob.Spec.EnableDynamicWorker || util.SatisfiedExpectations(tc.Expectations, jobKey, replicaTypes)
 
 	// Set default for the new tfjob.
+	tc.setDefaultRestartPolicies(tfjob)
 	scheme.Scheme.Default(tfjob)
 
 	var reconcileTFJobsErr error
 	if tfjobNeedsSync && tfjob.DeletionTimestamp == nil {
-		reconcileTFJobsErr = tc.ReconcileJobs(tfjob, tfjob.Spec.TFReplicaSpecs, tfjob.Status, &tfjob.Spec.RunPolicy)
+		reconcileTFJobsErr = tc.reconcileJobs(ctx, tfjob, tfjob.Spec.TFReplicaSpecs, tfjob.Status, &tfjob.Spec.RunPolicy)
 	}
 
 	if reconcileTFJobsErr != nil {
@@ -379,14 +537,6 @@ func (tc *TFController) GetGroupNameLabelValue() string {
 	return tfv1.GroupVersion.Group
 }
 
-func (tc *TFController) GetReplicaTypeLabelKey() string {
-	return tfReplicaTypeLabel
-}
-
-func (tc *TFController) GetReplicaIndexLabelKey() string {
-	return tfReplicaIndexLabel
-}
-
 func (tc *TFController) ControllerName() string {
 	return controllerName
 }
@@ -16,19 +16,24 @@
 package tensorflow
 
 import (
+	"sync/atomic"
 	"testing"
 	"time"
 
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	metav1unstructured "k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/util/wait"
 	kubeinformers "k8s.io/client-go/informers"
 	kubeclientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
 	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
 	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
 
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
 	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/common/pkg/controller.v1/expectation"
 	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
 	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
@@ -37,6 +42,7 @@ import (
 )
 
 var (
+	tfJobCreated   = commonv1.JobCreated
 	tfJobRunning   = commonv1.JobRunning
 	tfJobSucceeded = commonv1.JobSucceeded
 )
@@ -57,9 +63,12 @@ func newTFController(
 
 	tfJobInformer := NewUnstructuredTFJobInformer(config, metav1.NamespaceAll, time.Hour*12)
 
-	ctr := NewTFController(tfJobInformer, kubeClientSet,
+	ctr, err := NewTFController(tfJobInformer, kubeClientSet,
 		volcanoClientSet, tfJobClientSet, kubeInformerFactory,
 		tfJobInformerFactory, option)
+	if err != nil {
+		panic(err)
+	}
 	ctr.PodControl = &control.FakePodControl{}
 	ctr.ServiceControl = &control.FakeServiceControl{}
 	return ctr, kubeInformerFactory, tfJobInformerFactory
@@ -114,8 +123,8 @@ func TestNormalPath(t *testing.T) {
 			1, 0, 1,
 			0, 0, 0,
 			0, 0, 0,
-			// We can not check if it is created since the condition is set in addTFJob.
-			nil, "",
+			// The created condition is established by ReconcileJobs if it is missing.
+			&tfJobCreated, tfJobCreatedReason,
 			false,
 		},
 		"Distributed TFJob (4 workers, 2 PS) is created": {
@@ -126,7 +135,7 @@ func TestNormalPath(t *testing.T) {
 			6, 0, 6,
 			0, 0, 0,
 			0, 0, 0,
-			nil, "",
+			&tfJobCreated, tfJobCreatedReason,
 			false,
 		},
 		"Distributed TFJob (4 workers, 2 PS) is created and all replicas are pending": {
@@ -332,6 +341,78 @@ func TestNormalPath(t *testing.T) {
 	}
 }
 
+func TestCreateBatchSize(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	option := options.ServerOption{CreateBatchSize: 3}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, option)
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	tfJob := testutil.NewTFJob(10, 0)
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	fakePodControl := ctr.PodControl.(*control.FakePodControl)
+	fakeServiceControl := ctr.ServiceControl.(*control.FakeServiceControl)
+	if len(fakePodControl.Templates) != 3 {
+		t.Errorf("Unexpected number of pod creates. Expected 3, saw %d", len(fakePodControl.Templates))
+	}
+	if len(fakeServiceControl.Templates) != 3 {
+		t.Errorf("Unexpected number of service creates. Expected 3, saw %d", len(fakeServiceControl.Templates))
+	}
+
+	// Only the created pods are expected.
+	key, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Errorf("Failed to get the key of the TFJob: %v", err)
+	}
+	e, found, err := ctr.Expectations.GetExpectations(expectation.GenExpectationPodsKey(key, testutil.LabelWorker))
+	if err != nil || !found {
+		t.Fatalf("Expected to get the worker pod expectation, got found %v and error %v", found, err)
+	}
+	if add, del := e.GetExpectations(); add != 3 || del != 0 {
+		t.Errorf("Expected get 3 add and 0 del, got %d add and %d del", add, del)
+	}
+
+	// The job is requeued to create the rest of the replicas.
+	if ctr.WorkQueue.Len() != 1 {
+		t.Errorf("Expected the TFJob to be requeued, the queue length is %d", ctr.WorkQueue.Len())
+	}
+}
+
 func TestRun(t *testing.T) {
 	// Prepare the clientset and controller for the test.
 	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
@@ -377,3 +458,213 @@ func TestRun(t *testing.T) {
 		t.Errorf("Failed to run: %v", err)
 	}
 }
+
+// countingQueue counts the calls to Get, i.e. the workers waiting on the
+// queue while it is empty.
+type countingQueue struct {
+	workqueue.RateLimitingInterface
+	gets int32
+}
+
+func (q *countingQueue) Get() (interface{}, bool) {
+	atomic.AddInt32(&q.gets, 1)
+	return q.RateLimitingInterface.Get()
+}
+
+func TestRunThreadiness(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	threadiness := 3
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{Threadiness: threadiness})
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	queue := &countingQueue{RateLimitingInterface: ctr.WorkQueue}
+	ctr.WorkQueue = queue
+
+	stopCh := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		// Without a threadiness argument, the one of the ServerOption is used.
+		if err := ctr.Run(0, stopCh); err != nil {
+			t.Errorf("Failed to run: %v", err)
+		}
+		close(done)
+	}()
+
+	// Every worker blocks in Get as the queue is empty.
+	if err := wait.Poll(10*time.Millisecond, testutil.SleepInterval, func() (bool, error) {
+		return atomic.LoadInt32(&queue.gets) >= int32(threadiness), nil
+	}); err != nil {
+		t.Errorf("Expected %d workers, got %d", threadiness, atomic.LoadInt32(&queue.gets))
+	}
+	time.Sleep(50 * time.Millisecond)
+	if gets := atomic.LoadInt32(&queue.gets); gets != int32(threadiness) {
+		t.Errorf("Expected %d workers, got %d", threadiness, gets)
+	}
+	close(stopCh)
+	<-done
+}
+
+func TestDefaultRestartPolicies(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	option := options.ServerOption{
+		DefaultRestartPolicies: options.RestartPolicies{
+			tfv1.TFReplicaTypeWorker: commonv1.RestartPolicyOnFailure,
+			tfv1.TFReplicaTypePS:     commonv1.RestartPolicyAlways,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, option)
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	// The worker spec omits RestartPolicy, the PS spec sets it.
+	tfJob := testutil.NewTFJob(1, 1)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = ""
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS].RestartPolicy = commonv1.RestartPolicyNever
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	key, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Errorf("Failed to get the key of the TFJob: %v", err)
+	}
+
+	_, _ = ctr.syncTFJob(key)
+
+	expected := map[string]v1.RestartPolicy{
+		testutil.LabelWorker: v1.RestartPolicyOnFailure,
+		testutil.LabelPS:     v1.RestartPolicyNever,
+	}
+	fakePodControl := ctr.PodControl.(*control.FakePodControl)
+	if len(fakePodControl.Templates) != len(expected) {
+		t.Fatalf("Unexpected number of pod creates. Expected %d, saw %d", len(expected), len(fakePodControl.Templates))
+	}
+	for _, template := range fakePodControl.Templates {
+		rt := template.Labels[tfReplicaTypeLabel]
+		if template.Spec.RestartPolicy != expected[rt] {
+			t.Errorf("Expected restart policy %s of %s, got %s", expected[rt], rt, template.Spec.RestartPolicy)
+		}
+	}
+}
+
+func TestDefaultEnableDynamicWorker(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0,
+		options.ServerOption{DefaultEnableDynamicWorker: true})
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	// The first tfjob omits enableDynamicWorker, the second sets it to false.
+	defaulted := testutil.NewTFJob(1, 0)
+	defaulted.Name = "defaulted"
+	static := testutil.NewTFJob(1, 0)
+	static.Name = "static"
+	for _, tfJob := range []*tfv1.TFJob{defaulted, static} {
+		un, err := testutil.ConvertTFJobToUnstructured(tfJob)
+		if err != nil {
+			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+		if tfJob == static {
+			if err := metav1unstructured.SetNestedField(un.Object, false, "spec", "enableDynamicWorker"); err != nil {
+				t.Errorf("Failed to set enableDynamicWorker: %v", err)
+			}
+		}
+		if err := tfJobIndexer.Add(un); err != nil {
+			t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+		}
+	}
+
+	for _, c := range []struct {
+		name     string
+		expected bool
+	}{
+		{name: defaulted.Name, expected: true},
+		{name: static.Name, expected: false},
+	} {
+		tfJob, err := ctr.getTFJobFromName(defaulted.Namespace, c.name)
+		if err != nil {
+			t.Fatalf("Failed to get tfjob %s: %v", c.name, err)
+		}
+		if tfJob.Spec.EnableDynamicWorker != c.expected {
+			t.Errorf("Expected EnableDynamicWorker %v for tfjob %s, got %v", c.expected, c.name, tfJob.Spec.EnableDynamicWorker)
+		}
+	}
+}
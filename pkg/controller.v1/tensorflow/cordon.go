@@ -0,0 +1,98 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// drainingCordonedNodeReason is the normal reason when a pod on a cordoned
+// node is deleted, to be recreated elsewhere.
+const drainingCordonedNodeReason = "DrainingCordonedNode"
+
+// updateNode enqueues the tfjobs with pods on the node when it is cordoned.
+func (tc *TFController) updateNode(old, cur interface{}) {
+	oldNode, ok := old.(*v1.Node)
+	if !ok {
+		return
+	}
+	curNode, ok := cur.(*v1.Node)
+	if !ok || oldNode.Spec.Unschedulable || !curNode.Spec.Unschedulable {
+		return
+	}
+	pods, err := tc.PodLister.List(labels.Everything())
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("couldn't list the pods on node %s: %v", curNode.Name, err))
+		return
+	}
+	for _, pod := range pods {
+		if pod.Spec.NodeName != curNode.Name {
+			continue
+		}
+		controllerRef := metav1.GetControllerOf(pod)
+		if controllerRef == nil || controllerRef.Kind != tfv1.Kind {
+			continue
+		}
+		tc.WorkQueue.Add(pod.Namespace + "/" + controllerRef.Name)
+	}
+}
+
+// drainCordonedNodes deletes the pods of the tfjob on cordoned nodes, to be
+// recreated elsewhere by the next reconcile, instead of waiting for them to be
+// evicted when the nodes are drained. Replicas which are never restarted are
+// left alone.
+func (tc *TFController) drainCordonedNodes(tfJob *tfv1.TFJob, replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec, pods []*v1.Pod) error {
+	if !tc.option.DrainCordonedNodes {
+		return nil
+	}
+	for rtype, spec := range replicas {
+		if spec.RestartPolicy == commonv1.RestartPolicyNever {
+			continue
+		}
+		replicaPods, err := tc.FilterPodsForReplicaType(pods, strings.ToLower(string(rtype)))
+		if err != nil {
+			return err
+		}
+		for _, pod := range replicaPods {
+			if pod.DeletionTimestamp != nil || pod.Spec.NodeName == "" ||
+				(pod.Status.Phase != v1.PodRunning && pod.Status.Phase != v1.PodPending) {
+				continue
+			}
+			node, err := tc.nodeLister.Get(pod.Spec.NodeName)
+			if errors.IsNotFound(err) {
+				continue
+			} else if err != nil {
+				return err
+			}
+			if !node.Spec.Unschedulable {
+				continue
+			}
+			cause := fmt.Sprintf("node %s is cordoned, to be recreated elsewhere", node.Name)
+			if err := tc.deletePod(tfJob, pod, drainingCordonedNodeReason, cause); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// recreatedForClusterSpecDriftReason is the normal reason when the pods of a
+// replica type are recreated because the TF_CONFIG of one of them no longer
+// matches the cluster spec of the tfjob.
+const recreatedForClusterSpecDriftReason = "RecreatedForClusterSpecDrift"
+
+// getPodTFConfig returns the TF_CONFIG the pod was created with, from the env
+// of its tensorflow container, or from the annotation projected into its
+// TF_CONFIG file. The annotation is skipped if the operator patches it, as it
+// is kept up to date then.
+func (tc *TFController) getPodTFConfig(pod *v1.Pod) (string, bool) {
+	for _, container := range pod.Spec.Containers {
+		if container.Name != tfv1.DefaultContainerName {
+			continue
+		}
+		for _, env := range container.Env {
+			if env.Name == tfConfig && env.ValueFrom == nil {
+				return env.Value, true
+			}
+		}
+	}
+	if tc.option.PatchTFConfig {
+		return "", false
+	}
+	value, ok := pod.Annotations[tfConfigAnnotation]
+	return value, ok
+}
+
+// getDriftedPod returns an active pod of the replica type whose TF_CONFIG no
+// longer matches the one generated from the tfjob, or nil if there is none.
+// The pods out of range are about to be deleted by the scaling, and are not
+// checked.
+func (tc *TFController) getDriftedPod(tfJob *tfv1.TFJob, pods []*v1.Pod) (*v1.Pod, error) {
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || (pod.Status.Phase != v1.PodRunning && pod.Status.Phase != v1.PodPending) {
+			continue
+		}
+		rt, index := pod.Labels[tc.GetReplicaTypeLabelKey()], pod.Labels[tc.GetReplicaIndexLabelKey()]
+		if !inReplicaRange(tfJob, rt, index) {
+			continue
+		}
+		current, ok := tc.getPodTFConfig(pod)
+		if !ok {
+			continue
+		}
+		desired, err := tc.genTFConfig(tfJob, rt, index)
+		if err != nil {
+			return nil, err
+		}
+		if current != desired {
+			return pod, nil
+		}
+	}
+	return nil, nil
+}
+
+// recreateDriftedReplicas deletes all the active pods of every replica type
+// which has a pod whose TF_CONFIG drifted from the cluster spec of the tfjob,
+// e.g. because its members changed out of band. The whole replica type is
+// deleted in the same pass, instead of pod by pod, so that it is recreated by
+// the next reconcile with a single topology.
+func (tc *TFController) recreateDriftedReplicas(tfJob *tfv1.TFJob, replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec, pods []*v1.Pod) error {
+	if !tc.option.RecreateOnClusterSpecDrift || !isDistributed(tfJob) {
+		return nil
+	}
+	for rtype := range replicas {
+		replicaPods, err := tc.FilterPodsForReplicaType(pods, strings.ToLower(string(rtype)))
+		if err != nil {
+			return err
+		}
+		drifted, err := tc.getDriftedPod(tfJob, replicaPods)
+		if err != nil {
+			return err
+		}
+		if drifted == nil {
+			continue
+		}
+		commonutil.LoggerForReplica(tfJob, strings.ToLower(string(rtype))).Infof(
+			"Recreating the pods of replica type %s, the TF_CONFIG of pod %s/%s does not match the cluster spec",
+			rtype, drifted.Namespace, drifted.Name)
+		cause := fmt.Sprintf("the TF_CONFIG of pod %s does not match the cluster spec, recreating replica type %s", drifted.Name, rtype)
+		for _, pod := range replicaPods {
+			if pod.DeletionTimestamp != nil || (pod.Status.Phase != v1.PodRunning && pod.Status.Phase != v1.PodPending) {
+				continue
+			}
+			if err := tc.deletePod(tfJob, pod, recreatedForClusterSpecDriftReason, cause); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestRecreateOnClusterSpecDrift(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{RecreateOnClusterSpecDrift: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(2, 1)
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+	// worker-1 was given a cluster spec with 3 workers out of band.
+	drifted := tfJob.DeepCopy()
+	*drifted.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Replicas = 3
+	newPod := func(job *tfv1.TFJob, typ string, index int) *v1.Pod {
+		pod := testutil.NewPod(tfJob, typ, index)
+		pod.Status.Phase = v1.PodRunning
+		tfConfigStr, err := ctr.genTFConfig(job, typ, pod.Labels[ctr.GetReplicaIndexLabelKey()])
+		if err != nil {
+			t.Fatalf("Failed to generate TF_CONFIG: %v", err)
+		}
+		pod.Spec.Containers = []v1.Container{{
+			Name: tfv1.DefaultContainerName,
+			Env:  []v1.EnvVar{{Name: tfConfig, Value: tfConfigStr}},
+		}}
+		return pod
+	}
+	pods := []*v1.Pod{
+		newPod(tfJob, testutil.LabelWorker, 0),
+		newPod(drifted, testutil.LabelWorker, 1),
+		newPod(tfJob, testutil.LabelPS, 0),
+	}
+	for _, pod := range pods {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("Unexpected error when adding pod %v", err)
+		}
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	// All the workers are recreated together, the PS are left alone.
+	deleted := append([]string{}, fakePodControl.DeletePodName...)
+	sort.Strings(deleted)
+	expected := []string{pods[0].Name, pods[1].Name}
+	if !reflect.DeepEqual(deleted, expected) {
+		t.Errorf("Expected pods %v to be deleted, got %v", expected, deleted)
+	}
+
+	// Without drift nothing is recreated.
+	fakePodControl.Clear()
+	pods[1] = newPod(tfJob, testutil.LabelWorker, 1)
+	if err := podIndexer.Update(pods[1]); err != nil {
+		t.Errorf("Unexpected error when updating pod %v", err)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if len(fakePodControl.DeletePodName) != 0 {
+		t.Errorf("Expected no pods to be deleted, got %v", fakePodControl.DeletePodName)
+	}
+}
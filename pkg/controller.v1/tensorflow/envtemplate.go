@@ -0,0 +1,75 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"text/template"
+
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// invalidEnvTemplateReason is the warning reason when the templated env can
+// not be rendered for a tfjob.
+const invalidEnvTemplateReason = "InvalidEnvTemplate"
+
+// envTemplate is an environment variable whose value is rendered for every
+// pod, e.g. to tell preemptible workers where to resume from.
+type envTemplate struct {
+	name     string
+	template *template.Template
+}
+
+// envTemplateData is what the value of an envTemplate is rendered with.
+type envTemplateData struct {
+	Namespace    string
+	Name         string
+	UID          string
+	ReplicaType  string
+	ReplicaIndex string
+}
+
+// parseEnvTemplate parses an env template of the form "NAME=TEMPLATE", e.g.
+// "CHECKPOINT_DIR=/checkpoints/{{.Namespace}}/{{.Name}}".
+func parseEnvTemplate(value string) (*envTemplate, error) {
+	kv := strings.SplitN(value, "=", 2)
+	if len(kv) != 2 || kv[0] == "" {
+		return nil, fmt.Errorf("invalid env template %q, expected <name>=<template>", value)
+	}
+	t, err := template.New(kv[0]).Option("missingkey=error").Parse(kv[1])
+	if err != nil {
+		return nil, err
+	}
+	return &envTemplate{name: kv[0], template: t}, nil
+}
+
+// render renders the env of the given replica of the tfjob.
+func (e *envTemplate) render(tfjob *tfv1.TFJob, rtype, index string) (v1.EnvVar, error) {
+	var value bytes.Buffer
+	err := e.template.Execute(&value, envTemplateData{
+		Namespace:    tfjob.Namespace,
+		Name:         tfjob.Name,
+		UID:          string(tfjob.UID),
+		ReplicaType:  strings.ToLower(rtype),
+		ReplicaIndex: index,
+	})
+	if err != nil {
+		return v1.EnvVar{}, err
+	}
+	return v1.EnvVar{Name: e.name, Value: value.String()}, nil
+}
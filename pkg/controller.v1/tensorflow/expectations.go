@@ -0,0 +1,95 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"github.com/kubeflow/common/pkg/controller.v1/expectation"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var tfJobsOutstandingExpectations = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "tf_operator_outstanding_expectations",
+		Help: "Number of pod and service creations (add) and deletions (del) the controller still expects to observe",
+	},
+	[]string{"key", "type"},
+)
+
+// metricsExpectations reports the outstanding expectations of every key it
+// changes, so that expectations which are never satisfied can be spotted.
+type metricsExpectations struct {
+	expectation.ControllerExpectationsInterface
+}
+
+// newMetricsExpectations returns the given expectations reporting to
+// tfJobsOutstandingExpectations.
+func newMetricsExpectations(e expectation.ControllerExpectationsInterface) *metricsExpectations {
+	return &metricsExpectations{ControllerExpectationsInterface: e}
+}
+
+// report sets the outstanding expectations of the key, and drops the key when
+// it has no expectations.
+func (e *metricsExpectations) report(controllerKey string) {
+	exp, exists, err := e.ControllerExpectationsInterface.GetExpectations(controllerKey)
+	if err != nil || !exists {
+		tfJobsOutstandingExpectations.DeleteLabelValues(controllerKey, "add")
+		tfJobsOutstandingExpectations.DeleteLabelValues(controllerKey, "del")
+		return
+	}
+	add, del := exp.GetExpectations()
+	tfJobsOutstandingExpectations.WithLabelValues(controllerKey, "add").Set(float64(add))
+	tfJobsOutstandingExpectations.WithLabelValues(controllerKey, "del").Set(float64(del))
+}
+
+func (e *metricsExpectations) DeleteExpectations(controllerKey string) {
+	e.ControllerExpectationsInterface.DeleteExpectations(controllerKey)
+	e.report(controllerKey)
+}
+
+func (e *metricsExpectations) SetExpectations(controllerKey string, add, del int) error {
+	defer e.report(controllerKey)
+	return e.ControllerExpectationsInterface.SetExpectations(controllerKey, add, del)
+}
+
+func (e *metricsExpectations) ExpectCreations(controllerKey string, adds int) error {
+	defer e.report(controllerKey)
+	return e.ControllerExpectationsInterface.ExpectCreations(controllerKey, adds)
+}
+
+func (e *metricsExpectations) ExpectDeletions(controllerKey string, dels int) error {
+	defer e.report(controllerKey)
+	return e.ControllerExpectationsInterface.ExpectDeletions(controllerKey, dels)
+}
+
+func (e *metricsExpectations) CreationObserved(controllerKey string) {
+	e.ControllerExpectationsInterface.CreationObserved(controllerKey)
+	e.report(controllerKey)
+}
+
+func (e *metricsExpectations) DeletionObserved(controllerKey string) {
+	e.ControllerExpectationsInterface.DeletionObserved(controllerKey)
+	e.report(controllerKey)
+}
+
+func (e *metricsExpectations) RaiseExpectations(controllerKey string, add, del int) {
+	e.ControllerExpectationsInterface.RaiseExpectations(controllerKey, add, del)
+	e.report(controllerKey)
+}
+
+func (e *metricsExpectations) LowerExpectations(controllerKey string, add, del int) {
+	e.ControllerExpectationsInterface.LowerExpectations(controllerKey, add, del)
+	e.report(controllerKey)
+}
@@ -0,0 +1,183 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// tfJobResourcesUnavailable is the condition of a tfjob requesting an
+	// extended resource which no schedulable node provides, so that its pods
+	// stay pending.
+	tfJobResourcesUnavailable commonv1.JobConditionType = "ResourcesUnavailable"
+	// extendedResourceUnavailableReason is the reason of the
+	// ResourcesUnavailable condition, and the warning reason of the event
+	// emitted when it is set.
+	extendedResourceUnavailableReason = "ExtendedResourceUnavailable"
+)
+
+// enqueueTFJobsForNode enqueues the tfjobs waiting for an extended resource
+// when a node is added or updated, as it may provide it.
+func (tc *TFController) enqueueTFJobsForNode(obj interface{}) {
+	if _, ok := obj.(*v1.Node); !ok {
+		return
+	}
+	for _, un := range tc.tfJobInformer.GetIndexer().List() {
+		tfJob, err := tfJobFromUnstructured(un)
+		if err != nil || !tfv1.IsConditionTrue(tfJob.Status, tfJobResourcesUnavailable) {
+			continue
+		}
+		tc.WorkQueue.Add(tfJob.Namespace + "/" + tfJob.Name)
+	}
+}
+
+// isExtendedResourceName returns true if the resource is not a native
+// resource of kubernetes, e.g. nvidia.com/gpu.
+func isExtendedResourceName(name v1.ResourceName) bool {
+	return strings.Contains(string(name), "/") &&
+		!strings.HasPrefix(string(name), v1.ResourceDefaultNamespacePrefix) &&
+		!strings.HasPrefix(string(name), v1.DefaultResourceRequestsPrefix)
+}
+
+// requestedExtendedResources returns the extended resources requested by the
+// containers of the replicas, sorted by name.
+func requestedExtendedResources(replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) []v1.ResourceName {
+	requested := map[v1.ResourceName]bool{}
+	for _, spec := range replicas {
+		containers := append([]v1.Container{}, spec.Template.Spec.InitContainers...)
+		containers = append(containers, spec.Template.Spec.Containers...)
+		for _, container := range containers {
+			for _, list := range []v1.ResourceList{container.Resources.Requests, container.Resources.Limits} {
+				for name, quantity := range list {
+					if isExtendedResourceName(name) && !quantity.IsZero() {
+						requested[name] = true
+					}
+				}
+			}
+		}
+	}
+	names := make([]v1.ResourceName, 0, len(requested))
+	for name := range requested {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+	return names
+}
+
+// unavailableExtendedResources returns the extended resources requested by the
+// replicas which no schedulable node provides.
+func (tc *TFController) unavailableExtendedResources(replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) ([]v1.ResourceName, error) {
+	requested := requestedExtendedResources(replicas)
+	if len(requested) == 0 {
+		return nil, nil
+	}
+	nodes, err := tc.nodeLister.List(labels.Everything())
+	if err != nil {
+		return nil, err
+	}
+	var unavailable []v1.ResourceName
+	for _, name := range requested {
+		available := false
+		for _, node := range nodes {
+			if quantity, ok := node.Status.Allocatable[name]; ok && !node.Spec.Unschedulable && quantity.Sign() > 0 {
+				available = true
+				break
+			}
+		}
+		if !available {
+			unavailable = append(unavailable, name)
+		}
+	}
+	return unavailable, nil
+}
+
+// syncResourcesUnavailable sets the ResourcesUnavailable condition of the
+// tfjob if it requests an extended resource which no schedulable node
+// provides, and removes it otherwise. The pods are still created, as a node
+// providing it may be added later.
+func (tc *TFController) syncResourcesUnavailable(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus,
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) error {
+	if !tc.option.ValidateExtendedResources {
+		return nil
+	}
+	unavailable, err := tc.unavailableExtendedResources(replicas)
+	if err != nil {
+		return err
+	}
+	if len(unavailable) == 0 {
+		clearResourcesUnavailable(jobStatus)
+		return nil
+	}
+	tc.setResourcesUnavailable(tfJob, jobStatus, unavailable)
+	return nil
+}
+
+// setResourcesUnavailable sets the ResourcesUnavailable condition of the
+// tfjob, and emits an event when it is first set or the unavailable
+// resources change.
+func (tc *TFController) setResourcesUnavailable(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, unavailable []v1.ResourceName) {
+	names := make([]string, 0, len(unavailable))
+	for _, name := range unavailable {
+		names = append(names, string(name))
+	}
+	msg := fmt.Sprintf("TFJob %s/%s requests %s, which no schedulable node provides, its pods stay pending until one does",
+		tfJob.Namespace, tfJob.Name, strings.Join(names, ", "))
+	condition := tfv1.GetCondition(*jobStatus, tfJobResourcesUnavailable)
+	if condition != nil && condition.Status == v1.ConditionTrue && condition.Message == msg {
+		return
+	}
+	commonutil.LoggerForJob(tfJob).Warn(msg)
+	tc.Recorder.Event(tfJob, v1.EventTypeWarning, extendedResourceUnavailableReason, msg)
+
+	now := metav1.Now()
+	// The conditions are copied, as they may be shared with the tfjob.
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)+1)
+	for _, c := range jobStatus.Conditions {
+		if c.Type != tfJobResourcesUnavailable {
+			conditions = append(conditions, c)
+		}
+	}
+	jobStatus.Conditions = append(conditions, commonv1.JobCondition{
+		Type:               tfJobResourcesUnavailable,
+		Status:             v1.ConditionTrue,
+		Reason:             extendedResourceUnavailableReason,
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	})
+}
+
+// clearResourcesUnavailable removes the ResourcesUnavailable condition of the
+// tfjob.
+func clearResourcesUnavailable(jobStatus *commonv1.JobStatus) {
+	for i, condition := range jobStatus.Conditions {
+		if condition.Type == tfJobResourcesUnavailable {
+			conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)-1)
+			conditions = append(conditions, jobStatus.Conditions[:i]...)
+			jobStatus.Conditions = append(conditions, jobStatus.Conditions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestValidateExtendedResources(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ValidateExtendedResources: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	recorder := record.NewFakeRecorder(100)
+	ctr.Recorder = recorder
+	nodeIndexer := kubeInformerFactory.Core().V1().Nodes().Informer().GetIndexer()
+
+	gpu := v1.ResourceName("nvidia.com/gpu")
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Template.Spec.Containers[0].Resources.Limits = v1.ResourceList{
+		v1.ResourceCPU: resource.MustParse("1"),
+		gpu:            resource.MustParse("1"),
+	}
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+
+	// The only node with GPUs is cordoned.
+	nodes := []*v1.Node{
+		{ObjectMeta: metav1.ObjectMeta{Name: "cpu"}, Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{v1.ResourceCPU: resource.MustParse("8")},
+		}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "cordoned-gpu"}, Spec: v1.NodeSpec{Unschedulable: true}, Status: v1.NodeStatus{
+			Allocatable: v1.ResourceList{v1.ResourceCPU: resource.MustParse("8"), gpu: resource.MustParse("8")},
+		}},
+	}
+	for _, node := range nodes {
+		if err := nodeIndexer.Add(node); err != nil {
+			t.Fatalf("Unexpected error when adding node %v", err)
+		}
+	}
+
+	if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+		t.Fatalf("Failed to reconcile the tfjob: %v", err)
+	}
+
+	condition := tfv1.GetCondition(tfJob.Status, tfJobResourcesUnavailable)
+	if condition == nil || condition.Status != v1.ConditionTrue {
+		t.Fatalf("Expected condition %s, got %v", tfJobResourcesUnavailable, tfJob.Status.Conditions)
+	}
+	if !strings.Contains(condition.Message, string(gpu)) {
+		t.Errorf("Expected the condition message to name %s, got %q", gpu, condition.Message)
+	}
+	// The pods are still created, to be scheduled once a node provides it.
+	if fakePodControl.CreateCallCount != 1 {
+		t.Errorf("Expected 1 pod creation, got %d", fakePodControl.CreateCallCount)
+	}
+	found := false
+	for len(recorder.Events) > 0 {
+		if strings.HasPrefix(<-recorder.Events, v1.EventTypeWarning+" "+extendedResourceUnavailableReason) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a %s event", extendedResourceUnavailableReason)
+	}
+
+	// The condition is removed once a schedulable node provides it.
+	gpuNode := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "gpu"}, Status: v1.NodeStatus{
+		Allocatable: v1.ResourceList{v1.ResourceCPU: resource.MustParse("8"), gpu: resource.MustParse("8")},
+	}}
+	if err := nodeIndexer.Add(gpuNode); err != nil {
+		t.Fatalf("Unexpected error when adding node %v", err)
+	}
+
+	if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+		t.Fatalf("Failed to reconcile the tfjob: %v", err)
+	}
+
+	if tfv1.GetCondition(tfJob.Status, tfJobResourcesUnavailable) != nil {
+		t.Errorf("Expected condition %s to be removed, got %v", tfJobResourcesUnavailable, tfJob.Status.Conditions)
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// tfJobFailuresAggregating is the condition of a tfjob which has failed
+	// replicas, and waits for the failure aggregation window to end before
+	// failing.
+	tfJobFailuresAggregating commonv1.JobConditionType = "FailuresAggregating"
+	// failuresAggregatingReason is the reason of the FailuresAggregating condition.
+	failuresAggregatingReason = "FailuresAggregating"
+)
+
+// failureAggregationThreshold returns the number of replicas which have to be
+// failed at the end of the window for the tfjob to fail.
+func (tc *TFController) failureAggregationThreshold() int32 {
+	if tc.option.FailureAggregationThreshold > 0 {
+		return int32(tc.option.FailureAggregationThreshold)
+	}
+	return 1
+}
+
+// holdFailures returns true if the failed replicas of the tfjob must not fail
+// it yet, because the failure aggregation window, which starts with the first
+// failure, has not ended, or because fewer replicas than the threshold are
+// still failed at its end. The tfjob is requeued for the end of the window.
+func (tc *TFController) holdFailures(tfJob *tfv1.TFJob, tfJobKey string, jobStatus *commonv1.JobStatus, failed int32) bool {
+	window := tc.option.FailureAggregationWindow
+	if window <= 0 {
+		return false
+	}
+	condition := tfv1.GetCondition(*jobStatus, tfJobFailuresAggregating)
+	if condition == nil || condition.Status != v1.ConditionTrue {
+		setFailuresAggregating(tfJob, jobStatus, failed, window)
+		tc.WorkQueue.AddAfter(tfJobKey, window)
+		return true
+	}
+	if remaining := time.Until(condition.LastTransitionTime.Add(window)); remaining > 0 {
+		tc.WorkQueue.AddAfter(tfJobKey, remaining)
+		return true
+	}
+	return failed < tc.failureAggregationThreshold()
+}
+
+// setFailuresAggregating sets the FailuresAggregating condition of the tfjob,
+// starting the failure aggregation window.
+func setFailuresAggregating(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, failed int32, window time.Duration) {
+	msg := fmt.Sprintf("TFJob %s/%s has %d failed replica(s), waiting %v for them to recover before failing",
+		tfJob.Namespace, tfJob.Name, failed, window)
+	commonutil.LoggerForJob(tfJob).Info(msg)
+
+	now := metav1.Now()
+	// The conditions are copied, as they may be shared with the tfjob.
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)+1)
+	for _, c := range jobStatus.Conditions {
+		if c.Type != tfJobFailuresAggregating {
+			conditions = append(conditions, c)
+		}
+	}
+	jobStatus.Conditions = append(conditions, commonv1.JobCondition{
+		Type:               tfJobFailuresAggregating,
+		Status:             v1.ConditionTrue,
+		Reason:             failuresAggregatingReason,
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	})
+}
+
+// clearFailuresAggregating removes the FailuresAggregating condition of the
+// tfjob, so that the next failure starts a new window.
+func clearFailuresAggregating(jobStatus *commonv1.JobStatus) {
+	for i, condition := range jobStatus.Conditions {
+		if condition.Type == tfJobFailuresAggregating {
+			conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)-1)
+			conditions = append(conditions, jobStatus.Conditions[:i]...)
+			jobStatus.Conditions = append(conditions, jobStatus.Conditions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"time"
+
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// tfJobCleanupFinalizer keeps a deleted tfjob until ExternalCleanup has
+// cleaned up its external resources.
+const tfJobCleanupFinalizer = "kubeflow.org/tfjob-cleanup"
+
+const (
+	// failedExternalCleanupReason is the warning reason when ExternalCleanup
+	// fails, and the cleanup is retried.
+	failedExternalCleanupReason = "FailedExternalCleanup"
+	// forceRemovedFinalizerReason is the warning reason when the finalizer is
+	// removed although ExternalCleanup keeps failing.
+	forceRemovedFinalizerReason = "ForceRemovedFinalizer"
+)
+
+// hasCleanupFinalizer returns true if the tfjob has the cleanup finalizer.
+func hasCleanupFinalizer(tfJob *tfv1.TFJob) bool {
+	for _, f := range tfJob.Finalizers {
+		if f == tfJobCleanupFinalizer {
+			return true
+		}
+	}
+	return false
+}
+
+// syncFinalizer adds the cleanup finalizer to a live tfjob if ExternalCleanup
+// is set. For a deleted tfjob it runs ExternalCleanup and removes the
+// finalizer. When the cleanup keeps failing past the retries or the timeout
+// given in the ServerOption, the finalizer is removed anyway, so that the
+// deletion is not blocked forever. It returns true if the tfjob is deleted.
+func (tc *TFController) syncFinalizer(tfJob *tfv1.TFJob, key string) (bool, error) {
+	if tfJob.DeletionTimestamp == nil {
+		if tc.ExternalCleanup == nil || hasCleanupFinalizer(tfJob) {
+			return false, nil
+		}
+		tfJob.Finalizers = append(tfJob.Finalizers, tfJobCleanupFinalizer)
+		return false, tc.updateFinalizers(tfJob)
+	}
+	if !hasCleanupFinalizer(tfJob) {
+		return true, nil
+	}
+
+	// Without ExternalCleanup there is nothing to wait for.
+	if tc.ExternalCleanup != nil {
+		if err := tc.ExternalCleanup(tfJob); err != nil {
+			retries := tc.WorkQueue.NumRequeues(key)
+			if !tc.cleanupGraceExceeded(tfJob, retries) {
+				tc.Recorder.Eventf(tfJob, v1.EventTypeWarning, failedExternalCleanupReason,
+					"Error cleaning up external resources: %v", err)
+				return true, err
+			}
+			commonutil.LoggerForJob(tfJob).Warnf("Removing finalizer %s after %d failed cleanups: %v",
+				tfJobCleanupFinalizer, retries+1, err)
+			tc.Recorder.Eventf(tfJob, v1.EventTypeWarning, forceRemovedFinalizerReason,
+				"Removed finalizer %s after %d failed cleanups: %v", tfJobCleanupFinalizer, retries+1, err)
+		}
+	}
+
+	finalizers := make([]string, 0, len(tfJob.Finalizers))
+	for _, f := range tfJob.Finalizers {
+		if f != tfJobCleanupFinalizer {
+			finalizers = append(finalizers, f)
+		}
+	}
+	tfJob.Finalizers = finalizers
+	return true, tc.updateFinalizers(tfJob)
+}
+
+// cleanupGraceExceeded returns true if ExternalCleanup of the deleted tfjob
+// has failed for longer than the ServerOption allows.
+func (tc *TFController) cleanupGraceExceeded(tfJob *tfv1.TFJob, retries int) bool {
+	if maxRetries := tc.option.FinalizerCleanupMaxRetries; maxRetries > 0 && retries >= maxRetries {
+		return true
+	}
+	if timeout := tc.option.FinalizerCleanupTimeout; timeout > 0 && time.Since(tfJob.DeletionTimestamp.Time) >= timeout {
+		return true
+	}
+	return false
+}
+
+// updateFinalizers writes the finalizers of the tfjob to the API server, and
+// refreshes the object meta of the tfjob from the response.
+func (tc *TFController) updateFinalizers(tfJob *tfv1.TFJob) error {
+	updated, err := tc.tfJobClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Update(context.TODO(), tfJob, metav1.UpdateOptions{})
+	if err != nil {
+		return err
+	}
+	tfJob.ObjectMeta = updated.ObjectMeta
+	return nil
+}
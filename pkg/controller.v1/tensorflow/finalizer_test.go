@@ -0,0 +1,134 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestFinalizerForceRemoved(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	maxRetries := 3
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{FinalizerCleanupMaxRetries: maxRetries})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	fakeRecorder := record.NewFakeRecorder(100)
+	ctr.Recorder = fakeRecorder
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	cleanups := 0
+	ctr.ExternalCleanup = func(*tfv1.TFJob) error {
+		cleanups++
+		return fmt.Errorf("cloud resource is busy")
+	}
+
+	tfJob := testutil.NewTFJob(1, 0)
+	now := metav1.Now()
+	tfJob.DeletionTimestamp = &now
+	tfJob.Finalizers = []string{tfJobCleanupFinalizer}
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	key, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Fatalf("Failed to get the key of the tfjob: %v", err)
+	}
+
+	// Sync the way processNextWorkItem does, until the cleanup gives up.
+	for i := 0; i < 2*maxRetries; i++ {
+		if _, err := ctr.syncTFJob(key); err == nil {
+			break
+		}
+		ctr.WorkQueue.AddRateLimited(key)
+	}
+
+	if cleanups != maxRetries+1 {
+		t.Errorf("Expected %d cleanups, got %d", maxRetries+1, cleanups)
+	}
+	updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	if hasCleanupFinalizer(updated) {
+		t.Errorf("Expected the finalizer to be removed, got %v", updated.Finalizers)
+	}
+	if len(fakePodControl.Templates) != 0 {
+		t.Errorf("Expected no pod to be created for a deleted tfjob, got %d", len(fakePodControl.Templates))
+	}
+
+	close(fakeRecorder.Events)
+	found := false
+	for event := range fakeRecorder.Events {
+		if strings.Contains(event, "Warning "+forceRemovedFinalizerReason) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a %s event", forceRemovedFinalizerReason)
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// tfJobIdle is the condition of a tfjob whose replica types are all
+	// scaled to zero. Its pods and services are deleted, and the tfjob waits
+	// to be scaled up again.
+	tfJobIdle commonv1.JobConditionType = "Idle"
+	// tfJobIdleReason is the reason of the Idle condition, and of the event
+	// emitted when the tfjob becomes idle.
+	tfJobIdleReason = "TFJobIdle"
+	// tfJobResumedReason is the reason of the event emitted when an idle
+	// tfjob is scaled up again.
+	tfJobResumedReason = "TFJobResumed"
+)
+
+// isIdle returns true if every replica type of the tfjob is scaled to zero.
+func isIdle(replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) bool {
+	if len(replicas) == 0 {
+		return false
+	}
+	for _, spec := range replicas {
+		if spec == nil || spec.Replicas == nil || *spec.Replicas != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// hibernate deletes the pods and services of the idle tfjob, and sets its
+// Idle condition. The tfjob is kept, with its status, until it is resumed.
+func (tc *TFController) hibernate(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, pods []*v1.Pod, services []*v1.Service) error {
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := tc.deletePod(tfJob, pod, deletedPodReason, "all replicas scaled to zero"); err != nil {
+			return err
+		}
+	}
+	for _, service := range services {
+		if err := tc.ServiceControl.DeleteService(service.Namespace, service.Name, tfJob); err != nil {
+			return err
+		}
+	}
+
+	// No replica is left, and those of the resumed tfjob start over.
+	replicaStatuses := make(map[commonv1.ReplicaType]*commonv1.ReplicaStatus, len(jobStatus.ReplicaStatuses))
+	for rtype := range jobStatus.ReplicaStatuses {
+		replicaStatuses[rtype] = &commonv1.ReplicaStatus{}
+	}
+	jobStatus.ReplicaStatuses = replicaStatuses
+
+	if tfv1.IsConditionTrue(*jobStatus, tfJobIdle) {
+		return nil
+	}
+	msg := fmt.Sprintf("TFJob %s/%s is idle because all its replicas are scaled to zero.", tfJob.Namespace, tfJob.Name)
+	commonutil.LoggerForJob(tfJob).Info(msg)
+	tc.Recorder.Event(tfJob, v1.EventTypeNormal, tfJobIdleReason, msg)
+
+	now := metav1.Now()
+	// The conditions are copied, as they may be shared with the tfjob.
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)+1)
+	for _, c := range jobStatus.Conditions {
+		switch c.Type {
+		case tfJobIdle:
+			continue
+		case commonv1.JobRunning:
+			if c.Status == v1.ConditionTrue {
+				c.Status = v1.ConditionFalse
+				c.Reason = tfJobIdleReason
+				c.Message = msg
+				c.LastUpdateTime = now
+				c.LastTransitionTime = now
+			}
+		}
+		conditions = append(conditions, c)
+	}
+	jobStatus.Conditions = append(conditions, commonv1.JobCondition{
+		Type:               tfJobIdle,
+		Status:             v1.ConditionTrue,
+		Reason:             tfJobIdleReason,
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	})
+	return nil
+}
+
+// resume removes the Idle condition of the tfjob which is scaled up again,
+// and resets its start time, so that its replicas are created from scratch.
+func (tc *TFController) resume(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus) {
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions))
+	for _, c := range jobStatus.Conditions {
+		if c.Type != tfJobIdle {
+			conditions = append(conditions, c)
+		}
+	}
+	jobStatus.Conditions = conditions
+	jobStatus.StartTime = nil
+
+	msg := fmt.Sprintf("TFJob %s/%s is resumed.", tfJob.Namespace, tfJob.Name)
+	commonutil.LoggerForJob(tfJob).Info(msg)
+	tc.Recorder.Event(tfJob, v1.EventTypeNormal, tfJobResumedReason, msg)
+}
@@ -0,0 +1,158 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// tfJobImagePullFailed is the condition of a tfjob which has pods failing
+	// to pull their images.
+	tfJobImagePullFailed commonv1.JobConditionType = "ImagePullFailed"
+	// imagePullFailedReason is the reason of the ImagePullFailed condition,
+	// and the warning reason of the event emitted when it is set.
+	imagePullFailedReason = "ImagePullFailed"
+	// errImagePullReason and imagePullBackOffReason are the reasons of a
+	// container waiting for its image to be pulled after a pull failed.
+	errImagePullReason     = "ErrImagePull"
+	imagePullBackOffReason = "ImagePullBackOff"
+)
+
+// getImagePullFailures returns the images the pod fails to pull, with the
+// message of the kubelet, in the form "pod (image): message".
+func getImagePullFailures(pod *v1.Pod) []string {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodPending {
+		return nil
+	}
+	var failures []string
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			waiting := status.State.Waiting
+			if waiting == nil || (waiting.Reason != errImagePullReason && waiting.Reason != imagePullBackOffReason) {
+				continue
+			}
+			detail := waiting.Message
+			if detail == "" {
+				detail = waiting.Reason
+			}
+			failures = append(failures, fmt.Sprintf("%s (%s): %s", pod.Name, status.Image, detail))
+		}
+	}
+	return failures
+}
+
+// reconcileImagePulls sets the ImagePullFailed condition of the tfjob when
+// some of its pods fail to pull their images, and removes it once they do not.
+// Such pods stay pending, and would otherwise leave no signal on the tfjob.
+func (tc *TFController) reconcileImagePulls(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, pods []*v1.Pod) error {
+	var failures []string
+	for _, pod := range pods {
+		failures = append(failures, getImagePullFailures(pod)...)
+	}
+	if len(failures) == 0 {
+		clearImagePullFailed(jobStatus)
+		return nil
+	}
+
+	msg := fmt.Sprintf("TFJob %s/%s has pods failing to pull their images: %s",
+		tfJob.Namespace, tfJob.Name, strings.Join(failures, "; "))
+	tc.setImagePullFailed(tfJob, jobStatus, msg)
+
+	// The kubelet keeps retrying, check the tfjob again once the pods run out
+	// of time to pull their images.
+	if remaining := tc.imagePullTimeoutRemaining(jobStatus); remaining > 0 {
+		key, err := KeyFunc(tfJob)
+		if err != nil {
+			return err
+		}
+		tc.WorkQueue.AddAfter(key, remaining)
+	}
+	return nil
+}
+
+// imagePullTimeoutRemaining returns how long the pods of the tfjob may still
+// fail to pull their images, or 0 if there is no timeout or they are not
+// failing.
+func (tc *TFController) imagePullTimeoutRemaining(jobStatus *commonv1.JobStatus) time.Duration {
+	condition := tfv1.GetCondition(*jobStatus, tfJobImagePullFailed)
+	if tc.option.ImagePullTimeout <= 0 || condition == nil || condition.Status != v1.ConditionTrue {
+		return 0
+	}
+	if remaining := time.Until(condition.LastTransitionTime.Add(tc.option.ImagePullTimeout)); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// pastImagePullTimeout returns true if the pods of the tfjob have failed to
+// pull their images for longer than the timeout.
+func (tc *TFController) pastImagePullTimeout(jobStatus *commonv1.JobStatus) bool {
+	return tc.option.ImagePullTimeout > 0 && tfv1.IsConditionTrue(*jobStatus, tfJobImagePullFailed) &&
+		tc.imagePullTimeoutRemaining(jobStatus) == 0
+}
+
+// setImagePullFailed sets the ImagePullFailed condition of the tfjob, and
+// emits an event when the condition becomes true. The last transition time is
+// kept while pods keep failing, so that the timeout does not start over.
+func (tc *TFController) setImagePullFailed(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, msg string) {
+	now := metav1.Now()
+	condition := commonv1.JobCondition{
+		Type:               tfJobImagePullFailed,
+		Status:             v1.ConditionTrue,
+		Reason:             imagePullFailedReason,
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	}
+	// The conditions are copied, as they may be shared with the tfjob.
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)+1)
+	for _, c := range jobStatus.Conditions {
+		if c.Type != tfJobImagePullFailed {
+			conditions = append(conditions, c)
+			continue
+		}
+		if c.Message == msg {
+			// The same images still fail to be pulled.
+			return
+		}
+		condition.LastTransitionTime = c.LastTransitionTime
+	}
+	if condition.LastTransitionTime == now {
+		commonutil.LoggerForJob(tfJob).Warn(msg)
+		tc.Recorder.Event(tfJob, v1.EventTypeWarning, imagePullFailedReason, msg)
+	}
+	jobStatus.Conditions = append(conditions, condition)
+}
+
+// clearImagePullFailed removes the ImagePullFailed condition of the tfjob.
+func clearImagePullFailed(jobStatus *commonv1.JobStatus) {
+	for i, condition := range jobStatus.Conditions {
+		if condition.Type == tfJobImagePullFailed {
+			conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)-1)
+			conditions = append(conditions, jobStatus.Conditions[:i]...)
+			jobStatus.Conditions = append(conditions, jobStatus.Conditions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,156 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestImagePullFailed(t *testing.T) {
+	const image = "tensorflow/tensorflow:does-not-exist"
+
+	testCases := []struct {
+		description string
+		timeout     time.Duration
+		// failingSince is when the ImagePullFailed condition was set, if it was.
+		failingSince *time.Time
+
+		expectedFailed bool
+	}{
+		{
+			description: "the condition is set, and the tfjob keeps waiting without a timeout",
+		},
+		{
+			description: "the condition is set, and the tfjob keeps waiting within the timeout",
+			timeout:     10 * time.Minute,
+		},
+		{
+			description:    "the tfjob fails past the timeout",
+			timeout:        10 * time.Minute,
+			failingSince:   func() *time.Time { since := time.Now().Add(-time.Hour); return &since }(),
+			expectedFailed: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tfJob := testutil.NewTFJob(2, 0)
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+		pod.Status.Phase = v1.PodPending
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name:  tfv1.DefaultContainerName,
+			Image: image,
+			State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{
+				Reason:  imagePullBackOffReason,
+				Message: `Back-off pulling image "` + image + `"`,
+			}},
+		}}
+		if tc.failingSince != nil {
+			since := metav1.NewTime(*tc.failingSince)
+			tfJob.Status.Conditions = []commonv1.JobCondition{{
+				Type:               tfJobImagePullFailed,
+				Status:             v1.ConditionTrue,
+				Reason:             imagePullFailedReason,
+				LastUpdateTime:     since,
+				LastTransitionTime: since,
+			}}
+		}
+
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ImagePullTimeout: tc.timeout})
+		recorder := record.NewFakeRecorder(100)
+		ctr.Recorder = recorder
+		fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+		ctr.tfJobClientSet = fakeClientSet
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tc.description, err)
+		}
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+
+		if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+			t.Errorf("%s: unexpected error %v", tc.description, err)
+		}
+
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", tc.description, err)
+		}
+		condition := tfv1.GetCondition(updated.Status, tfJobImagePullFailed)
+		if condition == nil || condition.Status != v1.ConditionTrue {
+			t.Fatalf("%s: expected condition %s, got %v", tc.description, tfJobImagePullFailed, updated.Status.Conditions)
+		}
+		if !strings.Contains(condition.Message, pod.Name) || !strings.Contains(condition.Message, image) {
+			t.Errorf("%s: expected the condition to report pod %s and image %s, got %q",
+				tc.description, pod.Name, image, condition.Message)
+		}
+		if tc.failingSince == nil {
+			found := false
+			for len(recorder.Events) > 0 {
+				if strings.HasPrefix(<-recorder.Events, v1.EventTypeWarning+" "+imagePullFailedReason) {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s: expected a %s event", tc.description, imagePullFailedReason)
+			}
+		}
+
+		failed := tfv1.GetCondition(updated.Status, commonv1.JobFailed)
+		if tc.expectedFailed != (failed != nil && failed.Reason == TFJobFailedReasonImagePull) {
+			t.Errorf("%s: expected failed %v, got conditions %v", tc.description, tc.expectedFailed, updated.Status.Conditions)
+		}
+	}
+}
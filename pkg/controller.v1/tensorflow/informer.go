@@ -13,6 +13,7 @@ import (
 	"k8s.io/client-go/tools/cache"
 
 	tflogger "github.com/kubeflow/common/pkg/util"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
 	"github.com/kubeflow/tf-operator/pkg/apis/tensorflow/validation"
 	tfjobinformers "github.com/kubeflow/tf-operator/pkg/client/informers/externalversions"
@@ -75,7 +76,39 @@ func (tc *TFController) getTFJobFromKey(key string) (*tfv1.TFJob, error) {
 		return nil, errNotExists
 	}
 
-	return tfJobFromUnstructured(obj)
+	tfJob, err := tfJobFromUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	tc.setDefaultEnableDynamicWorker(tfJob, obj.(*metav1unstructured.Unstructured))
+	tc.setDefaultSuccessPolicy(tfJob, obj.(*metav1unstructured.Unstructured))
+	return tfJob, nil
+}
+
+// setDefaultEnableDynamicWorker enables dynamic workers for the tfjob if the
+// ServerOption defaults to it, unless the spec sets EnableDynamicWorker. The
+// unstructured tfjob tells an explicit false from an omitted field.
+func (tc *TFController) setDefaultEnableDynamicWorker(tfJob *tfv1.TFJob, un *metav1unstructured.Unstructured) {
+	if !tc.option.DefaultEnableDynamicWorker {
+		return
+	}
+	if _, found, _ := metav1unstructured.NestedFieldNoCopy(un.Object, "spec", "enableDynamicWorker"); found {
+		return
+	}
+	tfJob.Spec.EnableDynamicWorker = true
+}
+
+// setDefaultSuccessPolicy makes the tfjob succeed once all its workers do if
+// the ServerOption defaults to it, unless the spec sets SuccessPolicy.
+func (tc *TFController) setDefaultSuccessPolicy(tfJob *tfv1.TFJob, un *metav1unstructured.Unstructured) {
+	if tc.option.DefaultSuccessPolicy != options.SuccessPolicyAllWorkers {
+		return
+	}
+	if _, found, _ := metav1unstructured.NestedFieldNoCopy(un.Object, "spec", "successPolicy"); found {
+		return
+	}
+	policy := tfv1.SuccessPolicyAllWorkers
+	tfJob.Spec.SuccessPolicy = &policy
 }
 
 func tfJobFromUnstructured(obj interface{}) (*tfv1.TFJob, error) {
@@ -3,6 +3,9 @@ package tensorflow
 import (
 	"context"
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -24,6 +27,13 @@ const (
 	failedMarshalTFJobReason  = "InvalidTFJobSpec"
 	FailedDeleteJobReason     = "FailedDeleteJob"
 	SuccessfulDeleteJobReason = "SuccessfulDeleteJob"
+
+	// resetStartTimeReason is the normal reason when the start time of a tfjob
+	// is reset after its spec changed.
+	resetStartTimeReason = "ResetStartTime"
+	// unmanagedReason is the normal reason when a tfjob is skipped because its
+	// managed annotation is false.
+	unmanagedReason = "Unmanaged"
 )
 
 var (
@@ -113,6 +123,7 @@ func (tc *TFController) addTFJob(obj interface{}) {
 
 	// Set default for the new tfjob.
 	// TODO(Jeffwan): Consider to change to scheme https://github.com/kubeflow/tf-operator/issues/1317#issuecomment-890397705
+	tc.setDefaultRestartPolicies(tfJob)
 	tfv1.SetDefaults_TFJob(tfJob)
 	scheme.Scheme.Default(tfJob)
 
@@ -138,6 +149,23 @@ func (tc *TFController) addTFJob(obj interface{}) {
 }
 
 // updateTFJob enqueues the current tfjob.
+// setDefaultRestartPolicies sets the restart policy of the replica types which
+// leave it empty to the default given in the ServerOption. It must run before
+// the tfjob is defaulted, which sets the remaining ones to Never.
+func (tc *TFController) setDefaultRestartPolicies(tfJob *tfv1.TFJob) {
+	for rtype, spec := range tfJob.Spec.TFReplicaSpecs {
+		if spec == nil || spec.RestartPolicy != "" {
+			continue
+		}
+		for t, policy := range tc.option.DefaultRestartPolicies {
+			if strings.EqualFold(string(t), string(rtype)) {
+				spec.RestartPolicy = policy
+				break
+			}
+		}
+	}
+}
+
 func (tc *TFController) updateTFJob(old, cur interface{}) {
 	oldTFJob, err := tfJobFromUnstructured(old)
 	if err != nil {
@@ -154,9 +182,31 @@ func (tc *TFController) updateTFJob(old, cur interface{}) {
 		return
 	}
 
+	// A new reconcile nonce kicks the tfjob: it is queued right away, without
+	// the backoff of its failed reconciles.
+	if oldTFJob.Annotations[tfv1.ReconcileNonceAnnotation] != curTFJob.Annotations[tfv1.ReconcileNonceAnnotation] {
+		log.Infof("Reconcile of tfjob %s forced by its reconcile nonce", key)
+		tc.WorkQueue.Forget(key)
+	}
+
+	if tc.option.ReconcileOnGenerationChange && isStatusOnlyUpdate(oldTFJob, curTFJob) {
+		log.Debugf("Skipping the status update of tfjob: %s", key)
+		return
+	}
+
 	log.Infof("Updating tfjob: %s", oldTFJob.Name)
 	tc.enqueueTFJob(cur)
 
+	// Restart the clock of the active deadline on a spec change, if asked to.
+	startTimeReset := false
+	if curTFJob.Generation != oldTFJob.Generation && shouldResetStartTime(curTFJob) {
+		if err := tc.resetStartTime(curTFJob); err != nil {
+			log.Warnf("Failed to reset the start time of tfjob %s: %v", key, err)
+		} else {
+			startTimeReset = true
+		}
+	}
+
 	// check if need to add a new rsync for ActiveDeadlineSeconds
 	if curTFJob.Status.StartTime != nil {
 		curTFJobADS := curTFJob.Spec.RunPolicy.ActiveDeadlineSeconds
@@ -164,7 +214,7 @@ func (tc *TFController) updateTFJob(old, cur interface{}) {
 			return
 		}
 		oldTFJobADS := oldTFJob.Spec.RunPolicy.ActiveDeadlineSeconds
-		if oldTFJobADS == nil || *oldTFJobADS != *curTFJobADS {
+		if startTimeReset || oldTFJobADS == nil || *oldTFJobADS != *curTFJobADS {
 			now := metav1.Now()
 			start := curTFJob.Status.StartTime.Time
 			passed := now.Time.Sub(start)
@@ -175,3 +225,64 @@ func (tc *TFController) updateTFJob(old, cur interface{}) {
 		}
 	}
 }
+
+// isStatusOnlyUpdate returns true if only the status of the tfjob changed,
+// and its current generation has been reconciled already. The resyncs, which
+// deliver the same version of the tfjob, are not status only updates.
+func isStatusOnlyUpdate(oldTFJob, curTFJob *tfv1.TFJob) bool {
+	return oldTFJob.ResourceVersion != curTFJob.ResourceVersion &&
+		oldTFJob.Generation == curTFJob.Generation &&
+		getObservedGeneration(curTFJob) == curTFJob.Generation &&
+		reflect.DeepEqual(oldTFJob.Labels, curTFJob.Labels) &&
+		reflect.DeepEqual(withoutStatusAnnotations(oldTFJob.Annotations), withoutStatusAnnotations(curTFJob.Annotations)) &&
+		reflect.DeepEqual(oldTFJob.Finalizers, curTFJob.Finalizers) &&
+		oldTFJob.DeletionTimestamp.Equal(curTFJob.DeletionTimestamp)
+}
+
+// withoutStatusAnnotations returns the annotations but the ones the operator
+// reports the status of a tfjob in, which are status only updates too.
+func withoutStatusAnnotations(annotations map[string]string) map[string]string {
+	filtered := make(map[string]string, len(annotations))
+	for key, value := range annotations {
+		switch key {
+		case tfv1.ProgressAnnotation, tfv1.ReplicaNodesAnnotation, tfv1.ReplicaRestartsAnnotation, tfv1.ObservedGenerationAnnotation:
+			continue
+		}
+		filtered[key] = value
+	}
+	return filtered
+}
+
+// shouldResetStartTime returns true if the tfjob is running, and its active
+// deadline is measured from the last change of its spec.
+func shouldResetStartTime(tfJob *tfv1.TFJob) bool {
+	if tfJob.Annotations[tfv1.ResetDeadlineOnUpdateAnnotation] != "true" {
+		return false
+	}
+	return tfJob.Status.StartTime != nil && !isSucceeded(tfJob.Status) && !isFailed(tfJob.Status)
+}
+
+// resetStartTime sets the start time of the tfjob to now, and writes it to the
+// API server.
+func (tc *TFController) resetStartTime(tfJob *tfv1.TFJob) error {
+	now := metav1.Now()
+	tfJob.Status.StartTime = &now
+	if _, err := tc.tfJobClientSet.KubeflowV1().TFJobs(tfJob.Namespace).UpdateStatus(context.TODO(), tfJob, metav1.UpdateOptions{}); err != nil {
+		return err
+	}
+	tc.Recorder.Eventf(tfJob, v1.EventTypeNormal, resetStartTimeReason,
+		"Reset the start time of TFJob %s after its spec changed", tfJob.Name)
+	return nil
+}
+
+// skipUnmanaged returns true if the tfjob is not managed by this operator,
+// because its managed annotation is false, and emits an event saying so.
+func (tc *TFController) skipUnmanaged(tfJob *tfv1.TFJob) bool {
+	if managed, err := strconv.ParseBool(tfJob.Annotations[tfv1.ManagedAnnotation]); err != nil || managed {
+		return false
+	}
+	commonutil.LoggerForJob(tfJob).Infof("Skipping tfjob %s/%s, it is not managed by this operator", tfJob.Namespace, tfJob.Name)
+	tc.Recorder.Eventf(tfJob, v1.EventTypeNormal, unmanagedReason,
+		"TFJob is not managed by this operator, the %s annotation is false", tfv1.ManagedAnnotation)
+	return true
+}
@@ -15,6 +15,8 @@
 package tensorflow
 
 import (
+	"context"
+	"strings"
 	"testing"
 	"time"
 
@@ -32,6 +34,7 @@ import (
 	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
 	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
 	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
 )
 
@@ -206,6 +209,8 @@ func TestDeletePodsAndServices(t *testing.T) {
 		activeWorkerServices int32
 		activePSServices     int32
 
+		cleanPodPolicyAnnotation string
+
 		expectedPodDeletions int
 	}
 
@@ -286,6 +291,69 @@ func TestDeletePodsAndServices(t *testing.T) {
 
 			expectedPodDeletions: 0,
 		},
+		testCase{
+			description: "4 workers and 2 ps is running, policy is all, annotation is None",
+			tfJob:       testutil.NewTFJobWithCleanPolicy(0, 4, 2, common.CleanPodPolicyAll),
+
+			pendingWorkerPods:   0,
+			activeWorkerPods:    4,
+			succeededWorkerPods: 0,
+			failedWorkerPods:    0,
+
+			pendingPSPods:   0,
+			activePSPods:    2,
+			succeededPSPods: 0,
+			failedPSPods:    0,
+
+			activeWorkerServices: 4,
+			activePSServices:     2,
+
+			cleanPodPolicyAnnotation: string(common.CleanPodPolicyNone),
+
+			expectedPodDeletions: 0,
+		},
+		testCase{
+			description: "4 workers and 2 ps is running, policy is None, annotation is Running",
+			tfJob:       testutil.NewTFJobWithCleanPolicy(0, 4, 2, common.CleanPodPolicyNone),
+
+			pendingWorkerPods:   0,
+			activeWorkerPods:    4,
+			succeededWorkerPods: 0,
+			failedWorkerPods:    0,
+
+			pendingPSPods:   0,
+			activePSPods:    2,
+			succeededPSPods: 0,
+			failedPSPods:    0,
+
+			activeWorkerServices: 4,
+			activePSServices:     2,
+
+			cleanPodPolicyAnnotation: string(common.CleanPodPolicyRunning),
+
+			expectedPodDeletions: 6,
+		},
+		testCase{
+			description: "4 workers and 2 ps is running, policy is all, annotation is invalid",
+			tfJob:       testutil.NewTFJobWithCleanPolicy(0, 4, 2, common.CleanPodPolicyAll),
+
+			pendingWorkerPods:   0,
+			activeWorkerPods:    4,
+			succeededWorkerPods: 0,
+			failedWorkerPods:    0,
+
+			pendingPSPods:   0,
+			activePSPods:    2,
+			succeededPSPods: 0,
+			failedPSPods:    0,
+
+			activeWorkerServices: 4,
+			activePSServices:     2,
+
+			cleanPodPolicyAnnotation: "Nothing",
+
+			expectedPodDeletions: 6,
+		},
 	}
 	for _, tc := range testCases {
 		// Prepare the clientset and controller for the test.
@@ -325,6 +393,10 @@ func TestDeletePodsAndServices(t *testing.T) {
 		ctr.ServiceInformerSynced = testutil.AlwaysReady
 		tfJobIndexer := ctr.tfJobInformer.GetIndexer()
 
+		if tc.cleanPodPolicyAnnotation != "" {
+			tc.tfJob.Annotations = map[string]string{tfv1.CleanPodPolicyAnnotation: tc.cleanPodPolicyAnnotation}
+		}
+
 		// Set succeeded to run the logic about deleting.
 		err := commonutil.UpdateJobConditions(&tc.tfJob.Status, common.JobSucceeded, tfJobSucceededReason, "")
 		if err != nil {
@@ -546,6 +618,323 @@ func TestDeletePodsAndServices(t *testing.T) {
 // 	}
 // }
 
+func TestKeepAliveAfterCompletion(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeServiceControl := &control.FakeServiceControl{}
+	ctr.ServiceControl = fakeServiceControl
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	tfJob := testutil.NewTFJobWithCleanPolicy(0, 2, 1, common.CleanPodPolicyRunning)
+	tfJob.Spec.TFReplicaPolicies = map[common.ReplicaType]*tfv1.TFReplicaPolicy{
+		tfv1.TFReplicaTypePS: {KeepAliveAfterCompletion: true},
+	}
+
+	// Set succeeded to run the logic about deleting.
+	err := commonutil.UpdateJobConditions(&tfJob.Status, common.JobSucceeded, tfJobSucceededReason, "")
+	if err != nil {
+		t.Errorf("Append tfjob condition error: %v", err)
+	}
+
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 2, 0, 0, nil, t)
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelPS, 0, 1, 0, 0, nil, t)
+
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if len(fakePodControl.DeletePodName) != 2 {
+		t.Errorf("Unexpected number of pod deletes. Expected 2, saw %d", len(fakePodControl.DeletePodName))
+	}
+	for _, name := range fakePodControl.DeletePodName {
+		if strings.Contains(name, testutil.LabelPS) {
+			t.Errorf("Pod %s is kept alive after completion but was deleted", name)
+		}
+	}
+	if len(fakeServiceControl.DeleteServiceName) != 2 {
+		t.Errorf("Unexpected number of service deletes. Expected 2, saw %d", len(fakeServiceControl.DeleteServiceName))
+	}
+}
+
+func TestFinishedPastTTL(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeServiceControl := &control.FakeServiceControl{}
+	ctr.ServiceControl = fakeServiceControl
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	// The job failed a minute ago with a TTL of 10 seconds, and its deletion
+	// is pending.
+	tfJob := testutil.NewTFJob(2, 0)
+	ttl := int32(10)
+	tfJob.Spec.RunPolicy.TTLSecondsAfterFinished = &ttl
+	completionTime := metav1.NewTime(time.Now().Add(-time.Minute))
+	tfJob.Status.CompletionTime = &completionTime
+	deletionTimestamp := metav1.Now()
+	tfJob.DeletionTimestamp = &deletionTimestamp
+	err := commonutil.UpdateJobConditions(&tfJob.Status, common.JobFailed, TFJobFailedReasonPodFailure, "")
+	if err != nil {
+		t.Errorf("Append tfjob condition error: %v", err)
+	}
+
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+
+	// Only one of the two workers is left.
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 1, 0, 0, nil, t)
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if fakePodControl.CreateCallCount != 0 {
+		t.Errorf("Unexpected number of pod creates. Expected 0, saw %d", fakePodControl.CreateCallCount)
+	}
+	if len(fakePodControl.DeletePodName) != 0 {
+		t.Errorf("Unexpected number of pod deletes. Expected 0, saw %d", len(fakePodControl.DeletePodName))
+	}
+	if len(fakeServiceControl.DeleteServiceName) != 0 {
+		t.Errorf("Unexpected number of service deletes. Expected 0, saw %d", len(fakeServiceControl.DeleteServiceName))
+	}
+}
+
+func TestHibernate(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeServiceControl := &control.FakeServiceControl{}
+	ctr.ServiceControl = fakeServiceControl
+	ctr.Recorder = &record.FakeRecorder{}
+
+	// The job is running with a PS and two workers.
+	tfJob := testutil.NewTFJob(2, 1)
+	err := commonutil.UpdateJobConditions(&tfJob.Status, common.JobRunning, tfJobRunningReason, "")
+	if err != nil {
+		t.Errorf("Append tfjob condition error: %v", err)
+	}
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 2, 0, 0, nil, t)
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelPS, 0, 1, 0, 0, nil, t)
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+	// Scaling all the replicas to zero tears the job down.
+	zero := int32(0)
+	idleTFJob := tfJob.DeepCopy()
+	for _, spec := range idleTFJob.Spec.TFReplicaSpecs {
+		spec.Replicas = &zero
+	}
+	_ = ctr.ReconcileJobs(idleTFJob, idleTFJob.Spec.TFReplicaSpecs, idleTFJob.Status, &idleTFJob.Spec.RunPolicy)
+
+	if len(fakePodControl.DeletePodName) != 3 {
+		t.Errorf("Unexpected number of pod deletes. Expected 3, saw %d", len(fakePodControl.DeletePodName))
+	}
+	if len(fakeServiceControl.DeleteServiceName) != 3 {
+		t.Errorf("Unexpected number of service deletes. Expected 3, saw %d", len(fakeServiceControl.DeleteServiceName))
+	}
+	if fakePodControl.CreateCallCount != 0 {
+		t.Errorf("Unexpected number of pod creates. Expected 0, saw %d", fakePodControl.CreateCallCount)
+	}
+	idleTFJob, err = fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	if !tfv1.IsConditionTrue(idleTFJob.Status, tfJobIdle) {
+		t.Errorf("Expected the tfjob to be idle, got conditions %v", idleTFJob.Status.Conditions)
+	}
+	if isSucceeded(idleTFJob.Status) || tfv1.IsConditionTrue(idleTFJob.Status, common.JobRunning) {
+		t.Errorf("Expected the idle tfjob not to be succeeded or running, got conditions %v", idleTFJob.Status.Conditions)
+	}
+
+	// Scaling the replicas up again creates them from scratch.
+	if err := podIndexer.Replace(nil, ""); err != nil {
+		t.Fatalf("Failed to clear the pods: %v", err)
+	}
+	if err := serviceIndexer.Replace(nil, ""); err != nil {
+		t.Fatalf("Failed to clear the services: %v", err)
+	}
+	idleTFJob.Spec = tfJob.Spec
+	_ = ctr.ReconcileJobs(idleTFJob, idleTFJob.Spec.TFReplicaSpecs, idleTFJob.Status, &idleTFJob.Spec.RunPolicy)
+
+	if fakePodControl.CreateCallCount != 3 {
+		t.Errorf("Unexpected number of pod creates. Expected 3, saw %d", fakePodControl.CreateCallCount)
+	}
+	resumedTFJob, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	if tfv1.GetCondition(resumedTFJob.Status, tfJobIdle) != nil {
+		t.Errorf("Expected the resumed tfjob not to be idle, got conditions %v", resumedTFJob.Status.Conditions)
+	}
+}
+
+func TestEmptyReplicaSpecs(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.Spec.TFReplicaSpecs = map[common.ReplicaType]*common.ReplicaSpec{}
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+
+	if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+		t.Fatalf("Expected the tfjob to be reconciled, got error %v", err)
+	}
+	if fakePodControl.CreateCallCount != 0 {
+		t.Errorf("Unexpected number of pod creates. Expected 0, saw %d", fakePodControl.CreateCallCount)
+	}
+	updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	failed := tfv1.GetCondition(updated.Status, common.JobFailed)
+	if failed == nil || failed.Status != v1.ConditionTrue || failed.Reason != TFJobFailedReasonInvalidSpec {
+		t.Errorf("Expected the tfjob to be failed with reason %s, got conditions %v", TFJobFailedReasonInvalidSpec, updated.Status.Conditions)
+	}
+	if updated.Status.CompletionTime == nil {
+		t.Errorf("Expected the completion time of the failed tfjob to be set")
+	}
+}
+
 func TestActiveDeadlineSeconds(t *testing.T) {
 	type testCase struct {
 		description string
@@ -688,6 +1077,326 @@ func TestActiveDeadlineSeconds(t *testing.T) {
 	}
 }
 
+func TestResetDeadlineOnUpdate(t *testing.T) {
+	testCases := []struct {
+		description          string
+		annotations          map[string]string
+		expectedReset        bool
+		expectedPastDeadline bool
+	}{
+		{
+			description:          "the start time is kept by default",
+			annotations:          nil,
+			expectedReset:        false,
+			expectedPastDeadline: true,
+		},
+		{
+			description:          "the start time is reset with the annotation",
+			annotations:          map[string]string{tfv1.ResetDeadlineOnUpdateAnnotation: "true"},
+			expectedReset:        true,
+			expectedPastDeadline: false,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, _, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		ctr.Recorder = &record.FakeRecorder{}
+
+		// The tfjob started two hours ago with a deadline of one hour, and its
+		// workers are scaled up.
+		oldTFJob := testutil.NewTFJob(1, 0)
+		oldTFJob.Annotations = c.annotations
+		oldTFJob.Generation = 1
+		ads := int64(3600)
+		oldTFJob.Spec.RunPolicy.ActiveDeadlineSeconds = &ads
+		startTime := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+		oldTFJob.Status.StartTime = &startTime
+		err := commonutil.UpdateJobConditions(&oldTFJob.Status, common.JobRunning, tfJobRunningReason, "")
+		if err != nil {
+			t.Errorf("Append tfjob condition error: %v", err)
+		}
+		curTFJob := oldTFJob.DeepCopy()
+		curTFJob.Generation = 2
+		replicas := int32(2)
+		curTFJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Replicas = &replicas
+
+		fakeClientSet := tfjobfake.NewSimpleClientset(curTFJob)
+		ctr.tfJobClientSet = fakeClientSet
+		oldUnstructured, err := testutil.ConvertTFJobToUnstructured(oldTFJob)
+		if err != nil {
+			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+		curUnstructured, err := testutil.ConvertTFJobToUnstructured(curTFJob)
+		if err != nil {
+			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+
+		ctr.updateTFJob(oldUnstructured, curUnstructured)
+
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(curTFJob.Namespace).Get(context.TODO(), curTFJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", c.description, err)
+		}
+		reset := updated.Status.StartTime.After(startTime.Time)
+		if reset != c.expectedReset {
+			t.Errorf("%s: expected start time reset %v, got start time %v", c.description, c.expectedReset, updated.Status.StartTime)
+		}
+		pastDeadline := ctr.PastActiveDeadline(&updated.Spec.RunPolicy, updated.Status)
+		if pastDeadline != c.expectedPastDeadline {
+			t.Errorf("%s: expected past deadline %v, got %v", c.description, c.expectedPastDeadline, pastDeadline)
+		}
+	}
+}
+
+func TestReconcileOnGenerationChange(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ReconcileOnGenerationChange: true})
+	ctr.Recorder = &record.FakeRecorder{}
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.Generation = 1
+	tfJob.ResourceVersion = "1"
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+
+	update := func(old, cur *tfv1.TFJob) int {
+		t.Helper()
+		oldUnstructured, err := testutil.ConvertTFJobToUnstructured(old)
+		if err != nil {
+			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+		curUnstructured, err := testutil.ConvertTFJobToUnstructured(cur)
+		if err != nil {
+			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+		ctr.updateTFJob(oldUnstructured, curUnstructured)
+		queued := ctr.WorkQueue.Len()
+		for ctr.WorkQueue.Len() > 0 {
+			key, _ := ctr.WorkQueue.Get()
+			ctr.WorkQueue.Done(key)
+			ctr.WorkQueue.Forget(key)
+		}
+		return queued
+	}
+
+	// A status only update of the reconciled generation is skipped, along
+	// with the annotations the operator reports the status in.
+	old := tfJob.DeepCopy()
+	old.Annotations = map[string]string{tfv1.ObservedGenerationAnnotation: "1"}
+	statusUpdated := old.DeepCopy()
+	statusUpdated.ResourceVersion = "2"
+	statusUpdated.Annotations[tfv1.ProgressAnnotation] = "50"
+	err := commonutil.UpdateJobConditions(&statusUpdated.Status, common.JobRunning, tfJobRunningReason, "")
+	if err != nil {
+		t.Errorf("Append tfjob condition error: %v", err)
+	}
+	if queued := update(old, statusUpdated); queued != 0 {
+		t.Errorf("Expected the status only update to be skipped, got %d queued", queued)
+	}
+
+	// The resyncs still reconcile the tfjob.
+	if queued := update(statusUpdated, statusUpdated); queued != 1 {
+		t.Errorf("Expected the resync to be queued, got %d queued", queued)
+	}
+
+	// A generation bump reconciles the tfjob.
+	specUpdated := statusUpdated.DeepCopy()
+	specUpdated.ResourceVersion = "3"
+	specUpdated.Generation = 2
+	replicas := int32(2)
+	specUpdated.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Replicas = &replicas
+	if queued := update(statusUpdated, specUpdated); queued != 1 {
+		t.Errorf("Expected the spec update to be queued, got %d queued", queued)
+	}
+}
+
+func TestObservedGenerationOnlyUpdate(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.Generation = 1
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 1, 0, 0, nil, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+
+	reconcile := func() *tfv1.TFJob {
+		t.Helper()
+		if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+			t.Fatalf("Failed to reconcile the tfjob: %v", err)
+		}
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get the tfjob: %v", err)
+		}
+		return updated
+	}
+
+	tfJob = reconcile()
+	if generation := getObservedGeneration(tfJob); generation != 1 {
+		t.Errorf("Expected the observed generation 1, got %d", generation)
+	}
+
+	// The observed generation is recorded for a new generation, even when
+	// nothing else changes.
+	tfJob.Generation = 2
+	tfJob = reconcile()
+	if generation := getObservedGeneration(tfJob); generation != 2 {
+		t.Errorf("Expected the observed generation 2, got %d", generation)
+	}
+}
+
+func TestReconcileNonce(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ReconcileOnGenerationChange: true})
+	ctr.Recorder = &record.FakeRecorder{}
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.Generation = 1
+	tfJob.ResourceVersion = "1"
+	tfJob.Annotations = map[string]string{tfv1.ObservedGenerationAnnotation: "1"}
+	key, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Fatalf("Failed to get the key of the tfjob: %v", err)
+	}
+
+	// The tfjob failed to reconcile, and is backing off.
+	ctr.WorkQueue.AddRateLimited(key)
+	if requeues := ctr.WorkQueue.NumRequeues(key); requeues != 1 {
+		t.Fatalf("Expected the tfjob to be requeued once, got %d", requeues)
+	}
+
+	kicked := tfJob.DeepCopy()
+	kicked.ResourceVersion = "2"
+	kicked.Annotations[tfv1.ReconcileNonceAnnotation] = "1"
+	oldUnstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	curUnstructured, err := testutil.ConvertTFJobToUnstructured(kicked)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	ctr.updateTFJob(oldUnstructured, curUnstructured)
+
+	if queued := ctr.WorkQueue.Len(); queued != 1 {
+		t.Fatalf("Expected the kicked tfjob to be queued, got %d queued", queued)
+	}
+	if queuedKey, _ := ctr.WorkQueue.Get(); queuedKey != key {
+		t.Errorf("Expected the key %s to be queued, got %v", key, queuedKey)
+	}
+	if requeues := ctr.WorkQueue.NumRequeues(key); requeues != 0 {
+		t.Errorf("Expected the backoff of the kicked tfjob to be reset, got %d requeues", requeues)
+	}
+}
+
 func TestBackoffForOnFailure(t *testing.T) {
 	type testCase struct {
 		description string
@@ -808,3 +1517,77 @@ func TestBackoffForOnFailure(t *testing.T) {
 		}
 	}
 }
+
+func TestUnmanagedTFJob(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeServiceControl := &control.FakeServiceControl{}
+	ctr.ServiceControl = fakeServiceControl
+	recorder := record.NewFakeRecorder(100)
+	ctr.Recorder = recorder
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.Annotations = map[string]string{tfv1.ManagedAnnotation: "false"}
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+
+	key, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Fatalf("Failed to get the key of the tfjob: %v", err)
+	}
+	if forget, err := ctr.syncTFJob(key); err != nil || !forget {
+		t.Errorf("Expected the unmanaged tfjob to be forgotten, got forget %v and error %v", forget, err)
+	}
+	if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+		t.Errorf("Failed to reconcile the unmanaged tfjob: %v", err)
+	}
+
+	if len(fakePodControl.Templates) != 0 {
+		t.Errorf("Expected no pod creations for an unmanaged tfjob, got %d", len(fakePodControl.Templates))
+	}
+	if len(fakeServiceControl.Templates) != 0 {
+		t.Errorf("Expected no service creations for an unmanaged tfjob, got %d", len(fakeServiceControl.Templates))
+	}
+	if len(recorder.Events) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(recorder.Events))
+	}
+	for i := 0; i < 2; i++ {
+		if event := <-recorder.Events; !strings.HasPrefix(event, v1.EventTypeNormal+" "+unmanagedReason) {
+			t.Errorf("Expected an %s event, got %q", unmanagedReason, event)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+)
+
+// GetReplicaTypeLabelKey returns the label key the replica type of the pods
+// is read from.
+func (tc *TFController) GetReplicaTypeLabelKey() string {
+	if tc.option.ReplicaTypeLabel != "" {
+		return tc.option.ReplicaTypeLabel
+	}
+	return tfReplicaTypeLabel
+}
+
+// GetReplicaIndexLabelKey returns the label key the replica index of the pods
+// is read from.
+func (tc *TFController) GetReplicaIndexLabelKey() string {
+	if tc.option.ReplicaIndexLabel != "" {
+		return tc.option.ReplicaIndexLabel
+	}
+	return tfReplicaIndexLabel
+}
+
+// setReplicaTypeLabel sets the replica type label of a pod, under the
+// default key, which the services of the replicas select the pods by, and
+// under the configured key.
+func (tc *TFController) setReplicaTypeLabel(labels map[string]string, rt string) {
+	labels[tfReplicaTypeLabel] = rt
+	labels[tc.GetReplicaTypeLabelKey()] = rt
+}
+
+// setReplicaIndexLabel sets the replica index label of a pod, under the
+// default key and the configured key.
+func (tc *TFController) setReplicaIndexLabel(labels map[string]string, index string) {
+	labels[tfReplicaIndexLabel] = index
+	labels[tc.GetReplicaIndexLabelKey()] = index
+}
+
+// FilterPodsForReplicaType returns the pods of the given lower case replica
+// type, read from the configured label key.
+func (tc *TFController) FilterPodsForReplicaType(pods []*v1.Pod, replicaType string) ([]*v1.Pod, error) {
+	var result []*v1.Pod
+	for _, pod := range pods {
+		if pod.Labels[tc.GetReplicaTypeLabelKey()] == replicaType {
+			result = append(result, pod)
+		}
+	}
+	return result, nil
+}
+
+// GetPodSlices returns the pods by replica index, read from the configured
+// label key. The slice is long enough for the given number of replicas and
+// for the highest index of the pods.
+func (tc *TFController) GetPodSlices(pods []*v1.Pod, replicas int, logger *log.Entry) [][]*v1.Pod {
+	indexes := make(map[*v1.Pod]int, len(pods))
+	size := replicas
+	for _, pod := range pods {
+		label, ok := pod.Labels[tc.GetReplicaIndexLabelKey()]
+		if !ok {
+			logger.Warning("The pod do not have the index label.")
+			continue
+		}
+		index, err := strconv.Atoi(label)
+		if err != nil {
+			logger.Warningf("Error when strconv.Atoi: %v", err)
+			continue
+		}
+		if index < 0 || index >= replicas {
+			logger.Warningf("The label index is not expected: %d, pod: %s/%s", index, pod.Namespace, pod.Name)
+		}
+		if index < 0 {
+			continue
+		}
+		indexes[pod] = index
+		if index >= size {
+			size = index + 1
+		}
+	}
+	podSlices := make([][]*v1.Pod, size)
+	for _, pod := range pods {
+		if index, ok := indexes[pod]; ok {
+			podSlices[index] = append(podSlices[index], pod)
+		}
+	}
+	return podSlices
+}
@@ -0,0 +1,139 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// genClusterMembers returns the value of the ClusterMembers annotation of the
+// pods of the tfjob, the JSON of its cluster spec.
+func genClusterMembers(tfjob *tfv1.TFJob) (string, error) {
+	cluster, err := genClusterSpec(tfjob)
+	if err != nil {
+		return "", err
+	}
+	// The keys of the map are sorted, so that the value only changes with
+	// the members.
+	members, err := json.Marshal(cluster)
+	if err != nil {
+		return "", err
+	}
+	return string(members), nil
+}
+
+// setClusterMembersAnnotation sets the ClusterMembers annotation of the pod
+// template.
+func setClusterMembersAnnotation(podTemplate *v1.PodTemplateSpec, members string) {
+	if podTemplate.Annotations == nil {
+		podTemplate.Annotations = map[string]string{}
+	}
+	podTemplate.Annotations[tfv1.ClusterMembersAnnotation] = members
+}
+
+// syncClusterMembers updates the ClusterMembers annotation of the pods of the
+// tfjob which were created before its members changed, e.g. by a scaling.
+func (tc *TFController) syncClusterMembers(tfJob *tfv1.TFJob, pods []*v1.Pod) error {
+	if !tc.option.ClusterMembersAnnotation {
+		return nil
+	}
+	members, err := genClusterMembers(tfJob)
+	if err != nil {
+		return err
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": map[string]string{tfv1.ClusterMembersAnnotation: members},
+		},
+	})
+	if err != nil {
+		return err
+	}
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Annotations[tfv1.ClusterMembersAnnotation] == members {
+			continue
+		}
+		if _, err := tc.KubeClientSet.CoreV1().Pods(pod.Namespace).Patch(
+			context.TODO(), pod.Name, types.MergePatchType, patch, metav1.PatchOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// inReplicaRange returns true if the index is one of the replicas of the
+// given type in the tfjob.
+func inReplicaRange(tfJob *tfv1.TFJob, rt, index string) bool {
+	i, err := strconv.Atoi(index)
+	if err != nil {
+		return false
+	}
+	for rtype, spec := range tfJob.Spec.TFReplicaSpecs {
+		if strings.EqualFold(string(rtype), rt) {
+			return spec.Replicas != nil && i >= 0 && i < int(*spec.Replicas)
+		}
+	}
+	return false
+}
+
+// syncTFConfigFiles patches the TF_CONFIG annotation of the pods of the tfjob
+// which were created before its cluster spec changed, e.g. by a scaling. The
+// annotation is projected into the TF_CONFIG file of the pods, so that the
+// running replicas see the new members without being recreated.
+func (tc *TFController) syncTFConfigFiles(tfJob *tfv1.TFJob, pods []*v1.Pod) error {
+	if !tc.option.PatchTFConfig || !isDistributed(tfJob) {
+		return nil
+	}
+	for _, pod := range pods {
+		current, ok := pod.Annotations[tfConfigAnnotation]
+		if !ok || pod.DeletionTimestamp != nil {
+			continue
+		}
+		// The pods out of range are about to be deleted by the scaling.
+		rt, index := pod.Labels[tc.GetReplicaTypeLabelKey()], pod.Labels[tc.GetReplicaIndexLabelKey()]
+		if !inReplicaRange(tfJob, rt, index) {
+			continue
+		}
+		tfConfigStr, err := tc.genTFConfig(tfJob, rt, index)
+		if err != nil {
+			return err
+		}
+		if tfConfigStr == current {
+			continue
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"annotations": map[string]string{tfConfigAnnotation: tfConfigStr},
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if err := tc.PodControl.PatchPod(pod.Namespace, pod.Name, patch); err != nil {
+			return err
+		}
+		commonutil.LoggerForReplica(tfJob, rt).Infof("Patched the TF_CONFIG of pod %s/%s", pod.Namespace, pod.Name)
+	}
+	return nil
+}
@@ -0,0 +1,213 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+)
+
+const (
+	// tfJobVolumeMountFailed is the condition of a tfjob which has pods stuck
+	// in ContainerCreating because their volumes fail to mount.
+	tfJobVolumeMountFailed commonv1.JobConditionType = "VolumeMountFailed"
+	// volumeMountFailedReason is the reason of the VolumeMountFailed
+	// condition, and the warning reason of the event emitted when it is set.
+	volumeMountFailedReason = "VolumeMountFailed"
+	// failedMountReason is the reason of the events emitted by the kubelet
+	// when it fails to mount a volume of a pod.
+	failedMountReason = "FailedMount"
+	// containerCreatingReason is the reason of a container waiting for the
+	// kubelet to set up its pod.
+	containerCreatingReason = "ContainerCreating"
+	// recreatingStuckPodReason is the normal reason when a pod stuck mounting
+	// its volumes is recreated.
+	recreatingStuckPodReason = "RecreatingStuckPod"
+)
+
+// containerCreatingSince returns the time the pod was scheduled at if its
+// containers are still being created, or nil.
+func containerCreatingSince(pod *v1.Pod) *metav1.Time {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodPending {
+		return nil
+	}
+	var scheduled *metav1.Time
+	for i := range pod.Status.Conditions {
+		condition := &pod.Status.Conditions[i]
+		if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionTrue {
+			scheduled = &condition.LastTransitionTime
+		}
+	}
+	if scheduled == nil {
+		return nil
+	}
+	for _, statuses := range [][]v1.ContainerStatus{pod.Status.InitContainerStatuses, pod.Status.ContainerStatuses} {
+		for _, status := range statuses {
+			if status.State.Waiting != nil && status.State.Waiting.Reason == containerCreatingReason {
+				return scheduled
+			}
+		}
+	}
+	return nil
+}
+
+// hasFailedMountEvent returns true if the kubelet reported that it failed to
+// mount a volume of the pod.
+func (tc *TFController) hasFailedMountEvent(pod *v1.Pod) (bool, error) {
+	selector := fields.Set{
+		"involvedObject.kind": "Pod",
+		"involvedObject.name": pod.Name,
+		"reason":              failedMountReason,
+	}.AsSelector().String()
+	events, err := tc.KubeClientSet.CoreV1().Events(pod.Namespace).List(context.TODO(), metav1.ListOptions{FieldSelector: selector})
+	if err != nil {
+		return false, err
+	}
+	for _, event := range events.Items {
+		// Events of a former pod of the same name do not count.
+		if event.InvolvedObject.Name == pod.Name && event.Reason == failedMountReason &&
+			(event.InvolvedObject.UID == "" || event.InvolvedObject.UID == pod.UID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// reconcileStuckPods sets the VolumeMountFailed condition of the tfjob when
+// some of its pods stay in ContainerCreating past the timeout because their
+// volumes fail to mount, and recreates them within the retry budget. Such
+// pods neither run nor fail, and would otherwise leave the tfjob pending.
+func (tc *TFController) reconcileStuckPods(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, pods []*v1.Pod) error {
+	timeout := tc.option.VolumeMountTimeout
+	if timeout <= 0 {
+		return nil
+	}
+
+	var stuck []*v1.Pod
+	var requeueAfter time.Duration
+	for _, pod := range pods {
+		since := containerCreatingSince(pod)
+		if since == nil {
+			continue
+		}
+		// Pods may not change while they are stuck, check them again once
+		// they are past the timeout.
+		if remaining := time.Until(since.Add(timeout)); remaining > 0 {
+			if requeueAfter == 0 || remaining < requeueAfter {
+				requeueAfter = remaining
+			}
+			continue
+		}
+		failedMount, err := tc.hasFailedMountEvent(pod)
+		if err != nil {
+			return err
+		}
+		if failedMount {
+			stuck = append(stuck, pod)
+		}
+	}
+	if requeueAfter > 0 {
+		key, err := KeyFunc(tfJob)
+		if err != nil {
+			return err
+		}
+		tc.WorkQueue.AddAfter(key, requeueAfter)
+	}
+
+	if len(stuck) == 0 {
+		clearVolumeMountFailed(jobStatus)
+		return nil
+	}
+
+	recreations, _ := strconv.Atoi(tfJob.Annotations[tfv1.VolumeMountRecreationsAnnotation])
+	for _, pod := range stuck {
+		if recreations >= tc.option.VolumeMountRetries {
+			break
+		}
+		// The recreation is recorded first, so that the budget holds even if
+		// the deletion fails.
+		recreations++
+		if err := tc.patchTFJobAnnotation(tfJob, tfv1.VolumeMountRecreationsAnnotation, strconv.Itoa(recreations)); err != nil {
+			return err
+		}
+		cause := fmt.Sprintf("stuck mounting its volumes for more than %v, to be recreated", timeout)
+		if err := tc.deletePod(tfJob, pod, recreatingStuckPodReason, cause); err != nil {
+			return err
+		}
+	}
+
+	names := make([]string, 0, len(stuck))
+	for _, pod := range stuck {
+		names = append(names, pod.Name)
+	}
+	msg := fmt.Sprintf("TFJob %s/%s has pods stuck mounting their volumes for more than %v: %s",
+		tfJob.Namespace, tfJob.Name, timeout, strings.Join(names, ", "))
+	tc.setVolumeMountFailed(tfJob, jobStatus, msg)
+	return nil
+}
+
+// setVolumeMountFailed sets the VolumeMountFailed condition of the tfjob, and
+// emits an event when the condition becomes true.
+func (tc *TFController) setVolumeMountFailed(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, msg string) {
+	now := metav1.Now()
+	condition := commonv1.JobCondition{
+		Type:               tfJobVolumeMountFailed,
+		Status:             v1.ConditionTrue,
+		Reason:             volumeMountFailedReason,
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	}
+	// The conditions are copied, as they may be shared with the tfjob.
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)+1)
+	for _, c := range jobStatus.Conditions {
+		if c.Type != tfJobVolumeMountFailed {
+			conditions = append(conditions, c)
+			continue
+		}
+		if c.Message == msg {
+			// The same pods are still stuck.
+			return
+		}
+		condition.LastTransitionTime = c.LastTransitionTime
+	}
+	if condition.LastTransitionTime == now {
+		commonutil.LoggerForJob(tfJob).Warn(msg)
+		tc.Recorder.Event(tfJob, v1.EventTypeWarning, volumeMountFailedReason, msg)
+	}
+	jobStatus.Conditions = append(conditions, condition)
+}
+
+// clearVolumeMountFailed removes the VolumeMountFailed condition of the tfjob.
+func clearVolumeMountFailed(jobStatus *commonv1.JobStatus) {
+	for i, condition := range jobStatus.Conditions {
+		if condition.Type == tfJobVolumeMountFailed {
+			conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)-1)
+			conditions = append(conditions, jobStatus.Conditions[:i]...)
+			jobStatus.Conditions = append(conditions, jobStatus.Conditions[i+1:]...)
+			return
+		}
+	}
+}
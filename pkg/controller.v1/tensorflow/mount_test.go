@@ -0,0 +1,150 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubefake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestVolumeMountFailed(t *testing.T) {
+	tfJob := testutil.NewTFJob(3, 0)
+
+	// worker-0 and worker-1 are stuck in ContainerCreating, but only worker-0
+	// failed to mount its volumes. worker-2 was scheduled recently.
+	newContainerCreatingPod := func(index int, scheduled time.Time) *v1.Pod {
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, index)
+		pod.Status.Phase = v1.PodPending
+		pod.Status.Conditions = []v1.PodCondition{{
+			Type:               v1.PodScheduled,
+			Status:             v1.ConditionTrue,
+			LastTransitionTime: metav1.NewTime(scheduled),
+		}}
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name:  tfv1.DefaultContainerName,
+			State: v1.ContainerState{Waiting: &v1.ContainerStateWaiting{Reason: containerCreatingReason}},
+		}}
+		return pod
+	}
+	pods := []*v1.Pod{
+		newContainerCreatingPod(0, time.Now().Add(-time.Hour)),
+		newContainerCreatingPod(1, time.Now().Add(-time.Hour)),
+		newContainerCreatingPod(2, time.Now()),
+	}
+	kubeClientSet := kubefake.NewSimpleClientset(&v1.Event{
+		ObjectMeta: metav1.ObjectMeta{Name: pods[0].Name + ".failedmount", Namespace: tfJob.Namespace},
+		InvolvedObject: v1.ObjectReference{
+			Kind:      "Pod",
+			Name:      pods[0].Name,
+			Namespace: tfJob.Namespace,
+		},
+		Reason:  failedMountReason,
+		Message: `MountVolume.SetUp failed for volume "data": persistentvolumeclaim "data" not found`,
+		Type:    v1.EventTypeWarning,
+	})
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{
+			VolumeMountTimeout: 10 * time.Minute,
+			VolumeMountRetries: 1,
+		})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	recorder := record.NewFakeRecorder(100)
+	ctr.Recorder = recorder
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+	for _, pod := range pods {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+	}
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 3, t)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	condition := tfv1.GetCondition(tfJob.Status, tfJobVolumeMountFailed)
+	if condition == nil || condition.Status != v1.ConditionTrue {
+		t.Fatalf("Expected condition %s, got %v", tfJobVolumeMountFailed, tfJob.Status.Conditions)
+	}
+	if !strings.Contains(condition.Message, pods[0].Name) || strings.Contains(condition.Message, pods[1].Name) {
+		t.Errorf("Expected only pod %s to be reported stuck, got %q", pods[0].Name, condition.Message)
+	}
+	found := false
+	for len(recorder.Events) > 0 {
+		if strings.HasPrefix(<-recorder.Events, v1.EventTypeWarning+" "+volumeMountFailedReason) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a %s event", volumeMountFailedReason)
+	}
+
+	// The stuck pod is recreated once, within the retry budget.
+	expectedDeletePods := []string{pods[0].Name}
+	if !reflect.DeepEqual(expectedDeletePods, fakePodControl.DeletePodName) {
+		t.Errorf("Expected pods %v to be deleted, got %v", expectedDeletePods, fakePodControl.DeletePodName)
+	}
+	updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	if got := updated.Annotations[tfv1.VolumeMountRecreationsAnnotation]; got != "1" {
+		t.Errorf("Expected the %s annotation to be 1, got %q", tfv1.VolumeMountRecreationsAnnotation, got)
+	}
+
+	// The retry budget is exhausted, the pod is not recreated again.
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	if !reflect.DeepEqual(expectedDeletePods, fakePodControl.DeletePodName) {
+		t.Errorf("Expected pods %v to be deleted, got %v", expectedDeletePods, fakePodControl.DeletePodName)
+	}
+}
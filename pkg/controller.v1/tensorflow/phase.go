@@ -0,0 +1,103 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"sync"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Phases of a tfjob, as reported by tfJobsByPhase.
+const (
+	tfJobPhasePending   = "pending"
+	tfJobPhaseRunning   = "running"
+	tfJobPhaseSucceeded = "succeeded"
+	tfJobPhaseFailed    = "failed"
+)
+
+var (
+	tfJobsByPhase = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "tfjob_jobs",
+			Help: "Number of TF jobs in each phase",
+		},
+		[]string{"phase"},
+	)
+
+	// tfJobPhases tallies the phases of the tfjobs reported by tfJobsByPhase.
+	tfJobPhases = newPhaseTally(tfJobsByPhase)
+)
+
+// getPhase returns the phase of a tfjob with the given status.
+func getPhase(jobStatus commonv1.JobStatus) string {
+	switch {
+	case commonutil.IsFailed(jobStatus):
+		return tfJobPhaseFailed
+	case commonutil.IsSucceeded(jobStatus):
+		return tfJobPhaseSucceeded
+	case tfv1.IsConditionTrue(jobStatus, commonv1.JobRunning),
+		tfv1.IsConditionTrue(jobStatus, commonv1.JobRestarting):
+		return tfJobPhaseRunning
+	default:
+		return tfJobPhasePending
+	}
+}
+
+// phaseTally keeps the phase of every tfjob by key, and the number of tfjobs
+// in each phase in a gauge.
+type phaseTally struct {
+	mu     sync.Mutex
+	phases map[string]string
+	gauge  *prometheus.GaugeVec
+}
+
+func newPhaseTally(gauge *prometheus.GaugeVec) *phaseTally {
+	for _, phase := range []string{tfJobPhasePending, tfJobPhaseRunning, tfJobPhaseSucceeded, tfJobPhaseFailed} {
+		gauge.WithLabelValues(phase).Set(0)
+	}
+	return &phaseTally{phases: map[string]string{}, gauge: gauge}
+}
+
+// set moves the tfjob with the given key to the phase.
+func (p *phaseTally) set(key, phase string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old, ok := p.phases[key]
+	if ok && old == phase {
+		return
+	}
+	if ok {
+		p.gauge.WithLabelValues(old).Dec()
+	}
+	p.phases[key] = phase
+	p.gauge.WithLabelValues(phase).Inc()
+}
+
+// delete drops the deleted tfjob with the given key from its phase.
+func (p *phaseTally) delete(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	old, ok := p.phases[key]
+	if !ok {
+		return
+	}
+	delete(p.phases, key)
+	p.gauge.WithLabelValues(old).Dec()
+}
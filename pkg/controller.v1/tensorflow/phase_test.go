@@ -0,0 +1,112 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+	"github.com/prometheus/client_golang/prometheus"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestJobsByPhase(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	// Count the phases of this test only.
+	gauge := prometheus.NewGaugeVec(prometheus.GaugeOpts{Name: "test_tfjob_jobs"}, []string{"phase"})
+	defer func(phases *phaseTally) { tfJobPhases = phases }(tfJobPhases)
+	tfJobPhases = newPhaseTally(gauge)
+
+	tfJob := testutil.NewTFJob(1, 0)
+	otherTFJob := tfJob.DeepCopy()
+	otherTFJob.Name = "other-tfjob"
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob, otherTFJob)
+
+	reconcile := func(tfJob *tfv1.TFJob) {
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	}
+	check := func(step string, expected map[string]float64) {
+		for _, phase := range []string{tfJobPhasePending, tfJobPhaseRunning, tfJobPhaseSucceeded, tfJobPhaseFailed} {
+			if got := promtestutil.ToFloat64(gauge.WithLabelValues(phase)); got != expected[phase] {
+				t.Errorf("%s: expected %v %s jobs, got %v", step, expected[phase], phase, got)
+			}
+		}
+	}
+
+	// Both jobs are pending until their pods run.
+	reconcile(tfJob)
+	reconcile(otherTFJob)
+	check("created", map[string]float64{tfJobPhasePending: 2})
+
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 1, 0, 0, nil, t)
+	reconcile(tfJob)
+	check("running", map[string]float64{tfJobPhasePending: 1, tfJobPhaseRunning: 1})
+
+	if err := podIndexer.Replace(nil, ""); err != nil {
+		t.Fatalf("Failed to clear the pods: %v", err)
+	}
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 0, 1, 0, nil, t)
+	reconcile(tfJob)
+	check("succeeded", map[string]float64{tfJobPhasePending: 1, tfJobPhaseSucceeded: 1})
+
+	// A deleted job is not counted anymore.
+	if _, err := ctr.syncTFJob(testutil.GetKey(tfJob, t)); err != nil {
+		t.Fatalf("Failed to sync the deleted tfjob: %v", err)
+	}
+	check("deleted", map[string]float64{tfJobPhasePending: 1})
+}
@@ -16,16 +16,25 @@
 package tensorflow
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"net"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
 	"github.com/kubeflow/tf-operator/pkg/common/util"
 
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
 
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
 	"github.com/kubeflow/common/pkg/controller.v1/common"
@@ -40,6 +49,15 @@ import (
 const (
 	// gang scheduler name.
 	gangSchedulerName = "volcano"
+	// horovodRankEnv and horovodSizeEnv are the environment variables holding
+	// the rank of the replica among the chief and workers, and their number.
+	horovodRankEnv = "HOROVOD_RANK"
+	horovodSizeEnv = "HOROVOD_SIZE"
+	// tfJobUIDEnv, tfJobNameEnv and tfJobNamespaceEnv are the environment
+	// variables holding the metadata of the tfjob of the pod.
+	tfJobUIDEnv       = "TFJOB_UID"
+	tfJobNameEnv      = "TFJOB_NAME"
+	tfJobNamespaceEnv = "TFJOB_NAMESPACE"
 	// tfConfig is the environment variable name of TensorFlow cluster spec.
 	tfConfig = "TF_CONFIG"
 	// exitedWithCodeReason is the normal reason when the pod is exited because of the exit code.
@@ -52,6 +70,37 @@ const (
 	podTemplateSchedulerNameReason = "SettedPodTemplateSchedulerName"
 	// gangSchedulingPodGroupAnnotation is the annotation key used by batch schedulers
 	gangSchedulingPodGroupAnnotation = "scheduling.k8s.io/group-name"
+	// repairedOwnerReferenceReason is the normal reason when the controller reference
+	// of a pod labeled for the tfjob is patched back.
+	repairedOwnerReferenceReason = "RepairedOwnerReference"
+	// deletedStalePodReason is the normal reason when a pod labeled for the
+	// tfjob, but controlled by a previous tfjob of the same name, is deleted.
+	deletedStalePodReason = "DeletedStalePod"
+	// promotedStandbyReason is the normal reason when a standby pod is promoted
+	// to replace a replica.
+	promotedStandbyReason = "PromotedStandbyPod"
+	// failedResolveImageReason is the warning reason when the image resolver
+	// fails to resolve the image of a container.
+	failedResolveImageReason = "FailedResolveImage"
+	// oomKilledReason is the reason of a container terminated by the OOM killer.
+	oomKilledReason = "OOMKilled"
+	// evictedReason is the reason of a pod evicted by the kubelet.
+	evictedReason = "Evicted"
+	// recreatingEvictedPodReason is the normal reason when an evicted pod is
+	// deleted, to be recreated.
+	recreatingEvictedPodReason = "RecreatingEvictedPod"
+	// deletedPodReason is the normal reason when the controller deletes a pod,
+	// unless the cause of the deletion has a reason of its own.
+	deletedPodReason = "DeletedPod"
+	// scaleDownRejectedReason is the warning reason when the pods past the
+	// lowered replicas of a tfjob without dynamic workers are kept.
+	scaleDownRejectedReason = "ScaleDownRejected"
+	// waitForPSContainerName is the name of the init container of workers
+	// which waits for the DNS names of the PS to resolve.
+	waitForPSContainerName = "wait-for-ps"
+	// waitForPSScript resolves the hosts given as arguments one after the
+	// other, retrying until each of them resolves.
+	waitForPSScript = `for host in "$@"; do until nslookup "$host" >/dev/null 2>&1; do echo "waiting for $host"; sleep 2; done; done`
 )
 
 var (
@@ -64,8 +113,12 @@ var (
 	)
 )
 
-// reconcilePods checks and updates pods for each given TFReplicaSpec.
+// ReconcilePods checks and updates pods for each given TFReplicaSpec.
 // It will requeue the tfjob in case of an error while creating/deleting pods.
+// Within a reconcile pass, the services of the replica type are reconciled
+// first, so that the service of a new index exists before its pod starts to
+// resolve the cluster spec, and the creations are limited by the budgets of
+// the pass.
 func (tc *TFController) ReconcilePods(
 	job interface{},
 	jobStatus *commonv1.JobStatus,
@@ -74,6 +127,44 @@ func (tc *TFController) ReconcilePods(
 	spec *commonv1.ReplicaSpec,
 	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec,
 ) error {
+	tfJob, ok := job.(*tfv1.TFJob)
+	if !ok {
+		return fmt.Errorf("%v is not a type of TFJob", job)
+	}
+	pass := tc.getReconcilePass(tfJob)
+	if pass == nil {
+		return tc.reconcilePods(context.Background(), job, jobStatus, pods, rtype, spec, replicas, nil)
+	}
+
+	if err := tc.reconcileServices(tfJob, pass.services, rtype, spec, pass.serviceBudget); err != nil {
+		commonutil.LoggerForJob(tfJob).Warnf("ReconcileServices error %v", err)
+		return err
+	}
+
+	err := tc.reconcilePods(pass.ctx, job, jobStatus, pods, rtype, spec, replicas, pass.podBudget)
+	if isQuotaExceeded(err) {
+		// No more pods are created until the quota backoff elapses.
+		tc.setQuotaExceeded(tfJob, jobStatus, err)
+		pass.quotaBackoff = tc.quotaExceededBackoff()
+		pass.podBudget = &createBudget{}
+		return nil
+	}
+	return err
+}
+
+// reconcilePods is ReconcilePods which creates at most as many pods as the
+// given budget allows. A nil budget does not limit the creations. The context
+// is carried down to the creation of the pods.
+func (tc *TFController) reconcilePods(
+	ctx context.Context,
+	job interface{},
+	jobStatus *commonv1.JobStatus,
+	pods []*v1.Pod,
+	rtype commonv1.ReplicaType,
+	spec *commonv1.ReplicaSpec,
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec,
+	budget *createBudget,
+) error {
 
 	tfJob, ok := job.(*tfv1.TFJob)
 	if !ok {
@@ -82,12 +173,34 @@ func (tc *TFController) ReconcilePods(
 
 	// Convert ReplicaType to lower string.
 	rt := strings.ToLower(string(rtype))
-	logger := commonutil.LoggerForJob(tfJob)
+	logger := traceLogger(ctx, commonutil.LoggerForJob(tfJob))
+	// The workers are only created a while after all the PS, so that the
+	// servers bind before the workers connect.
+	holdWorkers := false
+	if rtype == tfv1.TFReplicaTypeWorker {
+		hold, remaining, err := tc.workerStartDelayRemaining(tfJob, pods, replicas)
+		if err != nil {
+			return err
+		}
+		if remaining > 0 {
+			logger.Infof("Deferring the creation of the workers for %v after the PS", remaining)
+			tfJobKey, err := KeyFunc(tfJob)
+			if err != nil {
+				return err
+			}
+			tc.WorkQueue.AddAfter(tfJobKey, remaining)
+		}
+		holdWorkers = hold
+	}
 	// Get all pods for the type rt.
 	pods, err := tc.FilterPodsForReplicaType(pods, rt)
 	if err != nil {
 		return err
 	}
+	// Standby pods are not replicas until they are promoted.
+	replicaPods, standbyPods := splitStandbyPods(pods)
+	// The failed PS are left alone while enough PS are healthy.
+	toleratePSFailures := rtype == tfv1.TFReplicaTypePS && tc.psFailuresTolerated(tfJob, replicaPods)
 	numReplicas := int(*spec.Replicas)
 	masterRole := false
 	//restart := false
@@ -95,52 +208,190 @@ func (tc *TFController) ReconcilePods(
 
 	initializeReplicaStatuses(jobStatus, rtype)
 
+	// Stop the replicas which have run past their deadline, and make sure the
+	// tfjob is synced again when the deadline passes.
+	if exceeded, remaining := replicaDeadlineExceeded(tfJob, jobStatus, rtype); exceeded {
+		return tc.stopReplicasPastDeadline(tfJob, jobStatus, pods, rtype)
+	} else if remaining > 0 {
+		tfJobKey, err := KeyFunc(tfJob)
+		if err != nil {
+			return err
+		}
+		tc.WorkQueue.AddAfter(tfJobKey, remaining)
+	}
+
 	// GetPodSlices will return enough information here to make decision to add/remove/update resources.
 	//
 	// For example, let's assume we have pods with replica-index 0, 1, 2
 	// If replica is 4, return a slice with size 4. [[0],[1],[2],[]], a pod with replica-index 3 will be created.
 	//
 	// If replica is 1, return a slice with size 3. [[0],[1],[2]], pod with replica-index 1 and 2 are out of range and will be deleted.
-	podSlices := tc.GetPodSlices(pods, numReplicas, logger)
+	podSlices := tc.GetPodSlices(replicaPods, numReplicas, logger)
+	// Scaling the replicas of a tfjob with dynamic workers waits for the
+	// cooldown of its last scaling, so that quick edits do not thrash the pods.
+	lastIndex := lastReplicaIndex(podSlices)
+	deferScaling, scaled := false, false
+	keptPastReplicas := 0
+	if isScaling(podSlices, numReplicas) {
+		if remaining := tc.scaleCooldownRemaining(tfJob); remaining > 0 {
+			logger.Infof("Deferring the scaling of %s for %v", rt, remaining)
+			tfJobKey, err := KeyFunc(tfJob)
+			if err != nil {
+				return err
+			}
+			tc.WorkQueue.AddAfter(tfJobKey, remaining)
+			deferScaling = true
+		}
+	}
+	// quotaErr stops the creation of pods once the namespace quota is exceeded,
+	// while the status of the existing pods is still counted.
+	var quotaErr error
 	for index, podSlice := range podSlices {
 		if len(podSlice) > 1 {
 			logger.Warningf("We have too many pods for %s %d", rt, index)
 		} else if len(podSlice) == 0 {
-			logger.Infof("Need to create new pod: %s-%d", rt, index)
-
 			// check if this replica is the master role
 			masterRole = tc.IsMasterRole(replicas, rtype, index)
+			scaleUp := lastIndex >= 0 && index > lastIndex
+			if (scaleUp && deferScaling) || holdWorkers {
+				continue
+			}
+
+			// Promote a standby pod if there is one, instead of creating a new pod.
+			if standby := getAvailableStandbyPod(standbyPods); standby != nil {
+				if err := tc.promoteStandbyPod(tfJob, standby, rt, strconv.Itoa(index), masterRole); err != nil {
+					return err
+				}
+				standbyPods = removePod(standbyPods, standby)
+				scaled = scaled || scaleUp
+				continue
+			}
+
+			if quotaErr != nil {
+				continue
+			}
+			if !budget.take() {
+				logger.Infof("Create batch size reached, deferring pod %s-%d", rt, index)
+				continue
+			}
+			logger.Infof("Need to create new pod: %s-%d", rt, index)
+
 			// TODO: [should change to CreateNewPod]
-			err = tc.createNewPod(tfJob, rt, strconv.Itoa(index), spec, masterRole, replicas)
-			if err != nil {
+			err = tc.createNewPod(ctx, tfJob, rt, strconv.Itoa(index), spec, masterRole, replicas)
+			if isQuotaExceeded(err) {
+				logger.Infof("Quota exceeded, deferring pod %s-%d: %v", rt, index, err)
+				quotaErr = err
+				continue
+			} else if err != nil {
 				return err
 			}
+			scaled = scaled || scaleUp
 		} else {
 			// Check the status of the current pod.
 			pod := podSlice[0]
 
 			// check if the index is in the valid range, if not, we should kill the pod
-			if index < 0 || index >= numReplicas {
-				err = tc.PodControl.DeletePod(pod.Namespace, pod.Name, tfJob)
+			// together with its service, so that no stale DNS entry is left behind.
+			// The pods past the replicas of a tfjob which may not scale down are kept.
+			if index >= numReplicas && tc.keepsPodsPastReplicas(tfJob) {
+				keptPastReplicas++
+			} else if (index < 0 || index >= numReplicas) && !deferScaling {
+				err = tc.deletePod(tfJob, pod, deletedPodReason, fmt.Sprintf("scaled down to %d replicas", numReplicas))
 				if err != nil {
 					return err
 				}
+				// Pod and service have the same name, thus the service could be deleted using pod's name.
+				err = tc.ServiceControl.DeleteService(pod.Namespace, pod.Name, tfJob)
+				if err != nil {
+					return err
+				}
+				scaled = true
+			}
+			// Evictions are infrastructure events, not failures of the training.
+			// The evicted pod is deleted, and recreated by the next reconcile.
+			if tc.option.RecreateEvictedPods && isEvictedPod(pod) {
+				if index >= 0 && index < numReplicas && pod.DeletionTimestamp == nil {
+					logger.Infof("Need to recreate the evicted pod: %v.%v", pod.Namespace, pod.Name)
+					if err := tc.deletePod(tfJob, pod, recreatingEvictedPodReason, "evicted, to be recreated: "+pod.Status.Message); err != nil {
+						return err
+					}
+				}
+				continue
 			}
 			// Get the exit code of the container.
 			var exitCode int32 = 0xbeef // magic number
+			oomKilled := false
 			for _, status := range pod.Status.ContainerStatuses {
 				state := status.State
 				if status.Name == tc.GetDefaultContainerName() && state.Terminated != nil {
 					exitCode = state.Terminated.ExitCode
+					oomKilled = state.Terminated.Reason == oomKilledReason
 					logger.Infof("Pod: %v.%v exited with code %v", pod.Namespace, pod.Name, exitCode)
 					tc.Recorder.Eventf(tfJob, v1.EventTypeNormal, exitedWithCodeReason, "Pod: %v.%v exited with code %v", pod.Namespace, pod.Name, exitCode)
 				}
 			}
+			// A failed sidecar may carry the exit code of the replica.
+			if tc.option.ExitCodeContainers == options.ExitCodeContainersAll && (exitCode == 0 || exitCode == 0xbeef) {
+				for _, status := range pod.Status.ContainerStatuses {
+					if status.State.Terminated != nil && status.State.Terminated.ExitCode != 0 {
+						exitCode = status.State.Terminated.ExitCode
+						break
+					}
+				}
+			}
+			// A pod failed before its containers terminated, e.g. rejected by the
+			// kubelet on admission, has no exit code, and may have no container
+			// statuses at all. It is a generic failure, retried like a
+			// retryable exit code.
+			noExitCode := exitCode == 0xbeef
+			phase := tc.getPodPhase(pod)
+			// A failed PS is neither recreated nor counted as failed while at
+			// least PSMinAvailable PS are healthy.
+			if toleratePSFailures && phase == v1.PodFailed {
+				logger.Infof("Tolerating the failed PS %v.%v, enough PS are healthy", pod.Namespace, pod.Name)
+				continue
+			}
+			// A failed PS is recreated, and not counted as failed, if the
+			// PS failure policy says so.
+			if rtype == tfv1.TFReplicaTypePS && getPSFailurePolicy(tfJob) == tfv1.PSFailurePolicyRestart && phase == v1.PodFailed {
+				if pod.DeletionTimestamp == nil && pastRestartLimit(tfJob, rtype) {
+					return tc.failPastRestartLimit(tfJob, jobStatus, rtype, pod)
+				}
+				logger.Infof("Need to restart the failed PS: %v.%v", pod.Namespace, pod.Name)
+				if err := tc.restartFailedPS(tfJob, jobStatus, pod); err != nil {
+					return err
+				}
+				continue
+			}
+			// A chief being deleted to restart all the replicas does not
+			// count as failed.
+			if tfv1.IsChieforMaster(rtype) && getChiefFailurePolicy(tfJob) == tfv1.ChiefFailurePolicyRestartAll &&
+				phase == v1.PodFailed && pod.DeletionTimestamp != nil {
+				continue
+			}
 			// Check if the pod is retryable.
 			if spec.RestartPolicy == commonv1.RestartPolicyExitCode {
-				if pod.Status.Phase == v1.PodFailed && train_util.IsRetryableExitCode(exitCode) {
+				if phase == v1.PodFailed && (noExitCode || train_util.IsRetryableExitCode(exitCode)) {
+					// A pod failing past the restart limit of its replica type
+					// fails the tfjob. Otherwise its recreation is recorded
+					// first, so that the limit holds even if the deletion fails.
+					if pod.DeletionTimestamp == nil {
+						if oomKilled {
+							tc.recordOOMKilled(tfJob, pod)
+						}
+						if pastRestartLimit(tfJob, rtype) {
+							return tc.failPastRestartLimit(tfJob, jobStatus, rtype, pod)
+						}
+						if err := tc.recordReplicaRecreation(tfJob, rtype); err != nil {
+							return err
+						}
+					}
 					logger.Infof("Need to restart the pod: %v.%v", pod.Namespace, pod.Name)
-					if err := tc.PodControl.DeletePod(pod.Namespace, pod.Name, tfJob); err != nil {
+					cause := fmt.Sprintf("exited with retryable code %d", exitCode)
+					if noExitCode {
+						cause = fmt.Sprintf("failed without an exit code: %s %s", pod.Status.Reason, pod.Status.Message)
+					}
+					if err := tc.deletePod(tfJob, pod, deletedPodReason, strings.TrimSpace(cause)); err != nil {
 						return err
 					}
 
@@ -158,15 +409,243 @@ func (tc *TFController) ReconcilePods(
 				}
 			}
 
-			updateJobReplicaStatuses(jobStatus, rtype, pod)
+			// The kubelet restarts the containers in place when the ExitCode restart
+			// policy is mapped to OnFailure, so the pod never fails on a permanent
+			// error. Count the replica as failed instead.
+			if spec.RestartPolicy == commonv1.RestartPolicyExitCode &&
+				restartsInPlaceOnExitCode(getReplicaPolicy(tfJob, rtype)) &&
+				phase != v1.PodSucceeded && isPermanentlyFailedInPlace(pod) {
+				logger.Infof("Pod %v.%v failed permanently", pod.Namespace, pod.Name)
+				jobStatus.ReplicaStatuses[rtype].Failed++
+				continue
+			}
+
+			// Running pods which are not ready yet are not counted as active
+			// if the operator is configured to do so.
+			if tc.option.ActiveRequiresPodReady && pod.Status.Phase == v1.PodRunning && !isPodReady(pod) {
+				continue
+			}
+			// Neither are running pods which have not been up for the min
+			// ready period yet.
+			if tc.minReadyRemaining(pod) > 0 {
+				continue
+			}
+			updateJobReplicaStatusesForPhase(jobStatus, rtype, phase)
 		}
 	}
+	if scaled {
+		if err := tc.recordScaleTime(tfJob); err != nil {
+			return err
+		}
+	}
+	if keptPastReplicas > 0 {
+		tc.Recorder.Eventf(tfJob, v1.EventTypeWarning, scaleDownRejectedReason,
+			"Kept %d %s pod(s) past %d replicas: the replicas of a tfjob without dynamic workers cannot be lowered",
+			keptPastReplicas, rt, numReplicas)
+	}
+	if quotaErr != nil {
+		return quotaErr
+	}
+	if err := tc.recreateStaleConfigPod(tfJob, replicaPods, rtype, spec); err != nil {
+		return err
+	}
+	if err := tc.rollingUpdatePods(tfJob, replicaPods, spec); err != nil {
+		return err
+	}
+	return tc.reconcileStandbyPods(ctx, tfJob, pods, standbyPods, rtype, spec, replicas, budget)
+}
+
+// repairOwnerReferences patches the controller reference back to the pods which
+// are labeled for the tfjob but have lost it, e.g. by a manual edit, so that they
+// are counted and garbage collected together with the tfjob. The pods still
+// controlled by a previous tfjob of the same name, which was deleted and
+// recreated before they were garbage collected, are deleted instead of
+// being adopted.
+func (tc *TFController) repairOwnerReferences(tfJob *tfv1.TFJob) error {
+	pods, err := tc.PodLister.Pods(tfJob.Namespace).List(labels.SelectorFromSet(tc.GenLabels(tfJob.Name)))
+	if err != nil {
+		return err
+	}
+
+	tfJobKey, err := KeyFunc(tfJob)
+	if err != nil {
+		return err
+	}
+	logger := commonutil.LoggerForJob(tfJob)
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if controllerRef := metav1.GetControllerOf(pod); controllerRef != nil {
+			if controllerRef.Kind == tfv1.Kind && controllerRef.Name == tfJob.Name && controllerRef.UID != tfJob.UID {
+				// The deletion is observed by observeStalePodDeletion, as the
+				// pod is not controlled by the tfjob.
+				var expectationPodsKey string
+				if rt, ok := pod.Labels[tc.GetReplicaTypeLabelKey()]; ok {
+					expectationPodsKey = expectation.GenExpectationPodsKey(tfJobKey, rt)
+					if !tc.Expectations.SatisfiedExpectations(expectationPodsKey) {
+						tc.Expectations.RaiseExpectations(expectationPodsKey, 0, 1)
+					} else if err := tc.Expectations.ExpectDeletions(expectationPodsKey, 1); err != nil {
+						return err
+					}
+				}
+				if err := tc.deletePod(tfJob, pod, deletedStalePodReason, "controlled by a previous tfjob of the same name"); err != nil {
+					if expectationPodsKey != "" {
+						tc.Expectations.DeletionObserved(expectationPodsKey)
+					}
+					return err
+				}
+			}
+			continue
+		}
+		if _, ok := pod.Labels[tc.GetReplicaTypeLabelKey()]; !ok {
+			continue
+		}
+		// The merge patch replaces the whole list, so keep the other owners of
+		// the pod, e.g. the additional owner references of the tfjob.
+		ownerReferences := []metav1.OwnerReference{}
+		for _, ref := range pod.OwnerReferences {
+			if ref.UID != tfJob.UID {
+				ownerReferences = append(ownerReferences, ref)
+			}
+		}
+		ownerReferences = append(ownerReferences, *tc.GenOwnerReference(tfJob))
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"ownerReferences": ownerReferences,
+				"uid":             pod.UID,
+			},
+		})
+		if err != nil {
+			return err
+		}
+		if err := tc.PodControl.PatchPod(pod.Namespace, pod.Name, patch); err != nil {
+			return err
+		}
+		logger.Infof("Repaired the controller reference of pod %s/%s", pod.Namespace, pod.Name)
+		tc.Recorder.Eventf(tfJob, v1.EventTypeNormal, repairedOwnerReferenceReason,
+			"Repaired the controller reference of pod %s", pod.Name)
+	}
+	return nil
+}
+
+// observeStalePodDeletion handles the deletion of the pods like the job
+// controller does, and also lowers the deletion expectation of the tfjob for
+// the pods controlled by a previous tfjob of the same name, which
+// repairOwnerReferences deletes, as the job controller ignores them.
+func (tc *TFController) observeStalePodDeletion(obj interface{}) {
+	tc.JobController.DeletePod(obj)
+
+	pod, ok := obj.(*v1.Pod)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			return
+		}
+		if pod, ok = tombstone.Obj.(*v1.Pod); !ok {
+			return
+		}
+	}
+	controllerRef := metav1.GetControllerOf(pod)
+	if controllerRef == nil || controllerRef.Kind != tfv1.Kind {
+		return
+	}
+	rt, ok := pod.Labels[tc.GetReplicaTypeLabelKey()]
+	if !ok {
+		return
+	}
+	tfJob, err := tc.getTFJobFromName(pod.Namespace, controllerRef.Name)
+	if err != nil || tfJob.UID == controllerRef.UID {
+		return
+	}
+	tfJobKey, err := KeyFunc(tfJob)
+	if err != nil {
+		return
+	}
+	tc.Expectations.DeletionObserved(expectation.GenExpectationPodsKey(tfJobKey, rt))
+	tc.enqueueTFJob(tfJob)
+}
+
+// deletePod deletes the pod of the tfjob, and emits an event with the given
+// reason stating the replica the pod was and why it was deleted, so that users
+// can tell after the fact.
+func (tc *TFController) deletePod(tfJob *tfv1.TFJob, pod *v1.Pod, reason, cause string) error {
+	if err := tc.PodControl.DeletePod(pod.Namespace, pod.Name, tfJob); err != nil {
+		return err
+	}
+	replica := pod.Labels[tc.GetReplicaTypeLabelKey()] + " standby"
+	if index, ok := pod.Labels[tc.GetReplicaIndexLabelKey()]; ok {
+		replica = pod.Labels[tc.GetReplicaTypeLabelKey()] + " " + index
+	}
+	tc.Recorder.Eventf(tfJob, v1.EventTypeNormal, reason, "Deleted pod %s (%s): %s", pod.Name, replica, cause)
+	return nil
+}
+
+// recordOOMKilled emits a warning that the given pod of the tfjob was OOM
+// killed. It is emitted when the OOM kill restarts or fails the tfjob, rather
+// than on every sync of the terminated pod.
+func (tc *TFController) recordOOMKilled(tfJob *tfv1.TFJob, pod *v1.Pod) {
+	tc.Recorder.Eventf(tfJob, v1.EventTypeWarning, TFJobFailedReasonOOMKilled,
+		"Pod: %v.%v of %s replica %s was OOM killed", pod.Namespace, pod.Name,
+		pod.Labels[tc.GetReplicaTypeLabelKey()], pod.Labels[tc.GetReplicaIndexLabelKey()])
+}
+
+// stopReplicasPastDeadline deletes the pods of the given replica type which
+// have not succeeded, and fails the tfjob if the replica policy says so.
+func (tc *TFController) stopReplicasPastDeadline(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus,
+	pods []*v1.Pod, rtype commonv1.ReplicaType) error {
+
+	deleted := 0
+	for _, pod := range pods {
+		if phase := tc.getPodPhase(pod); phase == v1.PodSucceeded {
+			updateJobReplicaStatusesForPhase(jobStatus, rtype, phase)
+			continue
+		}
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		if err := tc.deletePod(tfJob, pod, deletedPodReason, "replica type past its deadline"); err != nil {
+			return err
+		}
+		deleted++
+	}
+
+	msg := fmt.Sprintf("TFJob %s/%s %s replicas have exceeded their deadline.",
+		tfJob.Namespace, tfJob.Name, rtype)
+	if deleted > 0 {
+		tc.Recorder.Event(tfJob, v1.EventTypeWarning, TFJobFailedReasonReplicaDeadline, msg)
+	}
+	policy := getReplicaPolicy(tfJob, rtype)
+	if policy == nil || !policy.FailJobOnDeadlineExceeded || isFailed(*jobStatus) {
+		return nil
+	}
+	if jobStatus.CompletionTime == nil {
+		now := metav1.Now()
+		jobStatus.CompletionTime = &now
+	}
+	if err := commonutil.UpdateJobConditions(jobStatus, commonv1.JobFailed, TFJobFailedReasonReplicaDeadline, msg); err != nil {
+		commonutil.LoggerForJob(tfJob).Infof("Append tfjob condition error: %v", err)
+		return err
+	}
+	tfJobsFailureCount.WithLabelValues(tfJob.Namespace).Inc()
 	return nil
 }
 
 // createNewPod creates a new pod for the given index and type.
-func (tc *TFController) createNewPod(tfjob *tfv1.TFJob, rt, index string, spec *commonv1.ReplicaSpec, masterRole bool,
+func (tc *TFController) createNewPod(ctx context.Context, tfjob *tfv1.TFJob, rt, index string, spec *commonv1.ReplicaSpec, masterRole bool,
 	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) error {
+	return tc.createPod(ctx, tfjob, rt, index, spec, masterRole, false, replicas)
+}
+
+// createNewStandbyPod creates a new standby pod for the given type. The index
+// only makes the name of the pod unique.
+func (tc *TFController) createNewStandbyPod(ctx context.Context, tfjob *tfv1.TFJob, rt, index string, spec *commonv1.ReplicaSpec,
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) error {
+	return tc.createPod(ctx, tfjob, rt, index, spec, false, true, replicas)
+}
+
+func (tc *TFController) createPod(ctx context.Context, tfjob *tfv1.TFJob, rt, index string, spec *commonv1.ReplicaSpec, masterRole, standby bool,
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) (err error) {
 
 	tfjobKey, err := KeyFunc(tfjob)
 	if err != nil {
@@ -174,27 +653,51 @@ func (tc *TFController) createNewPod(tfjob *tfv1.TFJob, rt, index string, spec *
 		return err
 	}
 	expectationPodsKey := expectation.GenExpectationPodsKey(tfjobKey, rt)
-	err = tc.Expectations.ExpectCreations(expectationPodsKey, 1)
-	if err != nil {
-		return err
+	// Raise the pending expectation instead of overwriting it, so that all the
+	// pods created in a pass, or in subsequent batches, are waited for.
+	if !tc.Expectations.SatisfiedExpectations(expectationPodsKey) {
+		tc.Expectations.RaiseExpectations(expectationPodsKey, 1, 0)
+	} else {
+		err = tc.Expectations.ExpectCreations(expectationPodsKey, 1)
+		if err != nil {
+			return err
+		}
 	}
-	logger := commonutil.LoggerForReplica(tfjob, rt)
+	// Every error returned from here on means the pod won't be created, so
+	// lower the expectation raised above.
+	defer func() {
+		if err != nil {
+			tc.Expectations.CreationObserved(expectationPodsKey)
+		}
+	}()
+	logger := traceLogger(ctx, commonutil.LoggerForReplica(tfjob, rt))
 	// Create OwnerReference.
 	controllerRef := tc.GenOwnerReference(tfjob)
 
 	// Set type and index for the worker.
 	labels := tc.GenLabels(tfjob.Name)
-	labels[tfReplicaTypeLabel] = rt
-	labels[tfReplicaIndexLabel] = index
+	tc.setReplicaTypeLabel(labels, rt)
+	if standby {
+		labels[tfStandbyLabel] = "true"
+	} else {
+		tc.setReplicaIndexLabel(labels, index)
+	}
 
 	if masterRole {
 		labels[commonv1.JobRoleLabel] = "master"
 	}
+	if err := tc.setTemplateHashLabel(labels, spec); err != nil {
+		return err
+	}
 
 	podTemplate := spec.Template.DeepCopy()
 
 	// Set name for the template.
-	podTemplate.Name = common.GenGeneralName(tfjob.Name, rt, index)
+	if standby {
+		podTemplate.Name = common.GenGeneralName(tfjob.Name, rt, standbyNamePrefix+index)
+	} else {
+		podTemplate.Name = common.GenGeneralName(tfjob.Name, rt, index)
+	}
 
 	if podTemplate.Labels == nil {
 		podTemplate.Labels = make(map[string]string)
@@ -203,10 +706,84 @@ func (tc *TFController) createNewPod(tfjob *tfv1.TFJob, rt, index string, spec *
 	for key, value := range labels {
 		podTemplate.Labels[key] = value
 	}
+	setReplicaLabels(podTemplate, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
 
-	if err := tc.SetClusterSpec(tfjob, podTemplate, rt, index); err != nil {
+	setCommonSidecars(podTemplate, tfjob.Spec.CommonSidecars)
+	setCommonEnv(podTemplate, tfjob.Spec.CommonEnv)
+	if tc.option.JobMetadataEnv {
+		setCommonEnv(podTemplate, genJobMetadataEnv(tfjob))
+	}
+	if tc.envTemplate != nil {
+		env, err := tc.envTemplate.render(tfjob, rt, index)
+		if err != nil {
+			return err
+		}
+		setCommonEnv(podTemplate, []v1.EnvVar{env})
+	}
+	if tfjob.Spec.PortRange != nil && !standby {
+		var port int32
+		i, err := strconv.Atoi(index)
+		if err == nil {
+			port, err = getReplicaPort(tfjob, commonv1.ReplicaType(rt), int32(i))
+		}
+		if err != nil {
+			return err
+		}
+		setReplicaPort(podTemplate, port)
+	}
+	setDNS(podTemplate, tfjob.Spec.DNSPolicy, tfjob.Spec.DNSConfig)
+	if masterRole {
+		setNodePreference(podTemplate, tfjob.Spec.ChiefNodePreference)
+	}
+	setSecurityContext(podTemplate, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
+	setTerminationGracePeriod(podTemplate, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
+	setPreStopHook(podTemplate, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
+	setGPULimits(podTemplate, v1.ResourceName(tc.option.GPUResourceName))
+	if hash := tc.genConfigHash(tfjob.Namespace, &podTemplate.Spec); hash != "" {
+		if podTemplate.Annotations == nil {
+			podTemplate.Annotations = map[string]string{}
+		}
+		podTemplate.Annotations[configHashAnnotation] = hash
+	}
+
+	if err := tc.resolveImages(podTemplate); err != nil {
+		logger.Warningf("Failed to resolve the images of %s: %v", podTemplate.Name, err)
+		tc.Recorder.Eventf(tfjob, v1.EventTypeWarning, failedResolveImageReason,
+			"Failed to resolve the images of pod %s: %v", podTemplate.Name, err)
+		return err
+	}
+
+	envs, mounts := countTensorflowEnvAndMounts(podTemplate)
+	if standby {
+		// The TF_CONFIG file is filled in when the standby pod is promoted.
+		setTFConfigFile(podTemplate, "")
+	} else if err := tc.setClusterSpec(ctx, tfjob, podTemplate, rt, index); err != nil {
 		return err
 	}
+	shareClusterSpec(podTemplate, tc.option.ClusterSpecContainers, envs, mounts)
+	if tc.option.ClusterMembersAnnotation {
+		members, err := genClusterMembers(tfjob)
+		if err != nil {
+			return err
+		}
+		setClusterMembersAnnotation(podTemplate, members)
+	}
+	if tc.option.WaitForPSDNS && strings.EqualFold(rt, string(tfv1.TFReplicaTypeWorker)) && isDistributed(tfjob) {
+		hosts, err := getPSHosts(tfjob)
+		if err != nil {
+			return err
+		}
+		setWaitForPSInitContainer(podTemplate, hosts, tc.option.WaitForPSDNSImage)
+	}
+	if tc.option.HorovodRankEnv && !standby {
+		i, err := strconv.Atoi(index)
+		if err != nil {
+			return err
+		}
+		if rank, size, ok := getRank(tfjob, rt, i); ok {
+			setRankEnv(podTemplate, rank, size)
+		}
+	}
 
 	// Submit a warning event if the user specifies restart policy for
 	// the pod template. We recommend to set it from the replica level.
@@ -215,7 +792,7 @@ func (tc *TFController) createNewPod(tfjob *tfv1.TFJob, rt, index string, spec *
 		logger.Warning(errMsg)
 		tc.Recorder.Event(tfjob, v1.EventTypeWarning, podTemplateRestartPolicyReason, errMsg)
 	}
-	setRestartPolicy(podTemplate, spec)
+	setRestartPolicy(podTemplate, spec, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
 
 	// if gang-scheduling is enabled:
 	// 1. if user has specified other scheduler, we report a warning without overriding any fields.
@@ -236,6 +813,9 @@ func (tc *TFController) createNewPod(tfjob *tfv1.TFJob, rt, index string, spec *
 		podTemplate.Annotations[volcanoTaskSpecKey] = rt
 	}
 
+	if tc.createPodHook != nil {
+		tc.createPodHook(ctx, podTemplate)
+	}
 	err = tc.PodControl.CreatePodsWithControllerRef(tfjob.Namespace, podTemplate, tfjob, controllerRef)
 	if err != nil && errors.IsTimeout(err) {
 		// Pod is created but its initialization has timed out.
@@ -251,46 +831,106 @@ func (tc *TFController) createNewPod(tfjob *tfv1.TFJob, rt, index string, spec *
 		logger.Infof(
 			"Failed creation, decrementing expectations for tfjob %s/%s, key %s",
 			tfjob.Namespace, tfjob.Name, expectationPodsKey)
-		tc.Expectations.CreationObserved(expectationPodsKey)
 		return err
 	}
 	return nil
 }
 
-// SetClusterSpec generates and sets TF_CONFIG for the given podTemplateSpec.
+// SetClusterSpec generates the cluster spec and sets it for the given
+// podTemplateSpec, through the ClusterSpecEmitter of the controller.
 func (tc *TFController) SetClusterSpec(job interface{}, podTemplate *v1.PodTemplateSpec, rtype, index string) error {
+	return tc.setClusterSpec(context.Background(), job, podTemplate, rtype, index)
+}
+
+// setClusterSpec is SetClusterSpec carrying the context of the reconcile.
+func (tc *TFController) setClusterSpec(ctx context.Context, job interface{}, podTemplate *v1.PodTemplateSpec, rtype, index string) error {
 	tfjob, ok := job.(*tfv1.TFJob)
 	if !ok {
-		return fmt.Errorf("%v is not a type of MXJob", tfjob)
+		return fmt.Errorf("%v is not a type of TFJob", tfjob)
 	}
 
 	// Do not set TF_CONFIG for local training jobs.
 	if !isDistributed(tfjob) {
 		return nil
 	}
-	// Generate TF_CONFIG JSON string.
-	tfConfigStr, err := genTFConfigJSONStr(tfjob, rtype, index)
+	cluster, err := tc.genReplicaClusterSpec(tfjob, rtype, index)
 	if err != nil {
 		return err
 	}
+	return tc.clusterSpecEmitter.EmitClusterSpec(tfjob, cluster, podTemplate, rtype, index)
+}
 
-	if tfConfigStr == "" {
-		return nil
+// genTFConfig generates TF_CONFIG for the replica from its cluster spec.
+func (tc *TFController) genTFConfig(tfjob *tfv1.TFJob, rtype, index string) (string, error) {
+	cluster, err := tc.genReplicaClusterSpec(tfjob, rtype, index)
+	if err != nil {
+		return "", err
 	}
-	// Add TF_CONFIG environment variable to tensorflow container in the pod.
-	for i := range podTemplate.Spec.Containers {
-		if podTemplate.Spec.Containers[i].Name == tfv1.DefaultContainerName {
-			if len(podTemplate.Spec.Containers[i].Env) == 0 {
-				podTemplate.Spec.Containers[i].Env = make([]v1.EnvVar, 0)
+	return genTFConfigJSONStrFromClusterSpec(tfjob, cluster, tc.option.ClusterSpecKeys, rtype, index)
+}
+
+// genReplicaClusterSpec generates the cluster spec seen by the replica. If
+// WaitForPS is set, the cluster spec of a worker lists only the PS whose pods
+// exist, and the tfjob is requeued until all of them do.
+func (tc *TFController) genReplicaClusterSpec(tfjob *tfv1.TFJob, rtype, index string) (ClusterSpec, error) {
+	cluster, err := genClusterSpec(tfjob)
+	if err != nil {
+		return nil, err
+	}
+	if !tc.option.WaitForPS || !strings.EqualFold(rtype, string(tfv1.TFReplicaTypeWorker)) {
+		return cluster, nil
+	}
+
+	ps := strings.ToLower(string(tfv1.TFReplicaTypePS))
+	// The PS endpoints injected in the annotation have no pods to wait for.
+	// The annotation was parsed by genClusterSpec already.
+	injected, _ := getInjectedClusterSpec(tfjob)
+	if _, ok := injected[ps]; !ok && len(cluster[ps]) > 0 {
+		existing, err := tc.getExistingPSIndexes(tfjob)
+		if err != nil {
+			return nil, err
+		}
+		available := make([]string, 0, len(cluster[ps]))
+		for i, endpoint := range cluster[ps] {
+			if existing[strconv.Itoa(i)] {
+				available = append(available, endpoint)
+			}
+		}
+		if len(available) < len(cluster[ps]) {
+			commonutil.LoggerForReplica(tfjob, rtype).Infof("Leaving %d missing PS out of the cluster spec of %s-%s",
+				len(cluster[ps])-len(available), rtype, index)
+			tfjobKey, err := KeyFunc(tfjob)
+			if err != nil {
+				return nil, err
 			}
-			podTemplate.Spec.Containers[i].Env = append(podTemplate.Spec.Containers[i].Env, v1.EnvVar{
-				Name:  tfConfig,
-				Value: tfConfigStr,
-			})
-			break
+			tc.WorkQueue.AddRateLimited(tfjobKey)
 		}
+		cluster[ps] = available
 	}
-	return nil
+	return cluster, nil
+}
+
+// getExistingPSIndexes returns the indexes of the PS pods of the tfjob which
+// exist and are neither failed nor being deleted.
+func (tc *TFController) getExistingPSIndexes(tfjob *tfv1.TFJob) (map[string]bool, error) {
+	pods, err := tc.Controller.GetPodsForJob(tfjob)
+	if err != nil {
+		return nil, err
+	}
+	pods, err = tc.FilterPodsForReplicaType(pods, strings.ToLower(string(tfv1.TFReplicaTypePS)))
+	if err != nil {
+		return nil, err
+	}
+	indexes := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		if index, ok := pod.Labels[tc.GetReplicaIndexLabelKey()]; ok {
+			indexes[index] = true
+		}
+	}
+	return indexes, nil
 }
 
 // isDistributed returns if the TFJob is a distributed training job.
@@ -318,10 +958,436 @@ func isDistributed(tfjob *tfv1.TFJob) bool {
 	return distributionCount != 1
 }
 
-func setRestartPolicy(podTemplateSpec *v1.PodTemplateSpec, spec *commonv1.ReplicaSpec) {
+// genJobMetadataEnv returns the environment variables holding the UID, name
+// and namespace of the tfjob. They only depend on the tfjob, so that they stay
+// the same when its pods are recreated.
+func genJobMetadataEnv(tfjob *tfv1.TFJob) []v1.EnvVar {
+	return []v1.EnvVar{
+		{Name: tfJobUIDEnv, Value: string(tfjob.UID)},
+		{Name: tfJobNameEnv, Value: tfjob.Name},
+		{Name: tfJobNamespaceEnv, Value: tfjob.Namespace},
+	}
+}
+
+// setCommonSidecars appends the job level sidecars to the containers of the
+// pod template, skipping those named as one of its containers. They come
+// after the containers of the template, so that the tensorflow container keeps
+// its place.
+func setCommonSidecars(podTemplate *v1.PodTemplateSpec, sidecars []v1.Container) {
+	defined := make(map[string]bool, len(podTemplate.Spec.Containers))
+	for _, container := range podTemplate.Spec.Containers {
+		defined[container.Name] = true
+	}
+	for _, sidecar := range sidecars {
+		if !defined[sidecar.Name] {
+			podTemplate.Spec.Containers = append(podTemplate.Spec.Containers, *sidecar.DeepCopy())
+		}
+	}
+}
+
+// setCommonEnv adds the job level environment variables to every container of
+// the pod template, without overriding the variables defined in the template.
+func setCommonEnv(podTemplate *v1.PodTemplateSpec, env []v1.EnvVar) {
+	for i := range podTemplate.Spec.Containers {
+		container := &podTemplate.Spec.Containers[i]
+		defined := make(map[string]bool, len(container.Env))
+		for _, e := range container.Env {
+			defined[e.Name] = true
+		}
+		for _, e := range env {
+			if !defined[e.Name] {
+				container.Env = append(container.Env, *e.DeepCopy())
+			}
+		}
+	}
+}
+
+// getRank returns the rank of the replica among the chief, or master, and the
+// workers, and the number of those replicas. The chief has rank 0 and the
+// workers follow in the order of their indices. ok is false for the other
+// replica types.
+func getRank(tfjob *tfv1.TFJob, rt string, index int) (rank, size int, ok bool) {
+	numReplicas := func(rtype commonv1.ReplicaType) int {
+		if spec := tfjob.Spec.TFReplicaSpecs[rtype]; spec != nil && spec.Replicas != nil {
+			return int(*spec.Replicas)
+		}
+		return 0
+	}
+	chiefs := numReplicas(tfv1.TFReplicaTypeChief) + numReplicas(tfv1.TFReplicaTypeMaster)
+	size = chiefs + numReplicas(tfv1.TFReplicaTypeWorker)
+
+	switch {
+	case strings.EqualFold(rt, string(tfv1.TFReplicaTypeChief)), strings.EqualFold(rt, string(tfv1.TFReplicaTypeMaster)):
+		return index, size, true
+	case strings.EqualFold(rt, string(tfv1.TFReplicaTypeWorker)):
+		return chiefs + index, size, true
+	}
+	return 0, 0, false
+}
+
+// setRankEnv sets HOROVOD_RANK and HOROVOD_SIZE in the tensorflow container,
+// unless the container defines them.
+func setRankEnv(podTemplate *v1.PodTemplateSpec, rank, size int) {
+	env := []v1.EnvVar{
+		{Name: horovodRankEnv, Value: strconv.Itoa(rank)},
+		{Name: horovodSizeEnv, Value: strconv.Itoa(size)},
+	}
+	for i := range podTemplate.Spec.Containers {
+		container := &podTemplate.Spec.Containers[i]
+		if container.Name != tfv1.DefaultContainerName {
+			continue
+		}
+		defined := make(map[string]bool, len(container.Env))
+		for _, e := range container.Env {
+			defined[e.Name] = true
+		}
+		for _, e := range env {
+			if !defined[e.Name] {
+				container.Env = append(container.Env, e)
+			}
+		}
+	}
+}
+
+// resolveImages rewrites the images of the containers and init containers of
+// the pod template through the ImageResolver, if there is one.
+func (tc *TFController) resolveImages(podTemplate *v1.PodTemplateSpec) error {
+	if tc.ImageResolver == nil {
+		return nil
+	}
+	for _, containers := range [][]v1.Container{podTemplate.Spec.InitContainers, podTemplate.Spec.Containers} {
+		for i := range containers {
+			image, err := tc.ImageResolver(containers[i].Image)
+			if err != nil {
+				return fmt.Errorf("failed to resolve image %s of container %s: %v", containers[i].Image, containers[i].Name, err)
+			}
+			containers[i].Image = image
+		}
+	}
+	return nil
+}
+
+// setReplicaPort sets the tfjob port of the tensorflow container to the given
+// port, adding it if the container does not declare it.
+func setReplicaPort(podTemplate *v1.PodTemplateSpec, port int32) {
+	for i := range podTemplate.Spec.Containers {
+		container := &podTemplate.Spec.Containers[i]
+		if container.Name != tfv1.DefaultContainerName {
+			continue
+		}
+		for j := range container.Ports {
+			if container.Ports[j].Name == tfv1.DefaultPortName {
+				container.Ports[j].ContainerPort = port
+				return
+			}
+		}
+		container.Ports = append(container.Ports, v1.ContainerPort{Name: tfv1.DefaultPortName, ContainerPort: port})
+		return
+	}
+}
+
+// setDNS sets the job level DNS policy and config on the pod template, unless
+// the template specifies them.
+func setDNS(podTemplate *v1.PodTemplateSpec, policy v1.DNSPolicy, config *v1.PodDNSConfig) {
+	if podTemplate.Spec.DNSPolicy == "" {
+		podTemplate.Spec.DNSPolicy = policy
+	}
+	if podTemplate.Spec.DNSConfig == nil && config != nil {
+		podTemplate.Spec.DNSConfig = config.DeepCopy()
+	}
+}
+
+// setNodePreference adds a preferred node affinity for the nodes matching the
+// given preference to the pod template, after the preferences of the template.
+func setNodePreference(podTemplate *v1.PodTemplateSpec, preference *tfv1.NodePreference) {
+	if preference == nil {
+		return
+	}
+	key := preference.TopologyKey
+	if key == "" {
+		key = v1.LabelHostname
+	}
+	if podTemplate.Spec.Affinity == nil {
+		podTemplate.Spec.Affinity = &v1.Affinity{}
+	}
+	if podTemplate.Spec.Affinity.NodeAffinity == nil {
+		podTemplate.Spec.Affinity.NodeAffinity = &v1.NodeAffinity{}
+	}
+	nodeAffinity := podTemplate.Spec.Affinity.NodeAffinity
+	nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution = append(nodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution,
+		v1.PreferredSchedulingTerm{
+			Weight: 100,
+			Preference: v1.NodeSelectorTerm{
+				MatchExpressions: []v1.NodeSelectorRequirement{{
+					Key:      key,
+					Operator: v1.NodeSelectorOpIn,
+					Values:   []string{preference.Value},
+				}},
+			},
+		})
+}
+
+// setSecurityContext sets the default security contexts of the replica policy
+// on the pod template and its containers, unless they specify their own.
+func setSecurityContext(podTemplate *v1.PodTemplateSpec, policy *tfv1.TFReplicaPolicy) {
+	if policy == nil {
+		return
+	}
+	if podTemplate.Spec.SecurityContext == nil && policy.SecurityContext != nil {
+		podTemplate.Spec.SecurityContext = policy.SecurityContext.DeepCopy()
+	}
+	if policy.ContainerSecurityContext == nil {
+		return
+	}
+	for _, containers := range [][]v1.Container{podTemplate.Spec.InitContainers, podTemplate.Spec.Containers} {
+		for i := range containers {
+			if containers[i].SecurityContext == nil {
+				containers[i].SecurityContext = policy.ContainerSecurityContext.DeepCopy()
+			}
+		}
+	}
+}
+
+// getPodPhase returns the phase the pod is counted in. Unless the exit codes
+// of all the containers count, a pod which failed while its tensorflow
+// container succeeded, i.e. because of a sidecar, counts as succeeded. A pod
+// which failed within the grace period still counts as running.
+func (tc *TFController) getPodPhase(pod *v1.Pod) v1.PodPhase {
+	if pod.Status.Phase != v1.PodFailed || isEvictedPod(pod) {
+		return pod.Status.Phase
+	}
+	if tc.option.ExitCodeContainers != options.ExitCodeContainersAll {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name == tc.GetDefaultContainerName() && status.State.Terminated != nil && status.State.Terminated.ExitCode == 0 {
+				return v1.PodSucceeded
+			}
+		}
+	}
+	if tc.failedPodGraceRemaining(pod) > 0 {
+		return v1.PodRunning
+	}
+	return pod.Status.Phase
+}
+
+// failedPodGraceRemaining returns how long the failed pod still counts as
+// running, or 0 if it counts as failed. The pod failed when the last of its
+// containers terminated.
+func (tc *TFController) failedPodGraceRemaining(pod *v1.Pod) time.Duration {
+	if tc.option.FailedPodGracePeriod <= 0 || pod.Status.Phase != v1.PodFailed {
+		return 0
+	}
+	var failedAt time.Time
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.State.Terminated != nil && status.State.Terminated.FinishedAt.After(failedAt) {
+			failedAt = status.State.Terminated.FinishedAt.Time
+		}
+	}
+	if failedAt.IsZero() {
+		return 0
+	}
+	if remaining := time.Until(failedAt.Add(tc.option.FailedPodGracePeriod)); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// minReadyRemaining returns how long the running pod still has to be up
+// before it counts as active, or 0 if it does. The pod is up since it became
+// ready if ActiveRequiresPodReady is set, and since its containers last
+// started otherwise.
+func (tc *TFController) minReadyRemaining(pod *v1.Pod) time.Duration {
+	if tc.option.MinReadyPeriod <= 0 || pod.Status.Phase != v1.PodRunning {
+		return 0
+	}
+	var upSince time.Time
+	if tc.option.ActiveRequiresPodReady {
+		for _, condition := range pod.Status.Conditions {
+			if condition.Type == v1.PodReady && condition.Status == v1.ConditionTrue {
+				upSince = condition.LastTransitionTime.Time
+			}
+		}
+	} else {
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.State.Running != nil && status.State.Running.StartedAt.After(upSince) {
+				upSince = status.State.Running.StartedAt.Time
+			}
+		}
+	}
+	if upSince.IsZero() {
+		return 0
+	}
+	if remaining := time.Until(upSince.Add(tc.option.MinReadyPeriod)); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// requeueFailedPodGrace requeues the tfjob once the first grace period of its
+// pods which failed within it elapses, so that they are counted as failed even
+// if nothing else changes. The same goes for the min ready period of its
+// running pods, so that they are counted as active.
+func (tc *TFController) requeueFailedPodGrace(tfJob *tfv1.TFJob, pods []*v1.Pod) error {
+	var requeueAfter time.Duration
+	for _, pod := range pods {
+		for _, remaining := range []time.Duration{tc.failedPodGraceRemaining(pod), tc.minReadyRemaining(pod)} {
+			if remaining > 0 && (requeueAfter == 0 || remaining < requeueAfter) {
+				requeueAfter = remaining
+			}
+		}
+	}
+	if requeueAfter == 0 {
+		return nil
+	}
+	key, err := KeyFunc(tfJob)
+	if err != nil {
+		return err
+	}
+	tc.WorkQueue.AddAfter(key, requeueAfter)
+	return nil
+}
+
+// isEvictedPod returns true if the pod failed because it was evicted.
+func isEvictedPod(pod *v1.Pod) bool {
+	return pod.Status.Phase == v1.PodFailed && pod.Status.Reason == evictedReason
+}
+
+// isFailedPod returns true if the pod counts as failed. Evicted pods which are
+// recreated, and pods failed only because of a sidecar or within the grace
+// period, do not.
+func (tc *TFController) isFailedPod(pod *v1.Pod) bool {
+	if tc.option.RecreateEvictedPods && isEvictedPod(pod) {
+		return false
+	}
+	return tc.getPodPhase(pod) == v1.PodFailed
+}
+
+// setReplicaLabels adds the labels of the replica policy to the pod template.
+// Labels already in the template, and the labels the controller owns, are
+// left untouched.
+func setReplicaLabels(podTemplate *v1.PodTemplateSpec, policy *tfv1.TFReplicaPolicy) {
+	if policy == nil {
+		return
+	}
+	for key, value := range policy.Labels {
+		if _, ok := podTemplate.Labels[key]; ok || controllerLabels.Has(key) {
+			continue
+		}
+		podTemplate.Labels[key] = value
+	}
+}
+
+// setGPULimits copies the requests of the GPU resource to the limits of the
+// containers and init containers which request it without limiting it. GPUs
+// cannot be overcommitted, so their requests must equal their limits.
+func setGPULimits(podTemplate *v1.PodTemplateSpec, gpu v1.ResourceName) {
+	if gpu == "" {
+		return
+	}
+	for _, containers := range [][]v1.Container{podTemplate.Spec.InitContainers, podTemplate.Spec.Containers} {
+		for i := range containers {
+			resources := &containers[i].Resources
+			request, ok := resources.Requests[gpu]
+			if !ok {
+				continue
+			}
+			if _, ok := resources.Limits[gpu]; ok {
+				continue
+			}
+			if resources.Limits == nil {
+				resources.Limits = v1.ResourceList{}
+			}
+			resources.Limits[gpu] = request.DeepCopy()
+		}
+	}
+}
+
+// getPSHosts returns the host names of the PS in the cluster spec of the
+// tfjob, including the endpoints injected through its annotation.
+func getPSHosts(tfjob *tfv1.TFJob) ([]string, error) {
+	cluster, err := genClusterSpec(tfjob)
+	if err != nil {
+		return nil, err
+	}
+	endpoints := cluster[strings.ToLower(string(tfv1.TFReplicaTypePS))]
+	hosts := make([]string, 0, len(endpoints))
+	for _, endpoint := range endpoints {
+		host, _, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			// The endpoint has no port.
+			host = endpoint
+		}
+		hosts = append(hosts, host)
+	}
+	return hosts, nil
+}
+
+// setWaitForPSInitContainer prepends to the init containers of the pod
+// template one which blocks until the given PS hosts resolve, so that the
+// worker does not crash connecting to PS whose services are not there yet.
+func setWaitForPSInitContainer(podTemplate *v1.PodTemplateSpec, hosts []string, image string) {
+	if len(hosts) == 0 {
+		return
+	}
+	for _, container := range podTemplate.Spec.InitContainers {
+		if container.Name == waitForPSContainerName {
+			return
+		}
+	}
+	container := v1.Container{
+		Name:    waitForPSContainerName,
+		Image:   image,
+		Command: []string{"sh", "-c", waitForPSScript, waitForPSContainerName},
+		Args:    hosts,
+	}
+	podTemplate.Spec.InitContainers = append([]v1.Container{container}, podTemplate.Spec.InitContainers...)
+}
+
+// setTerminationGracePeriod sets the termination grace period of the replica
+// policy on the pod template, unless the template specifies one.
+func setTerminationGracePeriod(podTemplate *v1.PodTemplateSpec, policy *tfv1.TFReplicaPolicy) {
+	if policy == nil || policy.TerminationGracePeriodSeconds == nil || podTemplate.Spec.TerminationGracePeriodSeconds != nil {
+		return
+	}
+	seconds := *policy.TerminationGracePeriodSeconds
+	podTemplate.Spec.TerminationGracePeriodSeconds = &seconds
+}
+
+// setPreStopHook injects the preStop hook of the replica policy in the
+// tensorflow container of the pod template, unless it defines one.
+func setPreStopHook(podTemplate *v1.PodTemplateSpec, policy *tfv1.TFReplicaPolicy) {
+	if policy == nil {
+		return
+	}
+	var command []string
+	if len(policy.PreStopCommand) > 0 {
+		command = append(command, policy.PreStopCommand...)
+	} else if policy.PreStopSleepSeconds != nil {
+		command = []string{"sleep", strconv.Itoa(int(*policy.PreStopSleepSeconds))}
+	} else {
+		return
+	}
+	for i := range podTemplate.Spec.Containers {
+		container := &podTemplate.Spec.Containers[i]
+		if container.Name != tfv1.DefaultContainerName {
+			continue
+		}
+		if container.Lifecycle == nil {
+			container.Lifecycle = &v1.Lifecycle{}
+		}
+		if container.Lifecycle.PreStop == nil {
+			container.Lifecycle.PreStop = &v1.Handler{Exec: &v1.ExecAction{Command: command}}
+		}
+		break
+	}
+}
+
+func setRestartPolicy(podTemplateSpec *v1.PodTemplateSpec, spec *commonv1.ReplicaSpec, policy *tfv1.TFReplicaPolicy) {
 	// This is necessary since restartPolicyExitCode is not supported in v1.PodTemplateSpec
 	if spec.RestartPolicy == commonv1.RestartPolicyExitCode {
 		podTemplateSpec.Spec.RestartPolicy = v1.RestartPolicyNever
+		if restartsInPlaceOnExitCode(policy) {
+			podTemplateSpec.Spec.RestartPolicy = v1.RestartPolicyOnFailure
+		}
 	} else {
 		podTemplateSpec.Spec.RestartPolicy = v1.RestartPolicy(spec.RestartPolicy)
 	}
@@ -346,6 +1412,30 @@ func (tc *TFController) getPodSlices(tfjob *tfv1.TFJob, replicasNum *int32) ([][
 	return podSlices, nil
 }
 
+// restartsInPlaceOnExitCode returns true if the ExitCode restart policy is mapped
+// to the OnFailure pod restart policy, so that the kubelet restarts the failed
+// containers in place.
+func restartsInPlaceOnExitCode(policy *tfv1.TFReplicaPolicy) bool {
+	return policy != nil && policy.ExitCodePodRestartPolicy == v1.RestartPolicyOnFailure
+}
+
+// isPermanentlyFailedInPlace returns true if the default container of the pod
+// was last terminated with a permanent error exit code. The kubelet keeps
+// restarting such a container when the pod restart policy is OnFailure.
+func isPermanentlyFailedInPlace(pod *v1.Pod) bool {
+	for _, status := range pod.Status.ContainerStatuses {
+		if status.Name != tfv1.DefaultContainerName {
+			continue
+		}
+		terminated := status.LastTerminationState.Terminated
+		if status.State.Terminated != nil {
+			terminated = status.State.Terminated
+		}
+		return terminated != nil && terminated.ExitCode != 0 && !train_util.IsRetryableExitCode(terminated.ExitCode)
+	}
+	return false
+}
+
 func getContainerExitCode(pod *v1.Pod) int32 {
 	var exitCode int32 = 0xbeef // magic number
 	for _, status := range pod.Status.ContainerStatuses {
@@ -357,6 +1447,45 @@ func getContainerExitCode(pod *v1.Pod) int32 {
 	return exitCode
 }
 
+// GetActiveReplicaIndices returns the sorted indices of the given replica type
+// which have a live pod, i.e. a pod which is neither finished nor being deleted.
+// The indices are read from the replica index labels of the pods.
+func (tc *TFController) GetActiveReplicaIndices(tfjob *tfv1.TFJob, rtype commonv1.ReplicaType) ([]int, error) {
+	pods, err := tc.Controller.GetPodsForJob(tfjob)
+	if err != nil {
+		return nil, err
+	}
+	pods, err = tc.FilterPodsForReplicaType(pods, strings.ToLower(string(rtype)))
+	if err != nil {
+		return nil, err
+	}
+
+	logger := commonutil.LoggerForReplica(tfjob, strings.ToLower(string(rtype)))
+	seen := make(map[int]bool, len(pods))
+	indices := make([]int, 0, len(pods))
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || pod.Status.Phase == v1.PodSucceeded || pod.Status.Phase == v1.PodFailed {
+			continue
+		}
+		// Standby pods have no index.
+		label, ok := pod.Labels[tc.GetReplicaIndexLabelKey()]
+		if !ok {
+			continue
+		}
+		index, err := strconv.Atoi(label)
+		if err != nil {
+			logger.Warningf("Error when strconv.Atoi: %v", err)
+			continue
+		}
+		if !seen[index] {
+			seen[index] = true
+			indices = append(indices, index)
+		}
+	}
+	sort.Ints(indices)
+	return indices, nil
+}
+
 // IsWorker0Completed return true if pod of worker0 succeeded and exited with 0
 func (tc *TFController) IsWorker0Completed(tfjob *tfv1.TFJob, replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) (bool, error) {
 	worker0Completed := false
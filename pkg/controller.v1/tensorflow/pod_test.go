@@ -16,13 +16,23 @@
 package tensorflow
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"reflect"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/sets"
 	kubeclientset "k8s.io/client-go/kubernetes"
+	kubefake "k8s.io/client-go/kubernetes/fake"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/record"
 	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
@@ -32,10 +42,13 @@ import (
 	"github.com/kubeflow/common/pkg/controller.v1/common"
 	"github.com/kubeflow/common/pkg/controller.v1/control"
 	"github.com/kubeflow/common/pkg/controller.v1/expectation"
+	commonutil "github.com/kubeflow/common/pkg/util"
 	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
 	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
 	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
 )
 
 func TestAddPod(t *testing.T) {
@@ -142,7 +155,7 @@ func TestExpectation(t *testing.T) {
 	tfJob := testutil.NewTFJob(2, 1)
 
 	var err error
-	if err = ctr.createNewPod(tfJob, "worker", "0",
+	if err = ctr.createNewPod(context.TODO(), tfJob, "worker", "0",
 		tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker],
 		false, tfJob.Spec.TFReplicaSpecs); err != nil {
 		t.Errorf("Expected get nil, got error %v", err)
@@ -165,6 +178,72 @@ func TestExpectation(t *testing.T) {
 	}
 }
 
+func TestExpectationMetrics(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+
+	ctr.PodControl = &control.FakePodControl{}
+	tfJob := testutil.NewTFJob(1, 0)
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0",
+		tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker],
+		false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+
+	tfjobKey, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Errorf("Expected nil, got error %v", err)
+	}
+	expectationPodsKey := expectation.GenExpectationPodsKey(tfjobKey, "worker")
+	add := tfJobsOutstandingExpectations.WithLabelValues(expectationPodsKey, "add")
+	if got := promtestutil.ToFloat64(add); got != 1 {
+		t.Errorf("Expected 1 outstanding add, got %v", got)
+	}
+
+	// Observe the created pod.
+	ctr.AddPod(testutil.NewPod(tfJob, testutil.LabelWorker, 0))
+	if got := promtestutil.ToFloat64(add); got != 0 {
+		t.Errorf("Expected 0 outstanding add after the pod is observed, got %v", got)
+	}
+}
+
 func TestExpectationWithError(t *testing.T) {
 	// Prepare the clientset and controller for the test.
 	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
@@ -204,11 +283,150 @@ func TestExpectationWithError(t *testing.T) {
 	ctr.PodControl.(*control.FakePodControl).Err = fmt.Errorf("Fake")
 
 	var err error
-	if err = ctr.createNewPod(tfJob, "worker", "0",
+	if err = ctr.createNewPod(context.TODO(), tfJob, "worker", "0",
+		tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker],
+		false, tfJob.Spec.TFReplicaSpecs); err == nil {
+		t.Errorf("Expected error, got nil")
+	}
+
+	tfjobKey, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Errorf("Expected nil, got error %v", err)
+	}
+	expectationPodsKey := expectation.GenExpectationPodsKey(tfjobKey, "worker")
+	e, found, err := ctr.Expectations.GetExpectations(expectationPodsKey)
+	if err != nil {
+		t.Errorf("Expected nil, got error %v", err)
+	}
+	if !found {
+		t.Errorf("Expected to get the corresponding expectation")
+	}
+	if add, del := e.GetExpectations(); add != 0 || del != 0 {
+		t.Errorf("Expected get 0 add and 0 del, got %d add and %d del", add, del)
+	}
+}
+
+func TestExpectationWithClusterSpecError(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	tfJob := testutil.NewTFJob(2, 1)
+
+	// The cluster spec cannot be generated for an invalid index.
+	var err error
+	if err = ctr.createNewPod(context.TODO(), tfJob, "worker", "invalid",
 		tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker],
 		false, tfJob.Spec.TFReplicaSpecs); err == nil {
 		t.Errorf("Expected error, got nil")
 	}
+	if len(fakePodControl.Templates) != 0 {
+		t.Errorf("Expected no pod to be created, got %d", len(fakePodControl.Templates))
+	}
+
+	tfjobKey, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Errorf("Expected nil, got error %v", err)
+	}
+	expectationPodsKey := expectation.GenExpectationPodsKey(tfjobKey, "worker")
+	e, found, err := ctr.Expectations.GetExpectations(expectationPodsKey)
+	if err != nil {
+		t.Errorf("Expected nil, got error %v", err)
+	}
+	if !found {
+		t.Errorf("Expected to get the corresponding expectation")
+	}
+	if add, del := e.GetExpectations(); add != 0 || del != 0 {
+		t.Errorf("Expected get 0 add and 0 del, got %d add and %d del", add, del)
+	}
+}
+
+// hangingPodControl is a FakePodControl whose pod creations hang until it is
+// released.
+type hangingPodControl struct {
+	*control.FakePodControl
+	release chan struct{}
+}
+
+func (c *hangingPodControl) CreatePodsWithControllerRef(namespace string, template *v1.PodTemplateSpec,
+	object runtime.Object, controllerRef *metav1.OwnerReference) error {
+	<-c.release
+	return c.FakePodControl.CreatePodsWithControllerRef(namespace, template, object, controllerRef)
+}
+
+func TestExpectationWithTimeout(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	timeout := 10 * time.Millisecond
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{PodCreationTimeout: timeout})
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+
+	hanging := &hangingPodControl{FakePodControl: &control.FakePodControl{}, release: make(chan struct{})}
+	defer close(hanging.release)
+	ctr.PodControl = newTimeoutPodControl(hanging, timeout)
+	tfJob := testutil.NewTFJob(2, 1)
+
+	var err error
+	if err = ctr.createNewPod(context.TODO(), tfJob, "worker", "0",
+		tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker],
+		false, tfJob.Spec.TFReplicaSpecs); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
 
 	tfjobKey, err := KeyFunc(tfJob)
 	if err != nil {
@@ -350,96 +568,166 @@ func TestClusterSpec(t *testing.T) {
 	}
 }
 
-func TestIsDistributed(t *testing.T) {
+func TestClusterSpecFile(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{TFConfigFile: true})
+
+	os.Setenv(EnvCustomClusterDomain, "")
+	tfJob := testutil.NewTFJobWithNamespace(1, 1, "ns0")
+	podTemplate := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Template.DeepCopy()
+	if err := ctr.SetClusterSpec(tfJob, podTemplate, "worker", "0"); err != nil {
+		t.Fatalf("Failed to set cluster spec: %v", err)
+	}
+
+	expected, err := genTFConfigJSONStr(tfJob, "worker", "0")
+	if err != nil {
+		t.Fatalf("Failed to generate TF_CONFIG: %v", err)
+	}
+	if actual := podTemplate.Annotations[tfConfigAnnotation]; actual != expected {
+		t.Errorf("Expected annotation %s, got %s", expected, actual)
+	}
+
+	if len(podTemplate.Spec.Volumes) != 1 || podTemplate.Spec.Volumes[0].Name != tfConfigVolumeName ||
+		podTemplate.Spec.Volumes[0].DownwardAPI == nil {
+		t.Fatalf("Expected downward API volume %s, got %v", tfConfigVolumeName, podTemplate.Spec.Volumes)
+	}
+	items := podTemplate.Spec.Volumes[0].DownwardAPI.Items
+	if len(items) != 1 || items[0].Path != tfConfigFileName ||
+		items[0].FieldRef.FieldPath != "metadata.annotations['"+tfConfigAnnotation+"']" {
+		t.Errorf("Unexpected downward API items: %v", items)
+	}
+
+	container := podTemplate.Spec.Containers[0]
+	if len(container.VolumeMounts) != 1 || container.VolumeMounts[0].Name != tfConfigVolumeName ||
+		container.VolumeMounts[0].MountPath != tfConfigMountPath {
+		t.Errorf("Unexpected volume mounts: %v", container.VolumeMounts)
+	}
+	if len(container.Env) != 1 || container.Env[0].Name != tfConfigFileEnv ||
+		container.Env[0].Value != tfConfigMountPath+"/"+tfConfigFileName {
+		t.Errorf("Expected only %s env, got %v", tfConfigFileEnv, container.Env)
+	}
+}
+
+// fakeClusterSpecEmitter sets the replicas of each type of the cluster spec
+// in a REPLICAS env.
+type fakeClusterSpecEmitter struct{}
+
+func (fakeClusterSpecEmitter) EmitClusterSpec(tfjob *tfv1.TFJob, cluster ClusterSpec, podTemplate *v1.PodTemplateSpec, rtype, index string) error {
+	setTensorflowEnv(podTemplate, v1.EnvVar{
+		Name:  "REPLICAS",
+		Value: fmt.Sprintf("ps=%d,worker=%d", len(cluster["ps"]), len(cluster["worker"])),
+	})
+	return nil
+}
+
+func TestClusterSpecEmitter(t *testing.T) {
+	RegisterClusterSpecEmitter("fake", fakeClusterSpecEmitter{})
+
 	type tc struct {
-		tfJob    *tfv1.TFJob
-		expected bool
+		format      string
+		expectedEnv map[string]string
 	}
-	testCase := []tc{
-		{
-			tfJob:    testutil.NewTFJob(1, 0),
-			expected: false,
-		},
+	testCases := []tc{
 		{
-			tfJob:    testutil.NewTFJob(1, 1),
-			expected: true,
+			format: "fake",
+			expectedEnv: map[string]string{
+				"REPLICAS": "ps=1,worker=2",
+			},
 		},
 		{
-			tfJob:    testutil.NewTFJob(0, 1),
-			expected: false,
+			format: ClusterSpecFormatHostList,
+			expectedEnv: map[string]string{
+				"PS_HOSTS":     "test-tfjob-ps-0.ns0.svc:2222",
+				"WORKER_HOSTS": "test-tfjob-worker-0.ns0.svc:2222,test-tfjob-worker-1.ns0.svc:2222",
+				jobNameEnv:     "worker",
+				taskIndexEnv:   "1",
+			},
 		},
 		{
-			tfJob:    testutil.NewTFJobWithChief(1, 0),
-			expected: true,
+			format: ClusterSpecFormatTFConfigAndHostList,
+			expectedEnv: map[string]string{
+				tfConfig: `{"cluster":{"ps":["test-tfjob-ps-0.ns0.svc:2222"],` +
+					`"worker":["test-tfjob-worker-0.ns0.svc:2222","test-tfjob-worker-1.ns0.svc:2222"]},` +
+					`"task":{"type":"worker","index":1},"environment":"cloud"}`,
+				"PS_HOSTS":     "test-tfjob-ps-0.ns0.svc:2222",
+				"WORKER_HOSTS": "test-tfjob-worker-0.ns0.svc:2222,test-tfjob-worker-1.ns0.svc:2222",
+				jobNameEnv:     "worker",
+				taskIndexEnv:   "1",
+			},
 		},
 	}
-	for _, c := range testCase {
-		actual := isDistributed(c.tfJob)
-		if actual != c.expected {
-			t.Errorf("Expected %t, got %t", c.expected, actual)
-		}
-	}
-}
+	os.Setenv(EnvCustomClusterDomain, "")
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
 
-func TestRestartPolicy(t *testing.T) {
-	type tc struct {
-		tfJob                 *tfv1.TFJob
-		expectedRestartPolicy v1.RestartPolicy
-		expectedType          commonv1.ReplicaType
-	}
-	testCase := []tc{
-		func() tc {
-			tfJob := testutil.NewTFJob(1, 0)
-			specRestartPolicy := commonv1.RestartPolicyExitCode
-			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
-			return tc{
-				tfJob:                 tfJob,
-				expectedRestartPolicy: v1.RestartPolicyNever,
-				expectedType:          tfv1.TFReplicaTypeWorker,
-			}
-		}(),
-		func() tc {
-			tfJob := testutil.NewTFJob(1, 0)
-			specRestartPolicy := commonv1.RestartPolicyNever
-			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
-			return tc{
-				tfJob:                 tfJob,
-				expectedRestartPolicy: v1.RestartPolicyNever,
-				expectedType:          tfv1.TFReplicaTypeWorker,
-			}
-		}(),
-		func() tc {
-			tfJob := testutil.NewTFJob(1, 0)
-			specRestartPolicy := commonv1.RestartPolicyAlways
-			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
-			return tc{
-				tfJob:                 tfJob,
-				expectedRestartPolicy: v1.RestartPolicyAlways,
-				expectedType:          tfv1.TFReplicaTypeWorker,
-			}
-		}(),
-		func() tc {
-			tfJob := testutil.NewTFJob(1, 0)
-			specRestartPolicy := commonv1.RestartPolicyOnFailure
-			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
-			return tc{
-				tfJob:                 tfJob,
-				expectedRestartPolicy: v1.RestartPolicyOnFailure,
-				expectedType:          tfv1.TFReplicaTypeWorker,
-			}
-		}(),
-	}
-	for _, c := range testCase {
-		spec := c.tfJob.Spec.TFReplicaSpecs[c.expectedType]
-		podTemplate := spec.Template
-		setRestartPolicy(&podTemplate, spec)
-		if podTemplate.Spec.RestartPolicy != c.expectedRestartPolicy {
-			t.Errorf("Expected %s, got %s", c.expectedRestartPolicy, podTemplate.Spec.RestartPolicy)
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, _, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ClusterSpecFormat: c.format})
+
+		tfJob := testutil.NewTFJobWithNamespace(2, 1, "ns0")
+		podTemplate := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Template.DeepCopy()
+		if err := ctr.SetClusterSpec(tfJob, podTemplate, "worker", "1"); err != nil {
+			t.Fatalf("%s: failed to set cluster spec: %v", c.format, err)
+		}
+
+		env := map[string]string{}
+		for _, e := range podTemplate.Spec.Containers[0].Env {
+			env[e.Name] = e.Value
+		}
+		if !reflect.DeepEqual(env, c.expectedEnv) {
+			t.Errorf("%s: expected env %v, got %v", c.format, c.expectedEnv, env)
 		}
 	}
 }
 
-func TestExitCode(t *testing.T) {
+func TestCommonEnv(t *testing.T) {
 	// Prepare the clientset and controller for the test.
 	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
 		Host: "",
@@ -465,69 +753,52 @@ func TestExitCode(t *testing.T) {
 		},
 	}
 	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
-	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+	ctr, _, _ := newTFController(config, kubeClientSet,
 		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
 	fakePodControl := &control.FakePodControl{}
 	ctr.PodControl = fakePodControl
-	ctr.tfJobInformerSynced = testutil.AlwaysReady
-	ctr.PodInformerSynced = testutil.AlwaysReady
-	ctr.ServiceInformerSynced = testutil.AlwaysReady
-	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
-	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
 
-	stopCh := make(chan struct{})
-	run := func(<-chan struct{}) {
-		if err := ctr.Run(testutil.ThreadCount, stopCh); err != nil {
-			t.Errorf("Failed to run the controller: %v", err)
-		}
+	tfJob := testutil.NewTFJob(1, 1)
+	tfJob.Spec.CommonEnv = []v1.EnvVar{
+		{Name: "HTTP_PROXY", Value: "http://proxy:3128"},
+		{Name: "NO_PROXY", Value: "localhost"},
 	}
-	go run(stopCh)
-
-	tfJob := testutil.NewTFJob(1, 0)
-	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = commonv1.RestartPolicyExitCode
-	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
-	if err != nil {
-		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	workerSpec.Template.Spec.Containers[0].Env = []v1.EnvVar{
+		{Name: "HTTP_PROXY", Value: "http://worker-proxy:3128"},
 	}
 
-	if err := tfJobIndexer.Add(unstructured); err != nil {
-		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
 	}
-	pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
-	pod.Status.Phase = v1.PodFailed
-	pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{})
-	pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, v1.ContainerStatus{
-		Name: tfv1.DefaultContainerName,
-		State: v1.ContainerState{
-			Terminated: &v1.ContainerStateTerminated{
-				ExitCode: 130,
-			},
-		},
-	})
-
-	if err := podIndexer.Add(pod); err != nil {
-		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	if err := ctr.createNewPod(context.TODO(), tfJob, "ps", "0", tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS],
+		false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if len(fakePodControl.Templates) != 2 {
+		t.Fatalf("Expected 2 pods to be created, got %d", len(fakePodControl.Templates))
 	}
-	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
-	// _, err = ctr.syncTFJob(testutil.GetKey(tfJob, t))
-	// if err != nil {
-	// 	t.Errorf("%s: unexpected error when syncing jobs %v", tfJob.Name, err)
-	// }
 
-	found := false
-	for _, deletedPodName := range fakePodControl.DeletePodName {
-		if deletedPodName == pod.Name {
-			found = true
-		}
+	expected := []map[string]string{
+		{"HTTP_PROXY": "http://worker-proxy:3128", "NO_PROXY": "localhost"},
+		{"HTTP_PROXY": "http://proxy:3128", "NO_PROXY": "localhost"},
 	}
-	if !found {
-		t.Errorf("Failed to delete pod %s", pod.Name)
+	for i, template := range fakePodControl.Templates {
+		env := map[string]string{}
+		count := 0
+		for _, e := range template.Spec.Containers[0].Env {
+			if e.Name == "HTTP_PROXY" || e.Name == "NO_PROXY" {
+				env[e.Name] = e.Value
+				count++
+			}
+		}
+		if count != 2 || !reflect.DeepEqual(expected[i], env) {
+			t.Errorf("Expected env %v in %s, got %v", expected[i], template.Name, template.Spec.Containers[0].Env)
+		}
 	}
-	close(stopCh)
 }
 
-// Test scaling down number of workers while training is running
-func TestScaleDown(t *testing.T) {
+func TestRepairOwnerReferences(t *testing.T) {
 	// Prepare the clientset and controller for the test.
 	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
 		Host: "",
@@ -561,57 +832,107 @@ func TestScaleDown(t *testing.T) {
 	ctr.tfJobInformerSynced = testutil.AlwaysReady
 	ctr.PodInformerSynced = testutil.AlwaysReady
 	ctr.ServiceInformerSynced = testutil.AlwaysReady
-	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
 	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
 
-	stopCh := make(chan struct{})
-	run := func(<-chan struct{}) {
-		if err := ctr.Run(testutil.ThreadCount, stopCh); err != nil {
-			t.Errorf("Failed to run the controller: %v", err)
+	tfJob := testutil.NewTFJob(2, 0)
+	tfJob.UID = "test-tfjob-uid"
+	ownedPod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	orphanPod := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+	// The pod keeps an owner which is not its controller, e.g. an additional
+	// owner reference of the tfjob.
+	additionalOwner := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "owner", UID: "owner-uid"}
+	orphanPod.OwnerReferences = []metav1.OwnerReference{additionalOwner}
+	orphanPod.UID = "orphan-pod-uid"
+	for _, pod := range []*v1.Pod{ownedPod, orphanPod} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
 		}
 	}
-	go run(stopCh)
 
-	tfJob := testutil.NewTFJob(2, 0)
-	tfJob.SelfLink = "/api/v1/namespaces/default/tfjob/test-tfjob"
-	tfJob.Spec.EnableDynamicWorker = true
-	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
-	if err != nil {
-		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	if err := ctr.repairOwnerReferences(tfJob); err != nil {
+		t.Errorf("Failed to repair owner references: %v", err)
 	}
 
-	if err := tfJobIndexer.Add(unstructured); err != nil {
-		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	if len(fakePodControl.Patches) != 1 {
+		t.Fatalf("Expected 1 patch, got %d", len(fakePodControl.Patches))
 	}
-	pod0 := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
-	pod1 := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
-	pod2 := testutil.NewPod(tfJob, testutil.LabelWorker, 2)
-
-	if err := podIndexer.Add(pod0); err != nil {
-		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	patch := string(fakePodControl.Patches[0])
+	for _, expected := range []string{`"uid":"test-tfjob-uid"`, `"controller":true`, `"uid":"orphan-pod-uid"`} {
+		if !strings.Contains(patch, expected) {
+			t.Errorf("Expected patch %s to contain %s", patch, expected)
+		}
 	}
-	if err := podIndexer.Add(pod1); err != nil {
-		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	var patched v1.Pod
+	if err := json.Unmarshal(fakePodControl.Patches[0], &patched); err != nil {
+		t.Fatalf("Failed to decode patch %s: %v", patch, err)
 	}
-	if err := podIndexer.Add(pod2); err != nil {
+	if len(patched.OwnerReferences) != 2 || !reflect.DeepEqual(patched.OwnerReferences[0], additionalOwner) {
+		t.Errorf("Expected patch %s to keep the owner reference %v", patch, additionalOwner)
+	}
+}
+
+func TestDeleteStalePods(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	// The worker 0 pod is left over by a previous tfjob of the same name.
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.UID = "old-tfjob-uid"
+	stalePod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	stalePod.Status.Phase = v1.PodRunning
+	if err := podIndexer.Add(stalePod); err != nil {
 		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
 	}
+	tfJob.UID = "test-tfjob-uid"
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
 
 	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
-	// _, err = ctr.syncTFJob(testutil.GetKey(tfJob, t))
-	// if err != nil {
-	// 	t.Errorf("%s: unexpected error when syncing jobs %v", tfJob.Name, err)
-	// }
 
-	expectedDeletePods := []string{"worker-2"}
-	if !reflect.DeepEqual(expectedDeletePods, fakePodControl.DeletePodName) {
-		t.Errorf("Scale down workers test failed")
+	if !reflect.DeepEqual(fakePodControl.DeletePodName, []string{stalePod.Name}) {
+		t.Errorf("Expected the stale pod %s to be deleted, got %v", stalePod.Name, fakePodControl.DeletePodName)
+	}
+	if len(fakePodControl.Patches) != 0 {
+		t.Errorf("Expected the stale pod not to be adopted, got patches %v", fakePodControl.Patches)
+	}
+	status := tfJob.Status.ReplicaStatuses[tfv1.TFReplicaTypeWorker]
+	if status == nil || status.Active != 0 {
+		t.Errorf("Expected the stale pod not to be counted, got %v", status)
 	}
-	close(stopCh)
 }
 
-// Test scaling up number of workers while training is running
-func TestScaleUp(t *testing.T) {
+func TestStalePodDeletionExpectations(t *testing.T) {
 	// Prepare the clientset and controller for the test.
 	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
 		Host: "",
@@ -639,72 +960,604 @@ func TestScaleUp(t *testing.T) {
 	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
 	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
 		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
-	fakePodControl := &control.FakePodControl{}
-	ctr.PodControl = fakePodControl
 	ctr.tfJobInformerSynced = testutil.AlwaysReady
 	ctr.PodInformerSynced = testutil.AlwaysReady
 	ctr.ServiceInformerSynced = testutil.AlwaysReady
-	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
 	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
 
-	stopCh := make(chan struct{})
-	run := func(<-chan struct{}) {
-		if err := ctr.Run(testutil.ThreadCount, stopCh); err != nil {
-			t.Errorf("Failed to run the controller: %v", err)
-		}
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.UID = "old-tfjob-uid"
+	stalePod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	if err := podIndexer.Add(stalePod); err != nil {
+		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
 	}
-	go run(stopCh)
-
-	tfJob := testutil.NewTFJob(3, 0)
-	tfJob.Spec.EnableDynamicWorker = true
+	tfJob.UID = "test-tfjob-uid"
 	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
 	if err != nil {
-		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		t.Fatalf("Failed to convert the TFJob to Unstructured: %v", err)
 	}
-
 	if err := tfJobIndexer.Add(unstructured); err != nil {
-		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+		t.Fatalf("Failed to add tfjob to tfJobIndexer: %v", err)
 	}
-	pod0 := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
 
-	if err := podIndexer.Add(pod0); err != nil {
-		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	if err := ctr.repairOwnerReferences(tfJob); err != nil {
+		t.Fatalf("Failed to repair owner references: %v", err)
 	}
-
-	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
-	// _, err = ctr.syncTFJob(testutil.GetKey(tfJob, t))
-	// if err != nil {
-	// 	t.Errorf("%s: unexpected error when syncing jobs %v", tfJob.Name, err)
-	// }
-
-	if !(len(fakePodControl.Templates) == 2 && fakePodControl.Templates[0].Name == "test-tfjob-worker-1" && fakePodControl.Templates[1].Name == "test-tfjob-worker-2") {
-		t.Error("Scale up workers test failed")
+	expectationPodsKey := expectation.GenExpectationPodsKey(testutil.GetKey(tfJob, t), testutil.LabelWorker)
+	if ctr.Expectations.SatisfiedExpectations(expectationPodsKey) {
+		t.Errorf("Expected the deletion of the stale pod to be expected")
 	}
 
-	close(stopCh)
+	// The stale pod is not controlled by the tfjob, its deletion is observed
+	// all the same.
+	ctr.observeStalePodDeletion(stalePod)
+	if !ctr.Expectations.SatisfiedExpectations(expectationPodsKey) {
+		t.Errorf("Expected the deletion of the stale pod to be observed")
+	}
 }
 
-func TestIsWorker0Completed(t *testing.T) {
-	newInt32 := func(in int32) *int32 {
-		return &in
+func TestIsDistributed(t *testing.T) {
+	type tc struct {
+		tfJob    *tfv1.TFJob
+		expected bool
 	}
-	tests := []struct {
-		// worker failed, succeeded, running num
-		workers     [3]int32
-		tfJob       *tfv1.TFJob
-		replicas    map[commonv1.ReplicaType]*commonv1.ReplicaSpec
-		expected    bool
-		expectedErr bool
-	}{
+	testCase := []tc{
 		{
-			workers:     [3]int32{0, 0, 1},
-			tfJob:       testutil.NewTFJobV2(1, 1, 0, 0, 0),
-			expected:    false,
-			expectedErr: false,
-			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
-				tfv1.TFReplicaTypeWorker: {
-					Replicas: newInt32(1),
-					Template: testutil.NewTFReplicaSpecTemplate(),
+			tfJob:    testutil.NewTFJob(1, 0),
+			expected: false,
+		},
+		{
+			tfJob:    testutil.NewTFJob(1, 1),
+			expected: true,
+		},
+		{
+			tfJob:    testutil.NewTFJob(0, 1),
+			expected: false,
+		},
+		{
+			tfJob:    testutil.NewTFJobWithChief(1, 0),
+			expected: true,
+		},
+	}
+	for _, c := range testCase {
+		actual := isDistributed(c.tfJob)
+		if actual != c.expected {
+			t.Errorf("Expected %t, got %t", c.expected, actual)
+		}
+	}
+}
+
+func TestRestartPolicy(t *testing.T) {
+	type tc struct {
+		tfJob                 *tfv1.TFJob
+		expectedRestartPolicy v1.RestartPolicy
+		expectedType          commonv1.ReplicaType
+		policy                *tfv1.TFReplicaPolicy
+	}
+	testCase := []tc{
+		func() tc {
+			tfJob := testutil.NewTFJob(1, 0)
+			specRestartPolicy := commonv1.RestartPolicyExitCode
+			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
+			return tc{
+				tfJob:                 tfJob,
+				expectedRestartPolicy: v1.RestartPolicyNever,
+				expectedType:          tfv1.TFReplicaTypeWorker,
+			}
+		}(),
+		func() tc {
+			tfJob := testutil.NewTFJob(1, 0)
+			specRestartPolicy := commonv1.RestartPolicyNever
+			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
+			return tc{
+				tfJob:                 tfJob,
+				expectedRestartPolicy: v1.RestartPolicyNever,
+				expectedType:          tfv1.TFReplicaTypeWorker,
+			}
+		}(),
+		func() tc {
+			tfJob := testutil.NewTFJob(1, 0)
+			specRestartPolicy := commonv1.RestartPolicyAlways
+			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
+			return tc{
+				tfJob:                 tfJob,
+				expectedRestartPolicy: v1.RestartPolicyAlways,
+				expectedType:          tfv1.TFReplicaTypeWorker,
+			}
+		}(),
+		func() tc {
+			tfJob := testutil.NewTFJob(1, 0)
+			specRestartPolicy := commonv1.RestartPolicyOnFailure
+			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
+			return tc{
+				tfJob:                 tfJob,
+				expectedRestartPolicy: v1.RestartPolicyOnFailure,
+				expectedType:          tfv1.TFReplicaTypeWorker,
+			}
+		}(),
+		func() tc {
+			tfJob := testutil.NewTFJob(1, 0)
+			specRestartPolicy := commonv1.RestartPolicyExitCode
+			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
+			return tc{
+				tfJob:                 tfJob,
+				expectedRestartPolicy: v1.RestartPolicyNever,
+				expectedType:          tfv1.TFReplicaTypeWorker,
+				policy:                &tfv1.TFReplicaPolicy{ExitCodePodRestartPolicy: v1.RestartPolicyNever},
+			}
+		}(),
+		func() tc {
+			tfJob := testutil.NewTFJob(1, 0)
+			specRestartPolicy := commonv1.RestartPolicyExitCode
+			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
+			return tc{
+				tfJob:                 tfJob,
+				expectedRestartPolicy: v1.RestartPolicyOnFailure,
+				expectedType:          tfv1.TFReplicaTypeWorker,
+				policy:                &tfv1.TFReplicaPolicy{ExitCodePodRestartPolicy: v1.RestartPolicyOnFailure},
+			}
+		}(),
+		func() tc {
+			tfJob := testutil.NewTFJob(1, 0)
+			specRestartPolicy := commonv1.RestartPolicyAlways
+			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = specRestartPolicy
+			return tc{
+				tfJob:                 tfJob,
+				expectedRestartPolicy: v1.RestartPolicyAlways,
+				expectedType:          tfv1.TFReplicaTypeWorker,
+				policy:                &tfv1.TFReplicaPolicy{ExitCodePodRestartPolicy: v1.RestartPolicyOnFailure},
+			}
+		}(),
+	}
+	for _, c := range testCase {
+		spec := c.tfJob.Spec.TFReplicaSpecs[c.expectedType]
+		podTemplate := spec.Template
+		setRestartPolicy(&podTemplate, spec, c.policy)
+		if podTemplate.Spec.RestartPolicy != c.expectedRestartPolicy {
+			t.Errorf("Expected %s, got %s", c.expectedRestartPolicy, podTemplate.Spec.RestartPolicy)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeRecorder := record.NewFakeRecorder(100)
+	ctr.Recorder = fakeRecorder
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	stopCh := make(chan struct{})
+	run := func(<-chan struct{}) {
+		if err := ctr.Run(testutil.ThreadCount, stopCh); err != nil {
+			t.Errorf("Failed to run the controller: %v", err)
+		}
+	}
+	go run(stopCh)
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = commonv1.RestartPolicyExitCode
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	pod.Status.Phase = v1.PodFailed
+	pod.Spec.Containers = append(pod.Spec.Containers, v1.Container{})
+	pod.Status.ContainerStatuses = append(pod.Status.ContainerStatuses, v1.ContainerStatus{
+		Name: tfv1.DefaultContainerName,
+		State: v1.ContainerState{
+			Terminated: &v1.ContainerStateTerminated{
+				ExitCode: 130,
+			},
+		},
+	})
+
+	if err := podIndexer.Add(pod); err != nil {
+		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	}
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	// _, err = ctr.syncTFJob(testutil.GetKey(tfJob, t))
+	// if err != nil {
+	// 	t.Errorf("%s: unexpected error when syncing jobs %v", tfJob.Name, err)
+	// }
+
+	found := false
+	for _, deletedPodName := range fakePodControl.DeletePodName {
+		if deletedPodName == pod.Name {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Failed to delete pod %s", pod.Name)
+	}
+	close(stopCh)
+
+	// The deletion is explained by an event.
+	expectedEvent := fmt.Sprintf("%s %s Deleted pod %s (worker 0): exited with retryable code 130",
+		v1.EventTypeNormal, deletedPodReason, pod.Name)
+	found = false
+	for len(fakeRecorder.Events) > 0 {
+		if <-fakeRecorder.Events == expectedEvent {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected the event %q", expectedEvent)
+	}
+}
+
+// Test scaling down number of workers while training is running
+func TestEvictedPod(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{RecreateEvictedPods: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	// A single failure would fail the tfjob.
+	tfJob := testutil.NewTFJob(2, 0)
+	backoffLimit := int32(0)
+	tfJob.Spec.RunPolicy.BackoffLimit = &backoffLimit
+	runningPod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	runningPod.Status.Phase = v1.PodRunning
+	evictedPod := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+	evictedPod.Status.Phase = v1.PodFailed
+	evictedPod.Status.Reason = evictedReason
+	evictedPod.Status.Message = "The node was low on resource: memory."
+	for _, pod := range []*v1.Pod{runningPod, evictedPod} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("unexpected error when adding pod %v", err)
+		}
+	}
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if !reflect.DeepEqual(fakePodControl.DeletePodName, []string{evictedPod.Name}) {
+		t.Errorf("Expected the evicted pod %s to be deleted, got %v", evictedPod.Name, fakePodControl.DeletePodName)
+	}
+	if failed := tfJob.Status.ReplicaStatuses[tfv1.TFReplicaTypeWorker].Failed; failed != 0 {
+		t.Errorf("Expected no failed worker, got %d", failed)
+	}
+	if isFailed(tfJob.Status) {
+		t.Errorf("Expected the tfjob not to fail, got conditions %v", tfJob.Status.Conditions)
+	}
+
+	// The evicted pod is recreated once it is gone.
+	if err := podIndexer.Delete(evictedPod); err != nil {
+		t.Errorf("unexpected error when deleting pod %v", err)
+	}
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if fakePodControl.CreateCallCount != 1 || fakePodControl.Templates[0].Labels[tfReplicaIndexLabel] != "1" {
+		t.Errorf("Expected the evicted worker to be recreated, got %d creations", fakePodControl.CreateCallCount)
+	}
+	if isFailed(tfJob.Status) {
+		t.Errorf("Expected the tfjob not to fail, got conditions %v", tfJob.Status.Conditions)
+	}
+}
+
+func TestScaleDown(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeServiceControl := &control.FakeServiceControl{}
+	ctr.ServiceControl = fakeServiceControl
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	stopCh := make(chan struct{})
+	run := func(<-chan struct{}) {
+		if err := ctr.Run(testutil.ThreadCount, stopCh); err != nil {
+			t.Errorf("Failed to run the controller: %v", err)
+		}
+	}
+	go run(stopCh)
+
+	tfJob := testutil.NewTFJob(2, 0)
+	tfJob.SelfLink = "/api/v1/namespaces/default/tfjob/test-tfjob"
+	tfJob.Spec.EnableDynamicWorker = true
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	pod0 := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	pod1 := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+	pod2 := testutil.NewPod(tfJob, testutil.LabelWorker, 2)
+
+	if err := podIndexer.Add(pod0); err != nil {
+		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	}
+	if err := podIndexer.Add(pod1); err != nil {
+		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	}
+	if err := podIndexer.Add(pod2); err != nil {
+		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	// _, err = ctr.syncTFJob(testutil.GetKey(tfJob, t))
+	// if err != nil {
+	// 	t.Errorf("%s: unexpected error when syncing jobs %v", tfJob.Name, err)
+	// }
+
+	expectedDeletePods := []string{"worker-2"}
+	if !reflect.DeepEqual(expectedDeletePods, fakePodControl.DeletePodName) {
+		t.Errorf("Scale down workers test failed")
+	}
+	expectedDeleteServices := []string{"worker-2"}
+	if !reflect.DeepEqual(expectedDeleteServices, fakeServiceControl.DeleteServiceName) {
+		t.Errorf("Expected services %v to be deleted, got %v", expectedDeleteServices, fakeServiceControl.DeleteServiceName)
+	}
+	close(stopCh)
+}
+
+// Test scaling up number of workers while training is running
+func TestScaleUp(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	stopCh := make(chan struct{})
+	run := func(<-chan struct{}) {
+		if err := ctr.Run(testutil.ThreadCount, stopCh); err != nil {
+			t.Errorf("Failed to run the controller: %v", err)
+		}
+	}
+	go run(stopCh)
+
+	tfJob := testutil.NewTFJob(3, 0)
+	tfJob.Spec.EnableDynamicWorker = true
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	pod0 := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+
+	if err := podIndexer.Add(pod0); err != nil {
+		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	// _, err = ctr.syncTFJob(testutil.GetKey(tfJob, t))
+	// if err != nil {
+	// 	t.Errorf("%s: unexpected error when syncing jobs %v", tfJob.Name, err)
+	// }
+
+	if !(len(fakePodControl.Templates) == 2 && fakePodControl.Templates[0].Name == "test-tfjob-worker-1" && fakePodControl.Templates[1].Name == "test-tfjob-worker-2") {
+		t.Error("Scale up workers test failed")
+	}
+
+	close(stopCh)
+}
+
+// Test that scaling the workers again waits for the cooldown of the last scaling
+func TestScaleCooldown(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ScaleCooldown: time.Hour})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(3, 0)
+	tfJob.Spec.EnableDynamicWorker = true
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+	for i := 0; i < 3; i++ {
+		if err := podIndexer.Add(testutil.NewPod(tfJob, testutil.LabelWorker, i)); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+	}
+
+	// The first scaling deletes worker-2 and starts the cooldown.
+	replicas := int32(2)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Replicas = &replicas
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	// The second scaling, within the cooldown, is deferred.
+	replicas = 1
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	expectedDeletePods := []string{"worker-2"}
+	if !reflect.DeepEqual(expectedDeletePods, fakePodControl.DeletePodName) {
+		t.Errorf("Expected pods %v to be deleted, got %v", expectedDeletePods, fakePodControl.DeletePodName)
+	}
+	updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	if _, ok := updated.Annotations[tfv1.LastScaleTimeAnnotation]; !ok {
+		t.Errorf("Expected the %s annotation to be set", tfv1.LastScaleTimeAnnotation)
+	}
+}
+
+func TestIsWorker0Completed(t *testing.T) {
+	newInt32 := func(in int32) *int32 {
+		return &in
+	}
+	tests := []struct {
+		// worker failed, succeeded, running num
+		workers     [3]int32
+		tfJob       *tfv1.TFJob
+		replicas    map[commonv1.ReplicaType]*commonv1.ReplicaSpec
+		expected    bool
+		expectedErr bool
+	}{
+		{
+			workers:     [3]int32{0, 0, 1},
+			tfJob:       testutil.NewTFJobV2(1, 1, 0, 0, 0),
+			expected:    false,
+			expectedErr: false,
+			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: {
+					Replicas: newInt32(1),
+					Template: testutil.NewTFReplicaSpecTemplate(),
 				},
 				tfv1.TFReplicaTypePS: {
 					Replicas: newInt32(1),
@@ -713,67 +1566,2493 @@ func TestIsWorker0Completed(t *testing.T) {
 			},
 		},
 		{
-			workers:     [3]int32{0, 1, 0},
-			tfJob:       testutil.NewTFJobV2(1, 0, 0, 0, 0),
-			expected:    true,
-			expectedErr: false,
-			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
-				tfv1.TFReplicaTypeWorker: {
-					Replicas: newInt32(1),
-					Template: testutil.NewTFReplicaSpecTemplate(),
-				},
-			},
+			workers:     [3]int32{0, 1, 0},
+			tfJob:       testutil.NewTFJobV2(1, 0, 0, 0, 0),
+			expected:    true,
+			expectedErr: false,
+			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: {
+					Replicas: newInt32(1),
+					Template: testutil.NewTFReplicaSpecTemplate(),
+				},
+			},
+		},
+		{
+			workers:     [3]int32{0, 0, 0},
+			tfJob:       testutil.NewTFJobV2(0, 0, 1, 0, 0),
+			expected:    true,
+			expectedErr: false,
+			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeMaster: {
+					Replicas: newInt32(1),
+					Template: testutil.NewTFReplicaSpecTemplate(),
+				},
+			},
+		},
+		{
+			workers:     [3]int32{0, 0, 0},
+			tfJob:       testutil.NewTFJobV2(0, 0, 0, 1, 0),
+			expected:    true,
+			expectedErr: false,
+			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeChief: {
+					Replicas: newInt32(1),
+					Template: testutil.NewTFReplicaSpecTemplate(),
+				},
+			},
+		},
+		{
+			workers:     [3]int32{1, 1, 0},
+			tfJob:       testutil.NewTFJobV2(2, 0, 0, 0, 0),
+			expected:    true,
+			expectedErr: false,
+			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: {
+					Replicas: newInt32(2),
+					Template: testutil.NewTFReplicaSpecTemplate(),
+				},
+			},
+		},
+		{
+			workers:     [3]int32{1, 0, 1},
+			tfJob:       testutil.NewTFJobV2(2, 0, 0, 0, 0),
+			expected:    false,
+			expectedErr: false,
+			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
+				tfv1.TFReplicaTypeWorker: {
+					Replicas: newInt32(2),
+					Template: testutil.NewTFReplicaSpecTemplate(),
+				},
+			},
+		},
+	}
+	for _, tt := range tests {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		ctr.tfJobInformerSynced = testutil.AlwaysReady
+		ctr.PodInformerSynced = testutil.AlwaysReady
+		ctr.ServiceInformerSynced = testutil.AlwaysReady
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+		// only related to worker status
+		initializeReplicaStatuses(&tt.tfJob.Status, tfv1.TFReplicaTypeWorker)
+		// set status and add pod to indexer
+		setStatusForTest(tt.tfJob, tfv1.TFReplicaTypeWorker, tt.workers[0], tt.workers[1], tt.workers[2], false, true, podIndexer, t)
+
+		got, err := ctr.IsWorker0Completed(tt.tfJob, tt.replicas)
+		if (err != nil) != tt.expectedErr {
+			t.Errorf("IsWorker0Completed() error = %v, wantErr %v", err, tt.expectedErr)
+			return
+		}
+		if got != tt.expected {
+			t.Errorf("IsWorker0Completed() got = %v, want %v", got, tt.expected)
+		}
+	}
+}
+
+func TestStandbyPromotion(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(2, 0)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = commonv1.RestartPolicyExitCode
+	standbyReplicas := int32(1)
+	tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+		tfv1.TFReplicaTypeWorker: {StandbyReplicas: &standbyReplicas},
+	}
+
+	runningPod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	runningPod.Status.Phase = v1.PodRunning
+	failedPod := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+	failedPod.Status.Phase = v1.PodFailed
+	failedPod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name: tfv1.DefaultContainerName,
+		State: v1.ContainerState{
+			Terminated: &v1.ContainerStateTerminated{
+				ExitCode: 130,
+			},
+		},
+	}}
+	standbyPod := testutil.NewBasePod("standby-0", tfJob)
+	standbyPod.Labels[tfReplicaTypeLabel] = testutil.LabelWorker
+	standbyPod.Labels[tfStandbyLabel] = "true"
+	standbyPod.Status.Phase = v1.PodRunning
+	for _, pod := range []*v1.Pod{runningPod, failedPod, standbyPod} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+	}
+
+	// The failed worker is deleted, and the standby pod is kept.
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	if !reflect.DeepEqual(fakePodControl.DeletePodName, []string{failedPod.Name}) {
+		t.Errorf("Expected the failed pod to be deleted, got %v", fakePodControl.DeletePodName)
+	}
+	if len(fakePodControl.Templates) != 0 || len(fakePodControl.Patches) != 0 {
+		t.Errorf("Expected no pod to be created or patched, got %d created and %d patched",
+			len(fakePodControl.Templates), len(fakePodControl.Patches))
+	}
+
+	// Once the failed worker is gone, the standby pod replaces it instead of a new pod.
+	tfJob.Status = commonv1.JobStatus{}
+	if err := podIndexer.Delete(failedPod); err != nil {
+		t.Errorf("%s: unexpected error when deleting pod %v", tfJob.Name, err)
+	}
+	fakePodControl = &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if len(fakePodControl.Patches) != 1 {
+		t.Fatalf("Expected 1 patch, got %d", len(fakePodControl.Patches))
+	}
+	patch := string(fakePodControl.Patches[0])
+	for _, expected := range []string{`"replica-index":"1"`, `"standby":null`, tfConfigAnnotation} {
+		if !strings.Contains(patch, expected) {
+			t.Errorf("Expected patch %s to contain %s", patch, expected)
+		}
+	}
+	// A new standby pod is created in place of the promoted one.
+	if len(fakePodControl.Templates) != 1 {
+		t.Fatalf("Expected 1 pod to be created, got %d", len(fakePodControl.Templates))
+	}
+	template := fakePodControl.Templates[0]
+	if template.Labels[tfStandbyLabel] != "true" {
+		t.Errorf("Expected a standby pod to be created, got labels %v", template.Labels)
+	}
+	if _, ok := template.Labels[tfReplicaIndexLabel]; ok {
+		t.Errorf("Expected the standby pod to have no replica index, got labels %v", template.Labels)
+	}
+	if template.Annotations[tfConfigAnnotation] != "" {
+		t.Errorf("Expected the standby pod to have an empty TF_CONFIG, got %s", template.Annotations[tfConfigAnnotation])
+	}
+}
+
+func TestFailedStandbyPod(t *testing.T) {
+	testCases := []struct {
+		description string
+		rtype       commonv1.ReplicaType
+		update      func(tfJob *tfv1.TFJob)
+	}{
+		{
+			description: "A failed standby worker does not count against the backoff limit",
+			rtype:       tfv1.TFReplicaTypeWorker,
+			update: func(tfJob *tfv1.TFJob) {
+				backoffLimit := int32(0)
+				tfJob.Spec.RunPolicy.BackoffLimit = &backoffLimit
+			},
+		},
+		{
+			description: "A failed standby PS does not fail the tfjob",
+			rtype:       tfv1.TFReplicaTypePS,
+			update: func(tfJob *tfv1.TFJob) {
+				policy := tfv1.PSFailurePolicyFailJob
+				tfJob.Spec.PSFailurePolicy = &policy
+			},
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		ctr.PodControl = &control.FakePodControl{}
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		tfJob := testutil.NewTFJob(2, 1)
+		standbyReplicas := int32(1)
+		tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+			c.rtype: {StandbyReplicas: &standbyReplicas},
+		}
+		c.update(tfJob)
+		fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+		ctr.tfJobClientSet = fakeClientSet
+
+		// One of the workers is not created yet, so that the tfjob is not
+		// fully active.
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 1, 0, 0, nil, t)
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelPS, 0, 1, 0, 0, nil, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+		standbyPod := testutil.NewBasePod("standby-0", tfJob)
+		standbyPod.Labels[tfReplicaTypeLabel] = strings.ToLower(string(c.rtype))
+		standbyPod.Labels[tfStandbyLabel] = "true"
+		standbyPod.Status.Phase = v1.PodFailed
+		if err := podIndexer.Add(standbyPod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+		}
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		// The failed standby pod is not a replica, so the tfjob keeps running.
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", c.description, err)
+		}
+		if condition := tfv1.GetCondition(updated.Status, commonv1.JobFailed); condition != nil {
+			t.Errorf("%s: Expected the tfjob not to fail, got %v", c.description, updated.Status.Conditions)
+		}
+	}
+}
+
+func TestDNS(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	ndots := "2"
+	templateNdots := "5"
+	tfJob := testutil.NewTFJob(1, 1)
+	tfJob.Spec.DNSPolicy = v1.DNSClusterFirst
+	tfJob.Spec.DNSConfig = &v1.PodDNSConfig{
+		Options: []v1.PodDNSConfigOption{{Name: "ndots", Value: &ndots}},
+	}
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	workerSpec.Template.Spec.DNSPolicy = v1.DNSNone
+	workerSpec.Template.Spec.DNSConfig = &v1.PodDNSConfig{
+		Nameservers: []string{"10.0.0.10"},
+		Options:     []v1.PodDNSConfigOption{{Name: "ndots", Value: &templateNdots}},
+	}
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if err := ctr.createNewPod(context.TODO(), tfJob, "ps", "0", tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS],
+		false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if len(fakePodControl.Templates) != 2 {
+		t.Fatalf("Expected 2 pods to be created, got %d", len(fakePodControl.Templates))
+	}
+
+	// The worker template specifies its own DNS settings, which are kept.
+	worker := fakePodControl.Templates[0]
+	if worker.Spec.DNSPolicy != v1.DNSNone {
+		t.Errorf("Expected DNS policy %s in %s, got %s", v1.DNSNone, worker.Name, worker.Spec.DNSPolicy)
+	}
+	if !reflect.DeepEqual(worker.Spec.DNSConfig, workerSpec.Template.Spec.DNSConfig) {
+		t.Errorf("Expected DNS config %v in %s, got %v", workerSpec.Template.Spec.DNSConfig, worker.Name, worker.Spec.DNSConfig)
+	}
+
+	// The ps template does not, so the job level DNS settings are injected.
+	ps := fakePodControl.Templates[1]
+	if ps.Spec.DNSPolicy != v1.DNSClusterFirst {
+		t.Errorf("Expected DNS policy %s in %s, got %s", v1.DNSClusterFirst, ps.Name, ps.Spec.DNSPolicy)
+	}
+	if ps.Spec.DNSConfig == nil || len(ps.Spec.DNSConfig.Options) != 1 ||
+		ps.Spec.DNSConfig.Options[0].Name != "ndots" || *ps.Spec.DNSConfig.Options[0].Value != ndots {
+		t.Errorf("Expected ndots %s in %s, got %v", ndots, ps.Name, ps.Spec.DNSConfig)
+	}
+}
+
+func TestSecurityContext(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	runAsNonRoot := true
+	privileged := true
+	podSecurityContext := &v1.PodSecurityContext{RunAsNonRoot: &runAsNonRoot}
+	containerSecurityContext := &v1.SecurityContext{
+		Capabilities: &v1.Capabilities{Drop: []v1.Capability{"ALL"}},
+	}
+	templateSecurityContext := &v1.SecurityContext{Privileged: &privileged}
+
+	tfJob := testutil.NewTFJob(1, 1)
+	tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+		tfv1.TFReplicaTypeWorker: {
+			SecurityContext:          podSecurityContext,
+			ContainerSecurityContext: containerSecurityContext,
+		},
+	}
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	workerSpec.Template.Spec.Containers = append(workerSpec.Template.Spec.Containers, v1.Container{
+		Name:            "sidecar",
+		Image:           "sidecar",
+		SecurityContext: templateSecurityContext,
+	})
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if err := ctr.createNewPod(context.TODO(), tfJob, "ps", "0", tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS],
+		false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if len(fakePodControl.Templates) != 2 {
+		t.Fatalf("Expected 2 pods to be created, got %d", len(fakePodControl.Templates))
+	}
+
+	worker := fakePodControl.Templates[0]
+	if !reflect.DeepEqual(worker.Spec.SecurityContext, podSecurityContext) {
+		t.Errorf("Expected pod security context %v in %s, got %v", podSecurityContext, worker.Name, worker.Spec.SecurityContext)
+	}
+	// The tensorflow container had no security context, the sidecar keeps its own.
+	expected := []*v1.SecurityContext{containerSecurityContext, templateSecurityContext}
+	for i, container := range worker.Spec.Containers {
+		if !reflect.DeepEqual(container.SecurityContext, expected[i]) {
+			t.Errorf("Expected security context %v in container %s, got %v", expected[i], container.Name, container.SecurityContext)
+		}
+	}
+
+	// The ps replica has no policy, so nothing is injected.
+	ps := fakePodControl.Templates[1]
+	if ps.Spec.SecurityContext != nil || ps.Spec.Containers[0].SecurityContext != nil {
+		t.Errorf("Expected no security context in %s, got %v and %v",
+			ps.Name, ps.Spec.SecurityContext, ps.Spec.Containers[0].SecurityContext)
+	}
+}
+
+func TestTerminationGracePeriod(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	workerGracePeriod := int64(30)
+	psGracePeriod := int64(600)
+	templateGracePeriod := int64(5)
+	tfJob := testutil.NewTFJob(2, 1)
+	tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+		tfv1.TFReplicaTypeWorker: {TerminationGracePeriodSeconds: &workerGracePeriod},
+		tfv1.TFReplicaTypePS:     {TerminationGracePeriodSeconds: &psGracePeriod},
+	}
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	psSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS]
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if err := ctr.createNewPod(context.TODO(), tfJob, "ps", "0", psSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	// A grace period in the template is kept.
+	workerSpec.Template.Spec.TerminationGracePeriodSeconds = &templateGracePeriod
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "1", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if len(fakePodControl.Templates) != 3 {
+		t.Fatalf("Expected 3 pods to be created, got %d", len(fakePodControl.Templates))
+	}
+
+	expected := []int64{workerGracePeriod, psGracePeriod, templateGracePeriod}
+	for i, pod := range fakePodControl.Templates {
+		if pod.Spec.TerminationGracePeriodSeconds == nil || *pod.Spec.TerminationGracePeriodSeconds != expected[i] {
+			t.Errorf("Expected termination grace period %d in %s, got %v", expected[i], pod.Name, pod.Spec.TerminationGracePeriodSeconds)
+		}
+	}
+}
+
+func TestPreStopHook(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	sleepSeconds := int32(30)
+	drain := []string{"/bin/drain", "--checkpoint"}
+	templateHook := &v1.Handler{Exec: &v1.ExecAction{Command: []string{"/bin/stop"}}}
+	tfJob := testutil.NewTFJob(2, 1)
+	tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+		tfv1.TFReplicaTypeWorker: {PreStopSleepSeconds: &sleepSeconds},
+		tfv1.TFReplicaTypePS:     {PreStopCommand: drain},
+	}
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	psSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS]
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if err := ctr.createNewPod(context.TODO(), tfJob, "ps", "0", psSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	// A preStop hook in the template is kept.
+	workerSpec.Template.Spec.Containers[0].Lifecycle = &v1.Lifecycle{PreStop: templateHook}
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "1", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	// No hook is injected without a replica policy.
+	localTFJob := testutil.NewTFJob(1, 0)
+	if err := ctr.createNewPod(context.TODO(), localTFJob, "worker", "0", localTFJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker], false, localTFJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if len(fakePodControl.Templates) != 4 {
+		t.Fatalf("Expected 4 pods to be created, got %d", len(fakePodControl.Templates))
+	}
+
+	expected := []*v1.Lifecycle{
+		{PreStop: &v1.Handler{Exec: &v1.ExecAction{Command: []string{"sleep", "30"}}}},
+		{PreStop: &v1.Handler{Exec: &v1.ExecAction{Command: drain}}},
+		{PreStop: templateHook},
+		nil,
+	}
+	for i, pod := range fakePodControl.Templates {
+		if got := pod.Spec.Containers[0].Lifecycle; !reflect.DeepEqual(got, expected[i]) {
+			t.Errorf("Expected lifecycle %v in %s, got %v", expected[i], pod.Name, got)
+		}
+	}
+}
+
+func TestGPULimits(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	gpu := v1.ResourceName("nvidia.com/gpu")
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{GPUResourceName: string(gpu)})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	tfJob := testutil.NewTFJob(1, 0)
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	// The tensorflow container only limits the cpu, the sidecar limits its GPUs.
+	workerSpec.Template.Spec.Containers[0].Resources = v1.ResourceRequirements{
+		Requests: v1.ResourceList{gpu: resource.MustParse("2")},
+		Limits:   v1.ResourceList{v1.ResourceCPU: resource.MustParse("4")},
+	}
+	workerSpec.Template.Spec.Containers = append(workerSpec.Template.Spec.Containers, v1.Container{
+		Name:  "sidecar",
+		Image: "sidecar",
+		Resources: v1.ResourceRequirements{
+			Requests: v1.ResourceList{gpu: resource.MustParse("1")},
+			Limits:   v1.ResourceList{gpu: resource.MustParse("3")},
+		},
+	})
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+
+	containers := fakePodControl.Templates[0].Spec.Containers
+	expected := []v1.ResourceList{
+		{gpu: resource.MustParse("2"), v1.ResourceCPU: resource.MustParse("4")},
+		{gpu: resource.MustParse("3")},
+	}
+	for i, container := range containers {
+		if !reflect.DeepEqual(container.Resources.Limits, expected[i]) {
+			t.Errorf("Expected limits %v in container %s, got %v", expected[i], container.Name, container.Resources.Limits)
+		}
+	}
+	// The template of the tfjob is left untouched.
+	if _, ok := workerSpec.Template.Spec.Containers[0].Resources.Limits[gpu]; ok {
+		t.Errorf("Expected the template of the tfjob not to be changed")
+	}
+}
+
+func TestSidecarExitCode(t *testing.T) {
+	testCases := []struct {
+		description        string
+		exitCodeContainers string
+		expectedSucceeded  int32
+		expectedFailed     int32
+	}{
+		{
+			description:       "Only the tensorflow container counts by default",
+			expectedSucceeded: 1,
+		},
+		{
+			description:        "All the containers count",
+			exitCodeContainers: options.ExitCodeContainersAll,
+			expectedFailed:     1,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ExitCodeContainers: c.exitCodeContainers})
+		ctr.PodControl = &control.FakePodControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+		// The tensorflow container succeeded, but the logging sidecar failed.
+		tfJob := testutil.NewTFJob(2, 0)
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+		pod.Status.Phase = v1.PodFailed
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{
+			{
+				Name:  tfv1.DefaultContainerName,
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 0}},
+			},
+			{
+				Name:  "logging",
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 1}},
+			},
+		}
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+		}
+		initializeReplicaStatuses(&tfJob.Status, tfv1.TFReplicaTypeWorker)
+
+		err := ctr.reconcilePods(context.TODO(), tfJob, &tfJob.Status, []*v1.Pod{pod}, tfv1.TFReplicaTypeWorker,
+			tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker], tfJob.Spec.TFReplicaSpecs, newCreateBudget(0))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", c.description, err)
+		}
+		status := tfJob.Status.ReplicaStatuses[tfv1.TFReplicaTypeWorker]
+		if status.Succeeded != c.expectedSucceeded || status.Failed != c.expectedFailed {
+			t.Errorf("%s: Expected %d succeeded and %d failed, got %d and %d",
+				c.description, c.expectedSucceeded, c.expectedFailed, status.Succeeded, status.Failed)
+		}
+
+		if err := ctr.UpdateJobStatus(tfJob, tfJob.Spec.TFReplicaSpecs, &tfJob.Status); err != nil {
+			t.Errorf("%s: unexpected error %v", c.description, err)
+		}
+		if failed := isFailed(tfJob.Status); failed != (c.expectedFailed > 0) {
+			t.Errorf("%s: Expected failed %v, got %v", c.description, c.expectedFailed > 0, failed)
+		}
+	}
+}
+
+func TestTemplatedEnv(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, options.ServerOption{
+		TemplatedEnv: "CHECKPOINT_DIR=/checkpoints/{{.Namespace}}/{{.Name}}/{{.ReplicaType}}-{{.ReplicaIndex}}",
+	})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	tfJob := testutil.NewTFJob(2, 0)
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "1", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+
+	expected := "/checkpoints/" + tfJob.Namespace + "/" + tfJob.Name + "/worker-1"
+	found := false
+	for _, env := range fakePodControl.Templates[0].Spec.Containers[0].Env {
+		if env.Name == "CHECKPOINT_DIR" {
+			found = true
+			if env.Value != expected {
+				t.Errorf("Expected CHECKPOINT_DIR %s, got %s", expected, env.Value)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("Expected CHECKPOINT_DIR to be set, got %v", fakePodControl.Templates[0].Spec.Containers[0].Env)
+	}
+
+	// A template which does not render for the tfjob stops its reconciliation.
+	ctr.envTemplate, _ = parseEnvTemplate("CHECKPOINT_DIR={{.Missing}}")
+	ctr.Recorder = &record.FakeRecorder{}
+	if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err == nil {
+		t.Errorf("Expected an error rendering the templated env")
+	}
+	if len(fakePodControl.Templates) != 1 {
+		t.Errorf("Expected no pod to be created, got %d", len(fakePodControl.Templates)-1)
+	}
+
+	if _, err := parseEnvTemplate("{{.Name}}"); err == nil {
+		t.Errorf("Expected an error parsing a templated env without name")
+	}
+}
+
+func TestWaitForPSDNS(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{WaitForPSDNS: true, WaitForPSDNSImage: "busybox"})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	tfJob := testutil.NewTFJob(1, 2)
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	workerSpec.Template.Spec.InitContainers = []v1.Container{{Name: "init", Image: "init"}}
+	psSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS]
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+	if err := ctr.createNewPod(context.TODO(), tfJob, "ps", "0", psSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+
+	// The init container of the worker runs first, and waits for both PS.
+	initContainers := fakePodControl.Templates[0].Spec.InitContainers
+	if len(initContainers) != 2 || initContainers[0].Name != waitForPSContainerName {
+		t.Fatalf("Expected the %s init container to be injected first, got %v", waitForPSContainerName, initContainers)
+	}
+	expectedHosts := []string{
+		tfJob.Name + "-ps-0." + tfJob.Namespace + ".svc",
+		tfJob.Name + "-ps-1." + tfJob.Namespace + ".svc",
+	}
+	if !reflect.DeepEqual(expectedHosts, initContainers[0].Args) {
+		t.Errorf("Expected to wait on %v, got %v", expectedHosts, initContainers[0].Args)
+	}
+	if initContainers[0].Image != "busybox" {
+		t.Errorf("Expected image busybox, got %s", initContainers[0].Image)
+	}
+	// The PS do not wait for themselves.
+	if len(fakePodControl.Templates[1].Spec.InitContainers) != 0 {
+		t.Errorf("Expected no init container in the PS pod, got %v", fakePodControl.Templates[1].Spec.InitContainers)
+	}
+}
+
+func TestTFConfigSecret(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{TFConfigFile: true, TFConfigSecret: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeKubeClientSet := kubefake.NewSimpleClientset()
+	ctr.KubeClientSet = fakeKubeClientSet
+
+	tfJob := testutil.NewTFJob(2, 1)
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "1", tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker], false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+
+	secretName := "test-tfjob-worker-1-tf-config"
+	secret, err := fakeKubeClientSet.CoreV1().Secrets(tfJob.Namespace).Get(context.TODO(), secretName, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Expected the secret %s to be created, got error %v", secretName, err)
+	}
+	expected, err := genTFConfigJSONStr(tfJob, "worker", "1")
+	if err != nil {
+		t.Fatalf("Failed to generate TF_CONFIG: %v", err)
+	}
+	if got := string(secret.Data[tfConfigFileName]); got != expected {
+		t.Errorf("Expected TF_CONFIG %s in the secret, got %s", expected, got)
+	}
+
+	podTemplate := fakePodControl.Templates[0]
+	if _, ok := podTemplate.Annotations[tfConfigAnnotation]; ok {
+		t.Errorf("Expected TF_CONFIG not to be set in the annotations")
+	}
+	mounted := false
+	for _, volume := range podTemplate.Spec.Volumes {
+		if volume.Name == tfConfigVolumeName && volume.Secret != nil && volume.Secret.SecretName == secretName {
+			mounted = true
+		}
+	}
+	if !mounted {
+		t.Errorf("Expected the secret %s to be mounted, got volumes %v", secretName, podTemplate.Spec.Volumes)
+	}
+	for _, c := range podTemplate.Spec.Containers {
+		if c.Name == tfv1.DefaultContainerName && !reflect.DeepEqual(c.VolumeMounts, []v1.VolumeMount{{Name: tfConfigVolumeName, MountPath: tfConfigMountPath, ReadOnly: true}}) {
+			t.Errorf("Expected the TF_CONFIG volume to be mounted, got %v", c.VolumeMounts)
+		}
+	}
+
+	// The secrets are deleted when the tfjob finishes.
+	if err := ctr.deleteTFConfigSecrets(tfJob); err != nil {
+		t.Fatalf("Failed to delete the secrets: %v", err)
+	}
+	if _, err := fakeKubeClientSet.CoreV1().Secrets(tfJob.Namespace).Get(context.TODO(), secretName, metav1.GetOptions{}); err == nil {
+		t.Errorf("Expected the secret %s to be deleted", secretName)
+	}
+}
+
+func TestReplicaLabels(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	tfJob := testutil.NewTFJob(1, 1)
+	tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+		tfv1.TFReplicaTypeWorker: {
+			Labels: map[string]string{
+				"monitoring": "tf-worker",
+				"team":       "policy",
+				// The labels owned by the controller are not overridden.
+				tfReplicaTypeLabel:    "ps",
+				commonv1.JobRoleLabel: "master",
+			},
+		},
+		tfv1.TFReplicaTypePS: {
+			Labels: map[string]string{"monitoring": "tf-ps"},
+		},
+	}
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	workerSpec.Template.Labels = map[string]string{"team": "template"}
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if err := ctr.createNewPod(context.TODO(), tfJob, "ps", "0", tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS],
+		false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if len(fakePodControl.Templates) != 2 {
+		t.Fatalf("Expected 2 pods to be created, got %d", len(fakePodControl.Templates))
+	}
+
+	worker := fakePodControl.Templates[0]
+	expectedWorkerLabels := map[string]string{
+		"monitoring":        "tf-worker",
+		"team":              "template",
+		tfReplicaTypeLabel:  "worker",
+		tfReplicaIndexLabel: "0",
+	}
+	for key, value := range expectedWorkerLabels {
+		if worker.Labels[key] != value {
+			t.Errorf("Expected label %s=%s in %s, got %q", key, value, worker.Name, worker.Labels[key])
+		}
+	}
+	if role, ok := worker.Labels[commonv1.JobRoleLabel]; ok {
+		t.Errorf("Expected no %s label in %s, got %q", commonv1.JobRoleLabel, worker.Name, role)
+	}
+
+	ps := fakePodControl.Templates[1]
+	if ps.Labels["monitoring"] != "tf-ps" {
+		t.Errorf("Expected label monitoring=tf-ps in %s, got %q", ps.Name, ps.Labels["monitoring"])
+	}
+}
+
+func TestImageResolver(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.Recorder = &record.FakeRecorder{}
+
+	digest := "test-image-for-kubeflow-tf-operator@sha256:0123456789abcdef"
+	ctr.ImageResolver = func(image string) (string, error) {
+		if image != testutil.TestImageName {
+			return "", fmt.Errorf("unknown image %s", image)
+		}
+		return digest, nil
+	}
+
+	tfJob := testutil.NewTFJob(1, 0)
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Errorf("Expected get nil, got error %v", err)
+	}
+	if len(fakePodControl.Templates) != 1 {
+		t.Fatalf("Expected 1 pod to be created, got %d", len(fakePodControl.Templates))
+	}
+	if image := fakePodControl.Templates[0].Spec.Containers[0].Image; image != digest {
+		t.Errorf("Expected image %s, got %s", digest, image)
+	}
+	if workerSpec.Template.Spec.Containers[0].Image != testutil.TestImageName {
+		t.Errorf("Expected the replica spec to be left untouched, got image %s", workerSpec.Template.Spec.Containers[0].Image)
+	}
+
+	// A resolver error fails the creation, and the pod is not created.
+	workerSpec.Template.Spec.Containers[0].Image = "unknown:latest"
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "1", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err == nil {
+		t.Errorf("Expected an error for an image which can not be resolved")
+	}
+	if len(fakePodControl.Templates) != 1 {
+		t.Errorf("Expected no more pods to be created, got %d", len(fakePodControl.Templates))
+	}
+}
+
+func TestReplicaDeadline(t *testing.T) {
+	type tc struct {
+		name           string
+		failJob        bool
+		expectedFailed bool
+	}
+	testCases := []tc{
+		{
+			name:           "delete replicas past deadline",
+			failJob:        false,
+			expectedFailed: false,
+		},
+		{
+			name:           "fail job when replicas are past deadline",
+			failJob:        true,
+			expectedFailed: true,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		ctr.tfJobInformerSynced = testutil.AlwaysReady
+		ctr.PodInformerSynced = testutil.AlwaysReady
+		ctr.ServiceInformerSynced = testutil.AlwaysReady
+		tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		tfJob := testutil.NewTFJob(2, 1)
+		deadline := int64(10)
+		tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+			tfv1.TFReplicaTypeWorker: {
+				ActiveDeadlineSeconds:     &deadline,
+				FailJobOnDeadlineExceeded: c.failJob,
+			},
+		}
+		startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+		tfJob.Status.StartTime = &startTime
+		unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+		if err != nil {
+			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+		if err := tfJobIndexer.Add(unstructured); err != nil {
+			t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+		}
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 2, 0, 0, nil, t)
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelPS, 0, 1, 0, 0, nil, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		if len(fakePodControl.DeletePodName) != 2 {
+			t.Errorf("%s: expected 2 pods to be deleted, got %v", c.name, fakePodControl.DeletePodName)
+		}
+		for _, name := range fakePodControl.DeletePodName {
+			if !strings.HasPrefix(name, testutil.LabelWorker) {
+				t.Errorf("%s: expected only worker pods to be deleted, got %s", c.name, name)
+			}
+		}
+		if len(fakePodControl.Templates) != 0 {
+			t.Errorf("%s: expected no pod to be created, got %d", c.name, len(fakePodControl.Templates))
+		}
+
+		failed := false
+		for _, condition := range tfJob.Status.Conditions {
+			if condition.Type == commonv1.JobFailed && condition.Status == v1.ConditionTrue {
+				failed = true
+				if condition.Reason != TFJobFailedReasonReplicaDeadline {
+					t.Errorf("%s: expected reason %s, got %s", c.name, TFJobFailedReasonReplicaDeadline, condition.Reason)
+				}
+			}
+		}
+		if failed != c.expectedFailed {
+			t.Errorf("%s: expected failed %v, got %v", c.name, c.expectedFailed, failed)
+		}
+	}
+}
+
+func TestWaitForPS(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{WaitForPS: true})
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	// Only the first of the two PS has been created.
+	tfJob := testutil.NewTFJob(1, 2)
+	if err := podIndexer.Add(testutil.NewPod(tfJob, testutil.LabelPS, 0)); err != nil {
+		t.Errorf("unexpected error when adding pod %v", err)
+	}
+
+	podTemplate := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Template.DeepCopy()
+	if err := ctr.SetClusterSpec(tfJob, podTemplate, testutil.LabelWorker, "0"); err != nil {
+		t.Fatalf("Failed to set cluster spec: %v", err)
+	}
+	tfConfig := TFConfig{}
+	if err := json.Unmarshal([]byte(podTemplate.Spec.Containers[0].Env[0].Value), &tfConfig); err != nil {
+		t.Fatalf("Failed to unmarshal TF_CONFIG: %v", err)
+	}
+	ps := tfConfig.Cluster[testutil.LabelPS]
+	if len(ps) != 1 || !strings.HasPrefix(ps[0], common.GenGeneralName(tfJob.Name, testutil.LabelPS, "0")+".") {
+		t.Errorf("Expected only the created PS in TF_CONFIG, got %v", ps)
+	}
+	if len(tfConfig.Cluster[testutil.LabelWorker]) != 1 {
+		t.Errorf("Expected 1 worker in TF_CONFIG, got %v", tfConfig.Cluster[testutil.LabelWorker])
+	}
+	key, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Errorf("Failed to get the key of the TFJob: %v", err)
+	}
+	if requeues := ctr.WorkQueue.NumRequeues(key); requeues != 1 {
+		t.Errorf("Expected the tfjob to be requeued once, got %d", requeues)
+	}
+
+	// The TF_CONFIG of the PS itself lists all of them.
+	podTemplate = tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS].Template.DeepCopy()
+	if err := ctr.SetClusterSpec(tfJob, podTemplate, testutil.LabelPS, "1"); err != nil {
+		t.Fatalf("Failed to set cluster spec: %v", err)
+	}
+	tfConfig = TFConfig{}
+	if err := json.Unmarshal([]byte(podTemplate.Spec.Containers[0].Env[0].Value), &tfConfig); err != nil {
+		t.Fatalf("Failed to unmarshal TF_CONFIG: %v", err)
+	}
+	if len(tfConfig.Cluster[testutil.LabelPS]) != 2 {
+		t.Errorf("Expected 2 PS in the TF_CONFIG of a PS, got %v", tfConfig.Cluster[testutil.LabelPS])
+	}
+}
+
+func TestGetActiveReplicaIndices(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	// Workers 0 and 2 are live, worker 1 has failed, and the PS is live.
+	tfJob := testutil.NewTFJob(3, 1)
+	phases := []v1.PodPhase{v1.PodRunning, v1.PodFailed, v1.PodPending}
+	for i, phase := range phases {
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, i)
+		pod.Status.Phase = phase
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("unexpected error when adding pod %v", err)
+		}
+	}
+	ps := testutil.NewPod(tfJob, testutil.LabelPS, 0)
+	ps.Status.Phase = v1.PodRunning
+	if err := podIndexer.Add(ps); err != nil {
+		t.Errorf("unexpected error when adding pod %v", err)
+	}
+
+	indices, err := ctr.GetActiveReplicaIndices(tfJob, tfv1.TFReplicaTypeWorker)
+	if err != nil {
+		t.Fatalf("Expected nil, got error %v", err)
+	}
+	if expected := []int{0, 2}; !reflect.DeepEqual(indices, expected) {
+		t.Errorf("Expected active indices %v, got %v", expected, indices)
+	}
+}
+
+func TestHorovodRankEnv(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{HorovodRankEnv: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.Recorder = &record.FakeRecorder{}
+
+	tfJob := testutil.NewTFJobWithChief(3, 1)
+	testCases := []struct {
+		rtype        commonv1.ReplicaType
+		index        string
+		expectedRank string
+	}{
+		{tfv1.TFReplicaTypeChief, "0", "0"},
+		{tfv1.TFReplicaTypeWorker, "0", "1"},
+		{tfv1.TFReplicaTypeWorker, "1", "2"},
+		{tfv1.TFReplicaTypeWorker, "2", "3"},
+		{tfv1.TFReplicaTypePS, "0", ""},
+	}
+	for i, c := range testCases {
+		rt := strings.ToLower(string(c.rtype))
+		if err := ctr.createNewPod(context.TODO(), tfJob, rt, c.index, tfJob.Spec.TFReplicaSpecs[c.rtype],
+			c.rtype == tfv1.TFReplicaTypeChief, tfJob.Spec.TFReplicaSpecs); err != nil {
+			t.Fatalf("Expected get nil, got error %v", err)
+		}
+		env := map[string]string{}
+		for _, e := range fakePodControl.Templates[i].Spec.Containers[0].Env {
+			env[e.Name] = e.Value
+		}
+		if env[horovodRankEnv] != c.expectedRank {
+			t.Errorf("%s-%s: expected %s %q, got %q", rt, c.index, horovodRankEnv, c.expectedRank, env[horovodRankEnv])
+		}
+		expectedSize := "4"
+		if c.expectedRank == "" {
+			expectedSize = ""
+		}
+		if env[horovodSizeEnv] != expectedSize {
+			t.Errorf("%s-%s: expected %s %q, got %q", rt, c.index, horovodSizeEnv, expectedSize, env[horovodSizeEnv])
+		}
+	}
+}
+
+func TestClusterMembersAnnotation(t *testing.T) {
+	tfJob := testutil.NewTFJob(2, 1)
+	pods := append(testutil.NewPodList(2, v1.PodRunning, tfJob, testutil.LabelWorker, 0),
+		testutil.NewPodList(1, v1.PodRunning, tfJob, testutil.LabelPS, 0)...)
+	objects := make([]runtime.Object, 0, len(pods))
+	for _, pod := range pods {
+		objects = append(objects, pod)
+	}
+	kubeClientSet := kubefake.NewSimpleClientset(objects...)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ClusterMembersAnnotation: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+	for _, pod := range pods {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+	}
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 3, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+	checkMembers := func(annotations map[string]string, name string, workers int) {
+		t.Helper()
+		members := ClusterSpec{}
+		if err := json.Unmarshal([]byte(annotations[tfv1.ClusterMembersAnnotation]), &members); err != nil {
+			t.Fatalf("Failed to parse the %s annotation of %s: %v", tfv1.ClusterMembersAnnotation, name, err)
+		}
+		if len(members["worker"]) != workers || len(members["ps"]) != 1 {
+			t.Errorf("Expected %d workers and 1 PS in the members of %s, got %v", workers, name, members)
+		}
+	}
+	checkPods := func(workers int) {
+		t.Helper()
+		for _, pod := range pods {
+			updated, err := kubeClientSet.CoreV1().Pods(pod.Namespace).Get(context.TODO(), pod.Name, metav1.GetOptions{})
+			if err != nil {
+				t.Fatalf("Failed to get pod %s: %v", pod.Name, err)
+			}
+			checkMembers(updated.Annotations, pod.Name, workers)
+		}
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	checkPods(2)
+
+	// Once scaled up, the new worker and the existing pods have 3 workers.
+	*tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Replicas = 3
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	checkPods(3)
+	if len(fakePodControl.Templates) != 1 {
+		t.Fatalf("Expected 1 pod to be created, got %d", len(fakePodControl.Templates))
+	}
+	checkMembers(fakePodControl.Templates[0].Annotations, fakePodControl.Templates[0].Name, 3)
+}
+
+func TestFailedPodGracePeriod(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{FailedPodGracePeriod: time.Minute})
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(1, 0)
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+
+	// The worker failed just now.
+	pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	pod.Status.Phase = v1.PodFailed
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name: tfv1.DefaultContainerName,
+		State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+			ExitCode:   1,
+			FinishedAt: metav1.Now(),
+		}},
+	}}
+	if err := podIndexer.Add(pod); err != nil {
+		t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+	}
+
+	getStatus := func() commonv1.JobStatus {
+		t.Helper()
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("Failed to get the tfjob: %v", err)
+		}
+		return updated.Status
+	}
+
+	// Within the grace period, the worker is still counted as running.
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	status := getStatus()
+	if commonutil.IsFailed(status) {
+		t.Errorf("Expected the tfjob not to fail within the grace period, got %v", status.Conditions)
+	}
+	if replicaStatus := status.ReplicaStatuses[tfv1.TFReplicaTypeWorker]; replicaStatus == nil ||
+		replicaStatus.Failed != 0 || replicaStatus.Active != 1 {
+		t.Errorf("Expected the worker to be counted as active, got %v", replicaStatus)
+	}
+
+	// Once the grace period elapsed, the worker fails the tfjob.
+	pod = pod.DeepCopy()
+	pod.Status.ContainerStatuses[0].State.Terminated.FinishedAt = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	if err := podIndexer.Update(pod); err != nil {
+		t.Errorf("%s: unexpected error when updating pod %v", tfJob.Name, err)
+	}
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, status, &tfJob.Spec.RunPolicy)
+	status = getStatus()
+	if !commonutil.IsFailed(status) {
+		t.Errorf("Expected the tfjob to fail past the grace period, got %v", status.Conditions)
+	}
+}
+
+func TestCustomReplicaLabels(t *testing.T) {
+	const typeLabel, indexLabel = "example.com/role", "example.com/rank"
+
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ReplicaTypeLabel: typeLabel, ReplicaIndexLabel: indexLabel})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(3, 0)
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 3, t)
+
+	// Workers 0 and 1 are running, and only labeled under the custom keys.
+	// Their default labels are swapped, so that reading them would get
+	// their indexes wrong.
+	for i := 0; i < 2; i++ {
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, i)
+		pod.Labels[tfReplicaTypeLabel] = testutil.LabelPS
+		pod.Labels[tfReplicaIndexLabel] = strconv.Itoa(1 - i)
+		pod.Labels[typeLabel] = testutil.LabelWorker
+		pod.Labels[indexLabel] = strconv.Itoa(i)
+		pod.Status.Phase = v1.PodRunning
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	// Only worker 2 is missing, and it is labeled under both keys.
+	if len(fakePodControl.Templates) != 1 {
+		t.Fatalf("Expected 1 pod to be created, got %d", len(fakePodControl.Templates))
+	}
+	template := fakePodControl.Templates[0]
+	for _, key := range []string{typeLabel, tfReplicaTypeLabel} {
+		if template.Labels[key] != testutil.LabelWorker {
+			t.Errorf("Expected label %s to be %s, got %q", key, testutil.LabelWorker, template.Labels[key])
+		}
+	}
+	for _, key := range []string{indexLabel, tfReplicaIndexLabel} {
+		if template.Labels[key] != "2" {
+			t.Errorf("Expected label %s to be 2, got %q", key, template.Labels[key])
+		}
+	}
+	tfConfigStr := ""
+	for _, env := range template.Spec.Containers[0].Env {
+		if env.Name == tfConfig {
+			tfConfigStr = env.Value
+		}
+	}
+	tfConfig := TFConfig{}
+	if err := json.Unmarshal([]byte(tfConfigStr), &tfConfig); err != nil {
+		t.Fatalf("Failed to parse TF_CONFIG %q: %v", tfConfigStr, err)
+	}
+	if tfConfig.Task != (TaskSpec{Type: "worker", Index: 2}) {
+		t.Errorf("Expected the task worker 2, got %+v", tfConfig.Task)
+	}
+
+	updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	if status := updated.Status.ReplicaStatuses[tfv1.TFReplicaTypeWorker]; status == nil || status.Active != 2 {
+		t.Errorf("Expected 2 active workers, got %v", status)
+	}
+}
+
+func TestDrainCordonedNodes(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{DrainCordonedNodes: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+	nodeIndexer := kubeInformerFactory.Core().V1().Nodes().Informer().GetIndexer()
+
+	// The workers are restarted on failure, the PS never are.
+	tfJob := testutil.NewTFJob(2, 1)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = commonv1.RestartPolicyOnFailure
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS].RestartPolicy = commonv1.RestartPolicyNever
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+	cordoned := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "cordoned"}, Spec: v1.NodeSpec{Unschedulable: true}}
+	schedulable := &v1.Node{ObjectMeta: metav1.ObjectMeta{Name: "schedulable"}}
+	for _, node := range []*v1.Node{cordoned, schedulable} {
+		if err := nodeIndexer.Add(node); err != nil {
+			t.Errorf("Unexpected error when adding node %v", err)
+		}
+	}
+	// worker-0 and ps-0 run on the cordoned node, worker-1 does not.
+	pods := []*v1.Pod{
+		testutil.NewPod(tfJob, testutil.LabelWorker, 0),
+		testutil.NewPod(tfJob, testutil.LabelWorker, 1),
+		testutil.NewPod(tfJob, testutil.LabelPS, 0),
+	}
+	for i, nodeName := range []string{cordoned.Name, schedulable.Name, cordoned.Name} {
+		pods[i].Spec.NodeName = nodeName
+		pods[i].Status.Phase = v1.PodRunning
+		if err := podIndexer.Add(pods[i]); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+	}
+
+	// Cordoning the node syncs the tfjob.
+	ctr.updateNode(&v1.Node{ObjectMeta: metav1.ObjectMeta{Name: cordoned.Name}}, cordoned)
+	if ctr.WorkQueue.Len() != 1 {
+		t.Errorf("Expected the tfjob to be enqueued, got %d items", ctr.WorkQueue.Len())
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	expectedDeletePods := []string{pods[0].Name}
+	if !reflect.DeepEqual(expectedDeletePods, fakePodControl.DeletePodName) {
+		t.Errorf("Expected pods %v to be deleted for rescheduling, got %v", expectedDeletePods, fakePodControl.DeletePodName)
+	}
+}
+
+func TestJobMetadataEnv(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{JobMetadataEnv: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.UID = "0b6e2a3c-7d4f-4e1a-9c58-2f0d1e6b7a90"
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	workerSpec.Template.Spec.Containers = append(workerSpec.Template.Spec.Containers,
+		v1.Container{Name: "sidecar", Image: "sidecar"})
+
+	// The pod is created twice, as when it is recreated.
+	for i := 0; i < 2; i++ {
+		if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+			t.Fatalf("Expected get nil, got error %v", err)
+		}
+	}
+
+	expected := map[string]string{
+		tfJobUIDEnv:       string(tfJob.UID),
+		tfJobNameEnv:      tfJob.Name,
+		tfJobNamespaceEnv: tfJob.Namespace,
+	}
+	for _, template := range fakePodControl.Templates {
+		for _, container := range template.Spec.Containers {
+			env := map[string]string{}
+			for _, e := range container.Env {
+				env[e.Name] = e.Value
+			}
+			for name, value := range expected {
+				if env[name] != value {
+					t.Errorf("Expected %s=%s in container %s, got %q", name, value, container.Name, env[name])
+				}
+			}
+		}
+	}
+}
+
+func TestPatchTFConfig(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{TFConfigFile: true, PatchTFConfig: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.Recorder = &record.FakeRecorder{}
+
+	tfJob := testutil.NewTFJob(2, 1)
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+	// The existing pods were created with the TF_CONFIG of 2 workers.
+	pods := append(testutil.NewPodList(2, v1.PodRunning, tfJob, testutil.LabelWorker, 0),
+		testutil.NewPodList(1, v1.PodRunning, tfJob, testutil.LabelPS, 0)...)
+	for _, pod := range pods {
+		tfConfigStr, err := ctr.genTFConfig(tfJob, pod.Labels[tfReplicaTypeLabel], pod.Labels[tfReplicaIndexLabel])
+		if err != nil {
+			t.Fatalf("Failed to generate the TF_CONFIG of %s: %v", pod.Name, err)
+		}
+		pod.Annotations = map[string]string{tfConfigAnnotation: tfConfigStr}
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+	}
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 3, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+	// Nothing is patched while the cluster spec is unchanged.
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	if len(fakePodControl.Patches) != 0 {
+		t.Fatalf("Expected no patch, got %d", len(fakePodControl.Patches))
+	}
+
+	// Once scaled up, the existing pods are patched with the TF_CONFIG of 3
+	// workers, and none of them is deleted.
+	*tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Replicas = 3
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	if len(fakePodControl.DeletePodName) != 0 {
+		t.Errorf("Expected no pod to be deleted, got %v", fakePodControl.DeletePodName)
+	}
+	if len(fakePodControl.Templates) != 1 {
+		t.Errorf("Expected 1 pod to be created, got %d", len(fakePodControl.Templates))
+	}
+	if len(fakePodControl.Patches) != len(pods) {
+		t.Fatalf("Expected %d patches, got %d", len(pods), len(fakePodControl.Patches))
+	}
+	for _, data := range fakePodControl.Patches {
+		var patch struct {
+			Metadata struct {
+				Annotations map[string]string `json:"annotations"`
+			} `json:"metadata"`
+		}
+		if err := json.Unmarshal(data, &patch); err != nil {
+			t.Fatalf("Failed to parse the patch %s: %v", data, err)
+		}
+		tfConfig := TFConfig{}
+		if err := json.Unmarshal([]byte(patch.Metadata.Annotations[tfConfigAnnotation]), &tfConfig); err != nil {
+			t.Fatalf("Failed to parse the TF_CONFIG of the patch %s: %v", data, err)
+		}
+		if len(tfConfig.Cluster["worker"]) != 3 || len(tfConfig.Cluster["ps"]) != 1 {
+			t.Errorf("Expected 3 workers and 1 PS in the patched TF_CONFIG, got %v", tfConfig.Cluster)
+		}
+	}
+}
+
+func TestCommonSidecars(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	tfJob := testutil.NewTFJob(1, 1)
+	tfJob.Spec.CommonSidecars = []v1.Container{
+		{Name: "metrics-exporter", Image: "metrics-exporter"},
+		// The sidecar of the template is kept.
+		{Name: "logger", Image: "logger:common"},
+	}
+	tfJob.Spec.CommonEnv = []v1.EnvVar{{Name: "METRICS_PORT", Value: "9090"}}
+	psSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS]
+	psSpec.Template.Spec.Containers = append(psSpec.Template.Spec.Containers,
+		v1.Container{Name: "logger", Image: "logger:ps"})
+
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker], false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+	if err := ctr.createNewPod(context.TODO(), tfJob, "ps", "0", psSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+
+	expected := [][]string{
+		{tfv1.DefaultContainerName + ":", "metrics-exporter:metrics-exporter", "logger:logger:common"},
+		{tfv1.DefaultContainerName + ":", "logger:logger:ps", "metrics-exporter:metrics-exporter"},
+	}
+	for i, template := range fakePodControl.Templates {
+		var containers []string
+		for _, container := range template.Spec.Containers {
+			image := container.Image
+			if container.Name == tfv1.DefaultContainerName {
+				image = ""
+			}
+			containers = append(containers, container.Name+":"+image)
+		}
+		if !reflect.DeepEqual(containers, expected[i]) {
+			t.Errorf("Expected the containers %v in %s, got %v", expected[i], template.Name, containers)
+		}
+	}
+	// The sidecars get the common env, while those of the tfjob are left unchanged.
+	if env := fakePodControl.Templates[0].Spec.Containers[1].Env; len(env) != 1 || env[0].Name != "METRICS_PORT" {
+		t.Errorf("Expected the common env in the sidecar, got %v", env)
+	}
+	if len(tfJob.Spec.CommonSidecars[0].Env) != 0 {
+		t.Errorf("Expected the common sidecars to be left unchanged, got %v", tfJob.Spec.CommonSidecars)
+	}
+}
+
+func TestClusterSpecContainers(t *testing.T) {
+	testCases := []struct {
+		description  string
+		containers   options.ContainerNames
+		tfConfigFile bool
+		// expectedEnv is the cluster spec env expected in every container.
+		expectedEnv map[string]string
+	}{
+		{
+			description: "TF_CONFIG is only set in the tensorflow container by default",
+			expectedEnv: map[string]string{tfv1.DefaultContainerName: tfConfig},
+		},
+		{
+			description: "TF_CONFIG is set in every container",
+			containers:  options.ContainerNames{options.AllContainers},
+			expectedEnv: map[string]string{tfv1.DefaultContainerName: tfConfig, "exporter": tfConfig, "uploader": tfConfig},
+		},
+		{
+			description:  "the TF_CONFIG file is mounted in the selected containers",
+			containers:   options.ContainerNames{"exporter"},
+			tfConfigFile: true,
+			expectedEnv:  map[string]string{tfv1.DefaultContainerName: tfConfigFileEnv, "exporter": tfConfigFileEnv},
+		},
+	}
+
+	for _, tc := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0,
+			options.ServerOption{ClusterSpecContainers: tc.containers, TFConfigFile: tc.tfConfigFile})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+
+		tfJob := testutil.NewTFJob(2, 1)
+		workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+		workerSpec.Template.Spec.Containers = append(workerSpec.Template.Spec.Containers,
+			v1.Container{Name: "exporter", Image: "exporter"},
+			v1.Container{Name: "uploader", Image: "uploader"})
+		if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+			t.Fatalf("%s: expected get nil, got error %v", tc.description, err)
+		}
+
+		for _, container := range fakePodControl.Templates[0].Spec.Containers {
+			var names []string
+			for _, e := range container.Env {
+				if e.Name == tfConfig || e.Name == tfConfigFileEnv {
+					names = append(names, e.Name)
+				}
+			}
+			expected := tc.expectedEnv[container.Name]
+			if (expected == "" && len(names) != 0) || (expected != "" && (len(names) != 1 || names[0] != expected)) {
+				t.Errorf("%s: expected the cluster spec env %q in container %s, got %v", tc.description, expected, container.Name, names)
+			}
+			mounted := false
+			for _, m := range container.VolumeMounts {
+				mounted = mounted || m.Name == tfConfigVolumeName
+			}
+			if mounted != (tc.tfConfigFile && expected != "") {
+				t.Errorf("%s: expected the TF_CONFIG file mounted %v in container %s", tc.description, !mounted, container.Name)
+			}
+		}
+	}
+}
+
+func TestPortRange(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeServiceControl := &control.FakeServiceControl{}
+	ctr.ServiceControl = newReplicaPortServiceControl(fakeServiceControl)
+
+	// The PS comes first in alphabetical order, so it gets 3000, and the
+	// workers 3001 and 3002.
+	tfJob := testutil.NewTFJob(2, 1)
+	tfJob.Spec.PortRange = &tfv1.PortRange{Min: 3000, Max: 3009}
+	workerSpec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	for _, index := range []string{"0", "1"} {
+		if err := ctr.createNewPod(context.TODO(), tfJob, "worker", index, workerSpec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+			t.Fatalf("Expected get nil, got error %v", err)
+		}
+	}
+	if err := ctr.reconcileServices(tfJob, nil, tfv1.TFReplicaTypeWorker, workerSpec, nil); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+
+	expectedPorts := []int32{3001, 3002}
+	if len(fakePodControl.Templates) != 2 || len(fakeServiceControl.Templates) != 2 {
+		t.Fatalf("Expected 2 pods and 2 services, got %d and %d",
+			len(fakePodControl.Templates), len(fakeServiceControl.Templates))
+	}
+	for i, expected := range expectedPorts {
+		podTemplate := fakePodControl.Templates[i]
+		container := podTemplate.Spec.Containers[0]
+		if port := container.Ports[0].ContainerPort; port != expected {
+			t.Errorf("Expected pod %s to listen on %d, got %d", podTemplate.Name, expected, port)
+		}
+
+		var cfg TFConfig
+		for _, env := range container.Env {
+			if env.Name == tfConfig {
+				if err := json.Unmarshal([]byte(env.Value), &cfg); err != nil {
+					t.Fatalf("Failed to unmarshal TF_CONFIG: %v", err)
+				}
+			}
+		}
+		for j, endpoint := range cfg.Cluster["worker"] {
+			if !strings.HasSuffix(endpoint, fmt.Sprintf(":%d", expectedPorts[j])) {
+				t.Errorf("Expected worker %d at port %d in the TF_CONFIG of pod %s, got %s",
+					j, expectedPorts[j], podTemplate.Name, endpoint)
+			}
+		}
+		if len(cfg.Cluster["worker"]) != 2 {
+			t.Errorf("Expected 2 workers in the TF_CONFIG of pod %s, got %v", podTemplate.Name, cfg.Cluster)
+		}
+	}
+	for _, service := range fakeServiceControl.Templates {
+		index, _ := strconv.Atoi(service.Labels[tfReplicaIndexLabel])
+		if len(service.Spec.Ports) != 1 || service.Spec.Ports[0].Port != expectedPorts[index] {
+			t.Errorf("Expected service %s to expose port %d, got %v", service.Name, expectedPorts[index], service.Spec.Ports)
+		}
+	}
+}
+
+func TestTraceContext(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	traceIDs := map[string]string{}
+	ctr.createPodHook = func(ctx context.Context, podTemplate *v1.PodTemplateSpec) {
+		traceIDs[podTemplate.Name] = traceIDFrom(ctx)
+	}
+
+	tfJob := testutil.NewTFJob(2, 1)
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	ctx := context.WithValue(context.Background(), traceIDKey{}, "trace-1")
+	if err := ctr.reconcileJobs(ctx, tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+
+	// Every pod is created with the context of the reconcile.
+	if len(traceIDs) != 3 {
+		t.Errorf("Expected 3 pods to be created, got %v", traceIDs)
+	}
+	for name, id := range traceIDs {
+		if id != "trace-1" {
+			t.Errorf("Expected pod %s to be created with trace trace-1, got %q", name, id)
+		}
+	}
+
+	// The reconciles started out of the work queue get a trace of their own.
+	if id := traceIDFrom(newTraceContext(context.Background())); id == "" {
+		t.Error("Expected a new trace ID")
+	}
+}
+
+func TestChiefNodePreference(t *testing.T) {
+	testCases := []struct {
+		description string
+		tfJob       *tfv1.TFJob
+		// preferred is the pod expected to prefer the node.
+		preferred    string
+		expectedPods int
+	}{
+		{
+			description:  "The chief prefers the node",
+			tfJob:        testutil.NewTFJobV2(2, 0, 0, 1, 0),
+			preferred:    "chief-0",
+			expectedPods: 3,
 		},
 		{
-			workers:     [3]int32{0, 0, 0},
-			tfJob:       testutil.NewTFJobV2(0, 0, 1, 0, 0),
-			expected:    true,
-			expectedErr: false,
-			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
-				tfv1.TFReplicaTypeMaster: {
-					Replicas: newInt32(1),
-					Template: testutil.NewTFReplicaSpecTemplate(),
-				},
+			description:  "Worker 0 prefers the node without a chief",
+			tfJob:        testutil.NewTFJob(2, 0),
+			preferred:    "worker-0",
+			expectedPods: 2,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
 			},
 		},
-		{
-			workers:     [3]int32{0, 0, 0},
-			tfJob:       testutil.NewTFJobV2(0, 0, 0, 1, 0),
-			expected:    true,
-			expectedErr: false,
-			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
-				tfv1.TFReplicaTypeChief: {
-					Replicas: newInt32(1),
-					Template: testutil.NewTFReplicaSpecTemplate(),
-				},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
 			},
 		},
-		{
-			workers:     [3]int32{1, 1, 0},
-			tfJob:       testutil.NewTFJobV2(2, 0, 0, 0, 0),
-			expected:    true,
-			expectedErr: false,
-			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
-				tfv1.TFReplicaTypeWorker: {
-					Replicas: newInt32(2),
-					Template: testutil.NewTFReplicaSpecTemplate(),
-				},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
 			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+
+		tfJob := c.tfJob
+		tfJob.Spec.ChiefNodePreference = &tfv1.NodePreference{Value: "node-a"}
+		ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		if len(fakePodControl.Templates) != c.expectedPods {
+			t.Fatalf("%s: expected %d pods to be created, got %d", c.description, c.expectedPods, len(fakePodControl.Templates))
+		}
+		for _, podTemplate := range fakePodControl.Templates {
+			replica := podTemplate.Labels[tfReplicaTypeLabel] + "-" + podTemplate.Labels[tfReplicaIndexLabel]
+			affinity := podTemplate.Spec.Affinity
+			if replica != c.preferred {
+				if affinity != nil {
+					t.Errorf("%s: expected %s not to be constrained, got %v", c.description, replica, affinity)
+				}
+				continue
+			}
+			if affinity == nil || affinity.NodeAffinity == nil ||
+				len(affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution) != 1 {
+				t.Fatalf("%s: expected %s to prefer a node, got %v", c.description, replica, affinity)
+			}
+			requirement := affinity.NodeAffinity.PreferredDuringSchedulingIgnoredDuringExecution[0].Preference.MatchExpressions[0]
+			if requirement.Key != v1.LabelHostname || !reflect.DeepEqual(requirement.Values, []string{"node-a"}) {
+				t.Errorf("%s: expected %s to prefer node-a, got %v", c.description, replica, requirement)
+			}
+		}
+	}
+}
+
+func TestAdditionalOwnerReferences(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	// The pods and services are created for real, in a fake clientset.
+	fakeKubeClientSet := kubefake.NewSimpleClientset()
+	recorder := &record.FakeRecorder{}
+	ctr.PodControl = newAdditionalOwnersPodControl(control.RealPodControl{KubeClient: fakeKubeClientSet, Recorder: recorder})
+	ctr.ServiceControl = newAdditionalOwnersServiceControl(control.RealServiceControl{KubeClient: fakeKubeClientSet, Recorder: recorder})
+	ctr.Recorder = recorder
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.UID = "test-tfjob-uid"
+	owner := metav1.OwnerReference{APIVersion: "v1", Kind: "ConfigMap", Name: "pipeline", UID: "pipeline-uid"}
+	tfJob.Spec.AdditionalOwnerReferences = []metav1.OwnerReference{owner}
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	checkOwners := func(kind string, object metav1.Object) {
+		controllerRef := metav1.GetControllerOf(object)
+		if controllerRef == nil || controllerRef.UID != tfJob.UID {
+			t.Errorf("Expected %s %s to be controlled by the tfjob, got %v", kind, object.GetName(), object.GetOwnerReferences())
+		}
+		found := false
+		for _, ref := range object.GetOwnerReferences() {
+			if reflect.DeepEqual(ref, owner) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expected %s %s to be owned by %v, got %v", kind, object.GetName(), owner, object.GetOwnerReferences())
+		}
+	}
+	pods, err := fakeKubeClientSet.CoreV1().Pods(tfJob.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil || len(pods.Items) != 1 {
+		t.Fatalf("Expected 1 pod, got %v, error %v", pods, err)
+	}
+	checkOwners("pod", &pods.Items[0])
+	services, err := fakeKubeClientSet.CoreV1().Services(tfJob.Namespace).List(context.TODO(), metav1.ListOptions{})
+	if err != nil || len(services.Items) != 1 {
+		t.Fatalf("Expected 1 service, got %v, error %v", services, err)
+	}
+	checkOwners("service", &services.Items[0])
+}
+
+func TestFailedPodWithoutContainerStatuses(t *testing.T) {
+	testCases := []struct {
+		description   string
+		restartPolicy commonv1.RestartPolicy
+
+		expectedDeleted bool
+		expectedFailed  bool
+	}{
+		{
+			description:     "the pod is recreated with the ExitCode restart policy",
+			restartPolicy:   commonv1.RestartPolicyExitCode,
+			expectedDeleted: true,
 		},
 		{
-			workers:     [3]int32{1, 0, 1},
-			tfJob:       testutil.NewTFJobV2(2, 0, 0, 0, 0),
-			expected:    false,
-			expectedErr: false,
-			replicas: map[commonv1.ReplicaType]*commonv1.ReplicaSpec{
-				tfv1.TFReplicaTypeWorker: {
-					Replicas: newInt32(2),
-					Template: testutil.NewTFReplicaSpecTemplate(),
-				},
+			description:    "the pod is counted as failed with the Never restart policy",
+			restartPolicy:  commonv1.RestartPolicyNever,
+			expectedFailed: true,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
 			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		recorder := record.NewFakeRecorder(100)
+		ctr.Recorder = recorder
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		// The pod is rejected by the kubelet before any container starts.
+		tfJob := testutil.NewTFJob(1, 0)
+		tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = c.restartPolicy
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+		pod.Status.Phase = v1.PodFailed
+		pod.Status.Reason = "OutOfcpu"
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+		}
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+		ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		if deleted := len(fakePodControl.DeletePodName) == 1; deleted != c.expectedDeleted {
+			t.Errorf("%s: expected deleted %v, got %v", c.description, c.expectedDeleted, fakePodControl.DeletePodName)
+		}
+		if c.expectedDeleted {
+			found := false
+			for len(recorder.Events) > 0 {
+				if strings.Contains(<-recorder.Events, "failed without an exit code: OutOfcpu") {
+					found = true
+				}
+			}
+			if !found {
+				t.Errorf("%s: expected an event stating the pod failed without an exit code", c.description)
+			}
+		}
+		if failed := isFailed(tfJob.Status); failed != c.expectedFailed {
+			t.Errorf("%s: expected failed %v, got %v", c.description, c.expectedFailed, tfJob.Status.Conditions)
+		}
+	}
+}
+
+func TestStaticScaleDown(t *testing.T) {
+	testCases := []struct {
+		staticScaleDown string
+
+		expectedDeleted []string
+		expectedEvent   bool
+	}{
+		{
+			staticScaleDown: options.StaticScaleDownStrict,
+			expectedEvent:   true,
+		},
+		{
+			staticScaleDown: options.StaticScaleDownPermissive,
+			expectedDeleted: []string{"worker-1"},
 		},
 	}
-	for _, tt := range tests {
+
+	for _, c := range testCases {
 		// Prepare the clientset and controller for the test.
 		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
 			Host: "",
@@ -799,25 +4078,42 @@ func TestIsWorker0Completed(t *testing.T) {
 			},
 		}
 		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
-		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
-			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
-		ctr.tfJobInformerSynced = testutil.AlwaysReady
-		ctr.PodInformerSynced = testutil.AlwaysReady
-		ctr.ServiceInformerSynced = testutil.AlwaysReady
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0,
+			options.ServerOption{StaticScaleDown: c.staticScaleDown})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		fakeServiceControl := &control.FakeServiceControl{}
+		ctr.ServiceControl = fakeServiceControl
+		recorder := record.NewFakeRecorder(100)
+		ctr.Recorder = recorder
 		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
 
-		// only related to worker status
-		initializeReplicaStatuses(&tt.tfJob.Status, tfv1.TFReplicaTypeWorker)
-		// set status and add pod to indexer
-		setStatusForTest(tt.tfJob, tfv1.TFReplicaTypeWorker, tt.workers[0], tt.workers[1], tt.workers[2], false, true, podIndexer, t)
+		// The workers of the tfjob, without dynamic workers, are lowered from 2 to 1.
+		tfJob := testutil.NewTFJob(1, 0)
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 2, 0, 0, nil, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+		ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
 
-		got, err := ctr.IsWorker0Completed(tt.tfJob, tt.replicas)
-		if (err != nil) != tt.expectedErr {
-			t.Errorf("IsWorker0Completed() error = %v, wantErr %v", err, tt.expectedErr)
-			return
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		if !reflect.DeepEqual(fakePodControl.DeletePodName, c.expectedDeleted) {
+			t.Errorf("%s: expected deleted pods %v, got %v", c.staticScaleDown, c.expectedDeleted, fakePodControl.DeletePodName)
 		}
-		if got != tt.expected {
-			t.Errorf("IsWorker0Completed() got = %v, want %v", got, tt.expected)
+		// The service of a pod out of range is deleted along the pod, and by
+		// the reconcile of the services.
+		deletedServices := sets.NewString(fakeServiceControl.DeleteServiceName...)
+		if !deletedServices.Equal(sets.NewString(c.expectedDeleted...)) {
+			t.Errorf("%s: expected deleted services %v, got %v", c.staticScaleDown, c.expectedDeleted, fakeServiceControl.DeleteServiceName)
+		}
+		found := false
+		for len(recorder.Events) > 0 {
+			if strings.HasPrefix(<-recorder.Events, v1.EventTypeWarning+" "+scaleDownRejectedReason) {
+				found = true
+			}
+		}
+		if found != c.expectedEvent {
+			t.Errorf("%s: expected a %s event %v, got %v", c.staticScaleDown, scaleDownRejectedReason, c.expectedEvent, found)
 		}
 	}
 }
@@ -0,0 +1,97 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// additionalOwnersPodControl adds the AdditionalOwnerReferences of tfjobs to
+// the pods it creates for them. The pods are built from their template, which
+// does not carry owner references, so the additional owners are patched in
+// once the pod is created. The owner references are merged by UID, the
+// controller reference is kept.
+type additionalOwnersPodControl struct {
+	control.PodControlInterface
+}
+
+// newAdditionalOwnersPodControl returns the given pod control adding the
+// additional owners of tfjobs to their pods.
+func newAdditionalOwnersPodControl(c control.PodControlInterface) *additionalOwnersPodControl {
+	return &additionalOwnersPodControl{PodControlInterface: c}
+}
+
+func (c *additionalOwnersPodControl) CreatePodsWithControllerRef(namespace string, template *v1.PodTemplateSpec,
+	object runtime.Object, controllerRef *metav1.OwnerReference) error {
+	if err := c.PodControlInterface.CreatePodsWithControllerRef(namespace, template, object, controllerRef); err != nil {
+		return err
+	}
+	tfJob, ok := object.(*tfv1.TFJob)
+	if !ok || len(tfJob.Spec.AdditionalOwnerReferences) == 0 {
+		return nil
+	}
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"ownerReferences": tfJob.Spec.AdditionalOwnerReferences,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return c.PodControlInterface.PatchPod(namespace, template.Name, patch)
+}
+
+// timeoutPodControl gives up on the pod creations which take longer than its
+// timeout, returning an error, so that the expectation raised for the pod is
+// lowered and the tfjob is requeued instead of waiting on a hanging call. The
+// creation keeps going in the background, if it succeeds after all the pod
+// is observed by the informer.
+type timeoutPodControl struct {
+	control.PodControlInterface
+	timeout time.Duration
+}
+
+// newTimeoutPodControl returns the given pod control giving up on the pod
+// creations after the timeout, or the given pod control if it is not positive.
+func newTimeoutPodControl(c control.PodControlInterface, timeout time.Duration) control.PodControlInterface {
+	if timeout <= 0 {
+		return c
+	}
+	return &timeoutPodControl{PodControlInterface: c, timeout: timeout}
+}
+
+func (c *timeoutPodControl) CreatePodsWithControllerRef(namespace string, template *v1.PodTemplateSpec,
+	object runtime.Object, controllerRef *metav1.OwnerReference) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- c.PodControlInterface.CreatePodsWithControllerRef(namespace, template, object, controllerRef)
+	}()
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("timed out after %v creating pod %s/%s", c.timeout, namespace, template.Name)
+	}
+}
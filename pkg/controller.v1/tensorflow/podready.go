@@ -0,0 +1,165 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// tfJobPodsNotReady is the condition of a tfjob which has pods running
+	// without becoming ready past the PodReadyDeadlineSeconds of their
+	// replica type.
+	tfJobPodsNotReady commonv1.JobConditionType = "PodsNotReady"
+	// podsNotReadyReason is the reason of the PodsNotReady condition, and the
+	// warning reason of the event emitted when it is set.
+	podsNotReadyReason = "PodsNotReady"
+)
+
+// updatePod enqueues the tfjob controlling the pod on its updates, as the job
+// controller does. The tfjob is also enqueued by key when the Ready condition
+// of the pod changes, without resolving it in the tfjob cache first, so that
+// the readiness flip which makes the tfjob running is never missed. The
+// schedule latency of the pod is observed when it gets scheduled.
+func (tc *TFController) updatePod(old, cur interface{}) {
+	tc.JobController.UpdatePod(old, cur)
+
+	oldPod, ok := old.(*v1.Pod)
+	if !ok {
+		return
+	}
+	curPod, ok := cur.(*v1.Pod)
+	if !ok {
+		return
+	}
+	tc.observeScheduleLatency(oldPod, curPod)
+	if isPodReady(oldPod) == isPodReady(curPod) {
+		return
+	}
+	controllerRef := metav1.GetControllerOf(curPod)
+	if controllerRef == nil || controllerRef.Kind != tfv1.Kind {
+		return
+	}
+	tc.WorkQueue.Add(curPod.Namespace + "/" + controllerRef.Name)
+}
+
+// podReadyDeadlineRemaining returns how long the running pod may still not be
+// ready, or 0 if it is past the deadline. ok is false if the deadline does not
+// apply, because the pod is not running or is ready already.
+func podReadyDeadlineRemaining(pod *v1.Pod, deadlineSeconds int64) (remaining time.Duration, ok bool) {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != v1.PodRunning || pod.Status.StartTime == nil || isPodReady(pod) {
+		return 0, false
+	}
+	deadline := pod.Status.StartTime.Add(time.Duration(deadlineSeconds) * time.Second)
+	if remaining := time.Until(deadline); remaining > 0 {
+		return remaining, true
+	}
+	return 0, true
+}
+
+// reconcilePodReadiness sets the PodsNotReady condition of the tfjob when some
+// of its pods are not ready past the PodReadyDeadlineSeconds of their replica
+// type, and removes it once they are. It returns true if one of them fails the
+// tfjob. The tfjob is requeued when the next pod runs out of time.
+func (tc *TFController) reconcilePodReadiness(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, pods []*v1.Pod) (bool, error) {
+	var notReady []string
+	failJob := false
+	var next time.Duration
+	for _, pod := range pods {
+		rt := pod.Labels[tc.GetReplicaTypeLabelKey()]
+		policy := getReplicaPolicy(tfJob, commonv1.ReplicaType(rt))
+		if policy == nil || policy.PodReadyDeadlineSeconds == nil {
+			continue
+		}
+		remaining, ok := podReadyDeadlineRemaining(pod, *policy.PodReadyDeadlineSeconds)
+		if !ok {
+			continue
+		}
+		if remaining > 0 {
+			if next == 0 || remaining < next {
+				next = remaining
+			}
+			continue
+		}
+		notReady = append(notReady, pod.Name)
+		failJob = failJob || policy.FailJobOnPodReadyDeadlineExceeded
+	}
+
+	if next > 0 {
+		key, err := KeyFunc(tfJob)
+		if err != nil {
+			return false, err
+		}
+		tc.WorkQueue.AddAfter(key, next)
+	}
+	if len(notReady) == 0 {
+		clearPodsNotReady(jobStatus)
+		return false, nil
+	}
+	msg := fmt.Sprintf("TFJob %s/%s has pods not ready past their deadline: %s",
+		tfJob.Namespace, tfJob.Name, strings.Join(notReady, ", "))
+	tc.setPodsNotReady(tfJob, jobStatus, msg)
+	return failJob, nil
+}
+
+// setPodsNotReady sets the PodsNotReady condition of the tfjob, and emits an
+// event when the condition becomes true or its pods change.
+func (tc *TFController) setPodsNotReady(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, msg string) {
+	now := metav1.Now()
+	condition := commonv1.JobCondition{
+		Type:               tfJobPodsNotReady,
+		Status:             v1.ConditionTrue,
+		Reason:             podsNotReadyReason,
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	}
+	// The conditions are copied, as they may be shared with the tfjob.
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)+1)
+	for _, c := range jobStatus.Conditions {
+		if c.Type != tfJobPodsNotReady {
+			conditions = append(conditions, c)
+			continue
+		}
+		if c.Message == msg {
+			// The same pods are still not ready.
+			return
+		}
+		condition.LastTransitionTime = c.LastTransitionTime
+	}
+	commonutil.LoggerForJob(tfJob).Warn(msg)
+	tc.Recorder.Event(tfJob, v1.EventTypeWarning, podsNotReadyReason, msg)
+	jobStatus.Conditions = append(conditions, condition)
+}
+
+// clearPodsNotReady removes the PodsNotReady condition of the tfjob.
+func clearPodsNotReady(jobStatus *commonv1.JobStatus) {
+	for i, condition := range jobStatus.Conditions {
+		if condition.Type == tfJobPodsNotReady {
+			conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)-1)
+			conditions = append(conditions, jobStatus.Conditions[:i]...)
+			jobStatus.Conditions = append(conditions, jobStatus.Conditions[i+1:]...)
+			return
+		}
+	}
+}
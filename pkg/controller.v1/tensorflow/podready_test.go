@@ -0,0 +1,219 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestPodReadyDeadline(t *testing.T) {
+	testCases := []struct {
+		description string
+		// startedAgo is how long ago the pod, never ready, started.
+		startedAgo time.Duration
+		failJob    bool
+
+		expectedNotReady bool
+		expectedFailed   bool
+	}{
+		{
+			description: "the pod is within the deadline",
+			startedAgo:  time.Minute,
+			failJob:     true,
+		},
+		{
+			description:      "the condition is set past the deadline",
+			startedAgo:       time.Hour,
+			expectedNotReady: true,
+		},
+		{
+			description:      "the tfjob fails past the deadline",
+			startedAgo:       time.Hour,
+			failJob:          true,
+			expectedNotReady: true,
+			expectedFailed:   true,
+		},
+	}
+
+	for _, tc := range testCases {
+		tfJob := testutil.NewTFJob(1, 0)
+		deadline := int64(600)
+		tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+			tfv1.TFReplicaTypeWorker: {
+				PodReadyDeadlineSeconds:           &deadline,
+				FailJobOnPodReadyDeadlineExceeded: tc.failJob,
+			},
+		}
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+		startTime := metav1.NewTime(time.Now().Add(-tc.startedAgo))
+		pod.Status.Phase = v1.PodRunning
+		pod.Status.StartTime = &startTime
+		pod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionFalse}}
+
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		ctr.PodControl = &control.FakePodControl{}
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		recorder := record.NewFakeRecorder(100)
+		ctr.Recorder = recorder
+		fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+		ctr.tfJobClientSet = fakeClientSet
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tc.description, err)
+		}
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+
+		if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+			t.Errorf("%s: unexpected error %v", tc.description, err)
+		}
+
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", tc.description, err)
+		}
+		condition := tfv1.GetCondition(updated.Status, tfJobPodsNotReady)
+		if notReady := condition != nil && condition.Status == v1.ConditionTrue; notReady != tc.expectedNotReady {
+			t.Fatalf("%s: expected condition %s %v, got %v", tc.description, tfJobPodsNotReady, tc.expectedNotReady, updated.Status.Conditions)
+		}
+		if tc.expectedNotReady && !strings.Contains(condition.Message, pod.Name) {
+			t.Errorf("%s: expected the condition to report pod %s, got %q", tc.description, pod.Name, condition.Message)
+		}
+
+		failed := tfv1.GetCondition(updated.Status, commonv1.JobFailed)
+		if tc.expectedFailed != (failed != nil && failed.Reason == TFJobFailedReasonPodReadyDeadline) {
+			t.Errorf("%s: expected failed %v, got conditions %v", tc.description, tc.expectedFailed, updated.Status.Conditions)
+		}
+	}
+}
+
+func TestUpdatePodReady(t *testing.T) {
+	testCases := []struct {
+		description string
+		oldReady    v1.ConditionStatus
+		curReady    v1.ConditionStatus
+		expected    int
+	}{
+		{
+			description: "the pod becomes ready",
+			oldReady:    v1.ConditionFalse,
+			curReady:    v1.ConditionTrue,
+			expected:    1,
+		},
+		{
+			description: "the pod stops being ready",
+			oldReady:    v1.ConditionTrue,
+			curReady:    v1.ConditionFalse,
+			expected:    1,
+		},
+		{
+			description: "the readiness of the pod does not change",
+			oldReady:    v1.ConditionTrue,
+			curReady:    v1.ConditionTrue,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+
+		// The tfjob is left out of the cache, the readiness flip alone enqueues it.
+		tfJob := testutil.NewTFJob(1, 0)
+		oldPod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+		oldPod.ResourceVersion = "1"
+		oldPod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: c.oldReady}}
+		curPod := oldPod.DeepCopy()
+		curPod.ResourceVersion = "2"
+		curPod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: c.curReady}}
+
+		ctr.updatePod(oldPod, curPod)
+
+		if got := ctr.WorkQueue.Len(); got != c.expected {
+			t.Errorf("%s: expected %d tfjobs to be enqueued, got %d", c.description, c.expected, got)
+		}
+		if c.expected > 0 {
+			key, _ := ctr.WorkQueue.Get()
+			if key != tfJob.Namespace+"/"+tfJob.Name {
+				t.Errorf("%s: expected %s/%s to be enqueued, got %v", c.description, tfJob.Namespace, tfJob.Name, key)
+			}
+		}
+	}
+}
@@ -0,0 +1,372 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// The priority queue reports the metrics client-go reports for its work
+// queues, under the same names, as it replaces the queue of the controller.
+var (
+	workQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "depth",
+		Help:      "Current depth of workqueue",
+	}, []string{"name"})
+	workQueueAdds = promauto.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "workqueue",
+		Name:      "adds_total",
+		Help:      "Total number of adds handled by workqueue",
+	}, []string{"name"})
+	workQueueLatency = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: "workqueue",
+		Name:      "queue_duration_seconds",
+		Help:      "How long in seconds an item stays in workqueue before being requested",
+		Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+	workQueueWorkDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Subsystem: "workqueue",
+		Name:      "work_duration_seconds",
+		Help:      "How long in seconds processing an item from workqueue takes.",
+		Buckets:   prometheus.ExponentialBuckets(10e-9, 10, 10),
+	}, []string{"name"})
+	workQueueUnfinishedWork = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "unfinished_work_seconds",
+		Help:      "How many seconds of work has been done that is in progress and hasn't been observed by work_duration.",
+	}, []string{"name"})
+	workQueueLongestRunningProcessor = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Subsystem: "workqueue",
+		Name:      "longest_running_processor_seconds",
+		Help:      "How many seconds has the longest running processor for workqueue been running.",
+	}, []string{"name"})
+	workQueueRetries = promauto.NewCounterVec(prometheus.CounterOpts{
+		Subsystem: "workqueue",
+		Name:      "retries_total",
+		Help:      "Total number of retries handled by workqueue",
+	}, []string{"name"})
+)
+
+// unfinishedWorkUpdatePeriod is how often the metrics of the work in
+// progress are updated, as in client-go.
+const unfinishedWorkUpdatePeriod = 500 * time.Millisecond
+
+// priorityQueue is a rate limited work queue handing out the items with the
+// highest priority first, and the items of the same priority in the order they
+// were added. Like the queues of client-go, an item is queued at most once,
+// and an item added while it is processed is queued again once it is done.
+type priorityQueue struct {
+	cond        *sync.Cond
+	priority    func(item interface{}) int32
+	rateLimiter workqueue.RateLimiter
+
+	// queue holds the items waiting to be processed.
+	queue priorityItems
+	// dirty holds the items which need to be processed, queued or not.
+	dirty map[interface{}]bool
+	// processing holds the items being processed.
+	processing map[interface{}]bool
+	// seq orders the items of the same priority.
+	seq          int64
+	shuttingDown bool
+
+	metrics priorityQueueMetrics
+}
+
+var _ workqueue.RateLimitingInterface = &priorityQueue{}
+
+// newPriorityQueue returns a priority queue ordering the items by the given
+// priority, which is computed when an item is queued. Its metrics are
+// reported under the given name.
+func newPriorityQueue(rateLimiter workqueue.RateLimiter, name string, priority func(item interface{}) int32) *priorityQueue {
+	q := &priorityQueue{
+		cond:        sync.NewCond(&sync.Mutex{}),
+		priority:    priority,
+		rateLimiter: rateLimiter,
+		dirty:       map[interface{}]bool{},
+		processing:  map[interface{}]bool{},
+		metrics:     newPriorityQueueMetrics(name),
+	}
+	go q.updateUnfinishedWorkLoop()
+	return q
+}
+
+// push queues the item. It must be called with the lock held.
+func (q *priorityQueue) push(item interface{}, priority int32) {
+	q.seq++
+	heap.Push(&q.queue, priorityItem{item: item, priority: priority, seq: q.seq})
+	q.cond.Signal()
+}
+
+func (q *priorityQueue) Add(item interface{}) {
+	// The priority is computed out of the lock, as it may look the item up.
+	priority := q.priority(item)
+
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.shuttingDown || q.dirty[item] {
+		return
+	}
+	q.dirty[item] = true
+	q.metrics.add(item)
+	if q.processing[item] {
+		return
+	}
+	q.push(item, priority)
+}
+
+func (q *priorityQueue) Len() int {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.queue.Len()
+}
+
+func (q *priorityQueue) Get() (interface{}, bool) {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	for q.queue.Len() == 0 && !q.shuttingDown {
+		q.cond.Wait()
+	}
+	if q.queue.Len() == 0 {
+		return nil, true
+	}
+	item := heap.Pop(&q.queue).(priorityItem).item
+	q.processing[item] = true
+	delete(q.dirty, item)
+	q.metrics.get(item)
+	return item, false
+}
+
+func (q *priorityQueue) Done(item interface{}) {
+	q.cond.L.Lock()
+	q.metrics.done(item)
+	dirty := q.dirty[item]
+	delete(q.processing, item)
+	q.cond.L.Unlock()
+	if !dirty {
+		return
+	}
+
+	// The item was added again while it was processed.
+	priority := q.priority(item)
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	if q.dirty[item] && !q.processing[item] {
+		q.push(item, priority)
+	}
+}
+
+func (q *priorityQueue) ShutDown() {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	q.shuttingDown = true
+	q.cond.Broadcast()
+}
+
+func (q *priorityQueue) ShuttingDown() bool {
+	q.cond.L.Lock()
+	defer q.cond.L.Unlock()
+	return q.shuttingDown
+}
+
+func (q *priorityQueue) AddAfter(item interface{}, duration time.Duration) {
+	if q.ShuttingDown() {
+		return
+	}
+	q.metrics.retries.Inc()
+	if duration <= 0 {
+		q.Add(item)
+		return
+	}
+	time.AfterFunc(duration, func() { q.Add(item) })
+}
+
+func (q *priorityQueue) AddRateLimited(item interface{}) {
+	q.AddAfter(item, q.rateLimiter.When(item))
+}
+
+func (q *priorityQueue) Forget(item interface{}) {
+	q.rateLimiter.Forget(item)
+}
+
+func (q *priorityQueue) NumRequeues(item interface{}) int {
+	return q.rateLimiter.NumRequeues(item)
+}
+
+// updateUnfinishedWorkLoop updates the metrics of the work in progress until
+// the queue is shut down.
+func (q *priorityQueue) updateUnfinishedWorkLoop() {
+	ticker := time.NewTicker(unfinishedWorkUpdatePeriod)
+	defer ticker.Stop()
+	for range ticker.C {
+		q.cond.L.Lock()
+		shuttingDown := q.shuttingDown
+		if !shuttingDown {
+			q.metrics.updateUnfinishedWork()
+		}
+		q.cond.L.Unlock()
+		if shuttingDown {
+			return
+		}
+	}
+}
+
+// priorityQueueMetrics records the metrics of a priorityQueue the way
+// client-go does for its queues. It is guarded by the lock of the queue.
+type priorityQueueMetrics struct {
+	depth                   prometheus.Gauge
+	adds                    prometheus.Counter
+	latency                 prometheus.Observer
+	workDuration            prometheus.Observer
+	unfinishedWork          prometheus.Gauge
+	longestRunningProcessor prometheus.Gauge
+	retries                 prometheus.Counter
+
+	addTimes             map[interface{}]time.Time
+	processingStartTimes map[interface{}]time.Time
+}
+
+func newPriorityQueueMetrics(name string) priorityQueueMetrics {
+	return priorityQueueMetrics{
+		depth:                   workQueueDepth.WithLabelValues(name),
+		adds:                    workQueueAdds.WithLabelValues(name),
+		latency:                 workQueueLatency.WithLabelValues(name),
+		workDuration:            workQueueWorkDuration.WithLabelValues(name),
+		unfinishedWork:          workQueueUnfinishedWork.WithLabelValues(name),
+		longestRunningProcessor: workQueueLongestRunningProcessor.WithLabelValues(name),
+		retries:                 workQueueRetries.WithLabelValues(name),
+		addTimes:                map[interface{}]time.Time{},
+		processingStartTimes:    map[interface{}]time.Time{},
+	}
+}
+
+func (m *priorityQueueMetrics) add(item interface{}) {
+	m.adds.Inc()
+	m.depth.Inc()
+	if _, ok := m.addTimes[item]; !ok {
+		m.addTimes[item] = time.Now()
+	}
+}
+
+func (m *priorityQueueMetrics) get(item interface{}) {
+	m.depth.Dec()
+	m.processingStartTimes[item] = time.Now()
+	if start, ok := m.addTimes[item]; ok {
+		m.latency.Observe(time.Since(start).Seconds())
+		delete(m.addTimes, item)
+	}
+}
+
+func (m *priorityQueueMetrics) done(item interface{}) {
+	if start, ok := m.processingStartTimes[item]; ok {
+		m.workDuration.Observe(time.Since(start).Seconds())
+		delete(m.processingStartTimes, item)
+	}
+}
+
+func (m *priorityQueueMetrics) updateUnfinishedWork() {
+	var total, oldest float64
+	for _, start := range m.processingStartTimes {
+		age := time.Since(start).Seconds()
+		total += age
+		if age > oldest {
+			oldest = age
+		}
+	}
+	m.unfinishedWork.Set(total)
+	m.longestRunningProcessor.Set(oldest)
+}
+
+// priorityItem is an item of a priorityQueue.
+type priorityItem struct {
+	item     interface{}
+	priority int32
+	seq      int64
+}
+
+// priorityItems is a heap of items, the one with the highest priority, and
+// the lowest sequence number among those, first.
+type priorityItems []priorityItem
+
+func (p priorityItems) Len() int { return len(p) }
+
+func (p priorityItems) Less(i, j int) bool {
+	if p[i].priority != p[j].priority {
+		return p[i].priority > p[j].priority
+	}
+	return p[i].seq < p[j].seq
+}
+
+func (p priorityItems) Swap(i, j int) { p[i], p[j] = p[j], p[i] }
+
+func (p *priorityItems) Push(x interface{}) { *p = append(*p, x.(priorityItem)) }
+
+func (p *priorityItems) Pop() interface{} {
+	old := *p
+	n := len(old)
+	item := old[n-1]
+	*p = old[:n-1]
+	return item
+}
+
+// getTFJobPriority returns the priority of the tfjob with the given key, the
+// value of the priority class of its scheduling policy, or the highest of the
+// priority classes of its replicas if it has none. Unknown tfjobs and
+// priority classes have priority 0.
+func (tc *TFController) getTFJobPriority(item interface{}) int32 {
+	key, ok := item.(string)
+	if !ok {
+		return 0
+	}
+	obj, exists, err := tc.tfJobInformer.GetIndexer().GetByKey(key)
+	if err != nil || !exists {
+		return 0
+	}
+	tfJob, err := tfJobFromUnstructured(obj)
+	if err != nil {
+		return 0
+	}
+
+	var names []string
+	if policy := tfJob.Spec.RunPolicy.SchedulingPolicy; policy != nil && policy.PriorityClass != "" {
+		names = append(names, policy.PriorityClass)
+	} else {
+		for _, spec := range tfJob.Spec.TFReplicaSpecs {
+			if spec != nil && spec.Template.Spec.PriorityClassName != "" {
+				names = append(names, spec.Template.Spec.PriorityClassName)
+			}
+		}
+	}
+	var priority int32
+	found := false
+	for _, name := range names {
+		priorityClass, err := tc.PriorityClassLister.Get(name)
+		if err != nil {
+			continue
+		}
+		if !found || priorityClass.Value > priority {
+			priority = priorityClass.Value
+			found = true
+		}
+	}
+	return priority
+}
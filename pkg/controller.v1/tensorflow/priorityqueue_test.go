@@ -0,0 +1,198 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"testing"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	promtestutil "github.com/prometheus/client_golang/prometheus/testutil"
+	v1 "k8s.io/api/core/v1"
+	schedulingv1beta1 "k8s.io/api/scheduling/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/util/workqueue"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	priorities := map[string]int32{"high": 100, "low": 1}
+	q := newPriorityQueue(workqueue.DefaultControllerRateLimiter(), "test", func(item interface{}) int32 {
+		return priorities[item.(string)]
+	})
+
+	// Items of the same priority are handed out in the order they were added,
+	// and an item is queued only once.
+	for _, item := range []string{"low", "default", "high", "other", "low"} {
+		q.Add(item)
+	}
+	if q.Len() != 4 {
+		t.Errorf("Expected 4 queued items, got %d", q.Len())
+	}
+	var got []string
+	for q.Len() > 0 {
+		item, _ := q.Get()
+		got = append(got, item.(string))
+		q.Done(item)
+	}
+	expected := []string{"high", "low", "default", "other"}
+	for i := range expected {
+		if i >= len(got) || got[i] != expected[i] {
+			t.Fatalf("Expected the items in the order %v, got %v", expected, got)
+		}
+	}
+
+	// An item added while it is processed is queued again once it is done.
+	q.Add("low")
+	item, _ := q.Get()
+	q.Add("low")
+	if q.Len() != 0 {
+		t.Errorf("Expected the item being processed not to be queued, got %d queued items", q.Len())
+	}
+	q.Done(item)
+	if q.Len() != 1 {
+		t.Errorf("Expected the item to be queued again, got %d queued items", q.Len())
+	}
+
+	q.ShutDown()
+	if _, shutdown := q.Get(); shutdown {
+		t.Errorf("Expected the queued item to be handed out after the shutdown")
+	}
+	if _, shutdown := q.Get(); !shutdown {
+		t.Errorf("Expected the queue to be shut down")
+	}
+}
+
+func TestPriorityQueueMetrics(t *testing.T) {
+	q := newPriorityQueue(workqueue.DefaultControllerRateLimiter(), "test-metrics", func(item interface{}) int32 {
+		return 0
+	})
+	defer q.ShutDown()
+	adds := workQueueAdds.WithLabelValues("test-metrics")
+	depth := workQueueDepth.WithLabelValues("test-metrics")
+	retries := workQueueRetries.WithLabelValues("test-metrics")
+	initialAdds, initialDepth, initialRetries := promtestutil.ToFloat64(adds), promtestutil.ToFloat64(depth), promtestutil.ToFloat64(retries)
+
+	// The metrics are the ones of the client-go queues: an item queued twice
+	// is added once.
+	q.Add("a")
+	q.Add("a")
+	q.Add("b")
+	if got := promtestutil.ToFloat64(adds) - initialAdds; got != 2 {
+		t.Errorf("Expected 2 adds, got %v", got)
+	}
+	if got := promtestutil.ToFloat64(depth) - initialDepth; got != 2 {
+		t.Errorf("Expected a depth of 2, got %v", got)
+	}
+	item, _ := q.Get()
+	q.Done(item)
+	if got := promtestutil.ToFloat64(depth) - initialDepth; got != 1 {
+		t.Errorf("Expected a depth of 1, got %v", got)
+	}
+	q.AddRateLimited("c")
+	if got := promtestutil.ToFloat64(retries) - initialRetries; got != 1 {
+		t.Errorf("Expected 1 retry, got %v", got)
+	}
+}
+
+func TestTFJobPriority(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{EnablePriorityQueue: true})
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+	priorityClassIndexer := kubeInformerFactory.Scheduling().V1beta1().PriorityClasses().Informer().GetIndexer()
+
+	for name, value := range map[string]int32{"high-priority": 1000, "low-priority": 10, "negative-priority": -10} {
+		priorityClass := &schedulingv1beta1.PriorityClass{ObjectMeta: metav1.ObjectMeta{Name: name}, Value: value}
+		if err := priorityClassIndexer.Add(priorityClass); err != nil {
+			t.Fatalf("Failed to add priority class %s: %v", name, err)
+		}
+	}
+	newTFJob := func(name, priorityClass string) *tfv1.TFJob {
+		tfJob := testutil.NewTFJob(1, 0)
+		tfJob.Name = name
+		tfJob.Spec.RunPolicy.SchedulingPolicy = &commonv1.SchedulingPolicy{PriorityClass: priorityClass}
+		unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+		if err != nil {
+			t.Fatalf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+		if err := tfJobIndexer.Add(unstructured); err != nil {
+			t.Fatalf("Failed to add tfjob to tfJobIndexer: %v", err)
+		}
+		return tfJob
+	}
+
+	// The high priority tfjob is reconciled first, although it is enqueued last.
+	lowTFJob := newTFJob("low", "low-priority")
+	highTFJob := newTFJob("high", "high-priority")
+	ctr.enqueueTFJob(lowTFJob)
+	ctr.enqueueTFJob(highTFJob)
+
+	for _, expected := range []*tfv1.TFJob{highTFJob, lowTFJob} {
+		key, _ := ctr.WorkQueue.Get()
+		if key != testutil.GetKey(expected, t) {
+			t.Errorf("Expected %s to be dequeued, got %v", testutil.GetKey(expected, t), key)
+		}
+		ctr.WorkQueue.Done(key)
+	}
+
+	// A tfjob whose only known priority class is negative gets its value,
+	// even if another priority class of its replicas is unknown.
+	tfJob := testutil.NewTFJob(1, 1)
+	tfJob.Name = "negative"
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS].Template.Spec.PriorityClassName = "unknown-priority"
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Template.Spec.PriorityClassName = "negative-priority"
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Fatalf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Fatalf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	if priority := ctr.getTFJobPriority(testutil.GetKey(tfJob, t)); priority != -10 {
+		t.Errorf("Expected the priority -10, got %d", priority)
+	}
+}
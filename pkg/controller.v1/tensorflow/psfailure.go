@@ -0,0 +1,91 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// restartingPSReason is the normal reason when a failed PS is deleted, to be
+// recreated under the Restart PS failure policy.
+const restartingPSReason = "RestartingPS"
+
+// getPSFailurePolicy returns the PS failure policy of the tfjob.
+func getPSFailurePolicy(tfJob *tfv1.TFJob) tfv1.PSFailurePolicy {
+	if tfJob.Spec.PSFailurePolicy == nil {
+		return tfv1.PSFailurePolicyDefault
+	}
+	return *tfJob.Spec.PSFailurePolicy
+}
+
+// isPSPod returns true if the pod is a PS of its tfjob.
+func (tc *TFController) isPSPod(pod *v1.Pod) bool {
+	return strings.EqualFold(pod.Labels[tc.GetReplicaTypeLabelKey()], string(tfv1.TFReplicaTypePS))
+}
+
+// getFailedPS returns a failed PS pod of the tfjob, or nil if none failed.
+func (tc *TFController) getFailedPS(pods []*v1.Pod) *v1.Pod {
+	for _, pod := range pods {
+		if tc.isPSPod(pod) && tc.isFailedPod(pod) {
+			return pod
+		}
+	}
+	return nil
+}
+
+// psFailuresTolerated returns true if the failed PS pods of the tfjob are left
+// alone, because at least PSMinAvailable of its PS are still healthy, or false
+// if the PS failure policy applies to them.
+func (tc *TFController) psFailuresTolerated(tfJob *tfv1.TFJob, pods []*v1.Pod) bool {
+	ps, ok := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS]
+	if tfJob.Spec.PSMinAvailable == nil || !ok || ps.Replicas == nil {
+		return false
+	}
+	var failed int32
+	for _, pod := range pods {
+		if tc.isPSPod(pod) && tc.isFailedPod(pod) {
+			failed++
+		}
+	}
+	return *ps.Replicas-failed >= *tfJob.Spec.PSMinAvailable
+}
+
+// restartFailedPS deletes the failed PS pod, to be recreated by the next
+// reconcile, and sets the Restarting condition of the tfjob.
+func (tc *TFController) restartFailedPS(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, pod *v1.Pod) error {
+	if pod.DeletionTimestamp != nil {
+		return nil
+	}
+	if err := tc.recordReplicaRecreation(tfJob, tfv1.TFReplicaTypePS); err != nil {
+		return err
+	}
+	if err := tc.deletePod(tfJob, pod, restartingPSReason, "PS failed, to be recreated"); err != nil {
+		return err
+	}
+	msg := fmt.Sprintf("TFJob %s is restarting because PS %s failed.", tfJob.Name, pod.Name)
+	tc.Recorder.Event(tfJob, v1.EventTypeWarning, tfJobRestartingReason, msg)
+	if err := commonutil.UpdateJobConditions(jobStatus, commonv1.JobRestarting, tfJobRestartingReason, msg); err != nil {
+		commonutil.LoggerForJob(tfJob).Infof("Append tfjob condition error: %v", err)
+		return err
+	}
+	tfJobsRestartCount.WithLabelValues(tfJob.Namespace).Inc()
+	return nil
+}
@@ -0,0 +1,340 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestPSFailurePolicy(t *testing.T) {
+	testCases := []struct {
+		description     string
+		policy          tfv1.PSFailurePolicy
+		expectedFailed  bool
+		expectedRestart bool
+	}{
+		{
+			description:    "A failed PS fails the tfjob",
+			policy:         tfv1.PSFailurePolicyFailJob,
+			expectedFailed: true,
+		},
+		{
+			description:     "A failed PS is recreated",
+			policy:          tfv1.PSFailurePolicyRestart,
+			expectedRestart: true,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		// The PS exits with a retryable code, which the policy overrides.
+		tfJob := testutil.NewTFJob(1, 1)
+		tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS].RestartPolicy = commonv1.RestartPolicyExitCode
+		policy := c.policy
+		tfJob.Spec.PSFailurePolicy = &policy
+		fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+		ctr.tfJobClientSet = fakeClientSet
+
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 1, 0, 0, nil, t)
+		ps := testutil.NewPod(tfJob, testutil.LabelPS, 0)
+		ps.Status.Phase = v1.PodFailed
+		ps.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name:  tfv1.DefaultContainerName,
+			State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 137}},
+		}}
+		if err := podIndexer.Add(ps); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+		}
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", c.description, err)
+		}
+		condition := tfv1.GetCondition(updated.Status, commonv1.JobFailed)
+		if failed := condition != nil && condition.Status == v1.ConditionTrue; failed != c.expectedFailed {
+			t.Errorf("%s: Expected failed %v, got %v", c.description, c.expectedFailed, updated.Status.Conditions)
+		}
+		if c.expectedFailed && condition.Reason != TFJobFailedReasonPSFailure {
+			t.Errorf("%s: Expected reason %s, got %s", c.description, TFJobFailedReasonPSFailure, condition.Reason)
+		}
+		if !c.expectedRestart {
+			continue
+		}
+
+		// The failed PS is deleted, and recreated by the next reconcile.
+		if len(fakePodControl.DeletePodName) != 1 || fakePodControl.DeletePodName[0] != ps.Name {
+			t.Errorf("%s: Expected pod %s to be deleted, got %v", c.description, ps.Name, fakePodControl.DeletePodName)
+		}
+		if err := podIndexer.Delete(ps); err != nil {
+			t.Errorf("%s: unexpected error when deleting pod %v", c.description, err)
+		}
+		_ = ctr.ReconcileJobs(updated, updated.Spec.TFReplicaSpecs, updated.Status, &updated.Spec.RunPolicy)
+		if len(fakePodControl.Templates) != 1 || fakePodControl.Templates[0].Labels[tfReplicaTypeLabel] != testutil.LabelPS ||
+			fakePodControl.Templates[0].Labels[tfReplicaIndexLabel] != "0" {
+			t.Errorf("%s: Expected PS 0 to be recreated, got %v", c.description, fakePodControl.Templates)
+		}
+	}
+}
+
+func TestPSMinAvailable(t *testing.T) {
+	testCases := []struct {
+		description    string
+		failedPS       int32
+		expectedFailed bool
+	}{
+		{
+			description: "Losing a PS above the min available continues the tfjob",
+			failedPS:    1,
+		},
+		{
+			description:    "Losing PS below the min available fails the tfjob",
+			failedPS:       2,
+			expectedFailed: true,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		tfJob := testutil.NewTFJob(1, 5)
+		policy := tfv1.PSFailurePolicyFailJob
+		tfJob.Spec.PSFailurePolicy = &policy
+		minAvailable := int32(4)
+		tfJob.Spec.PSMinAvailable = &minAvailable
+		fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+		ctr.tfJobClientSet = fakeClientSet
+
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 1, 0, 0, nil, t)
+		testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelPS, 0, 5-c.failedPS, 0, c.failedPS, nil, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 5, t)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", c.description, err)
+		}
+		condition := tfv1.GetCondition(updated.Status, commonv1.JobFailed)
+		if failed := condition != nil && condition.Status == v1.ConditionTrue; failed != c.expectedFailed {
+			t.Errorf("%s: Expected failed %v, got %v", c.description, c.expectedFailed, updated.Status.Conditions)
+		}
+		if c.expectedFailed && condition.Reason != TFJobFailedReasonPSFailure {
+			t.Errorf("%s: Expected reason %s, got %s", c.description, TFJobFailedReasonPSFailure, condition.Reason)
+		}
+		// The tolerated PS is left alone rather than recreated.
+		if !c.expectedFailed && len(fakePodControl.DeletePodName) != 0 {
+			t.Errorf("%s: Expected no pod to be deleted, got %v", c.description, fakePodControl.DeletePodName)
+		}
+	}
+}
+
+func TestToleratedPSWithOtherExemptions(t *testing.T) {
+	testCases := []struct {
+		description string
+		option      options.ServerOption
+		update      func(pod *v1.Pod)
+	}{
+		{
+			description: "A tolerated PS which was evicted",
+			option:      options.ServerOption{RecreateEvictedPods: true},
+			update: func(pod *v1.Pod) {
+				pod.Status.Reason = evictedReason
+			},
+		},
+		{
+			description: "A tolerated PS which failed because of a sidecar",
+			update: func(pod *v1.Pod) {
+				pod.Status.ContainerStatuses[0].State.Terminated.ExitCode = 0
+			},
+		},
+		{
+			description: "A tolerated PS which failed within the grace period",
+			option:      options.ServerOption{FailedPodGracePeriod: time.Minute},
+			update: func(pod *v1.Pod) {
+				pod.Status.ContainerStatuses[0].State.Terminated.FinishedAt = metav1.Now()
+			},
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, c.option)
+		ctr.PodControl = &control.FakePodControl{}
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		// A single failure fails the tfjob, and a single PS is enough.
+		tfJob := testutil.NewTFJob(1, 2)
+		backoffLimit := int32(0)
+		tfJob.Spec.RunPolicy.BackoffLimit = &backoffLimit
+		minAvailable := int32(1)
+		tfJob.Spec.PSMinAvailable = &minAvailable
+		fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+		ctr.tfJobClientSet = fakeClientSet
+
+		// The worker failed for good, the first PS is exempted twice.
+		newFailedPod := func(typ string, index int) *v1.Pod {
+			pod := testutil.NewPod(tfJob, typ, index)
+			pod.Status.Phase = v1.PodFailed
+			pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+				Name: tfv1.DefaultContainerName,
+				State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{
+					ExitCode:   1,
+					FinishedAt: metav1.NewTime(time.Now().Add(-2 * time.Minute)),
+				}},
+			}}
+			return pod
+		}
+		worker := newFailedPod(testutil.LabelWorker, 0)
+		ps := newFailedPod(testutil.LabelPS, 0)
+		c.update(ps)
+		healthyPS := testutil.NewPod(tfJob, testutil.LabelPS, 1)
+		healthyPS.Status.Phase = v1.PodRunning
+		for _, pod := range []*v1.Pod{worker, ps, healthyPS} {
+			if err := podIndexer.Add(pod); err != nil {
+				t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+			}
+		}
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 2, t)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		// The exempted PS does not make up for the failed worker.
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", c.description, err)
+		}
+		condition := tfv1.GetCondition(updated.Status, commonv1.JobFailed)
+		if condition == nil || condition.Status != v1.ConditionTrue || condition.Reason != TFJobFailedReasonBackoff {
+			t.Errorf("%s: Expected the tfjob to fail past its backoff limit, got %v", c.description, updated.Status.Conditions)
+		}
+	}
+}
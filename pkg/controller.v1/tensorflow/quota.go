@@ -0,0 +1,110 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	// tfJobQuotaExceeded is the condition of a tfjob which stopped creating
+	// pods because the ResourceQuota of its namespace is exceeded.
+	tfJobQuotaExceeded commonv1.JobConditionType = "QuotaExceeded"
+	// quotaExceededReason is the reason of the QuotaExceeded condition, and
+	// the warning reason of the event emitted when it is set.
+	quotaExceededReason = "QuotaExceeded"
+	// defaultQuotaExceededBackoff is how long a tfjob stops creating pods after
+	// the quota is exceeded, if the ServerOption does not set it.
+	defaultQuotaExceededBackoff = time.Minute
+)
+
+// isQuotaExceeded returns true if the error is the API server refusing a
+// creation because it would exceed a ResourceQuota.
+func isQuotaExceeded(err error) bool {
+	return err != nil && errors.IsForbidden(err) && strings.Contains(err.Error(), "exceeded quota")
+}
+
+// quotaExceededBackoff returns how long a tfjob stops creating pods after the
+// quota is exceeded.
+func (tc *TFController) quotaExceededBackoff() time.Duration {
+	if tc.option.QuotaExceededBackoff > 0 {
+		return tc.option.QuotaExceededBackoff
+	}
+	return defaultQuotaExceededBackoff
+}
+
+// quotaBackoffRemaining returns how long the tfjob still has to wait before
+// creating pods, or 0 if it does not have to.
+func (tc *TFController) quotaBackoffRemaining(jobStatus *commonv1.JobStatus) time.Duration {
+	condition := tfv1.GetCondition(*jobStatus, tfJobQuotaExceeded)
+	if condition == nil || condition.Status != v1.ConditionTrue {
+		return 0
+	}
+	if remaining := time.Until(condition.LastUpdateTime.Add(tc.quotaExceededBackoff())); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// setQuotaExceeded sets the QuotaExceeded condition of the tfjob, and emits
+// an event. The last update time of the condition is refreshed every time, so
+// that the backoff starts over when the quota is exceeded again.
+func (tc *TFController) setQuotaExceeded(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, err error) {
+	msg := fmt.Sprintf("TFJob %s/%s stopped creating pods for %v because the namespace quota is exceeded: %v",
+		tfJob.Namespace, tfJob.Name, tc.quotaExceededBackoff(), err)
+	commonutil.LoggerForJob(tfJob).Warn(msg)
+	tc.Recorder.Event(tfJob, v1.EventTypeWarning, quotaExceededReason, msg)
+
+	now := metav1.Now()
+	condition := commonv1.JobCondition{
+		Type:               tfJobQuotaExceeded,
+		Status:             v1.ConditionTrue,
+		Reason:             quotaExceededReason,
+		Message:            msg,
+		LastUpdateTime:     now,
+		LastTransitionTime: now,
+	}
+	// The conditions are copied, as they may be shared with the tfjob.
+	conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)+1)
+	for _, c := range jobStatus.Conditions {
+		if c.Type != tfJobQuotaExceeded {
+			conditions = append(conditions, c)
+		} else if c.Status == v1.ConditionTrue {
+			condition.LastTransitionTime = c.LastTransitionTime
+		}
+	}
+	jobStatus.Conditions = append(conditions, condition)
+}
+
+// clearQuotaExceeded removes the QuotaExceeded condition of the tfjob.
+func clearQuotaExceeded(jobStatus *commonv1.JobStatus) {
+	for i, condition := range jobStatus.Conditions {
+		if condition.Type == tfJobQuotaExceeded {
+			conditions := make([]commonv1.JobCondition, 0, len(jobStatus.Conditions)-1)
+			conditions = append(conditions, jobStatus.Conditions[:i]...)
+			jobStatus.Conditions = append(conditions, jobStatus.Conditions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestQuotaExceeded(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	recorder := record.NewFakeRecorder(100)
+	ctr.Recorder = recorder
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(2, 0)
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+
+	// The first creation is refused, and the second one is not tried.
+	fakePodControl.Err = errors.NewForbidden(schema.GroupResource{Resource: "pods"}, "test-tfjob-worker-0",
+		fmt.Errorf("exceeded quota: compute-resources"))
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if fakePodControl.CreateCallCount != 1 {
+		t.Errorf("Expected 1 pod creation, got %d", fakePodControl.CreateCallCount)
+	}
+	quotaExceeded := false
+	for _, condition := range tfJob.Status.Conditions {
+		if condition.Type == tfJobQuotaExceeded && condition.Status == v1.ConditionTrue {
+			quotaExceeded = true
+		}
+	}
+	if !quotaExceeded {
+		t.Errorf("Expected condition %s, got %v", tfJobQuotaExceeded, tfJob.Status.Conditions)
+	}
+	found := false
+	for len(recorder.Events) > 0 {
+		if strings.HasPrefix(<-recorder.Events, v1.EventTypeWarning+" "+quotaExceededReason) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a %s event", quotaExceededReason)
+	}
+
+	// No pods are created during the backoff, even if the quota is freed.
+	fakePodControl.Err = nil
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if fakePodControl.CreateCallCount != 1 {
+		t.Errorf("Expected pod creations to be deferred, got %d creations", fakePodControl.CreateCallCount)
+	}
+}
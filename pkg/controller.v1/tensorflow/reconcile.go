@@ -0,0 +1,567 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	"github.com/kubeflow/common/pkg/util/k8sutil"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	log "github.com/sirupsen/logrus"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+)
+
+// reconcilePass is the state of the reconcile of a tfjob, which the hooks
+// JobController.ReconcileJobs calls back into share.
+type reconcilePass struct {
+	// ctx carries the trace ID of the reconcile down to the creation of the pods.
+	ctx context.Context
+	// jobStatus is the latest status of the tfjob in the reconcile.
+	jobStatus commonv1.JobStatus
+	// written is set once the status has been written in the reconcile.
+	written bool
+	// pods and services are those of the tfjob when the reconcile started.
+	pods     []*v1.Pod
+	services []*v1.Service
+	// podBudget and serviceBudget limit the pods and services created in the
+	// reconcile.
+	podBudget     *createBudget
+	serviceBudget *createBudget
+	// queued is set if the namespace has too many active jobs for the tfjob
+	// to create pods.
+	queued bool
+	// quotaBackoff is the time left before pods are created again after the
+	// namespace quota was exceeded.
+	quotaBackoff time.Duration
+}
+
+// reconcilePasses holds the reconcile pass of every tfjob being reconciled,
+// by key.
+type reconcilePasses struct {
+	mu     sync.Mutex
+	passes map[string]*reconcilePass
+}
+
+// start records the pass of the tfjob with the given key.
+func (p *reconcilePasses) start(key string, pass *reconcilePass) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.passes == nil {
+		p.passes = map[string]*reconcilePass{}
+	}
+	p.passes[key] = pass
+}
+
+// finish drops the pass of the tfjob with the given key.
+func (p *reconcilePasses) finish(key string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	delete(p.passes, key)
+}
+
+// get returns the pass of the tfjob with the given key, or nil if the tfjob
+// is not being reconciled, e.g. when a hook is called on its own.
+func (p *reconcilePasses) get(key string) *reconcilePass {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.passes[key]
+}
+
+// getReconcilePass returns the pass reconciling the tfjob, if any.
+func (tc *TFController) getReconcilePass(tfJob *tfv1.TFJob) *reconcilePass {
+	key, err := KeyFunc(tfJob)
+	if err != nil {
+		return nil
+	}
+	return tc.reconcilePasses.get(key)
+}
+
+// ReconcileJobs checks and updates replicas for each given ReplicaSpec.
+// It will requeue the job in case of an error while creating/deleting pods/services.
+func (tc *TFController) ReconcileJobs(
+	job interface{},
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec,
+	jobStatus commonv1.JobStatus,
+	runPolicy *commonv1.RunPolicy) error {
+	return tc.reconcileJobs(newTraceContext(context.Background()), job, replicas, jobStatus, runPolicy)
+}
+
+// reconcileJobs is ReconcileJobs which carries the given context, e.g. the
+// trace ID of the reconcile, down to the creation of the pods.
+// The job is reconciled by JobController.ReconcileJobs in kubeflow/common,
+// around which the TFJob specific policies, e.g. its failure policies, are
+// applied. The pods and services of its replicas are reconciled by the
+// ReconcilePods and UpdateJobStatus hooks, within the pass started here.
+func (tc *TFController) reconcileJobs(
+	ctx context.Context,
+	job interface{},
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec,
+	jobStatus commonv1.JobStatus,
+	runPolicy *commonv1.RunPolicy) error {
+
+	tfJob, ok := job.(*tfv1.TFJob)
+	if !ok {
+		return fmt.Errorf("%v is not a type of TFJob", job)
+	}
+	if tc.skipUnmanaged(tfJob) {
+		return nil
+	}
+	jobName := tfJob.GetName()
+	jobKey, err := KeyFunc(job)
+	if err != nil {
+		utilruntime.HandleError(fmt.Errorf("Couldn't get key for job object %#v: %v", job, err))
+		return err
+	}
+	traceLogger(ctx, commonutil.LoggerForJob(tfJob)).Infof("Reconciling for job %s", jobName)
+
+	pass := &reconcilePass{ctx: ctx, jobStatus: jobStatus}
+	tc.reconcilePasses.start(jobKey, pass)
+	defer func() {
+		tc.reconcilePasses.finish(jobKey)
+		// Report the phase the reconciliation leaves the job in.
+		tfJobPhases.set(jobKey, getPhase(pass.jobStatus))
+	}()
+
+	// A finished job past its TTL only waits to be deleted. Late pod events
+	// must not make it touch its pods, or create new ones, in the meantime.
+	if isFinishedPastTTL(runPolicy, jobStatus) {
+		log.Infof("Job %s is past its TTL after finishing, skipping reconcile", jobName)
+		if tfJob.DeletionTimestamp != nil {
+			return nil
+		}
+		return tc.CleanupJob(runPolicy, jobStatus, job)
+	}
+
+	// The templated env is rendered for every pod. Check that it renders for
+	// the job before reconciling it, instead of failing pod after pod.
+	if tc.envTemplate != nil {
+		if _, err := tc.envTemplate.render(tfJob, "", ""); err != nil {
+			tc.Recorder.Eventf(tfJob, v1.EventTypeWarning, invalidEnvTemplateReason,
+				"Failed to render the templated env: %v", err)
+			return err
+		}
+	}
+
+	if err := tc.repairOwnerReferences(tfJob); err != nil {
+		log.Warnf("Repair owner references error %v", err)
+		return err
+	}
+
+	// The relabeled pods are counted by the next reconcile, which their
+	// update triggers, once the cache has them. Counting them now would
+	// recreate them, and release them from the job.
+	if tc.option.RelabelLegacyPods {
+		relabeled, err := tc.relabelLegacyPods(tfJob)
+		if err != nil {
+			log.Warnf("Relabel legacy pods error %v", err)
+			return err
+		}
+		if relabeled {
+			return nil
+		}
+	}
+
+	// The pods kept alive after completion are listed as well, unlike in the
+	// GetPodsForJob hook.
+	pods, err := tc.JobController.GetPodsForJob(job)
+	if err != nil {
+		log.Warnf("GetPodsForJob error %v", err)
+		return err
+	}
+
+	services, err := tc.GetServicesForJob(job)
+	if err != nil {
+		log.Warnf("GetServicesForJob error %v", err)
+		return err
+	}
+
+	oldStatus := jobStatus.DeepCopy()
+
+	// Summarize the job once, in the reconcile which finishes it.
+	defer func() {
+		if !isSucceeded(*oldStatus) && !isFailed(*oldStatus) && (isSucceeded(pass.jobStatus) || isFailed(pass.jobStatus)) {
+			tc.recordJobSummary(tfJob, pass.jobStatus, replicas, pods)
+		}
+	}()
+
+	// The created condition is normally set when the add event is handled.
+	// Establish it here as well, in case the event was missed, e.g. because
+	// the controller was restarted.
+	if len(jobStatus.Conditions) == 0 {
+		msg := fmt.Sprintf("TFJob %s is created.", jobName)
+		if err := commonutil.UpdateJobConditions(&jobStatus, commonv1.JobCreated, tfJobCreatedReason, msg); err != nil {
+			log.Infof("Append job condition error: %v", err)
+			return err
+		}
+	}
+
+	if commonutil.IsSucceeded(jobStatus) || commonutil.IsFailed(jobStatus) {
+		return tc.reconcileFinishedJob(tfJob, replicas, jobStatus, runPolicy, pods, *oldStatus)
+	}
+
+	// A tfjob without replica specs has nothing to run. It is failed instead
+	// of being reconciled over and over, e.g. when it was created before the
+	// validation rejected it.
+	if len(replicas) == 0 {
+		msg := fmt.Sprintf("TFJob %s has failed because it has no replica specs", jobName)
+		tc.Recorder.Event(tfJob, v1.EventTypeWarning, TFJobFailedReasonInvalidSpec, msg)
+		if jobStatus.CompletionTime == nil {
+			now := metav1.Now()
+			jobStatus.CompletionTime = &now
+		}
+		if err := commonutil.UpdateJobConditions(&jobStatus, commonv1.JobFailed, TFJobFailedReasonInvalidSpec, msg); err != nil {
+			log.Infof("Append job condition error: %v", err)
+			return err
+		}
+		return tc.UpdateJobStatusInApiServer(job, &jobStatus)
+	}
+
+	// A tfjob whose replica types are all scaled to zero is hibernated instead
+	// of being considered as completed, until it is scaled up again.
+	if isIdle(replicas) {
+		if err := tc.hibernate(tfJob, &jobStatus, pods, services); err != nil {
+			return err
+		}
+		pass.jobStatus = jobStatus
+		if err := tc.syncStatusAnnotations(tfJob, replicas, pods, &jobStatus); err != nil {
+			log.Warnf("SyncStatusAnnotations error %v", err)
+			return err
+		}
+		if !reflect.DeepEqual(*oldStatus, jobStatus) {
+			return tc.UpdateJobStatusInApiServer(job, &jobStatus)
+		}
+		return nil
+	}
+	if tfv1.IsConditionTrue(jobStatus, tfJobIdle) {
+		tc.resume(tfJob, &jobStatus)
+	}
+
+	// retrieve the previous number of retry
+	previousRetry := tc.WorkQueue.NumRequeues(jobKey)
+
+	// Standby pods are not replicas until they are promoted, so they neither
+	// count as active nor as failed.
+	replicaPods, _ := splitStandbyPods(pods)
+	activePods := k8sutil.FilterActivePods(replicaPods)
+
+	active := int32(len(activePods))
+	// Evicted pods which are recreated, pods failed only because of a sidecar
+	// or within the grace period, and the PS failed while enough PS are
+	// healthy do not count against the backoff limit. Each pod is counted
+	// once, whichever of these applies to it.
+	toleratePSFailures := tc.psFailuresTolerated(tfJob, replicaPods)
+	var failed int32
+	for _, pod := range replicaPods {
+		if tc.isFailedPod(pod) && !(toleratePSFailures && tc.isPSPod(pod)) {
+			failed++
+		}
+	}
+	// Requeue the pods failed within the grace period, to count them once it
+	// elapses.
+	if err := tc.requeueFailedPodGrace(tfJob, pods); err != nil {
+		return err
+	}
+	totalReplicas := k8sutil.GetTotalReplicas(replicas)
+	prevReplicasFailedNum := k8sutil.GetTotalFailedReplicas(jobStatus.ReplicaStatuses)
+
+	if err := tc.reconcileImagePulls(tfJob, &jobStatus, pods); err != nil {
+		return err
+	}
+	podReadyDeadlineExceeded, err := tc.reconcilePodReadiness(tfJob, &jobStatus, pods)
+	if err != nil {
+		return err
+	}
+
+	var failureMessage, failureReason string
+	exceedsBackoffLimit := false
+	pastBackoffLimit := false
+
+	if runPolicy.BackoffLimit != nil {
+		jobHasNewFailure := failed > prevReplicasFailedNum
+		// new failures happen when status does not reflect the failures and active
+		// is different than parallelism, otherwise the previous controller loop
+		// failed updating status so even if we pick up failure it is not a new one
+		exceedsBackoffLimit = jobHasNewFailure && (active != totalReplicas) &&
+			(int32(previousRetry)+1 > *runPolicy.BackoffLimit)
+
+		pastBackoffLimit, err = tc.PastBackoffLimit(jobName, runPolicy, replicas, replicaPods)
+		if err != nil {
+			return err
+		}
+	}
+
+	if exceedsBackoffLimit || pastBackoffLimit {
+		// check if the number of pod restart exceeds backoff (for restart OnFailure only)
+		// OR if the number of failed jobs increased since the last syncJob
+		failureMessage = fmt.Sprintf("Job %s has failed because it has reached the specified backoff limit", jobName)
+		failureReason = TFJobFailedReasonBackoff
+	} else if tc.PastActiveDeadline(runPolicy, jobStatus) {
+		failureMessage = fmt.Sprintf("Job %s has failed because it was active longer than specified deadline", jobName)
+		failureReason = TFJobFailedReasonDeadline
+	} else if getPSFailurePolicy(tfJob) == tfv1.PSFailurePolicyFailJob && !toleratePSFailures {
+		// A failed PS fails the job right away, whatever its restart policy,
+		// unless enough PS are still healthy.
+		if pod := tc.getFailedPS(replicaPods); pod != nil {
+			failureMessage = fmt.Sprintf("Job %s has failed because PS %s failed", jobName, pod.Name)
+			failureReason = TFJobFailedReasonPSFailure
+		}
+	}
+	// A failed chief fails the job right away, or makes it restart all its
+	// replicas within the backoff limit, whatever its restart policy.
+	var failedChief *v1.Pod
+	if failureReason == "" && getChiefFailurePolicy(tfJob) != tfv1.ChiefFailurePolicyDefault {
+		failedChief = tc.getFailedChief(replicaPods)
+	}
+	if failedChief != nil {
+		if getChiefFailurePolicy(tfJob) == tfv1.ChiefFailurePolicyFailJob {
+			failureMessage = fmt.Sprintf("Job %s has failed because chief %s failed", jobName, failedChief.Name)
+			failureReason = TFJobFailedReasonChiefFailure
+		} else if pastChiefRestartLimit(tfJob, runPolicy) {
+			failureMessage = fmt.Sprintf("Job %s has failed because it has reached the specified backoff limit", jobName)
+			failureReason = TFJobFailedReasonBackoff
+		} else {
+			if err := tc.restartAllReplicas(tfJob, &jobStatus, pods, failedChief); err != nil {
+				return err
+			}
+			return tc.UpdateJobStatusInApiServer(job, &jobStatus)
+		}
+	}
+	if failureReason == "" && tc.pastImagePullTimeout(&jobStatus) {
+		failureMessage = fmt.Sprintf("Job %s has failed because its pods failed to pull their images for more than %v",
+			jobName, tc.option.ImagePullTimeout)
+		failureReason = TFJobFailedReasonImagePull
+	}
+	if failureReason == "" && podReadyDeadlineExceeded {
+		failureMessage = fmt.Sprintf("Job %s has failed because its pods were not ready past their deadline", jobName)
+		failureReason = TFJobFailedReasonPodReadyDeadline
+	}
+
+	if failureReason != "" {
+		// The failed job is cleaned up as any finished job, before its status
+		// is written.
+		if jobStatus.CompletionTime == nil {
+			now := metav1.Now()
+			jobStatus.CompletionTime = &now
+		}
+		tc.Recorder.Event(tfJob, v1.EventTypeNormal, failureReason, failureMessage)
+		if err := commonutil.UpdateJobConditions(&jobStatus, commonv1.JobFailed, failureReason, failureMessage); err != nil {
+			log.Infof("Append job condition error: %v", err)
+			return err
+		}
+		return tc.reconcileFinishedJob(tfJob, replicas, jobStatus, runPolicy, pods, *oldStatus)
+	}
+
+	if err := tc.syncResourcesUnavailable(tfJob, &jobStatus, replicas); err != nil {
+		log.Warnf("SyncResourcesUnavailable error %v", err)
+		return err
+	}
+
+	// Limit the number of pods and services created in this pass,
+	// the rest are created when the job is synced again.
+	pass.pods = pods
+	pass.services = services
+	pass.podBudget = newCreateBudget(tc.option.CreateBatchSize)
+	pass.serviceBudget = newCreateBudget(tc.option.CreateBatchSize)
+
+	// No pods are created while the job backs off from an exceeded quota.
+	pass.quotaBackoff = tc.quotaBackoffRemaining(&jobStatus)
+	if pass.quotaBackoff > 0 {
+		pass.podBudget = &createBudget{}
+	}
+
+	// Neither are they while the namespace has too many active jobs.
+	pass.queued = tc.isQueued(tfJob, activePods)
+	if pass.queued {
+		tc.setQueued(tfJob, &jobStatus)
+		pass.podBudget = &createBudget{}
+		pass.serviceBudget = &createBudget{}
+	} else {
+		clearQueued(&jobStatus)
+	}
+
+	pass.jobStatus = jobStatus
+	if err := tc.JobController.ReconcileJobs(job, replicas, jobStatus, tc.getCommonRunPolicy(tfJob, runPolicy)); err != nil {
+		return err
+	}
+
+	// A job which succeeded while some of its workers still run, e.g. once
+	// its worker 0 did, cleans them up right away according to its
+	// CleanPodPolicy, instead of waiting for its next sync.
+	if isSucceeded(pass.jobStatus) {
+		if err := tc.JobController.DeletePodsAndServices(tc.getCommonRunPolicy(tfJob, runPolicy), job,
+			filterKeepAlivePods(tfJob, pods, tc.GetReplicaTypeLabelKey())); err != nil {
+			log.Warnf("DeletePodsAndServices error %v", err)
+			return err
+		}
+	}
+	// The changes made around JobController.ReconcileJobs are written, if
+	// it did not write the status itself.
+	if !pass.written && !reflect.DeepEqual(*oldStatus, pass.jobStatus) {
+		if err := tc.UpdateJobStatusInApiServer(job, &pass.jobStatus); err != nil {
+			return err
+		}
+	}
+	if err := tc.syncStatusAnnotations(tfJob, replicas, pods, &pass.jobStatus); err != nil {
+		log.Warnf("SyncStatusAnnotations error %v", err)
+		return err
+	}
+	return nil
+}
+
+// reconcileFinishedJob cleans up the succeeded or failed tfjob through
+// JobController.ReconcileJobs, which leaves out the pods kept alive after
+// completion, as the GetPodsForJob hook does not list them.
+func (tc *TFController) reconcileFinishedJob(tfJob *tfv1.TFJob, replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec,
+	jobStatus commonv1.JobStatus, runPolicy *commonv1.RunPolicy, pods []*v1.Pod, oldStatus commonv1.JobStatus) error {
+	pass := tc.getReconcilePass(tfJob)
+	pass.jobStatus = jobStatus
+	if err := tc.JobController.ReconcileJobs(tfJob, replicas, jobStatus, tc.getCommonRunPolicy(tfJob, runPolicy)); err != nil {
+		return err
+	}
+
+	if tc.option.TFConfigSecret {
+		if err := tc.deleteTFConfigSecrets(tfJob); err != nil {
+			return err
+		}
+	}
+
+	// The status changed before JobController.ReconcileJobs, e.g. when the
+	// tfjob was failed by its own policies, is written here.
+	if !pass.written && !reflect.DeepEqual(oldStatus, pass.jobStatus) {
+		if err := tc.UpdateJobStatusInApiServer(tfJob, &pass.jobStatus); err != nil {
+			return err
+		}
+	}
+	if err := tc.syncStatusAnnotations(tfJob, replicas, pods, &pass.jobStatus); err != nil {
+		log.Warnf("SyncStatusAnnotations error %v", err)
+		return err
+	}
+	return nil
+}
+
+// GetPodsForJob returns the set of pods that this job should manage. While a
+// finished tfjob is reconciled, the pods of the replica types kept alive
+// after completion are left out, so that the job controller does not clean
+// them up.
+func (tc *TFController) GetPodsForJob(job interface{}) ([]*v1.Pod, error) {
+	pods, err := tc.JobController.GetPodsForJob(job)
+	if err != nil {
+		return nil, err
+	}
+	tfJob, ok := job.(*tfv1.TFJob)
+	if !ok {
+		return pods, nil
+	}
+	pass := tc.getReconcilePass(tfJob)
+	if pass == nil || (!isSucceeded(pass.jobStatus) && !isFailed(pass.jobStatus)) {
+		return pods, nil
+	}
+	return filterKeepAlivePods(tfJob, pods, tc.GetReplicaTypeLabelKey()), nil
+}
+
+// filterKeepAlivePods returns the pods without those of the replica types
+// kept alive after completion.
+func filterKeepAlivePods(tfJob *tfv1.TFJob, pods []*v1.Pod, replicaTypeLabel string) []*v1.Pod {
+	var filtered []*v1.Pod
+	for _, pod := range pods {
+		if !keepAliveAfterCompletion(tfJob, commonv1.ReplicaType(pod.Labels[replicaTypeLabel])) {
+			filtered = append(filtered, pod)
+		}
+	}
+	return filtered
+}
+
+// getCommonRunPolicy returns the run policy JobController.ReconcileJobs
+// reconciles the tfjob with. Its CleanPodPolicy may be overridden by the
+// annotation. The backoff limit and the active deadline are left out, as they
+// are checked beforehand, to fail the tfjob with its own reasons.
+func (tc *TFController) getCommonRunPolicy(tfJob *tfv1.TFJob, runPolicy *commonv1.RunPolicy) *commonv1.RunPolicy {
+	commonRunPolicy := runPolicy.DeepCopy()
+	cleanPodPolicy := getCleanPodPolicy(tfJob, runPolicy)
+	commonRunPolicy.CleanPodPolicy = &cleanPodPolicy
+	commonRunPolicy.BackoffLimit = nil
+	commonRunPolicy.ActiveDeadlineSeconds = nil
+	return commonRunPolicy
+}
+
+// reconcileJobPods runs the steps which reconcile the pods of the tfjob as a
+// whole, once the pods of each replica type are reconciled in the pass.
+func (tc *TFController) reconcileJobPods(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus,
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec, pass *reconcilePass) error {
+	pods := pass.pods
+
+	if err := tc.reconcileStuckPods(tfJob, jobStatus, pods); err != nil {
+		log.Warnf("ReconcileStuckPods error %v", err)
+		return err
+	}
+
+	if err := tc.drainCordonedNodes(tfJob, replicas, pods); err != nil {
+		log.Warnf("DrainCordonedNodes error %v", err)
+		return err
+	}
+
+	if err := tc.recreateDriftedReplicas(tfJob, replicas, pods); err != nil {
+		log.Warnf("RecreateDriftedReplicas error %v", err)
+		return err
+	}
+
+	if err := tc.syncClusterMembers(tfJob, pods); err != nil {
+		log.Warnf("SyncClusterMembers error %v", err)
+		return err
+	}
+
+	if err := tc.syncTFConfigFiles(tfJob, pods); err != nil {
+		log.Warnf("SyncTFConfigFiles error %v", err)
+		return err
+	}
+
+	jobKey, err := KeyFunc(tfJob)
+	if err != nil {
+		return err
+	}
+	if pass.queued {
+		tc.WorkQueue.AddAfter(jobKey, queuedJobRecheckInterval)
+	} else if pass.quotaBackoff > 0 {
+		tc.WorkQueue.AddAfter(jobKey, pass.quotaBackoff)
+	} else {
+		clearQuotaExceeded(jobStatus)
+		if pass.podBudget.hasDeferred() || pass.serviceBudget.hasDeferred() {
+			tc.WorkQueue.Add(jobKey)
+		}
+	}
+	return nil
+}
+
+// getCleanPodPolicy returns the CleanPodPolicy of the TFJob, which the
+// CleanPodPolicy annotation overrides, if it is set to a valid policy.
+func getCleanPodPolicy(tfJob *tfv1.TFJob, runPolicy *commonv1.RunPolicy) commonv1.CleanPodPolicy {
+	if value, ok := tfJob.Annotations[tfv1.CleanPodPolicyAnnotation]; ok {
+		switch policy := commonv1.CleanPodPolicy(value); policy {
+		case commonv1.CleanPodPolicyAll, commonv1.CleanPodPolicyRunning, commonv1.CleanPodPolicyNone:
+			return policy
+		default:
+			commonutil.LoggerForJob(tfJob).Warnf("Ignoring the invalid %s annotation %q", tfv1.CleanPodPolicyAnnotation, value)
+		}
+	}
+	return *runPolicy.CleanPodPolicy
+}
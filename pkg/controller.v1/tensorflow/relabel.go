@@ -0,0 +1,93 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"encoding/json"
+
+	commonutil "github.com/kubeflow/common/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+)
+
+const (
+	// Legacy label keys of the replica type and index of the pods, which
+	// the pods created by older versions of the operator carry.
+	legacyReplicaTypeLabel  = "tf-replica-type"
+	legacyReplicaIndexLabel = "tf-replica-index"
+
+	relabeledLegacyPodReason = "RelabeledLegacyPod"
+)
+
+// relabelLegacyPods patches the current labels onto the pods controlled by
+// the tfjob which only carry the legacy ones, so that they are selected by
+// the labels of the tfjob again. It returns whether any pod was relabeled.
+func (tc *TFController) relabelLegacyPods(tfJob *tfv1.TFJob) (bool, error) {
+	pods, err := tc.PodLister.Pods(tfJob.Namespace).List(labels.SelectorFromSet(labels.Set{labelTFJobName: tfJob.Name}))
+	if err != nil {
+		return false, err
+	}
+
+	logger := commonutil.LoggerForJob(tfJob)
+	relabeled := false
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil || !metav1.IsControlledBy(pod, tfJob) {
+			continue
+		}
+		podLabels := tc.legacyPodLabels(tfJob, pod)
+		if len(podLabels) == 0 {
+			continue
+		}
+		patch, err := json.Marshal(map[string]interface{}{
+			"metadata": map[string]interface{}{
+				"labels": podLabels,
+			},
+		})
+		if err != nil {
+			return relabeled, err
+		}
+		if err := tc.PodControl.PatchPod(pod.Namespace, pod.Name, patch); err != nil {
+			return relabeled, err
+		}
+		relabeled = true
+		logger.Infof("Relabeled the legacy pod %s/%s", pod.Namespace, pod.Name)
+		tc.Recorder.Eventf(tfJob, v1.EventTypeNormal, relabeledLegacyPodReason,
+			"Relabeled the legacy pod %s", pod.Name)
+	}
+	return relabeled, nil
+}
+
+// legacyPodLabels returns the current labels of the tfjob which the pod is
+// missing, with their values read from its legacy labels.
+func (tc *TFController) legacyPodLabels(tfJob *tfv1.TFJob, pod *v1.Pod) map[string]string {
+	expected := tc.GenLabels(tfJob.Name)
+	if rt, ok := pod.Labels[legacyReplicaTypeLabel]; ok {
+		tc.setReplicaTypeLabel(expected, rt)
+	}
+	if index, ok := pod.Labels[legacyReplicaIndexLabel]; ok {
+		tc.setReplicaIndexLabel(expected, index)
+	}
+
+	missing := make(map[string]string)
+	for key, value := range expected {
+		if _, ok := pod.Labels[key]; !ok {
+			missing[key] = value
+		}
+	}
+	return missing
+}
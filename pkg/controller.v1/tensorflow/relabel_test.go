@@ -0,0 +1,137 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestRelabelLegacyPods(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{RelabelLegacyPods: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = record.NewFakeRecorder(100)
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(1, 0)
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+
+	// The running worker only carries the legacy labels.
+	pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	pod.Status.Phase = v1.PodRunning
+	delete(pod.Labels, commonv1.JobNameLabel)
+	delete(pod.Labels, tfReplicaTypeLabel)
+	delete(pod.Labels, tfReplicaIndexLabel)
+	pod.Labels[labelTFJobName] = tfJob.Name
+	pod.Labels[legacyReplicaTypeLabel] = testutil.LabelWorker
+	pod.Labels[legacyReplicaIndexLabel] = "0"
+	if err := podIndexer.Add(pod); err != nil {
+		t.Fatalf("unexpected error when adding pod %v", err)
+	}
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+
+	if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+		t.Fatalf("unexpected error when reconciling %v", err)
+	}
+	if fakePodControl.CreateCallCount != 0 {
+		t.Errorf("Expected no pod to be created while relabeling, got %d", fakePodControl.CreateCallCount)
+	}
+	if len(fakePodControl.Patches) != 1 {
+		t.Fatalf("Expected the legacy pod to be patched once, got %d patches", len(fakePodControl.Patches))
+	}
+	var patch struct {
+		Metadata struct {
+			Labels map[string]string `json:"labels"`
+		} `json:"metadata"`
+	}
+	if err := json.Unmarshal(fakePodControl.Patches[0], &patch); err != nil {
+		t.Fatalf("unexpected error when decoding the patch %v", err)
+	}
+	expected := map[string]string{
+		commonv1.JobNameLabel: tfJob.Name,
+		tfReplicaTypeLabel:    testutil.LabelWorker,
+		tfReplicaIndexLabel:   "0",
+	}
+	for key, value := range expected {
+		if patch.Metadata.Labels[key] != value {
+			t.Errorf("Expected label %s=%s, got %v", key, value, patch.Metadata.Labels)
+		}
+	}
+
+	// Once the cache has the relabeled pod, it is counted as the worker.
+	relabeled := pod.DeepCopy()
+	for key, value := range patch.Metadata.Labels {
+		relabeled.Labels[key] = value
+	}
+	if err := podIndexer.Update(relabeled); err != nil {
+		t.Fatalf("unexpected error when updating pod %v", err)
+	}
+	fakePodControl.Clear()
+	if err := ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy); err != nil {
+		t.Fatalf("unexpected error when reconciling %v", err)
+	}
+	if fakePodControl.CreateCallCount != 0 || len(fakePodControl.Patches) != 0 {
+		t.Errorf("Expected the relabeled pod to be left alone, got %d creations and %d patches",
+			fakePodControl.CreateCallCount, len(fakePodControl.Patches))
+	}
+	status := tfJob.Status.ReplicaStatuses[commonv1.ReplicaType(tfv1.TFReplicaTypeWorker)]
+	if status == nil || status.Active != 1 {
+		t.Errorf("Expected the relabeled pod to be counted as active, got %v", status)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// getReplicaRecreations returns the number of times the pods of each replica
+// type of the tfjob were recreated after failing, by lower case replica type.
+func getReplicaRecreations(tfJob *tfv1.TFJob) map[string]int32 {
+	recreations := map[string]int32{}
+	if value, ok := tfJob.Annotations[tfv1.ReplicaRecreationsAnnotation]; ok {
+		// A corrupted annotation starts the count over.
+		_ = json.Unmarshal([]byte(value), &recreations)
+	}
+	return recreations
+}
+
+// pastRestartLimit returns true if the pods of the replica type were recreated
+// as many times as its restart limit allows.
+func pastRestartLimit(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType) bool {
+	policy := getReplicaPolicy(tfJob, rtype)
+	if policy == nil || policy.RestartLimit == nil {
+		return false
+	}
+	return getReplicaRecreations(tfJob)[strings.ToLower(string(rtype))] >= *policy.RestartLimit
+}
+
+// recordReplicaRecreation counts a recreation of a failed pod of the replica
+// type, if it has a restart limit.
+func (tc *TFController) recordReplicaRecreation(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType) error {
+	policy := getReplicaPolicy(tfJob, rtype)
+	if policy == nil || policy.RestartLimit == nil {
+		return nil
+	}
+	recreations := getReplicaRecreations(tfJob)
+	recreations[strings.ToLower(string(rtype))]++
+	value, err := json.Marshal(recreations)
+	if err != nil {
+		return err
+	}
+	return tc.patchTFJobAnnotation(tfJob, tfv1.ReplicaRecreationsAnnotation, string(value))
+}
+
+// failPastRestartLimit fails the tfjob because the pod of the replica type
+// failed after its restart limit was reached.
+func (tc *TFController) failPastRestartLimit(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus,
+	rtype commonv1.ReplicaType, pod *v1.Pod) error {
+
+	if isFailed(*jobStatus) {
+		return nil
+	}
+	msg := fmt.Sprintf("TFJob %s/%s has failed because %s pod %s failed after %s reached its restart limit of %d.",
+		tfJob.Namespace, tfJob.Name, rtype, pod.Name, rtype, *getReplicaPolicy(tfJob, rtype).RestartLimit)
+	commonutil.LoggerForJob(tfJob).Info(msg)
+	tc.Recorder.Event(tfJob, v1.EventTypeWarning, TFJobFailedReasonRestartLimit, msg)
+
+	if jobStatus.CompletionTime == nil {
+		now := metav1.Now()
+		jobStatus.CompletionTime = &now
+	}
+	if err := commonutil.UpdateJobConditions(jobStatus, commonv1.JobFailed, TFJobFailedReasonRestartLimit, msg); err != nil {
+		commonutil.LoggerForJob(tfJob).Infof("Append tfjob condition error: %v", err)
+		return err
+	}
+	tfJobsFailureCount.WithLabelValues(tfJob.Namespace).Inc()
+	return nil
+}
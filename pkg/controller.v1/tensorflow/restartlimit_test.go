@@ -0,0 +1,153 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestRestartLimit(t *testing.T) {
+	testCases := []struct {
+		description string
+		// failedType is the replica type whose pod fails with a retryable code.
+		failedType          string
+		expectedFailed      bool
+		expectedRecreations string
+	}{
+		{
+			description:         "A PS failing past its restart limit fails the tfjob",
+			failedType:          testutil.LabelPS,
+			expectedFailed:      true,
+			expectedRecreations: `{"ps":2,"worker":3}`,
+		},
+		{
+			description:         "A worker within its restart limit is recreated",
+			failedType:          testutil.LabelWorker,
+			expectedRecreations: `{"ps":2,"worker":4}`,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		// The PS used up its 2 recreations, the workers only 3 of their 10.
+		tfJob := testutil.NewTFJob(1, 1)
+		psLimit, workerLimit := int32(2), int32(10)
+		tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+			tfv1.TFReplicaTypePS:     {RestartLimit: &psLimit},
+			tfv1.TFReplicaTypeWorker: {RestartLimit: &workerLimit},
+		}
+		for _, spec := range tfJob.Spec.TFReplicaSpecs {
+			spec.RestartPolicy = commonv1.RestartPolicyExitCode
+		}
+		tfJob.Annotations = map[string]string{tfv1.ReplicaRecreationsAnnotation: `{"ps":2,"worker":3}`}
+		fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+		ctr.tfJobClientSet = fakeClientSet
+
+		var failedPod *v1.Pod
+		for _, rt := range []string{testutil.LabelWorker, testutil.LabelPS} {
+			pod := testutil.NewPod(tfJob, rt, 0)
+			pod.Status.Phase = v1.PodRunning
+			if rt == c.failedType {
+				pod.Status.Phase = v1.PodFailed
+				pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+					Name:  tfv1.DefaultContainerName,
+					State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 137}},
+				}}
+				failedPod = pod
+			}
+			if err := podIndexer.Add(pod); err != nil {
+				t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+			}
+		}
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 1, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+		if err != nil {
+			t.Fatalf("%s: failed to get the tfjob: %v", c.description, err)
+		}
+		condition := tfv1.GetCondition(updated.Status, commonv1.JobFailed)
+		if failed := condition != nil && condition.Status == v1.ConditionTrue; failed != c.expectedFailed {
+			t.Errorf("%s: Expected failed %v, got %v", c.description, c.expectedFailed, updated.Status.Conditions)
+		}
+		if c.expectedFailed {
+			if condition.Reason != TFJobFailedReasonRestartLimit || !strings.Contains(condition.Message, string(tfv1.TFReplicaTypePS)) {
+				t.Errorf("%s: Expected the tfjob to fail citing PS with reason %s, got %s: %s",
+					c.description, TFJobFailedReasonRestartLimit, condition.Reason, condition.Message)
+			}
+			if len(fakePodControl.DeletePodName) != 0 {
+				t.Errorf("%s: Expected no pod to be recreated, got %v deleted", c.description, fakePodControl.DeletePodName)
+			}
+		} else if len(fakePodControl.DeletePodName) != 1 || fakePodControl.DeletePodName[0] != failedPod.Name {
+			t.Errorf("%s: Expected pod %s to be recreated, got %v deleted", c.description, failedPod.Name, fakePodControl.DeletePodName)
+		}
+		if recreations := updated.Annotations[tfv1.ReplicaRecreationsAnnotation]; recreations != c.expectedRecreations {
+			t.Errorf("%s: Expected the recreations %s, got %s", c.description, c.expectedRecreations, recreations)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"encoding/json"
+	"hash/fnv"
+	"sort"
+	"strconv"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+const (
+	// templateHashLabel is the pod label holding the hash of the replica
+	// template the pod was created from.
+	templateHashLabel = "tf-operator.kubeflow.org/template-hash"
+	// recreatedForTemplateChangeReason is the normal reason when a pod is
+	// recreated because the template of its replica type changed.
+	recreatedForTemplateChangeReason = "RecreatedForTemplateChange"
+	// defaultRollingUpdateMaxUnavailable is how many replicas of a type may be
+	// unavailable during a rolling update, if the ServerOption does not set it.
+	defaultRollingUpdateMaxUnavailable = 1
+)
+
+// genTemplateHash returns a hash of the replica template, as set in the tfjob.
+func genTemplateHash(template *v1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return strconv.FormatUint(uint64(hasher.Sum32()), 16), nil
+}
+
+// setTemplateHashLabel sets the template hash label of the pod, if the
+// operator runs rolling updates.
+func (tc *TFController) setTemplateHashLabel(labels map[string]string, spec *commonv1.ReplicaSpec) error {
+	if !tc.option.RollingUpdate {
+		return nil
+	}
+	hash, err := genTemplateHash(&spec.Template)
+	if err != nil {
+		return err
+	}
+	labels[templateHashLabel] = hash
+	return nil
+}
+
+// rollingUpdateMaxUnavailable returns how many replicas of a type may be
+// unavailable during a rolling update.
+func (tc *TFController) rollingUpdateMaxUnavailable() int {
+	if tc.option.RollingUpdateMaxUnavailable > 0 {
+		return tc.option.RollingUpdateMaxUnavailable
+	}
+	return defaultRollingUpdateMaxUnavailable
+}
+
+// rollingUpdatePods deletes the running pods of the replica type created from
+// an older template, so that they are recreated from the current one by the
+// next reconcile. The replicas without a running pod, e.g. pending or being
+// deleted, count as unavailable, and no more pods are deleted than the max
+// unavailable allows. Pods created before the rolling updates were enabled
+// have no hash, and are left alone.
+func (tc *TFController) rollingUpdatePods(tfJob *tfv1.TFJob, pods []*v1.Pod, spec *commonv1.ReplicaSpec) error {
+	if !tc.option.RollingUpdate || spec.Replicas == nil {
+		return nil
+	}
+	hash, err := genTemplateHash(&spec.Template)
+	if err != nil {
+		return err
+	}
+
+	available := 0
+	var stale []*v1.Pod
+	for _, pod := range pods {
+		if pod.DeletionTimestamp != nil {
+			continue
+		}
+		switch pod.Status.Phase {
+		case v1.PodRunning:
+			available++
+			if podHash, ok := pod.Labels[templateHashLabel]; ok && podHash != hash {
+				stale = append(stale, pod)
+			}
+		case v1.PodSucceeded:
+			available++
+		}
+	}
+	budget := tc.rollingUpdateMaxUnavailable() - (int(*spec.Replicas) - available)
+	if budget <= 0 || len(stale) == 0 {
+		return nil
+	}
+
+	// The pods are recreated in the order of their names.
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Name < stale[j].Name })
+	if len(stale) > budget {
+		stale = stale[:budget]
+	}
+	for _, pod := range stale {
+		commonutil.LoggerForJob(tfJob).Infof("Recreating pod %s/%s for the changed template", pod.Namespace, pod.Name)
+		if err := tc.deletePod(tfJob, pod, recreatedForTemplateChangeReason, "template changed, to be recreated"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
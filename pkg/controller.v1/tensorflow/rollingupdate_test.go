@@ -0,0 +1,170 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestRollingUpdate(t *testing.T) {
+	testCases := []struct {
+		description    string
+		maxUnavailable int
+		// pendingWorkers are the indices of the workers whose pods are pending.
+		pendingWorkers []int
+
+		expectedDeleted []string
+	}{
+		{
+			description:     "the workers are recreated one at a time by default",
+			expectedDeleted: []string{"worker-0"},
+		},
+		{
+			description:     "two workers may be unavailable",
+			maxUnavailable:  2,
+			expectedDeleted: []string{"worker-0", "worker-1"},
+		},
+		{
+			description:    "no worker is recreated while one is pending",
+			pendingWorkers: []int{2},
+		},
+	}
+
+	for _, tc := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0,
+			options.ServerOption{RollingUpdate: true, RollingUpdateMaxUnavailable: tc.maxUnavailable})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+		// The workers were created from the template with the old image.
+		tfJob := testutil.NewTFJob(3, 0)
+		ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+		workerTemplate := &tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Template
+		oldHash, err := genTemplateHash(workerTemplate)
+		if err != nil {
+			t.Fatalf("%s: failed to hash the template: %v", tc.description, err)
+		}
+		for i := 0; i < 3; i++ {
+			pod := testutil.NewPod(tfJob, testutil.LabelWorker, i)
+			pod.Status.Phase = v1.PodRunning
+			for _, pending := range tc.pendingWorkers {
+				if i == pending {
+					pod.Status.Phase = v1.PodPending
+				}
+			}
+			pod.Labels[templateHashLabel] = oldHash
+			if err := podIndexer.Add(pod); err != nil {
+				t.Errorf("%s: unexpected error when adding pod %v", tc.description, err)
+			}
+		}
+		testutil.SetServices(kubeInformerFactory.Core().V1().Services().Informer().GetIndexer(), tfJob, testutil.LabelWorker, 3, t)
+
+		// The worker image is changed.
+		workerTemplate.Spec.Containers[0].Image = "tensorflow/tensorflow:new"
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		if !reflect.DeepEqual(fakePodControl.DeletePodName, tc.expectedDeleted) {
+			t.Errorf("%s: expected the pods %v to be deleted, got %v", tc.description, tc.expectedDeleted, fakePodControl.DeletePodName)
+		}
+	}
+}
+
+func TestTemplateHashLabel(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{RollingUpdate: true})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+
+	tfJob := testutil.NewTFJob(1, 0)
+	spec := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker]
+	if err := ctr.createNewPod(context.TODO(), tfJob, "worker", "0", spec, false, tfJob.Spec.TFReplicaSpecs); err != nil {
+		t.Fatalf("Expected get nil, got error %v", err)
+	}
+	expected, err := genTemplateHash(&spec.Template)
+	if err != nil {
+		t.Fatalf("Failed to hash the template: %v", err)
+	}
+	if got := fakePodControl.Templates[0].Labels[templateHashLabel]; got != expected {
+		t.Errorf("Expected the template hash %q, got %q", expected, got)
+	}
+}
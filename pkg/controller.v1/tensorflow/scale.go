@@ -0,0 +1,113 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// lastReplicaIndex returns the highest index of the pod slices which has a
+// pod, or -1 if none has.
+func lastReplicaIndex(podSlices [][]*v1.Pod) int {
+	last := -1
+	for index, podSlice := range podSlices {
+		if len(podSlice) > 0 {
+			last = index
+		}
+	}
+	return last
+}
+
+// isScaling returns true if reconciling the pod slices of a running replica
+// type adds replicas after the last existing one, or removes replicas out of
+// range.
+func isScaling(podSlices [][]*v1.Pod, numReplicas int) bool {
+	last := lastReplicaIndex(podSlices)
+	return last >= 0 && (last >= numReplicas || last < numReplicas-1)
+}
+
+// keepsPodsPastReplicas returns true if the pods and services of the tfjob past
+// its replicas are kept, because it does not enable dynamic workers and the
+// static scale down is strict.
+func (tc *TFController) keepsPodsPastReplicas(tfJob *tfv1.TFJob) bool {
+	return !tfJob.Spec.EnableDynamicWorker && tc.option.StaticScaleDown == options.StaticScaleDownStrict
+}
+
+// scaleCooldownRemaining returns how long the tfjob still has to wait before
+// scaling its replicas, or 0 if it does not have to.
+func (tc *TFController) scaleCooldownRemaining(tfJob *tfv1.TFJob) time.Duration {
+	if !tfJob.Spec.EnableDynamicWorker || tc.option.ScaleCooldown <= 0 {
+		return 0
+	}
+	lastScaleTime, err := time.Parse(time.RFC3339, tfJob.Annotations[tfv1.LastScaleTimeAnnotation])
+	if err != nil {
+		return 0
+	}
+	if remaining := time.Until(lastScaleTime.Add(tc.option.ScaleCooldown)); remaining > 0 {
+		return remaining
+	}
+	return 0
+}
+
+// recordScaleTime sets the LastScaleTime annotation of the tfjob to now, so
+// that the following scaling waits for the cooldown.
+func (tc *TFController) recordScaleTime(tfJob *tfv1.TFJob) error {
+	if !tfJob.Spec.EnableDynamicWorker || tc.option.ScaleCooldown <= 0 {
+		return nil
+	}
+	return tc.patchTFJobAnnotation(tfJob, tfv1.LastScaleTimeAnnotation, metav1.Now().UTC().Format(time.RFC3339))
+}
+
+// patchTFJobAnnotation sets the annotation of the tfjob to the value through
+// a merge patch, which does not conflict with concurrent updates of its spec.
+func (tc *TFController) patchTFJobAnnotation(tfJob *tfv1.TFJob, key, value string) error {
+	return tc.patchTFJobAnnotations(tfJob, map[string]string{key: value})
+}
+
+// patchTFJobAnnotations sets the annotations of the tfjob to the values in a
+// single merge patch.
+func (tc *TFController) patchTFJobAnnotations(tfJob *tfv1.TFJob, annotations map[string]string) error {
+	patch, err := json.Marshal(map[string]interface{}{
+		"metadata": map[string]interface{}{
+			"annotations": annotations,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	patched, err := tc.tfJobClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Patch(
+		context.TODO(), tfJob.Name, types.MergePatchType, patch, metav1.PatchOptions{})
+	if err != nil {
+		return err
+	}
+	// Keep the tfjob being reconciled in line with the patched one, so that
+	// the update of its status does not conflict.
+	if tfJob.Annotations == nil {
+		tfJob.Annotations = map[string]string{}
+	}
+	for key, value := range annotations {
+		tfJob.Annotations[key] = value
+	}
+	tfJob.ResourceVersion = patched.ResourceVersion
+	return nil
+}
@@ -0,0 +1,67 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// tfJobPodScheduleLatency reports how long the pods of tfjobs wait to be
+// scheduled after they are created, by replica type.
+var tfJobPodScheduleLatency = promauto.NewHistogramVec(
+	prometheus.HistogramOpts{
+		Name:    "tfjob_pod_schedule_latency_seconds",
+		Help:    "The time between the creation of the pods of TF jobs and their scheduling",
+		Buckets: prometheus.ExponentialBuckets(0.5, 2, 14),
+	},
+	[]string{"replica_type"},
+)
+
+// getPodScheduledTime returns when the pod was scheduled, or nil if it is not.
+func getPodScheduledTime(pod *v1.Pod) *metav1.Time {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == v1.PodScheduled && condition.Status == v1.ConditionTrue {
+			return &condition.LastTransitionTime
+		}
+	}
+	return nil
+}
+
+// observeScheduleLatency observes the schedule latency of a pod of a tfjob
+// when an update shows it scheduled for the first time. The pods seen already
+// scheduled when they are added, e.g. when the controller restarts, are not
+// observed, so that they are not counted twice.
+func (tc *TFController) observeScheduleLatency(oldPod, curPod *v1.Pod) {
+	if getPodScheduledTime(oldPod) != nil {
+		return
+	}
+	scheduledAt := getPodScheduledTime(curPod)
+	if scheduledAt == nil {
+		return
+	}
+	controllerRef := metav1.GetControllerOf(curPod)
+	if controllerRef == nil || controllerRef.Kind != tfv1.Kind {
+		return
+	}
+	latency := scheduledAt.Sub(curPod.CreationTimestamp.Time)
+	if latency < 0 {
+		latency = 0
+	}
+	tfJobPodScheduleLatency.WithLabelValues(curPod.Labels[tc.GetReplicaTypeLabelKey()]).Observe(latency.Seconds())
+}
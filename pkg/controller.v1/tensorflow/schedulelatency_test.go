@@ -0,0 +1,101 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestScheduleLatency(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+
+	// The pod was created at 10:00:00, and scheduled at 10:00:12.
+	created := time.Date(2021, 6, 1, 10, 0, 0, 0, time.UTC)
+	tfJob := testutil.NewTFJob(1, 1)
+	oldPod := testutil.NewPod(tfJob, testutil.LabelPS, 0)
+	oldPod.ResourceVersion = "1"
+	oldPod.CreationTimestamp = metav1.NewTime(created)
+	oldPod.Status.Conditions = []v1.PodCondition{{Type: v1.PodScheduled, Status: v1.ConditionFalse}}
+	curPod := oldPod.DeepCopy()
+	curPod.ResourceVersion = "2"
+	curPod.Status.Conditions = []v1.PodCondition{{
+		Type:               v1.PodScheduled,
+		Status:             v1.ConditionTrue,
+		LastTransitionTime: metav1.NewTime(created.Add(12 * time.Second)),
+	}}
+
+	histogram := tfJobPodScheduleLatency.WithLabelValues(testutil.LabelPS).(prometheus.Histogram)
+	before := &dto.Metric{}
+	if err := histogram.Write(before); err != nil {
+		t.Fatalf("Failed to read the histogram: %v", err)
+	}
+
+	ctr.updatePod(oldPod, curPod)
+	// Later updates of the scheduled pod are not observed again.
+	updatedPod := curPod.DeepCopy()
+	updatedPod.ResourceVersion = "3"
+	ctr.updatePod(curPod, updatedPod)
+
+	after := &dto.Metric{}
+	if err := histogram.Write(after); err != nil {
+		t.Fatalf("Failed to read the histogram: %v", err)
+	}
+	if count := after.Histogram.GetSampleCount() - before.Histogram.GetSampleCount(); count != 1 {
+		t.Errorf("Expected 1 observation, got %d", count)
+	}
+	if sum := after.Histogram.GetSampleSum() - before.Histogram.GetSampleSum(); sum != 12 {
+		t.Errorf("Expected a latency of 12 seconds, got %v", sum)
+	}
+}
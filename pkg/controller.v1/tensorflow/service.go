@@ -0,0 +1,158 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/tools/cache"
+)
+
+// ReconcileServices checks and updates services for each given TFReplicaSpec.
+// It will requeue the tfjob in case of an error while creating/deleting services.
+func (tc *TFController) ReconcileServices(
+	job metav1.Object,
+	services []*v1.Service,
+	rtype commonv1.ReplicaType,
+	spec *commonv1.ReplicaSpec) error {
+	tfJob, ok := job.(*tfv1.TFJob)
+	if !ok {
+		return fmt.Errorf("%v is not a type of TFJob", job)
+	}
+	// Within a reconcile pass, the services are reconciled before the pods
+	// of their replica type, by ReconcilePods.
+	if tc.getReconcilePass(tfJob) != nil {
+		return nil
+	}
+	return tc.reconcileServices(job, services, rtype, spec, nil)
+}
+
+// reconcileServices is ReconcileServices which creates at most as many
+// services as the given budget allows. A nil budget does not limit the creations.
+// It is adapted from JobController.ReconcileServices in kubeflow/common.
+func (tc *TFController) reconcileServices(
+	job metav1.Object,
+	services []*v1.Service,
+	rtype commonv1.ReplicaType,
+	spec *commonv1.ReplicaSpec,
+	budget *createBudget) error {
+
+	tfJob, ok := job.(*tfv1.TFJob)
+	if !ok {
+		return fmt.Errorf("%v is not a type of TFJob", job)
+	}
+
+	// Convert ReplicaType to lower string.
+	rt := strings.ToLower(string(rtype))
+	logger := commonutil.LoggerForReplica(tfJob, rt)
+
+	replicas := int(*spec.Replicas)
+	// Get all services for the type rt.
+	services, err := tc.FilterServicesForReplicaType(services, rt)
+	if err != nil {
+		return err
+	}
+
+	// GetServiceSlices will return enough information here to make decision to add/remove/update resources.
+	//
+	// For example, let's assume we have services with replica-index 0, 1, 2
+	// If replica is 4, return a slice with size 4. [[0],[1],[2],[]], a svc with replica-index 3 will be created.
+	//
+	// If replica is 1, return a slice with size 3. [[0],[1],[2]], svc with replica-index 1 and 2 are out of range and will be deleted.
+	serviceSlices := tc.GetServiceSlices(services, replicas, logger)
+	// Like their pods, the services of a tfjob with dynamic workers are not
+	// scaled while it cools down from its last scaling.
+	deferScaling := tc.scaleCooldownRemaining(tfJob) > 0
+	lastIndex := -1
+	for index, serviceSlice := range serviceSlices {
+		if len(serviceSlice) > 0 {
+			lastIndex = index
+		}
+	}
+
+	for index, serviceSlice := range serviceSlices {
+		if len(serviceSlice) > 1 {
+			logger.Warningf("We have too many services for %s %d", rt, index)
+		} else if len(serviceSlice) == 0 {
+			if deferScaling && lastIndex >= 0 && index > lastIndex {
+				continue
+			}
+			if !budget.take() {
+				logger.Infof("Create batch size reached, deferring service %s-%d", rt, index)
+				continue
+			}
+			logger.Infof("need to create new service: %s-%d", rt, index)
+			err = tc.CreateNewService(tfJob, rtype, spec, strconv.Itoa(index))
+			if err != nil {
+				return err
+			}
+		} else {
+			// Check the status of the current svc.
+			svc := serviceSlice[0]
+
+			// check if the index is in the valid range, if not, we should kill the svc
+			// The services of the pods kept past the replicas are kept too.
+			if index >= replicas && tc.keepsPodsPastReplicas(tfJob) {
+				continue
+			}
+			if (index < 0 || index >= replicas) && !deferScaling {
+				err = tc.ServiceControl.DeleteService(svc.Namespace, svc.Name, tfJob)
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// deleteService enqueues the tfjob which owns the deleted service, so that the
+// service is recreated if the pod it resolves is still alive.
+// obj could be an *v1.Service, or a DeletionFinalStateUnknown marker item.
+func (tc *TFController) deleteService(obj interface{}) {
+	service, ok := obj.(*v1.Service)
+	if !ok {
+		tombstone, ok := obj.(cache.DeletedFinalStateUnknown)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("couldn't get object from tombstone %+v", obj))
+			return
+		}
+		service, ok = tombstone.Obj.(*v1.Service)
+		if !ok {
+			utilruntime.HandleError(fmt.Errorf("tombstone contained object that is not a service %+v", obj))
+			return
+		}
+	}
+
+	controllerRef := metav1.GetControllerOf(service)
+	if controllerRef == nil || controllerRef.Kind != tfv1.Kind {
+		// No controller should care about orphans being deleted.
+		return
+	}
+	tfJob, err := tc.getTFJobFromName(service.Namespace, controllerRef.Name)
+	if err != nil || tfJob.UID != controllerRef.UID {
+		return
+	}
+	commonutil.LoggerForJob(tfJob).Infof("Service %s/%s is deleted", service.Namespace, service.Name)
+	tc.enqueueTFJob(tfJob)
+}
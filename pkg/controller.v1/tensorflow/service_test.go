@@ -0,0 +1,158 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestRecreateMissingService(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakePodControl := &control.FakePodControl{}
+	ctr.PodControl = fakePodControl
+	fakeServiceControl := &control.FakeServiceControl{}
+	ctr.ServiceControl = fakeServiceControl
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(2, 0)
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := tfJobIndexer.Add(unstructured); err != nil {
+		t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 2, 0, 0, nil, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+
+	// Delete the service of worker 1 while its pod is still running.
+	deleted := testutil.NewService(tfJob, testutil.LabelWorker, 1, t)
+	if err := serviceIndexer.Delete(deleted); err != nil {
+		t.Errorf("Failed to delete service from serviceIndexer: %v", err)
+	}
+	ctr.deleteService(deleted)
+	if got := ctr.WorkQueue.Len(); got != 1 {
+		t.Errorf("Expected the tfjob to be enqueued after the service is deleted, got queue length %d", got)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if len(fakeServiceControl.Templates) != 1 {
+		t.Fatalf("Expected 1 service to be created, got %d", len(fakeServiceControl.Templates))
+	}
+	if index := fakeServiceControl.Templates[0].Labels[tfReplicaIndexLabel]; index != "1" {
+		t.Errorf("Expected the service of worker 1 to be recreated, got worker %s", index)
+	}
+	if len(fakePodControl.Templates) != 0 {
+		t.Errorf("Expected no pod to be created, got %d", len(fakePodControl.Templates))
+	}
+}
+
+func TestPublishNotReadyAddresses(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakeServiceControl := &control.FakeServiceControl{}
+	ctr.ServiceControl = newPublishNotReadyServiceControl(fakeServiceControl)
+
+	tfJob := testutil.NewTFJob(1, 1)
+	for _, rtype := range []commonv1.ReplicaType{tfv1.TFReplicaTypePS, tfv1.TFReplicaTypeWorker} {
+		if err := ctr.reconcileServices(tfJob, nil, rtype, tfJob.Spec.TFReplicaSpecs[rtype], nil); err != nil {
+			t.Fatalf("Expected get nil, got error %v", err)
+		}
+	}
+
+	if len(fakeServiceControl.Templates) != 2 {
+		t.Fatalf("Expected 2 services to be created, got %d", len(fakeServiceControl.Templates))
+	}
+	for _, service := range fakeServiceControl.Templates {
+		if !service.Spec.PublishNotReadyAddresses {
+			t.Errorf("Expected service %s to publish not ready addresses", service.Name)
+		}
+		if service.Spec.ClusterIP != v1.ClusterIPNone {
+			t.Errorf("Expected service %s to be headless, got cluster IP %q", service.Name, service.Spec.ClusterIP)
+		}
+	}
+}
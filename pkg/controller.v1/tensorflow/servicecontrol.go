@@ -0,0 +1,147 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"strconv"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// publishNotReadyServiceControl creates the headless services of the replicas
+// with publishNotReadyAddresses, so that their DNS names resolve as soon as
+// their pods have an IP. Replicas connect to their peers to establish the
+// cluster before they are ready, if they ever have a readiness probe.
+type publishNotReadyServiceControl struct {
+	control.ServiceControlInterface
+}
+
+// newPublishNotReadyServiceControl returns the given service control creating
+// services which publish not ready addresses.
+func newPublishNotReadyServiceControl(c control.ServiceControlInterface) *publishNotReadyServiceControl {
+	return &publishNotReadyServiceControl{ServiceControlInterface: c}
+}
+
+func (c *publishNotReadyServiceControl) CreateServices(namespace string, service *v1.Service, object runtime.Object) error {
+	service = service.DeepCopy()
+	service.Spec.PublishNotReadyAddresses = true
+	return c.ServiceControlInterface.CreateServices(namespace, service, object)
+}
+
+func (c *publishNotReadyServiceControl) CreateServicesWithControllerRef(namespace string, service *v1.Service,
+	object runtime.Object, controllerRef *metav1.OwnerReference) error {
+	service = service.DeepCopy()
+	service.Spec.PublishNotReadyAddresses = true
+	return c.ServiceControlInterface.CreateServicesWithControllerRef(namespace, service, object, controllerRef)
+}
+
+// replicaPortServiceControl creates the headless services of the replicas of
+// tfjobs with a port range with the port assigned to their replica, instead of
+// the port of their replica type.
+type replicaPortServiceControl struct {
+	control.ServiceControlInterface
+}
+
+// newReplicaPortServiceControl returns the given service control creating
+// services with the ports of their replicas.
+func newReplicaPortServiceControl(c control.ServiceControlInterface) *replicaPortServiceControl {
+	return &replicaPortServiceControl{ServiceControlInterface: c}
+}
+
+func (c *replicaPortServiceControl) CreateServices(namespace string, service *v1.Service, object runtime.Object) error {
+	service, err := setServiceReplicaPort(service, object)
+	if err != nil {
+		return err
+	}
+	return c.ServiceControlInterface.CreateServices(namespace, service, object)
+}
+
+func (c *replicaPortServiceControl) CreateServicesWithControllerRef(namespace string, service *v1.Service,
+	object runtime.Object, controllerRef *metav1.OwnerReference) error {
+	service, err := setServiceReplicaPort(service, object)
+	if err != nil {
+		return err
+	}
+	return c.ServiceControlInterface.CreateServicesWithControllerRef(namespace, service, object, controllerRef)
+}
+
+// setServiceReplicaPort returns a copy of the service of a replica of a tfjob
+// with a port range, with the tfjob port set to the port of the replica.
+func setServiceReplicaPort(service *v1.Service, object runtime.Object) (*v1.Service, error) {
+	tfJob, ok := object.(*tfv1.TFJob)
+	if !ok || tfJob.Spec.PortRange == nil {
+		return service, nil
+	}
+	index, err := strconv.Atoi(service.Labels[tfReplicaIndexLabel])
+	if err != nil {
+		return nil, fmt.Errorf("invalid replica index of service %s: %v", service.Name, err)
+	}
+	port, err := getReplicaPort(tfJob, commonv1.ReplicaType(service.Labels[tfReplicaTypeLabel]), int32(index))
+	if err != nil {
+		return nil, err
+	}
+
+	service = service.DeepCopy()
+	for i := range service.Spec.Ports {
+		if service.Spec.Ports[i].Name == tfv1.DefaultPortName {
+			service.Spec.Ports[i].Port = port
+			return service, nil
+		}
+	}
+	service.Spec.Ports = append(service.Spec.Ports, v1.ServicePort{Name: tfv1.DefaultPortName, Port: port})
+	return service, nil
+}
+
+// additionalOwnersServiceControl adds the AdditionalOwnerReferences of tfjobs
+// to the services it creates for them, along their controller reference.
+type additionalOwnersServiceControl struct {
+	control.ServiceControlInterface
+}
+
+// newAdditionalOwnersServiceControl returns the given service control adding
+// the additional owners of tfjobs to their services.
+func newAdditionalOwnersServiceControl(c control.ServiceControlInterface) *additionalOwnersServiceControl {
+	return &additionalOwnersServiceControl{ServiceControlInterface: c}
+}
+
+func (c *additionalOwnersServiceControl) CreateServices(namespace string, service *v1.Service, object runtime.Object) error {
+	return c.ServiceControlInterface.CreateServices(namespace, setServiceAdditionalOwners(service, object), object)
+}
+
+func (c *additionalOwnersServiceControl) CreateServicesWithControllerRef(namespace string, service *v1.Service,
+	object runtime.Object, controllerRef *metav1.OwnerReference) error {
+	return c.ServiceControlInterface.CreateServicesWithControllerRef(namespace,
+		setServiceAdditionalOwners(service, object), object, controllerRef)
+}
+
+// setServiceAdditionalOwners returns a copy of the service of a tfjob with the
+// additional owners of the tfjob.
+func setServiceAdditionalOwners(service *v1.Service, object runtime.Object) *v1.Service {
+	tfJob, ok := object.(*tfv1.TFJob)
+	if !ok || len(tfJob.Spec.AdditionalOwnerReferences) == 0 {
+		return service
+	}
+	service = service.DeepCopy()
+	for _, ref := range tfJob.Spec.AdditionalOwnerReferences {
+		service.OwnerReferences = append(service.OwnerReferences, *ref.DeepCopy())
+	}
+	return service
+}
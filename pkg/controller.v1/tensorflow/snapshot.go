@@ -0,0 +1,112 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/expectation"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// JobStateSnapshot is what the controller believes about a tfjob, as computed
+// from its informers, so that e2e tests can assert on it.
+type JobStateSnapshot struct {
+	// Pods and Services are the numbers of pods and services controlled by
+	// the tfjob, by replica type.
+	Pods     map[commonv1.ReplicaType]int
+	Services map[commonv1.ReplicaType]int
+	// Conditions are the conditions of the tfjob in the cache.
+	Conditions []commonv1.JobCondition
+	// PodExpectations and ServiceExpectations are the outstanding creations
+	// and deletions the controller waits to observe, by replica type. The
+	// replica types without expectations are left out.
+	PodExpectations     map[commonv1.ReplicaType]ExpectationsSnapshot
+	ServiceExpectations map[commonv1.ReplicaType]ExpectationsSnapshot
+}
+
+// ExpectationsSnapshot is the number of creations and deletions the
+// controller still expects to observe.
+type ExpectationsSnapshot struct {
+	Add int64
+	Del int64
+}
+
+// SnapshotJobState returns what the controller believes about the tfjob. The
+// tfjob is looked up in the cache, the given one only names it.
+func (tc *TFController) SnapshotJobState(tfJob *tfv1.TFJob) (JobStateSnapshot, error) {
+	cached, err := tc.getTFJobFromName(tfJob.Namespace, tfJob.Name)
+	if err != nil {
+		return JobStateSnapshot{}, err
+	}
+	jobKey, err := KeyFunc(cached)
+	if err != nil {
+		return JobStateSnapshot{}, err
+	}
+	selector := labels.SelectorFromSet(tc.GenLabels(cached.Name))
+	pods, err := tc.PodLister.Pods(cached.Namespace).List(selector)
+	if err != nil {
+		return JobStateSnapshot{}, err
+	}
+	services, err := tc.ServiceLister.Services(cached.Namespace).List(selector)
+	if err != nil {
+		return JobStateSnapshot{}, err
+	}
+
+	snapshot := JobStateSnapshot{
+		Pods:                make(map[commonv1.ReplicaType]int),
+		Services:            make(map[commonv1.ReplicaType]int),
+		Conditions:          cached.Status.Conditions,
+		PodExpectations:     make(map[commonv1.ReplicaType]ExpectationsSnapshot),
+		ServiceExpectations: make(map[commonv1.ReplicaType]ExpectationsSnapshot),
+	}
+	for rtype := range cached.Spec.TFReplicaSpecs {
+		rt := strings.ToLower(string(rtype))
+		for _, pod := range pods {
+			if metav1.IsControlledBy(pod, cached) && pod.Labels[tc.GetReplicaTypeLabelKey()] == rt {
+				snapshot.Pods[rtype]++
+			}
+		}
+		for _, service := range services {
+			if metav1.IsControlledBy(service, cached) && service.Labels[tc.GetReplicaTypeLabelKey()] == rt {
+				snapshot.Services[rtype]++
+			}
+		}
+		if exp, ok := tc.snapshotExpectations(expectation.GenExpectationPodsKey(jobKey, rt)); ok {
+			snapshot.PodExpectations[rtype] = exp
+		}
+		if exp, ok := tc.snapshotExpectations(expectation.GenExpectationServicesKey(jobKey, rt)); ok {
+			snapshot.ServiceExpectations[rtype] = exp
+		}
+	}
+	return snapshot, nil
+}
+
+// snapshotExpectations returns the outstanding expectations of the key, and
+// false if it has none.
+func (tc *TFController) snapshotExpectations(key string) (ExpectationsSnapshot, bool) {
+	exp, exists, err := tc.Expectations.GetExpectations(key)
+	if err != nil || !exists {
+		return ExpectationsSnapshot{}, false
+	}
+	add, del := exp.GetExpectations()
+	if add <= 0 && del <= 0 {
+		return ExpectationsSnapshot{}, false
+	}
+	return ExpectationsSnapshot{Add: add, Del: del}, true
+}
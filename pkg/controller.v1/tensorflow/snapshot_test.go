@@ -0,0 +1,120 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"reflect"
+	"testing"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/expectation"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	v1 "k8s.io/api/core/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestSnapshotJobState(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+	serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(3, 1)
+	if err := commonutil.UpdateJobConditions(&tfJob.Status, commonv1.JobRunning,
+		tfJobRunningReason, "TFJob is running."); err != nil {
+		t.Fatalf("Failed to set the running condition: %v", err)
+	}
+	unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+	if err != nil {
+		t.Fatalf("Failed to convert the TFJob to Unstructured: %v", err)
+	}
+	if err := ctr.tfJobInformer.GetIndexer().Add(unstructured); err != nil {
+		t.Fatalf("Failed to add tfjob to tfJobIndexer: %v", err)
+	}
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 2, 0, 0, nil, t)
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelPS, 0, 1, 0, 0, nil, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 3, t)
+	testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+
+	// The third worker pod is still expected.
+	jobKey, err := KeyFunc(tfJob)
+	if err != nil {
+		t.Fatalf("Failed to get the key of the tfjob: %v", err)
+	}
+	if err := ctr.Expectations.ExpectCreations(expectation.GenExpectationPodsKey(jobKey, "worker"), 1); err != nil {
+		t.Fatalf("Failed to set the expectations: %v", err)
+	}
+
+	snapshot, err := ctr.SnapshotJobState(tfJob)
+	if err != nil {
+		t.Fatalf("Failed to snapshot the tfjob: %v", err)
+	}
+
+	expected := JobStateSnapshot{
+		Pods: map[commonv1.ReplicaType]int{
+			tfv1.TFReplicaTypeWorker: 2,
+			tfv1.TFReplicaTypePS:     1,
+		},
+		Services: map[commonv1.ReplicaType]int{
+			tfv1.TFReplicaTypeWorker: 3,
+			tfv1.TFReplicaTypePS:     1,
+		},
+		PodExpectations: map[commonv1.ReplicaType]ExpectationsSnapshot{
+			tfv1.TFReplicaTypeWorker: {Add: 1},
+		},
+		ServiceExpectations: map[commonv1.ReplicaType]ExpectationsSnapshot{},
+	}
+	conditions := snapshot.Conditions
+	snapshot.Conditions = nil
+	if !reflect.DeepEqual(snapshot, expected) {
+		t.Errorf("Expected snapshot %+v, got %+v", expected, snapshot)
+	}
+	if len(conditions) != 1 || conditions[0].Type != commonv1.JobRunning {
+		t.Errorf("Expected the running condition, got %v", conditions)
+	}
+}
@@ -0,0 +1,188 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	"github.com/kubeflow/common/pkg/controller.v1/common"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// standbyNamePrefix is prepended to the index in the names of standby pods.
+const standbyNamePrefix = "standby-"
+
+// getStandbyReplicas returns the number of standby pods to keep for the given replica type.
+func getStandbyReplicas(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType) int {
+	policy := getReplicaPolicy(tfJob, rtype)
+	if policy == nil || policy.StandbyReplicas == nil {
+		return 0
+	}
+	return int(*policy.StandbyReplicas)
+}
+
+// splitStandbyPods splits the given pods into replica pods and standby pods.
+func splitStandbyPods(pods []*v1.Pod) ([]*v1.Pod, []*v1.Pod) {
+	var replicaPods, standbyPods []*v1.Pod
+	for _, pod := range pods {
+		if _, ok := pod.Labels[tfStandbyLabel]; ok {
+			standbyPods = append(standbyPods, pod)
+		} else {
+			replicaPods = append(replicaPods, pod)
+		}
+	}
+	return replicaPods, standbyPods
+}
+
+// isStandbyPodAvailable returns true if the standby pod can be promoted.
+func isStandbyPodAvailable(pod *v1.Pod) bool {
+	if pod.DeletionTimestamp != nil {
+		return false
+	}
+	return pod.Status.Phase == v1.PodPending || pod.Status.Phase == v1.PodRunning
+}
+
+// getAvailableStandbyPod returns a standby pod which can be promoted, preferring
+// running pods, or nil if there is none.
+func getAvailableStandbyPod(standbyPods []*v1.Pod) *v1.Pod {
+	var available *v1.Pod
+	for _, pod := range standbyPods {
+		if !isStandbyPodAvailable(pod) {
+			continue
+		}
+		if pod.Status.Phase == v1.PodRunning {
+			return pod
+		}
+		if available == nil {
+			available = pod
+		}
+	}
+	return available
+}
+
+// removePod returns the given pods without the given pod.
+func removePod(pods []*v1.Pod, pod *v1.Pod) []*v1.Pod {
+	remaining := make([]*v1.Pod, 0, len(pods))
+	for _, p := range pods {
+		if p != pod {
+			remaining = append(remaining, p)
+		}
+	}
+	return remaining
+}
+
+// promoteStandbyPod turns the standby pod into the replica of the given index.
+// The pod is relabeled with the replica index, so that the service of the
+// replica selects it, and TF_CONFIG is written to its TF_CONFIG file.
+func (tc *TFController) promoteStandbyPod(tfJob *tfv1.TFJob, pod *v1.Pod, rt, index string, masterRole bool) error {
+	podLabels := map[string]interface{}{
+		tfStandbyLabel:      nil,
+		tfReplicaIndexLabel: index,
+	}
+	podLabels[tc.GetReplicaIndexLabelKey()] = index
+	if masterRole {
+		podLabels[commonv1.JobRoleLabel] = "master"
+	}
+	metadata := map[string]interface{}{
+		"labels": podLabels,
+	}
+	if isDistributed(tfJob) {
+		tfConfigStr, err := tc.genTFConfig(tfJob, rt, index)
+		if err != nil {
+			return err
+		}
+		metadata["annotations"] = map[string]interface{}{
+			tfConfigAnnotation: tfConfigStr,
+		}
+	}
+	patch, err := json.Marshal(map[string]interface{}{"metadata": metadata})
+	if err != nil {
+		return err
+	}
+	if err := tc.PodControl.PatchPod(pod.Namespace, pod.Name, patch); err != nil {
+		return err
+	}
+
+	commonutil.LoggerForReplica(tfJob, rt).Infof("Promoted standby pod %s/%s to %s-%s", pod.Namespace, pod.Name, rt, index)
+	tc.Recorder.Eventf(tfJob, v1.EventTypeNormal, promotedStandbyReason,
+		"Promoted standby pod %s to %s-%s", pod.Name, rt, index)
+	return nil
+}
+
+// reconcileStandbyPods keeps the number of standby pods of the given type at
+// StandbyReplicas. Finished standby pods are deleted. The given pods are all
+// the pods of the type, and are used to pick unused names for the new standby pods.
+func (tc *TFController) reconcileStandbyPods(
+	ctx context.Context,
+	tfJob *tfv1.TFJob,
+	pods []*v1.Pod,
+	standbyPods []*v1.Pod,
+	rtype commonv1.ReplicaType,
+	spec *commonv1.ReplicaSpec,
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec,
+	budget *createBudget) error {
+
+	rt := strings.ToLower(string(rtype))
+	logger := traceLogger(ctx, commonutil.LoggerForReplica(tfJob, rt))
+
+	var available []*v1.Pod
+	for _, pod := range standbyPods {
+		if isStandbyPodAvailable(pod) {
+			available = append(available, pod)
+		} else if pod.DeletionTimestamp == nil {
+			if err := tc.deletePod(tfJob, pod, deletedPodReason, "standby pod not available"); err != nil {
+				return err
+			}
+		}
+	}
+	sort.Slice(available, func(i, j int) bool { return available[i].Name < available[j].Name })
+
+	desired := getStandbyReplicas(tfJob, rtype)
+	for i := desired; i < len(available); i++ {
+		if err := tc.deletePod(tfJob, available[i], deletedPodReason, fmt.Sprintf("more than %d standby pods", desired)); err != nil {
+			return err
+		}
+	}
+
+	// Promoted pods keep their names, so skip the names which are taken.
+	names := make(map[string]bool, len(pods))
+	for _, pod := range pods {
+		names[pod.Name] = true
+	}
+	for i, missing := 0, desired-len(available); missing > 0; i++ {
+		index := strconv.Itoa(i)
+		if names[common.GenGeneralName(tfJob.Name, rt, standbyNamePrefix+index)] {
+			continue
+		}
+		if !budget.take() {
+			logger.Infof("Create batch size reached, deferring standby pod %s-%s%s", rt, standbyNamePrefix, index)
+			return nil
+		}
+		logger.Infof("Need to create new standby pod: %s-%s%s", rt, standbyNamePrefix, index)
+		if err := tc.createNewStandbyPod(ctx, tfJob, rt, index, spec, replicas); err != nil {
+			return err
+		}
+		missing--
+	}
+	return nil
+}
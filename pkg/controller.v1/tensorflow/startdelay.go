@@ -0,0 +1,54 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"strings"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// workerStartDelayRemaining returns whether the workers of the tfjob have to
+// wait before being created, and how long, after the pods of all its PS were
+// created. The remaining time is 0 while some PS pods do not exist yet, as
+// their creation syncs the tfjob again.
+func (tc *TFController) workerStartDelayRemaining(tfJob *tfv1.TFJob, pods []*v1.Pod,
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) (bool, time.Duration, error) {
+	psSpec := replicas[tfv1.TFReplicaTypePS]
+	if tc.option.WorkerStartDelay <= 0 || psSpec == nil || psSpec.Replicas == nil || *psSpec.Replicas == 0 {
+		return false, 0, nil
+	}
+	psPods, err := tc.FilterPodsForReplicaType(pods, strings.ToLower(string(tfv1.TFReplicaTypePS)))
+	if err != nil {
+		return false, 0, err
+	}
+	psPods, _ = splitStandbyPods(psPods)
+	if len(psPods) < int(*psSpec.Replicas) {
+		return true, 0, nil
+	}
+	var lastCreated time.Time
+	for _, pod := range psPods {
+		if pod.CreationTimestamp.After(lastCreated) {
+			lastCreated = pod.CreationTimestamp.Time
+		}
+	}
+	if remaining := time.Until(lastCreated.Add(tc.option.WorkerStartDelay)); remaining > 0 {
+		return true, remaining, nil
+	}
+	return false, 0, nil
+}
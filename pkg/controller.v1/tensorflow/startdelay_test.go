@@ -0,0 +1,125 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"testing"
+	"time"
+
+	"github.com/kubeflow/common/pkg/controller.v1/control"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kubeclientset "k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
+	batchv1beta1 "volcano.sh/apis/pkg/apis/scheduling/v1beta1"
+	volcanoclient "volcano.sh/apis/pkg/client/clientset/versioned"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestWorkerStartDelay(t *testing.T) {
+	testCases := []struct {
+		description string
+		// psCreatedAgo is how long ago the PS pod was created, or nil if it
+		// does not exist.
+		psCreatedAgo *time.Duration
+
+		expectedPS      int
+		expectedWorkers int
+	}{
+		{
+			description: "the workers wait for the PS to be created",
+			expectedPS:  1,
+		},
+		{
+			description:  "the workers wait for the delay after the PS creation",
+			psCreatedAgo: durationPtr(time.Second),
+		},
+		{
+			description:     "the workers are created after the delay",
+			psCreatedAgo:    durationPtr(time.Minute),
+			expectedWorkers: 2,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet, volcanoClientSet, tfJobClientSet, 0,
+			options.ServerOption{WorkerStartDelay: 10 * time.Second})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+		serviceIndexer := kubeInformerFactory.Core().V1().Services().Informer().GetIndexer()
+
+		tfJob := testutil.NewTFJob(2, 1)
+		if c.psCreatedAgo != nil {
+			pod := testutil.NewPod(tfJob, testutil.LabelPS, 0)
+			pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-*c.psCreatedAgo))
+			pod.Status.Phase = v1.PodRunning
+			if err := podIndexer.Add(pod); err != nil {
+				t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+			}
+		}
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelWorker, 2, t)
+		testutil.SetServices(serviceIndexer, tfJob, testutil.LabelPS, 1, t)
+		ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		created := map[string]int{}
+		for _, template := range fakePodControl.Templates {
+			created[template.Labels[tfReplicaTypeLabel]]++
+		}
+		if created[testutil.LabelPS] != c.expectedPS || created[testutil.LabelWorker] != c.expectedWorkers {
+			t.Errorf("%s: expected %d PS and %d workers to be created, got %v",
+				c.description, c.expectedPS, c.expectedWorkers, created)
+		}
+	}
+}
+
+func durationPtr(d time.Duration) *time.Duration {
+	return &d
+}
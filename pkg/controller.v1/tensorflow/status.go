@@ -17,20 +17,67 @@ package tensorflow
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
 	commonutil "github.com/kubeflow/common/pkg/util"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
 )
 
+// Reasons of the Failed condition of a tfjob. Every path which fails a tfjob
+// sets one of them, so that tools can tell the failures apart.
+const (
+	// TFJobFailedReasonPodFailure is added in a tfjob when it is failed
+	// because its replicas failed.
+	TFJobFailedReasonPodFailure = "TFJobFailed"
+	// TFJobFailedReasonOOMKilled is added in a tfjob when it is failed because
+	// a container of a replica was killed for running out of memory. It is also
+	// the reason of the event emitted when a replica is OOM killed.
+	TFJobFailedReasonOOMKilled = "WorkerOOMKilled"
+	// TFJobFailedReasonBackoff is added in a tfjob when it is failed because
+	// it has reached its backoff limit.
+	TFJobFailedReasonBackoff = "BackoffLimitExceeded"
+	// TFJobFailedReasonDeadline is added in a tfjob when it is failed because
+	// it was active longer than its ActiveDeadlineSeconds.
+	TFJobFailedReasonDeadline = "DeadlineExceeded"
+	// TFJobFailedReasonReplicaDeadline is added in a tfjob when it is failed
+	// because a replica type was active longer than its ActiveDeadlineSeconds.
+	// It is also the reason of the event emitted when the replicas are stopped.
+	TFJobFailedReasonReplicaDeadline = "ReplicaDeadlineExceeded"
+	// TFJobFailedReasonInvalidSpec is added in a tfjob when it is failed
+	// because its spec is invalid, e.g. it has no replica specs.
+	TFJobFailedReasonInvalidSpec = "InvalidTFJobSpec"
+	// TFJobFailedReasonPSFailure is added in a tfjob when it is failed because
+	// a PS failed under the FailJob PS failure policy.
+	TFJobFailedReasonPSFailure = "PSFailed"
+	// TFJobFailedReasonImagePull is added in a tfjob when it is failed because
+	// its pods failed to pull their images for longer than the timeout.
+	TFJobFailedReasonImagePull = "ImagePullFailed"
+	// TFJobFailedReasonChiefFailure is added in a tfjob when it is failed
+	// because its chief failed under the FailJob chief failure policy.
+	TFJobFailedReasonChiefFailure = "ChiefFailed"
+	// TFJobFailedReasonPodReadyDeadline is added in a tfjob when it is failed
+	// because a pod was not ready past the PodReadyDeadlineSeconds of its
+	// replica type.
+	TFJobFailedReasonPodReadyDeadline = "PodReadyDeadlineExceeded"
+	// TFJobFailedReasonRestartLimit is added in a tfjob when it is failed
+	// because a pod failed after its replica type reached its RestartLimit.
+	TFJobFailedReasonRestartLimit = "RestartLimitExceeded"
+)
+
 const (
 	// tfJobCreatedReason is added in a tfjob when it is created.
 	tfJobCreatedReason = "TFJobCreated"
@@ -38,10 +85,12 @@ const (
 	tfJobSucceededReason = "TFJobSucceeded"
 	// tfJobRunningReason is added in a tfjob when it is running.
 	tfJobRunningReason = "TFJobRunning"
-	// tfJobFailedReason is added in a tfjob when it is failed.
-	tfJobFailedReason = "TFJobFailed"
 	// tfJobRestarting is added in a tfjob when it is restarting.
 	tfJobRestartingReason = "TFJobRestarting"
+
+	// maxTerminationMessageLength is the maximum length of the termination
+	// message of a failed container surfaced in the Failed condition.
+	maxTerminationMessageLength = 512
 )
 
 var (
@@ -75,6 +124,18 @@ func (tc *TFController) UpdateJobStatus(job interface{}, replicas map[commonv1.R
 
 	logger := commonutil.LoggerForJob(tfJob)
 
+	// Within a reconcile pass, the pods of the tfjob are reconciled as a
+	// whole once those of each replica type are, and the status is kept for
+	// the steps around JobController.ReconcileJobs.
+	if pass := tc.getReconcilePass(tfJob); pass != nil {
+		if err := tc.reconcileJobPods(tfJob, jobStatus, replicas, pass); err != nil {
+			return err
+		}
+		defer func() {
+			pass.jobStatus = *jobStatus.DeepCopy()
+		}()
+	}
+
 	worker0Completed, err := tc.IsWorker0Completed(tfJob, replicas)
 	if err != nil {
 		logger.Warnf("check if worker 0 completed error %v", err)
@@ -91,15 +152,34 @@ func (tc *TFController) UpdateJobStatus(job interface{}, replicas map[commonv1.R
 			tc.WorkQueue.AddAfter(tfJobKey, time.Duration(*tfJob.Spec.RunPolicy.ActiveDeadlineSeconds)*time.Second)
 		}
 	}
-	// iterate the replica spec based on this order
-	allTypes := []commonv1.ReplicaType{
-		tfv1.TFReplicaTypeChief,
-		tfv1.TFReplicaTypeEval,
-		tfv1.TFReplicaTypeMaster,
-		tfv1.TFReplicaTypePS,
-		tfv1.TFReplicaTypeWorker,
+	// A replica type may have failed the tfjob when its deadline passed.
+	if isFailed(*jobStatus) {
+		tfJob.Status = *jobStatus.DeepCopy()
+		return nil
+	}
+	// The running condition is only set once the replicas required by the
+	// running gate are active.
+	runningGatePassed := tc.passesRunningGate(replicas, jobStatus)
+	// The failed replicas of all the types count against the failure
+	// aggregation threshold together.
+	var totalFailed int32
+	for rtype := range replicas {
+		if status := jobStatus.ReplicaStatuses[rtype]; status != nil {
+			totalFailed += status.Failed
+		}
+	}
+	if totalFailed == 0 {
+		clearFailuresAggregating(jobStatus)
 	}
-	for _, rtype := range allTypes {
+	// Evaluate the replica types in order, until one of them settles the tfjob.
+	evaluationOrder := tc.evaluationOrder
+	if len(evaluationOrder) == 0 {
+		evaluationOrder = defaultEvaluationOrder
+	}
+	for _, rtype := range evaluationOrder {
+		if isSucceeded(*jobStatus) || isFailed(*jobStatus) {
+			break
+		}
 		if replicas[rtype] == nil {
 			continue
 		}
@@ -119,7 +199,7 @@ func (tc *TFController) UpdateJobStatus(job interface{}, replicas map[commonv1.R
 		// according to the Chief/Master spec.
 		if ContainChieforMasterSpec(tfJob.Spec.TFReplicaSpecs) {
 			if tfv1.IsChieforMaster(rtype) {
-				if running > 0 {
+				if running > 0 && runningGatePassed {
 					msg := fmt.Sprintf("TFJob %s/%s is running.",
 						tfJob.Namespace, tfJob.Name)
 					err := commonutil.UpdateJobConditions(jobStatus,
@@ -167,7 +247,7 @@ func (tc *TFController) UpdateJobStatus(job interface{}, replicas map[commonv1.R
 						return err
 					}
 					tfJobsSuccessCount.WithLabelValues(tfJob.Namespace).Inc()
-				} else if running > 0 {
+				} else if running > 0 && runningGatePassed {
 					// Some workers are still running, leave a running condition.
 					msg := fmt.Sprintf("TFJob %s/%s is running.",
 						tfJob.Namespace, tfJob.Name)
@@ -192,16 +272,30 @@ func (tc *TFController) UpdateJobStatus(job interface{}, replicas map[commonv1.R
 				// job is restarting, no need to set it failed
 				// we know it because we update the status condition when reconciling the replicas
 				tfJobsFailureCount.WithLabelValues(tfJob.Namespace).Inc()
+			} else if tc.holdFailures(tfJob, tfJobKey, jobStatus, totalFailed) {
+				logger.Infof("TFJob %s/%s holds its %d failed replica(s) within the failure aggregation window",
+					tfJob.Namespace, tfJob.Name, totalFailed)
 			} else {
 				msg := fmt.Sprintf("TFJob %s/%s has failed because %d %s replica(s) failed.",
 					tfJob.Namespace, tfJob.Name, failed, rtype)
-				tc.Recorder.Event(tfJob, corev1.EventTypeNormal, tfJobFailedReason, msg)
+				// Failures caused by the OOM killer are reported with a dedicated
+				// reason, so that tools can react to them, e.g. by adding memory.
+				reason := TFJobFailedReasonPodFailure
+				terminationMsg, oomKilledPod := tc.getTerminationMessage(tfJob, rtype)
+				if terminationMsg != "" {
+					msg += " " + terminationMsg
+				}
+				if oomKilledPod != nil {
+					reason = TFJobFailedReasonOOMKilled
+					tc.recordOOMKilled(tfJob, oomKilledPod)
+				}
+				tc.Recorder.Event(tfJob, corev1.EventTypeNormal, reason, msg)
 				if jobStatus.CompletionTime == nil {
 					now := metav1.Now()
 					jobStatus.CompletionTime = &now
 				}
 				err := commonutil.UpdateJobConditions(jobStatus,
-					commonv1.JobFailed, tfJobFailedReason, msg)
+					commonv1.JobFailed, reason, msg)
 				if err != nil {
 					commonutil.LoggerForJob(tfJob).Infof("Append tfjob condition error: %v", err)
 					return err
@@ -219,6 +313,74 @@ func (tc *TFController) UpdateJobStatus(job interface{}, replicas map[commonv1.R
 	return nil
 }
 
+// passesRunningGate returns true if the replica types of the tfjob have at
+// least the number of active replicas the running gate requires.
+func (tc *TFController) passesRunningGate(replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec, jobStatus *commonv1.JobStatus) bool {
+	for rtype, spec := range replicas {
+		if spec == nil || spec.Replicas == nil {
+			continue
+		}
+		for t, min := range tc.option.RunningGate {
+			if !strings.EqualFold(string(t), string(rtype)) {
+				continue
+			}
+			var active, succeeded int32
+			if status := jobStatus.ReplicaStatuses[rtype]; status != nil {
+				active, succeeded = status.Active, status.Succeeded
+			}
+			// Succeeded replicas are not active anymore, and not required.
+			required := *spec.Replicas - succeeded
+			if min != options.AllReplicas && min < required {
+				required = min
+			}
+			if active < required {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// defaultEvaluationOrder is the order the replica types of a tfjob are
+// evaluated in by UpdateJobStatus, unless the ServerOption sets one.
+var defaultEvaluationOrder = []commonv1.ReplicaType{
+	tfv1.TFReplicaTypeChief,
+	tfv1.TFReplicaTypeEval,
+	tfv1.TFReplicaTypeMaster,
+	tfv1.TFReplicaTypePS,
+	tfv1.TFReplicaTypeWorker,
+}
+
+// getEvaluationOrder returns the given replica types, matched case
+// insensitively, followed by the other ones in the default order.
+func getEvaluationOrder(order []commonv1.ReplicaType) ([]commonv1.ReplicaType, error) {
+	evaluationOrder := make([]commonv1.ReplicaType, 0, len(defaultEvaluationOrder))
+	seen := map[commonv1.ReplicaType]bool{}
+	for _, rtype := range order {
+		known := false
+		for _, t := range defaultEvaluationOrder {
+			if strings.EqualFold(string(rtype), string(t)) {
+				rtype, known = t, true
+				break
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown replica type %q", rtype)
+		}
+		if seen[rtype] {
+			return nil, fmt.Errorf("duplicate replica type %q", rtype)
+		}
+		seen[rtype] = true
+		evaluationOrder = append(evaluationOrder, rtype)
+	}
+	for _, rtype := range defaultEvaluationOrder {
+		if !seen[rtype] {
+			evaluationOrder = append(evaluationOrder, rtype)
+		}
+	}
+	return evaluationOrder, nil
+}
+
 // UpdateJobStatusInApiServer updates the status of the given TFJob.
 func (tc *TFController) UpdateJobStatusInApiServer(job interface{}, jobStatus *commonv1.JobStatus) error {
 	tfJob, ok := job.(*tfv1.TFJob)
@@ -235,9 +397,181 @@ func (tc *TFController) UpdateJobStatusInApiServer(job interface{}, jobStatus *c
 
 	tfJob = tfJob.DeepCopy()
 	tfJob.Status = *jobStatus.DeepCopy()
+	// The status is only written when the reconciliation changed it, so that
+	// the last reconcile time does not make the tfjob reconcile over and over.
+	now := metav1.Now()
+	tfJob.Status.LastReconcileTime = &now
 
 	_, err := tc.tfJobClientSet.KubeflowV1().TFJobs(tfJob.Namespace).UpdateStatus(context.TODO(), tfJob, metav1.UpdateOptions{})
-	return err
+	if err != nil {
+		return err
+	}
+	if pass := tc.getReconcilePass(tfJob); pass != nil {
+		pass.jobStatus = *jobStatus.DeepCopy()
+		pass.written = true
+	}
+	return nil
+}
+
+// genStatusAnnotations returns the annotations the tfjob reports its
+// progress, the nodes and restarts of its replicas and its observed
+// generation in, as its status is the JobStatus shared by the training
+// operators.
+func genStatusAnnotations(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus,
+	replicaNodes map[commonv1.ReplicaType][]string, replicaRestarts map[commonv1.ReplicaType]int32) (map[string]string, error) {
+	nodes := make(map[string][]string, len(replicaNodes))
+	for rtype, n := range replicaNodes {
+		nodes[strings.ToLower(string(rtype))] = n
+	}
+	nodesValue, err := json.Marshal(nodes)
+	if err != nil {
+		return nil, err
+	}
+	restarts := make(map[string]int32, len(replicaRestarts))
+	for rtype, r := range replicaRestarts {
+		restarts[strings.ToLower(string(rtype))] = r
+	}
+	restartsValue, err := json.Marshal(restarts)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]string{
+		tfv1.ProgressAnnotation:        strconv.Itoa(int(getProgress(tfJob, jobStatus))),
+		tfv1.ReplicaNodesAnnotation:    string(nodesValue),
+		tfv1.ReplicaRestartsAnnotation: string(restartsValue),
+		// The status is reconciled from the tfjob as it is now.
+		tfv1.ObservedGenerationAnnotation: strconv.FormatInt(tfJob.Generation, 10),
+	}, nil
+}
+
+// updateStatusAnnotations patches the given status annotations of the tfjob
+// whose value changed.
+func (tc *TFController) updateStatusAnnotations(tfJob *tfv1.TFJob, annotations map[string]string) error {
+	changed := map[string]string{}
+	for key, value := range annotations {
+		if current, ok := tfJob.Annotations[key]; !ok || current != value {
+			changed[key] = value
+		}
+	}
+	if len(changed) == 0 {
+		return nil
+	}
+	return tc.patchTFJobAnnotations(tfJob, changed)
+}
+
+// syncStatusAnnotations updates the status annotations of the tfjob from its
+// pods and its job status.
+func (tc *TFController) syncStatusAnnotations(tfJob *tfv1.TFJob, replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec,
+	pods []*v1.Pod, jobStatus *commonv1.JobStatus) error {
+	replicaNodes, err := tc.getReplicaNodes(replicas, pods)
+	if err != nil {
+		return err
+	}
+	replicaRestarts, err := tc.getReplicaRestarts(replicas, pods)
+	if err != nil {
+		return err
+	}
+	annotations, err := genStatusAnnotations(tfJob, jobStatus, replicaNodes, replicaRestarts)
+	if err != nil {
+		return err
+	}
+	return tc.updateStatusAnnotations(tfJob, annotations)
+}
+
+// getObservedGeneration returns the generation of the tfjob last reconciled,
+// or 0 if it was never reconciled.
+func getObservedGeneration(tfJob *tfv1.TFJob) int64 {
+	generation, err := strconv.ParseInt(tfJob.Annotations[tfv1.ObservedGenerationAnnotation], 10, 64)
+	if err != nil {
+		return 0
+	}
+	return generation
+}
+
+// getReplicaNodes returns the names of the nodes the pods of the replicas are
+// scheduled on, by replica type and index. Replicas without a scheduled pod
+// get an empty name.
+func (tc *TFController) getReplicaNodes(replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec, pods []*v1.Pod) (map[commonv1.ReplicaType][]string, error) {
+	replicaNodes := make(map[commonv1.ReplicaType][]string, len(replicas))
+	for rtype, spec := range replicas {
+		// Skip the specs the validation rejects instead of panicking on them.
+		if spec == nil || spec.Replicas == nil || *spec.Replicas < 0 {
+			continue
+		}
+		nodes := make([]string, int(*spec.Replicas))
+		replicaPods, err := tc.FilterPodsForReplicaType(pods, strings.ToLower(string(rtype)))
+		if err != nil {
+			return nil, err
+		}
+		for _, pod := range replicaPods {
+			if pod.DeletionTimestamp != nil {
+				continue
+			}
+			// Standby pods have no index.
+			index, err := strconv.Atoi(pod.Labels[tc.GetReplicaIndexLabelKey()])
+			if err != nil || index < 0 || index >= len(nodes) {
+				continue
+			}
+			if nodes[index] == "" {
+				nodes[index] = pod.Spec.NodeName
+			}
+		}
+		replicaNodes[rtype] = nodes
+	}
+	return replicaNodes, nil
+}
+
+// getReplicaRestarts returns the total number of container restarts of the
+// pods of each replica type. The restarts of init containers are counted too.
+func (tc *TFController) getReplicaRestarts(replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec, pods []*v1.Pod) (map[commonv1.ReplicaType]int32, error) {
+	replicaRestarts := make(map[commonv1.ReplicaType]int32, len(replicas))
+	for rtype := range replicas {
+		replicaPods, err := tc.FilterPodsForReplicaType(pods, strings.ToLower(string(rtype)))
+		if err != nil {
+			return nil, err
+		}
+		var restarts int32
+		for _, pod := range replicaPods {
+			for _, status := range pod.Status.InitContainerStatuses {
+				restarts += status.RestartCount
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				restarts += status.RestartCount
+			}
+		}
+		replicaRestarts[rtype] = restarts
+	}
+	return replicaRestarts, nil
+}
+
+// getProgress returns the percentage of the replicas which have succeeded.
+// PS and evaluators never succeed on their own, so they are not counted. With
+// the default success policy the TFJob may succeed before all the workers do,
+// so the progress only reaches 100 when the TFJob succeeds.
+func getProgress(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus) int32 {
+	if isSucceeded(*jobStatus) {
+		return 100
+	}
+
+	var succeeded, total int32
+	for rtype, spec := range tfJob.Spec.TFReplicaSpecs {
+		if rtype == tfv1.TFReplicaTypePS || rtype == tfv1.TFReplicaTypeEval || spec == nil || spec.Replicas == nil {
+			continue
+		}
+		total += *spec.Replicas
+		if status := jobStatus.ReplicaStatuses[rtype]; status != nil {
+			succeeded += status.Succeeded
+		}
+	}
+	progress := int32(0)
+	if total > 0 {
+		progress = succeeded * 100 / total
+	}
+	// Only a succeeded TFJob is done.
+	if progress > 99 {
+		progress = 99
+	}
+	return progress
 }
 
 // initializeReplicaStatuses initializes the ReplicaStatuses for replica.
@@ -249,9 +583,74 @@ func initializeReplicaStatuses(jobStatus *commonv1.JobStatus, rtype commonv1.Rep
 	jobStatus.ReplicaStatuses[rtype] = &commonv1.ReplicaStatus{}
 }
 
+// getTerminationMessage returns the reason and message of the terminated
+// tensorflow container of the first failed pod of the given replica type, or
+// an empty string if there is none. Long messages are truncated. It also
+// returns the pod if the container was OOM killed.
+func (tc *TFController) getTerminationMessage(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType) (string, *corev1.Pod) {
+	podLabels := tc.GenLabels(tfJob.Name)
+	podLabels[tc.GetReplicaTypeLabelKey()] = strings.ToLower(string(rtype))
+	pods, err := tc.PodLister.Pods(tfJob.Namespace).List(labels.SelectorFromSet(podLabels))
+	if err != nil {
+		commonutil.LoggerForJob(tfJob).Warnf("list pods error %v", err)
+		return "", nil
+	}
+	sort.Slice(pods, func(i, j int) bool { return pods[i].Name < pods[j].Name })
+
+	for _, pod := range pods {
+		if pod.Status.Phase != corev1.PodFailed && !isPermanentlyFailedInPlace(pod) {
+			continue
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			if status.Name != tfv1.DefaultContainerName {
+				continue
+			}
+			terminated := status.State.Terminated
+			if terminated == nil {
+				terminated = status.LastTerminationState.Terminated
+			}
+			if terminated == nil || (terminated.Reason == "" && terminated.Message == "") {
+				continue
+			}
+			// The kubelet reads the terminationMessagePath of the container into Message.
+			msg := fmt.Sprintf("Pod %s terminated with exit code %d", pod.Name, terminated.ExitCode)
+			if terminated.Reason != "" {
+				msg += fmt.Sprintf(", reason: %s", terminated.Reason)
+			}
+			if terminated.Message != "" {
+				msg += fmt.Sprintf(", message: %s", strings.TrimSpace(terminated.Message))
+			}
+			if len(msg) > maxTerminationMessageLength {
+				msg = msg[:maxTerminationMessageLength] + "..."
+			}
+			if terminated.Reason == oomKilledReason {
+				return msg, pod
+			}
+			return msg, nil
+		}
+	}
+	return "", nil
+}
+
+// isPodReady returns true if the pod has the Ready condition set to true.
+func isPodReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
+
 // updateJobReplicaStatuses updates the JobReplicaStatuses according to the pod.
 func updateJobReplicaStatuses(jobStatus *commonv1.JobStatus, rtype commonv1.ReplicaType, pod *corev1.Pod) {
-	switch pod.Status.Phase {
+	updateJobReplicaStatusesForPhase(jobStatus, rtype, pod.Status.Phase)
+}
+
+// updateJobReplicaStatusesForPhase counts a replica of the given type in the
+// given phase.
+func updateJobReplicaStatusesForPhase(jobStatus *commonv1.JobStatus, rtype commonv1.ReplicaType, phase corev1.PodPhase) {
+	switch phase {
 	case corev1.PodRunning:
 		jobStatus.ReplicaStatuses[rtype].Active++
 	case corev1.PodSucceeded:
@@ -262,18 +661,9 @@ func updateJobReplicaStatuses(jobStatus *commonv1.JobStatus, rtype commonv1.Repl
 }
 
 func isSucceeded(status commonv1.JobStatus) bool {
-	return hasCondition(status, commonv1.JobSucceeded)
+	return tfv1.IsConditionTrue(status, commonv1.JobSucceeded)
 }
 
 func isFailed(status commonv1.JobStatus) bool {
-	return hasCondition(status, commonv1.JobFailed)
-}
-
-func hasCondition(status commonv1.JobStatus, condType commonv1.JobConditionType) bool {
-	for _, condition := range status.Conditions {
-		if condition.Type == condType && condition.Status == v1.ConditionTrue {
-			return true
-		}
-	}
-	return false
+	return tfv1.IsConditionTrue(status, commonv1.JobFailed)
 }
@@ -16,11 +16,16 @@
 package tensorflow
 
 import (
+	"context"
 	"fmt"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	kubeclientset "k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 	"k8s.io/client-go/tools/cache"
@@ -31,9 +36,11 @@ import (
 
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
 	"github.com/kubeflow/common/pkg/controller.v1/control"
+	commonutil "github.com/kubeflow/common/pkg/util"
 	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
 	tfjobclientset "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned"
+	tfjobfake "github.com/kubeflow/tf-operator/pkg/client/clientset/versioned/fake"
 	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
 )
 
@@ -94,209 +101,739 @@ func TestFailed(t *testing.T) {
 	}
 }
 
-func TestStatus(t *testing.T) {
-	type testCase struct {
-		description string
-		tfJob       *tfv1.TFJob
+func TestEvaluationOrder(t *testing.T) {
+	type replicaCounts struct {
+		failed, succeeded, active int32
+	}
+	testCases := []struct {
+		description       string
+		order             options.ReplicaTypes
+		chief             replicaCounts
+		worker            replicaCounts
+		expectedSucceeded bool
+		expectedFailed    bool
+	}{
+		{
+			description:       "Chief succeeded while a worker failed",
+			chief:             replicaCounts{succeeded: 1},
+			worker:            replicaCounts{failed: 1, active: 1},
+			expectedSucceeded: true,
+		},
+		{
+			description: "Workers succeeded while the chief is running",
+			chief:       replicaCounts{active: 1},
+			worker:      replicaCounts{succeeded: 2},
+		},
+		{
+			description:    "Workers evaluated first",
+			order:          options.ReplicaTypes{"worker", "chief"},
+			chief:          replicaCounts{succeeded: 1},
+			worker:         replicaCounts{failed: 1, active: 1},
+			expectedFailed: true,
+		},
+	}
 
-		expectedFailedPS    int32
-		expectedSucceededPS int32
-		expectedActivePS    int32
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
 
-		expectedFailedWorker    int32
-		expectedSucceededWorker int32
-		expectedActiveWorker    int32
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
 
-		expectedFailedChief    int32
-		expectedSucceededChief int32
-		expectedActiveChief    int32
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ReplicaEvaluationOrder: c.order})
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
 
-		restart          bool
-		worker0Completed bool
+		tfJob := testutil.NewTFJobWithChief(2, 0)
+		initializeReplicaStatuses(&tfJob.Status, tfv1.TFReplicaTypeChief)
+		initializeReplicaStatuses(&tfJob.Status, tfv1.TFReplicaTypeWorker)
+		setStatusForTest(tfJob, tfv1.TFReplicaTypeChief, c.chief.failed, c.chief.succeeded, c.chief.active, false, false, podIndexer, t)
+		setStatusForTest(tfJob, tfv1.TFReplicaTypeWorker, c.worker.failed, c.worker.succeeded, c.worker.active, false, false, podIndexer, t)
 
-		expectedType commonv1.JobConditionType
+		if err := ctr.UpdateJobStatus(tfJob, tfJob.Spec.TFReplicaSpecs, &tfJob.Status); err != nil {
+			t.Errorf("%s: Expected error %v to be nil", c.description, err)
+		}
+		if got := isSucceeded(tfJob.Status); got != c.expectedSucceeded {
+			t.Errorf("%s: Expected succeeded %v, got %v", c.description, c.expectedSucceeded, got)
+		}
+		if got := isFailed(tfJob.Status); got != c.expectedFailed {
+			t.Errorf("%s: Expected failed %v, got %v", c.description, c.expectedFailed, got)
+		}
 	}
+}
 
-	testCases := []testCase{
-		testCase{
-			description:             "Chief worker is succeeded",
-			tfJob:                   testutil.NewTFJobWithChief(1, 0),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        0,
-			expectedFailedWorker:    0,
-			expectedSucceededWorker: 1,
-			expectedActiveWorker:    0,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  1,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobSucceeded,
+func TestChiefOrMasterGatesSuccess(t *testing.T) {
+	testCases := []struct {
+		description       string
+		rtype             commonv1.ReplicaType
+		chief             int32
+		workers           int32
+		expectedSucceeded bool
+	}{
+		{"Master succeeded while the workers are running", tfv1.TFReplicaTypeMaster, 1, 0, true},
+		{"Workers succeeded while the master is running", tfv1.TFReplicaTypeMaster, 0, 2, false},
+		{"Chief succeeded while the workers are running", tfv1.TFReplicaTypeChief, 1, 0, true},
+		{"Workers succeeded while the chief is running", tfv1.TFReplicaTypeChief, 0, 2, false},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
 		},
-		testCase{
-			description:             "Chief worker is running",
-			tfJob:                   testutil.NewTFJobWithChief(1, 0),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        0,
-			expectedFailedWorker:    0,
-			expectedSucceededWorker: 0,
-			expectedActiveWorker:    0,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     1,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobRunning,
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
 		},
-		testCase{
-			description:             "Chief worker is failed",
-			tfJob:                   testutil.NewTFJobWithChief(1, 0),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        0,
-			expectedFailedWorker:    0,
-			expectedSucceededWorker: 0,
-			expectedActiveWorker:    0,
-			expectedFailedChief:     1,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobFailed,
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+		var tfJob *tfv1.TFJob
+		if c.rtype == tfv1.TFReplicaTypeMaster {
+			tfJob = testutil.NewTFJobV2(2, 0, 1, 0, 0)
+		} else {
+			tfJob = testutil.NewTFJobV2(2, 0, 0, 1, 0)
+		}
+		initializeReplicaStatuses(&tfJob.Status, c.rtype)
+		initializeReplicaStatuses(&tfJob.Status, tfv1.TFReplicaTypeWorker)
+		setStatusForTest(tfJob, c.rtype, 0, c.chief, 1-c.chief, false, false, podIndexer, t)
+		setStatusForTest(tfJob, tfv1.TFReplicaTypeWorker, 0, c.workers, 2-c.workers, false, false, podIndexer, t)
+
+		if err := ctr.UpdateJobStatus(tfJob, tfJob.Spec.TFReplicaSpecs, &tfJob.Status); err != nil {
+			t.Errorf("%s: Expected error %v to be nil", c.description, err)
+		}
+		if got := isSucceeded(tfJob.Status); got != c.expectedSucceeded {
+			t.Errorf("%s: Expected succeeded %v, got %v", c.description, c.expectedSucceeded, got)
+		}
+	}
+}
+
+func TestRunningGate(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
 		},
-		testCase{
-			description:             "(No chief worker) Worker is failed",
-			tfJob:                   testutil.NewTFJob(1, 0),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        0,
-			expectedFailedWorker:    1,
-			expectedSucceededWorker: 0,
-			expectedActiveWorker:    0,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobFailed,
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
 		},
-		testCase{
-			description:             "(No chief worker) Worker is succeeded",
-			tfJob:                   testutil.NewTFJob(1, 0),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        0,
-			expectedFailedWorker:    0,
-			expectedSucceededWorker: 1,
-			expectedActiveWorker:    0,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobSucceeded,
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
 		},
-		testCase{
-			description:             "(No chief worker) Worker is running",
-			tfJob:                   testutil.NewTFJob(1, 0),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        0,
-			expectedFailedWorker:    0,
-			expectedSucceededWorker: 0,
-			expectedActiveWorker:    1,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobRunning,
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{
+			RunningGate: options.RunningGate{"ps": options.AllReplicas, "worker": 1},
+		})
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(2, 2)
+	initializeReplicaStatuses(&tfJob.Status, tfv1.TFReplicaTypeWorker)
+	initializeReplicaStatuses(&tfJob.Status, tfv1.TFReplicaTypePS)
+
+	// Only the workers are active, the tfjob is not running yet.
+	setStatusForTest(tfJob, tfv1.TFReplicaTypeWorker, 0, 0, 2, false, false, podIndexer, t)
+	if err := ctr.UpdateJobStatus(tfJob, tfJob.Spec.TFReplicaSpecs, &tfJob.Status); err != nil {
+		t.Errorf("Expected error %v to be nil", err)
+	}
+	if tfv1.IsConditionTrue(tfJob.Status, commonv1.JobRunning) {
+		t.Errorf("Expected the tfjob not to be running without active PS, got %v", tfJob.Status.Conditions)
+	}
+
+	// One PS out of two is not enough either.
+	setStatusForTest(tfJob, tfv1.TFReplicaTypePS, 0, 0, 1, false, false, podIndexer, t)
+	if err := ctr.UpdateJobStatus(tfJob, tfJob.Spec.TFReplicaSpecs, &tfJob.Status); err != nil {
+		t.Errorf("Expected error %v to be nil", err)
+	}
+	if tfv1.IsConditionTrue(tfJob.Status, commonv1.JobRunning) {
+		t.Errorf("Expected the tfjob not to be running with 1 active PS, got %v", tfJob.Status.Conditions)
+	}
+
+	// All PS are active.
+	setStatusForTest(tfJob, tfv1.TFReplicaTypePS, 0, 0, 1, false, false, podIndexer, t)
+	if err := ctr.UpdateJobStatus(tfJob, tfJob.Spec.TFReplicaSpecs, &tfJob.Status); err != nil {
+		t.Errorf("Expected error %v to be nil", err)
+	}
+	if !tfv1.IsConditionTrue(tfJob.Status, commonv1.JobRunning) {
+		t.Errorf("Expected the tfjob to be running, got %v", tfJob.Status.Conditions)
+	}
+}
+
+func TestLastReconcileTime(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
 		},
-		testCase{
-			description:             "(No chief worker) 2 workers are succeeded, 2 workers are active",
-			tfJob:                   testutil.NewTFJob(4, 2),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        2,
-			expectedFailedWorker:    0,
-			expectedSucceededWorker: 2,
-			expectedActiveWorker:    2,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobRunning,
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
 		},
-		testCase{
-			description:             "(No chief worker) 2 workers are running, 2 workers are failed",
-			tfJob:                   testutil.NewTFJob(4, 2),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        2,
-			expectedFailedWorker:    2,
-			expectedSucceededWorker: 0,
-			expectedActiveWorker:    2,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobFailed,
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
 		},
-		testCase{
-			description:             "(No chief worker) 2 workers are succeeded, 2 workers are failed",
-			tfJob:                   testutil.NewTFJob(4, 2),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        2,
-			expectedFailedWorker:    2,
-			expectedSucceededWorker: 2,
-			expectedActiveWorker:    0,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobFailed,
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.Recorder = &record.FakeRecorder{}
+
+	tfJob := testutil.NewTFJob(1, 0)
+	fakeClientSet := tfjobfake.NewSimpleClientset(tfJob)
+	ctr.tfJobClientSet = fakeClientSet
+
+	// The first reconcile sets the start time of the tfjob.
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+	updated, err := fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	lastReconcileTime := updated.Status.LastReconcileTime
+	if lastReconcileTime == nil {
+		t.Fatalf("Expected the last reconcile time to be set")
+	}
+
+	// Reconciling without changes does not update the status again.
+	_ = ctr.ReconcileJobs(updated, updated.Spec.TFReplicaSpecs, updated.Status, &updated.Spec.RunPolicy)
+	updated, err = fakeClientSet.KubeflowV1().TFJobs(tfJob.Namespace).Get(context.TODO(), tfJob.Name, metav1.GetOptions{})
+	if err != nil {
+		t.Fatalf("Failed to get the tfjob: %v", err)
+	}
+	if !updated.Status.LastReconcileTime.Equal(lastReconcileTime) {
+		t.Errorf("Expected the last reconcile time %v to be kept, got %v", lastReconcileTime, updated.Status.LastReconcileTime)
+	}
+}
+
+func TestActiveRequiresPodReady(t *testing.T) {
+	testCases := []struct {
+		description            string
+		activeRequiresPodReady bool
+		expectedActive         int32
+	}{
+		{
+			description:            "running pods are active regardless of readiness",
+			activeRequiresPodReady: false,
+			expectedActive:         2,
+		},
+		{
+			description:            "only ready pods are active",
+			activeRequiresPodReady: true,
+			expectedActive:         1,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{ActiveRequiresPodReady: c.activeRequiresPodReady})
+		ctr.Recorder = &record.FakeRecorder{}
+		ctr.tfJobInformerSynced = testutil.AlwaysReady
+		ctr.PodInformerSynced = testutil.AlwaysReady
+		ctr.ServiceInformerSynced = testutil.AlwaysReady
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+		tfJob := testutil.NewTFJob(2, 0)
+		readyPod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+		readyPod.Status.Phase = v1.PodRunning
+		readyPod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionTrue}}
+		notReadyPod := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+		notReadyPod.Status.Phase = v1.PodRunning
+		notReadyPod.Status.Conditions = []v1.PodCondition{{Type: v1.PodReady, Status: v1.ConditionFalse}}
+		for _, pod := range []*v1.Pod{readyPod, notReadyPod} {
+			if err := podIndexer.Add(pod); err != nil {
+				t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+			}
+		}
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		active := tfJob.Status.ReplicaStatuses[tfv1.TFReplicaTypeWorker].Active
+		if active != c.expectedActive {
+			t.Errorf("%s: expected %d active workers, got %d", c.description, c.expectedActive, active)
+		}
+	}
+}
+
+func TestReplicaNodes(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	// worker-0 and ps-0 are scheduled, worker-1 is not, and worker-2 has no pod.
+	tfJob := testutil.NewTFJob(3, 1)
+	scheduledWorker := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	scheduledWorker.Spec.NodeName = "node-a"
+	scheduledWorker.Status.Phase = v1.PodRunning
+	pendingWorker := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+	pendingWorker.Status.Phase = v1.PodPending
+	scheduledPS := testutil.NewPod(tfJob, testutil.LabelPS, 0)
+	scheduledPS.Spec.NodeName = "node-b"
+	scheduledPS.Status.Phase = v1.PodRunning
+	for _, pod := range []*v1.Pod{scheduledWorker, pendingWorker, scheduledPS} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("unexpected error when adding pod %v", err)
+		}
+	}
+
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	expected := `{"ps":["node-b"],"worker":["node-a","",""]}`
+	if nodes := tfJob.Annotations[tfv1.ReplicaNodesAnnotation]; nodes != expected {
+		t.Errorf("Expected replica nodes %s, got %s", expected, nodes)
+	}
+}
+
+func TestReplicaNodesInvalidReplicas(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, _, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+
+	// The specs without replicas or with negative replicas are skipped.
+	negative := int32(-1)
+	tfJob := testutil.NewTFJob(1, 1)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].Replicas = nil
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS].Replicas = &negative
+	pods := []*v1.Pod{
+		testutil.NewPod(tfJob, testutil.LabelWorker, 0),
+		testutil.NewPod(tfJob, testutil.LabelPS, 0),
+	}
+
+	replicaNodes, err := ctr.getReplicaNodes(tfJob.Spec.TFReplicaSpecs, pods)
+	if err != nil {
+		t.Fatalf("Failed to get the replica nodes: %v", err)
+	}
+	if len(replicaNodes) != 0 {
+		t.Errorf("Expected no replica nodes, got %v", replicaNodes)
+	}
+}
+
+func TestReplicaRestarts(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
 		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	// The workers restarted 2+1+3 times, including the init container of
+	// worker-1, and ps-0 never restarted.
+	tfJob := testutil.NewTFJob(2, 1)
+	worker0 := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	worker0.Status.Phase = v1.PodRunning
+	worker0.Status.ContainerStatuses = []v1.ContainerStatus{
+		{Name: tfv1.DefaultContainerName, RestartCount: 2},
+	}
+	worker1 := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+	worker1.Status.Phase = v1.PodRunning
+	worker1.Status.InitContainerStatuses = []v1.ContainerStatus{
+		{Name: "init", RestartCount: 1},
+	}
+	worker1.Status.ContainerStatuses = []v1.ContainerStatus{
+		{Name: tfv1.DefaultContainerName, RestartCount: 3},
+	}
+	ps0 := testutil.NewPod(tfJob, testutil.LabelPS, 0)
+	ps0.Status.Phase = v1.PodRunning
+	ps0.Status.ContainerStatuses = []v1.ContainerStatus{
+		{Name: tfv1.DefaultContainerName},
+	}
+	for _, pod := range []*v1.Pod{worker0, worker1, ps0} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("unexpected error when adding pod %v", err)
+		}
+	}
+
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	expected := `{"ps":0,"worker":6}`
+	if restarts := tfJob.Annotations[tfv1.ReplicaRestartsAnnotation]; restarts != expected {
+		t.Errorf("Expected replica restarts %s, got %s", expected, restarts)
+	}
+}
+
+func TestStatus(t *testing.T) {
+	type testCase struct {
+		description string
+		tfJob       *tfv1.TFJob
+
+		expectedFailedPS    int32
+		expectedSucceededPS int32
+		expectedActivePS    int32
+
+		expectedFailedWorker    int32
+		expectedSucceededWorker int32
+		expectedActiveWorker    int32
+
+		expectedFailedChief    int32
+		expectedSucceededChief int32
+		expectedActiveChief    int32
+
+		restart          bool
+		worker0Completed bool
+
+		expectedType commonv1.JobConditionType
+	}
+
+	testCases := []testCase{
 		testCase{
-			description:             "(No chief worker) worker-0 are succeeded, 3 workers are active",
-			tfJob:                   testutil.NewTFJob(4, 2),
+			description:             "Chief worker is succeeded",
+			tfJob:                   testutil.NewTFJobWithChief(1, 0),
 			expectedFailedPS:        0,
 			expectedSucceededPS:     0,
-			expectedActivePS:        2,
+			expectedActivePS:        0,
 			expectedFailedWorker:    0,
 			expectedSucceededWorker: 1,
-			expectedActiveWorker:    3,
+			expectedActiveWorker:    0,
 			expectedFailedChief:     0,
-			expectedSucceededChief:  0,
+			expectedSucceededChief:  1,
 			expectedActiveChief:     0,
 			restart:                 false,
-			worker0Completed:        true,
+			worker0Completed:        false,
 			expectedType:            commonv1.JobSucceeded,
 		},
 		testCase{
-			description:             "(No chief worker, successPolicy: AllWorkers) worker-0 are succeeded, 3 workers are active",
-			tfJob:                   testutil.NewTFJobWithSuccessPolicy(4, 0, tfv1.SuccessPolicyAllWorkers),
+			description:             "Chief worker is running",
+			tfJob:                   testutil.NewTFJobWithChief(1, 0),
 			expectedFailedPS:        0,
 			expectedSucceededPS:     0,
 			expectedActivePS:        0,
 			expectedFailedWorker:    0,
-			expectedSucceededWorker: 1,
-			expectedActiveWorker:    3,
+			expectedSucceededWorker: 0,
+			expectedActiveWorker:    0,
 			expectedFailedChief:     0,
 			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
+			expectedActiveChief:     1,
 			restart:                 false,
-			worker0Completed:        true,
+			worker0Completed:        false,
 			expectedType:            commonv1.JobRunning,
 		},
 		testCase{
-			description:             "(No chief worker, successPolicy: AllWorkers) 4 workers are succeeded",
-			tfJob:                   testutil.NewTFJobWithSuccessPolicy(4, 0, tfv1.SuccessPolicyAllWorkers),
+			description:             "Chief worker is failed",
+			tfJob:                   testutil.NewTFJobWithChief(1, 0),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        0,
+			expectedFailedWorker:    0,
+			expectedSucceededWorker: 0,
+			expectedActiveWorker:    0,
+			expectedFailedChief:     1,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobFailed,
+		},
+		testCase{
+			description:             "(No chief worker) Worker is failed",
+			tfJob:                   testutil.NewTFJob(1, 0),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        0,
+			expectedFailedWorker:    1,
+			expectedSucceededWorker: 0,
+			expectedActiveWorker:    0,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobFailed,
+		},
+		testCase{
+			description:             "(No chief worker) Worker is succeeded",
+			tfJob:                   testutil.NewTFJob(1, 0),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        0,
+			expectedFailedWorker:    0,
+			expectedSucceededWorker: 1,
+			expectedActiveWorker:    0,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobSucceeded,
+		},
+		testCase{
+			description:             "(No chief worker) Worker is running",
+			tfJob:                   testutil.NewTFJob(1, 0),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        0,
+			expectedFailedWorker:    0,
+			expectedSucceededWorker: 0,
+			expectedActiveWorker:    1,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobRunning,
+		},
+		testCase{
+			description:             "(No chief worker) 2 workers are succeeded, 2 workers are active",
+			tfJob:                   testutil.NewTFJob(4, 2),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        2,
+			expectedFailedWorker:    0,
+			expectedSucceededWorker: 2,
+			expectedActiveWorker:    2,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobRunning,
+		},
+		testCase{
+			description:             "(No chief worker) 2 workers are running, 2 workers are failed",
+			tfJob:                   testutil.NewTFJob(4, 2),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        2,
+			expectedFailedWorker:    2,
+			expectedSucceededWorker: 0,
+			expectedActiveWorker:    2,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobFailed,
+		},
+		testCase{
+			description:             "(No chief worker) 2 workers are succeeded, 2 workers are failed",
+			tfJob:                   testutil.NewTFJob(4, 2),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        2,
+			expectedFailedWorker:    2,
+			expectedSucceededWorker: 2,
+			expectedActiveWorker:    0,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobFailed,
+		},
+		testCase{
+			description:             "(No chief worker) worker-0 are succeeded, 3 workers are active",
+			tfJob:                   testutil.NewTFJob(4, 2),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        2,
+			expectedFailedWorker:    0,
+			expectedSucceededWorker: 1,
+			expectedActiveWorker:    3,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        true,
+			expectedType:            commonv1.JobSucceeded,
+		},
+		testCase{
+			description:             "(No chief worker, successPolicy: AllWorkers) worker-0 are succeeded, 3 workers are active",
+			tfJob:                   testutil.NewTFJobWithSuccessPolicy(4, 0, tfv1.SuccessPolicyAllWorkers),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        0,
+			expectedFailedWorker:    0,
+			expectedSucceededWorker: 1,
+			expectedActiveWorker:    3,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        true,
+			expectedType:            commonv1.JobRunning,
+		},
+		testCase{
+			description:             "(No chief worker, successPolicy: AllWorkers) 4 workers are succeeded",
+			tfJob:                   testutil.NewTFJobWithSuccessPolicy(4, 0, tfv1.SuccessPolicyAllWorkers),
 			expectedFailedPS:        0,
 			expectedSucceededPS:     0,
 			expectedActivePS:        0,
@@ -390,41 +927,607 @@ func TestStatus(t *testing.T) {
 			worker0Completed:        false,
 			expectedType:            commonv1.JobFailed,
 		},
-		testCase{
-			description:             "Chief is succeeded, workers are failed",
-			tfJob:                   testutil.NewTFJobWithChief(4, 2),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        2,
-			expectedFailedWorker:    4,
-			expectedSucceededWorker: 0,
-			expectedActiveWorker:    0,
-			expectedFailedChief:     0,
-			expectedSucceededChief:  1,
-			expectedActiveChief:     0,
-			restart:                 false,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobSucceeded,
+		testCase{
+			description:             "Chief is succeeded, workers are failed",
+			tfJob:                   testutil.NewTFJobWithChief(4, 2),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        2,
+			expectedFailedWorker:    4,
+			expectedSucceededWorker: 0,
+			expectedActiveWorker:    0,
+			expectedFailedChief:     0,
+			expectedSucceededChief:  1,
+			expectedActiveChief:     0,
+			restart:                 false,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobSucceeded,
+		},
+		testCase{
+			description:             "Chief is failed and restarting",
+			tfJob:                   testutil.NewTFJobWithChief(4, 2),
+			expectedFailedPS:        0,
+			expectedSucceededPS:     0,
+			expectedActivePS:        2,
+			expectedFailedWorker:    4,
+			expectedSucceededWorker: 0,
+			expectedActiveWorker:    0,
+			expectedFailedChief:     1,
+			expectedSucceededChief:  0,
+			expectedActiveChief:     0,
+			restart:                 true,
+			worker0Completed:        false,
+			expectedType:            commonv1.JobRestarting,
+		},
+	}
+
+	for i, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.Recorder = &record.FakeRecorder{}
+		ctr.tfJobInformerSynced = testutil.AlwaysReady
+		ctr.PodInformerSynced = testutil.AlwaysReady
+		ctr.ServiceInformerSynced = testutil.AlwaysReady
+		tfJobIndexer := ctr.tfJobInformer.GetIndexer()
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+		stopCh := make(chan struct{})
+		run := func(<-chan struct{}) {
+			if err := ctr.Run(testutil.ThreadCount, stopCh); err != nil {
+				t.Errorf("Failed to run the controller: %v", err)
+			}
+		}
+		go run(stopCh)
+
+		unstructured, err := testutil.ConvertTFJobToUnstructured(c.tfJob)
+		if err != nil {
+			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		}
+
+		if err := tfJobIndexer.Add(unstructured); err != nil {
+			t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+		}
+
+		initializeReplicaStatuses(&c.tfJob.Status, tfv1.TFReplicaTypeWorker)
+		initializeReplicaStatuses(&c.tfJob.Status, tfv1.TFReplicaTypeChief)
+		initializeReplicaStatuses(&c.tfJob.Status, tfv1.TFReplicaTypePS)
+
+		setStatusForTest(c.tfJob, tfv1.TFReplicaTypePS, c.expectedFailedPS, c.expectedSucceededPS, c.expectedActivePS, c.restart, c.worker0Completed, podIndexer, t)
+		setStatusForTest(c.tfJob, tfv1.TFReplicaTypeWorker, c.expectedFailedWorker, c.expectedSucceededWorker, c.expectedActiveWorker, c.restart, c.worker0Completed, podIndexer, t)
+		setStatusForTest(c.tfJob, tfv1.TFReplicaTypeChief, c.expectedFailedChief, c.expectedSucceededChief, c.expectedActiveChief, c.restart, c.worker0Completed, podIndexer, t)
+
+		// err = ctr.UpdateJobStatus(c.tfJob, c.tfJob.Spec.TFReplicaSpecs, &c.tfJob.Status)
+		// if err != nil {
+		// 	t.Errorf("%s: Expected error %v to be nil", c.description, err)
+		// }
+		_ = ctr.ReconcileJobs(c.tfJob, c.tfJob.Spec.TFReplicaSpecs, c.tfJob.Status, &c.tfJob.Spec.RunPolicy)
+
+		// Test filterOutCondition
+		filterOutConditionTest(c.tfJob.Status, t)
+
+		found := false
+		for _, condition := range c.tfJob.Status.Conditions {
+			if condition.Type == c.expectedType {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Case[%d]%s: Condition %s is not found", i, c.description, c.expectedType)
+		}
+	}
+}
+
+func setStatusForTest(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType, failed, succeeded, active int32, restart bool, worker0Completed bool, podIndexer cache.Indexer, t *testing.T) {
+	if restart == true {
+		tfJob.Spec.TFReplicaSpecs[rtype].RestartPolicy = commonv1.RestartPolicyExitCode
+	}
+
+	var typ string
+	switch rtype {
+	case tfv1.TFReplicaTypeWorker:
+		typ = testutil.LabelWorker
+	case tfv1.TFReplicaTypePS:
+		typ = testutil.LabelPS
+	case tfv1.TFReplicaTypeChief:
+		typ = testutil.LabelChief
+	default:
+		fmt.Println("wrong type")
+	}
+
+	var i int32
+	index := 0
+	for i = 0; i < succeeded; i++ {
+		pod := testutil.NewPod(tfJob, typ, index)
+		pod.Status.Phase = v1.PodSucceeded
+		if worker0Completed == true && rtype == tfv1.TFReplicaTypeWorker && index == 0 {
+			pod.Status.ContainerStatuses = []v1.ContainerStatus{
+				{
+					Name: tfv1.DefaultContainerName,
+					State: corev1.ContainerState{
+						Terminated: &corev1.ContainerStateTerminated{
+							ExitCode: int32(0), // exit with 0
+						},
+					},
+				},
+			}
+		}
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+		updateJobReplicaStatuses(&tfJob.Status, rtype, pod)
+
+		index++
+	}
+	for i = 0; i < failed; i++ {
+		pod := testutil.NewPod(tfJob, typ, index)
+		pod.Status.Phase = v1.PodFailed
+		if restart == true {
+			if pod.Status.ContainerStatuses == nil {
+				pod.Status.ContainerStatuses = []v1.ContainerStatus{
+					{
+						Name: tfv1.DefaultContainerName,
+						State: corev1.ContainerState{
+							Terminated: &corev1.ContainerStateTerminated{
+								ExitCode: int32(130), // 130 is a retryable code
+							},
+						},
+					},
+				}
+			}
+		}
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+		updateJobReplicaStatuses(&tfJob.Status, rtype, pod)
+		index++
+	}
+	for i = 0; i < active; i++ {
+		pod := testutil.NewPod(tfJob, typ, index)
+		pod.Status.Phase = v1.PodRunning
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		}
+		updateJobReplicaStatuses(&tfJob.Status, rtype, pod)
+		index++
+	}
+}
+
+func filterOutConditionTest(status commonv1.JobStatus, t *testing.T) {
+	flag := isFailed(status) || isSucceeded(status)
+	for _, condition := range status.Conditions {
+		if flag && condition.Type == commonv1.JobRunning && condition.Status == v1.ConditionTrue {
+			t.Error("Error condition status when succeeded or failed")
+		}
+	}
+}
+
+func TestTerminationMessage(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(1, 0)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = commonv1.RestartPolicyNever
+	pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	pod.Status.Phase = v1.PodFailed
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name: tfv1.DefaultContainerName,
+		State: v1.ContainerState{
+			Terminated: &v1.ContainerStateTerminated{
+				ExitCode: 1,
+				Reason:   "Error",
+				Message:  "ValueError: invalid learning rate" + strings.Repeat("x", maxTerminationMessageLength),
+			},
+		},
+	}}
+	if err := podIndexer.Add(pod); err != nil {
+		t.Errorf("unexpected error when adding pod %v", err)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	found := false
+	for _, condition := range tfJob.Status.Conditions {
+		if condition.Type != commonv1.JobFailed {
+			continue
+		}
+		found = true
+		if condition.Reason != TFJobFailedReasonPodFailure {
+			t.Errorf("expected the Failed condition reason %s, got %s", TFJobFailedReasonPodFailure, condition.Reason)
+		}
+		if !strings.Contains(condition.Message, "ValueError: invalid learning rate") {
+			t.Errorf("expected the termination message in the Failed condition, got %q", condition.Message)
+		}
+		if !strings.HasSuffix(condition.Message, "...") {
+			t.Errorf("expected the termination message to be truncated, got %q", condition.Message)
+		}
+	}
+	if !found {
+		t.Errorf("expected a Failed condition, got %v", tfJob.Status.Conditions)
+	}
+}
+
+func TestProgress(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.Recorder = &record.FakeRecorder{}
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	// 2 of 4 workers succeeded, the PS is not counted.
+	tfJob := testutil.NewTFJob(4, 1)
+	allWorkers := tfv1.SuccessPolicyAllWorkers
+	tfJob.Spec.SuccessPolicy = &allWorkers
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelWorker, 0, 2, 2, 0, nil, t)
+	testutil.SetPodsStatuses(podIndexer, tfJob, testutil.LabelPS, 0, 1, 0, 0, nil, t)
+
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if progress := tfJob.Annotations[tfv1.ProgressAnnotation]; progress != "50" {
+		t.Errorf("Expected progress 50, got %s", progress)
+	}
+
+	// All workers succeeded, but the job is only done once it succeeds.
+	jobStatus := tfJob.Status.DeepCopy()
+	jobStatus.Conditions = nil
+	jobStatus.ReplicaStatuses[tfv1.TFReplicaTypeWorker].Succeeded = 4
+	if progress := getProgress(tfJob, jobStatus); progress != 99 {
+		t.Errorf("Expected progress 99 before the job succeeds, got %d", progress)
+	}
+	if err := commonutil.UpdateJobConditions(jobStatus, commonv1.JobSucceeded, tfJobSucceededReason, ""); err != nil {
+		t.Errorf("Failed to update the job conditions: %v", err)
+	}
+	if progress := getProgress(tfJob, jobStatus); progress != 100 {
+		t.Errorf("Expected progress 100 after the job succeeds, got %d", progress)
+	}
+}
+
+func TestOOMKilled(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	fakeRecorder := record.NewFakeRecorder(100)
+	ctr.Recorder = fakeRecorder
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.tfJobInformerSynced = testutil.AlwaysReady
+	ctr.PodInformerSynced = testutil.AlwaysReady
+	ctr.ServiceInformerSynced = testutil.AlwaysReady
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	tfJob := testutil.NewTFJob(2, 0)
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = commonv1.RestartPolicyNever
+	runningPod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	runningPod.Status.Phase = v1.PodRunning
+	oomKilledPod := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+	oomKilledPod.Status.Phase = v1.PodFailed
+	oomKilledPod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name: tfv1.DefaultContainerName,
+		State: v1.ContainerState{
+			Terminated: &v1.ContainerStateTerminated{
+				ExitCode: 137,
+				Reason:   oomKilledReason,
+			},
+		},
+	}}
+	for _, pod := range []*v1.Pod{runningPod, oomKilledPod} {
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("unexpected error when adding pod %v", err)
+		}
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	found := false
+	for _, condition := range tfJob.Status.Conditions {
+		if condition.Type == commonv1.JobFailed {
+			found = true
+			if condition.Reason != TFJobFailedReasonOOMKilled {
+				t.Errorf("expected the Failed condition reason %s, got %s", TFJobFailedReasonOOMKilled, condition.Reason)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("expected a Failed condition, got %v", tfJob.Status.Conditions)
+	}
+
+	// The failed tfjob does not warn about the OOM killed pod again.
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	close(fakeRecorder.Events)
+	warnings := 0
+	for event := range fakeRecorder.Events {
+		if strings.Contains(event, "Warning "+TFJobFailedReasonOOMKilled) && strings.Contains(event, "worker replica 1") {
+			warnings++
+		}
+	}
+	if warnings != 1 {
+		t.Errorf("expected one %s event for worker replica 1, got %d", TFJobFailedReasonOOMKilled, warnings)
+	}
+}
+
+func TestFailedConditionReason(t *testing.T) {
+	backoffLimit := int32(0)
+	deadline := int64(10)
+	startTime := metav1.NewTime(time.Now().Add(-time.Minute))
+
+	testCases := []struct {
+		description    string
+		setUp          func(tfJob *tfv1.TFJob)
+		oomKilled      bool
+		failed         bool
+		expectedReason string
+	}{
+		{
+			description:    "replica failure",
+			failed:         true,
+			expectedReason: TFJobFailedReasonPodFailure,
+		},
+		{
+			description:    "replica OOM killed",
+			failed:         true,
+			oomKilled:      true,
+			expectedReason: TFJobFailedReasonOOMKilled,
+		},
+		{
+			description: "backoff limit reached",
+			setUp: func(tfJob *tfv1.TFJob) {
+				tfJob.Spec.RunPolicy.BackoffLimit = &backoffLimit
+			},
+			failed:         true,
+			expectedReason: TFJobFailedReasonBackoff,
+		},
+		{
+			description: "active deadline passed",
+			setUp: func(tfJob *tfv1.TFJob) {
+				tfJob.Spec.RunPolicy.ActiveDeadlineSeconds = &deadline
+				tfJob.Status.StartTime = &startTime
+			},
+			expectedReason: TFJobFailedReasonDeadline,
+		},
+		{
+			description: "replica deadline passed",
+			setUp: func(tfJob *tfv1.TFJob) {
+				tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+					tfv1.TFReplicaTypeWorker: {
+						ActiveDeadlineSeconds:     &deadline,
+						FailJobOnDeadlineExceeded: true,
+					},
+				}
+				tfJob.Status.StartTime = &startTime
+			},
+			expectedReason: TFJobFailedReasonReplicaDeadline,
+		},
+	}
+
+	for _, c := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
+
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
+		}
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+		fakeRecorder := record.NewFakeRecorder(100)
+		ctr.Recorder = fakeRecorder
+		ctr.PodControl = &control.FakePodControl{}
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.tfJobInformerSynced = testutil.AlwaysReady
+		ctr.PodInformerSynced = testutil.AlwaysReady
+		ctr.ServiceInformerSynced = testutil.AlwaysReady
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+		tfJob := testutil.NewTFJob(2, 0)
+		tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypeWorker].RestartPolicy = commonv1.RestartPolicyNever
+		if c.setUp != nil {
+			c.setUp(tfJob)
+		}
+		pods := []*v1.Pod{
+			testutil.NewPod(tfJob, testutil.LabelWorker, 0),
+			testutil.NewPod(tfJob, testutil.LabelWorker, 1),
+		}
+		pods[0].Status.Phase = v1.PodRunning
+		pods[1].Status.Phase = v1.PodRunning
+		if c.failed {
+			pods[1].Status.Phase = v1.PodFailed
+		}
+		if c.oomKilled {
+			pods[1].Status.ContainerStatuses = []v1.ContainerStatus{{
+				Name: tfv1.DefaultContainerName,
+				State: v1.ContainerState{
+					Terminated: &v1.ContainerStateTerminated{
+						ExitCode: 137,
+						Reason:   oomKilledReason,
+					},
+				},
+			}}
+		}
+		for _, pod := range pods {
+			if err := podIndexer.Add(pod); err != nil {
+				t.Errorf("%s: unexpected error when adding pod %v", c.description, err)
+			}
+		}
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		// The Failed condition may only be written to the API server, so
+		// check the event recorded along with it as well.
+		for _, condition := range tfJob.Status.Conditions {
+			if condition.Type == commonv1.JobFailed && condition.Reason != c.expectedReason {
+				t.Errorf("%s: expected the Failed condition reason %s, got %s",
+					c.description, c.expectedReason, condition.Reason)
+			}
+		}
+		close(fakeRecorder.Events)
+		found := false
+		for event := range fakeRecorder.Events {
+			if strings.Contains(event, " "+c.expectedReason+" ") {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("%s: expected a failure event with reason %s", c.description, c.expectedReason)
+		}
+	}
+}
+
+func TestFailureAggregationWindow(t *testing.T) {
+	testCases := []struct {
+		description string
+		threshold   int
+		// recovered is set if the failed worker recovers within the window.
+		recovered bool
+
+		expectedFailed bool
+	}{
+		{
+			description: "the transient failure does not fail the tfjob",
+			recovered:   true,
+		},
+		{
+			description:    "the persistent failure fails the tfjob after the window",
+			expectedFailed: true,
 		},
-		testCase{
-			description:             "Chief is failed and restarting",
-			tfJob:                   testutil.NewTFJobWithChief(4, 2),
-			expectedFailedPS:        0,
-			expectedSucceededPS:     0,
-			expectedActivePS:        2,
-			expectedFailedWorker:    4,
-			expectedSucceededWorker: 0,
-			expectedActiveWorker:    0,
-			expectedFailedChief:     1,
-			expectedSucceededChief:  0,
-			expectedActiveChief:     0,
-			restart:                 true,
-			worker0Completed:        false,
-			expectedType:            commonv1.JobRestarting,
+		{
+			description: "the persistent failure under the threshold does not fail the tfjob",
+			threshold:   2,
 		},
 	}
 
-	for i, c := range testCases {
+	for _, c := range testCases {
 		// Prepare the clientset and controller for the test.
 		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
 			Host: "",
@@ -450,143 +1553,290 @@ func TestStatus(t *testing.T) {
 			},
 		}
 		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
-		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
-			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
-		fakePodControl := &control.FakePodControl{}
-		ctr.PodControl = fakePodControl
+		ctr, _, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{
+				FailureAggregationWindow:    time.Minute,
+				FailureAggregationThreshold: c.threshold,
+			})
 		ctr.Recorder = &record.FakeRecorder{}
-		ctr.tfJobInformerSynced = testutil.AlwaysReady
-		ctr.PodInformerSynced = testutil.AlwaysReady
-		ctr.ServiceInformerSynced = testutil.AlwaysReady
-		tfJobIndexer := ctr.tfJobInformer.GetIndexer()
-		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
 
-		stopCh := make(chan struct{})
-		run := func(<-chan struct{}) {
-			if err := ctr.Run(testutil.ThreadCount, stopCh); err != nil {
-				t.Errorf("Failed to run the controller: %v", err)
-			}
+		tfJob := testutil.NewTFJob(3, 0)
+		tfJob.Status.ReplicaStatuses = map[commonv1.ReplicaType]*commonv1.ReplicaStatus{
+			tfv1.TFReplicaTypeWorker: {Active: 2, Failed: 1},
 		}
-		go run(stopCh)
 
-		unstructured, err := testutil.ConvertTFJobToUnstructured(c.tfJob)
-		if err != nil {
-			t.Errorf("Failed to convert the TFJob to Unstructured: %v", err)
+		// The failure starts the window.
+		if err := ctr.UpdateJobStatus(tfJob, tfJob.Spec.TFReplicaSpecs, &tfJob.Status); err != nil {
+			t.Errorf("%s: expected error %v to be nil", c.description, err)
+		}
+		if isFailed(tfJob.Status) {
+			t.Errorf("%s: expected the tfjob not to fail within the window", c.description)
+		}
+		if !tfv1.IsConditionTrue(tfJob.Status, tfJobFailuresAggregating) {
+			t.Errorf("%s: expected condition %s, got %v", c.description, tfJobFailuresAggregating, tfJob.Status.Conditions)
 		}
 
-		if err := tfJobIndexer.Add(unstructured); err != nil {
-			t.Errorf("Failed to add tfjob to tfJobIndexer: %v", err)
+		// The window ends.
+		for i := range tfJob.Status.Conditions {
+			if tfJob.Status.Conditions[i].Type == tfJobFailuresAggregating {
+				tfJob.Status.Conditions[i].LastTransitionTime = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+			}
+		}
+		if c.recovered {
+			tfJob.Status.ReplicaStatuses[tfv1.TFReplicaTypeWorker] = &commonv1.ReplicaStatus{Active: 3}
 		}
+		if err := ctr.UpdateJobStatus(tfJob, tfJob.Spec.TFReplicaSpecs, &tfJob.Status); err != nil {
+			t.Errorf("%s: expected error %v to be nil", c.description, err)
+		}
+		if failed := isFailed(tfJob.Status); failed != c.expectedFailed {
+			t.Errorf("%s: expected failed %v, got %v", c.description, c.expectedFailed, failed)
+		}
+		if c.recovered && tfv1.GetCondition(tfJob.Status, tfJobFailuresAggregating) != nil {
+			t.Errorf("%s: expected condition %s to be removed, got %v", c.description, tfJobFailuresAggregating, tfJob.Status.Conditions)
+		}
+	}
+}
 
-		initializeReplicaStatuses(&c.tfJob.Status, tfv1.TFReplicaTypeWorker)
-		initializeReplicaStatuses(&c.tfJob.Status, tfv1.TFReplicaTypeChief)
-		initializeReplicaStatuses(&c.tfJob.Status, tfv1.TFReplicaTypePS)
+func TestJobSummary(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
 
-		setStatusForTest(c.tfJob, tfv1.TFReplicaTypePS, c.expectedFailedPS, c.expectedSucceededPS, c.expectedActivePS, c.restart, c.worker0Completed, podIndexer, t)
-		setStatusForTest(c.tfJob, tfv1.TFReplicaTypeWorker, c.expectedFailedWorker, c.expectedSucceededWorker, c.expectedActiveWorker, c.restart, c.worker0Completed, podIndexer, t)
-		setStatusForTest(c.tfJob, tfv1.TFReplicaTypeChief, c.expectedFailedChief, c.expectedSucceededChief, c.expectedActiveChief, c.restart, c.worker0Completed, podIndexer, t)
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
 
-		// err = ctr.UpdateJobStatus(c.tfJob, c.tfJob.Spec.TFReplicaSpecs, &c.tfJob.Status)
-		// if err != nil {
-		// 	t.Errorf("%s: Expected error %v to be nil", c.description, err)
-		// }
-		_ = ctr.ReconcileJobs(c.tfJob, c.tfJob.Spec.TFReplicaSpecs, c.tfJob.Status, &c.tfJob.Spec.RunPolicy)
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{})
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	recorder := record.NewFakeRecorder(100)
+	ctr.Recorder = recorder
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
 
-		// Test filterOutCondition
-		filterOutConditionTest(c.tfJob.Status, t)
+	// Both workers succeeded, after restarting 1+2 times.
+	tfJob := testutil.NewTFJob(2, 0)
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	for i, restarts := range []int32{1, 2} {
+		pod := testutil.NewPod(tfJob, testutil.LabelWorker, i)
+		pod.Status.Phase = v1.PodSucceeded
+		pod.Status.ContainerStatuses = []v1.ContainerStatus{
+			{Name: tfv1.DefaultContainerName, RestartCount: restarts},
+		}
+		if err := podIndexer.Add(pod); err != nil {
+			t.Errorf("unexpected error when adding pod %v", err)
+		}
+	}
 
-		found := false
-		for _, condition := range c.tfJob.Status.Conditions {
-			if condition.Type == c.expectedType {
-				found = true
-			}
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if !isSucceeded(tfJob.Status) {
+		t.Fatalf("Expected the tfjob to succeed, got %v", tfJob.Status.Conditions)
+	}
+	// Reconciling the finished job does not summarize it again.
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	var summaries []string
+	for len(recorder.Events) > 0 {
+		if event := <-recorder.Events; strings.HasPrefix(event, v1.EventTypeNormal+" "+jobSummaryReason) {
+			summaries = append(summaries, event)
 		}
-		if !found {
-			t.Errorf("Case[%d]%s: Condition %s is not found", i, c.description, c.expectedType)
+	}
+	if len(summaries) != 1 {
+		t.Fatalf("Expected 1 %s event, got %v", jobSummaryReason, summaries)
+	}
+	for _, expected := range []string{"TFJob succeeded after", "Worker: 2 succeeded, 0 failed, 3 restarts"} {
+		if !strings.Contains(summaries[0], expected) {
+			t.Errorf("Expected the summary to contain %q, got %q", expected, summaries[0])
 		}
 	}
 }
 
-func setStatusForTest(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType, failed, succeeded, active int32, restart bool, worker0Completed bool, podIndexer cache.Indexer, t *testing.T) {
-	if restart == true {
-		tfJob.Spec.TFReplicaSpecs[rtype].RestartPolicy = commonv1.RestartPolicyExitCode
+func TestDefaultSuccessPolicy(t *testing.T) {
+	testCases := []struct {
+		description          string
+		defaultSuccessPolicy string
+		expectSucceeded      bool
+		expectWorker1Deleted bool
+	}{
+		{
+			description:          "worker 0 succeeds the tfjob and the running worker is cleaned up",
+			defaultSuccessPolicy: options.SuccessPolicyWorker0,
+			expectSucceeded:      true,
+			expectWorker1Deleted: true,
+		},
+		{
+			description:          "the tfjob waits for all its workers",
+			defaultSuccessPolicy: options.SuccessPolicyAllWorkers,
+			expectSucceeded:      false,
+		},
 	}
+	for _, tc := range testCases {
+		// Prepare the clientset and controller for the test.
+		kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &v1.SchemeGroupVersion,
+			},
+		},
+		)
 
-	var typ string
-	switch rtype {
-	case tfv1.TFReplicaTypeWorker:
-		typ = testutil.LabelWorker
-	case tfv1.TFReplicaTypePS:
-		typ = testutil.LabelPS
-	case tfv1.TFReplicaTypeChief:
-		typ = testutil.LabelChief
-	default:
-		fmt.Println("wrong type")
-	}
+		// Prepare the volcano clientset and controller for the test.
+		volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &batchv1beta1.SchemeGroupVersion,
+			},
+		},
+		)
 
-	var i int32
-	index := 0
-	for i = 0; i < succeeded; i++ {
-		pod := testutil.NewPod(tfJob, typ, index)
-		pod.Status.Phase = v1.PodSucceeded
-		if worker0Completed == true && rtype == tfv1.TFReplicaTypeWorker && index == 0 {
-			pod.Status.ContainerStatuses = []v1.ContainerStatus{
-				{
-					Name: tfv1.DefaultContainerName,
-					State: corev1.ContainerState{
-						Terminated: &corev1.ContainerStateTerminated{
-							ExitCode: int32(0), // exit with 0
-						},
-					},
-				},
-			}
+		config := &rest.Config{
+			Host: "",
+			ContentConfig: rest.ContentConfig{
+				GroupVersion: &tfv1.GroupVersion,
+			},
 		}
-		if err := podIndexer.Add(pod); err != nil {
-			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+		ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+			volcanoClientSet, tfJobClientSet, 0, options.ServerOption{DefaultSuccessPolicy: tc.defaultSuccessPolicy})
+		fakePodControl := &control.FakePodControl{}
+		ctr.PodControl = fakePodControl
+		ctr.ServiceControl = &control.FakeServiceControl{}
+		ctr.Recorder = &record.FakeRecorder{}
+		podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+		// The spec does not set a success policy.
+		tfJob := testutil.NewTFJob(2, 0)
+		tfJob.Spec.SuccessPolicy = nil
+		unstructured, err := testutil.ConvertTFJobToUnstructured(tfJob)
+		if err != nil {
+			t.Fatalf("Failed to convert the TFJob to Unstructured: %v", err)
 		}
-		updateJobReplicaStatuses(&tfJob.Status, rtype, pod)
+		if err := ctr.tfJobInformer.GetIndexer().Add(unstructured); err != nil {
+			t.Fatalf("Failed to add tfjob to tfJobIndexer: %v", err)
+		}
+		tfJob, err = ctr.getTFJobFromName(tfJob.Namespace, tfJob.Name)
+		if err != nil {
+			t.Fatalf("Failed to get the tfjob: %v", err)
+		}
+		tfv1.SetObjectDefaults_TFJob(tfJob)
+		ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
 
-		index++
-	}
-	for i = 0; i < failed; i++ {
-		pod := testutil.NewPod(tfJob, typ, index)
-		pod.Status.Phase = v1.PodFailed
-		if restart == true {
-			if pod.Status.ContainerStatuses == nil {
-				pod.Status.ContainerStatuses = []v1.ContainerStatus{
-					{
-						Name: tfv1.DefaultContainerName,
-						State: corev1.ContainerState{
-							Terminated: &corev1.ContainerStateTerminated{
-								ExitCode: int32(130), // 130 is a retryable code
-							},
-						},
-					},
-				}
+		// worker-0 succeeded, worker-1 is still running.
+		worker0 := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+		worker0.Status.Phase = v1.PodSucceeded
+		worker0.Status.ContainerStatuses = []v1.ContainerStatus{{
+			Name:  tfv1.DefaultContainerName,
+			State: v1.ContainerState{Terminated: &v1.ContainerStateTerminated{ExitCode: 0}},
+		}}
+		worker1 := testutil.NewPod(tfJob, testutil.LabelWorker, 1)
+		worker1.Status.Phase = v1.PodRunning
+		for _, pod := range []*v1.Pod{worker0, worker1} {
+			if err := podIndexer.Add(pod); err != nil {
+				t.Errorf("unexpected error when adding pod %v", err)
 			}
 		}
-		if err := podIndexer.Add(pod); err != nil {
-			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+
+		_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+		if succeeded := isSucceeded(tfJob.Status); succeeded != tc.expectSucceeded {
+			t.Errorf("%s: expected succeeded %v, got conditions %v", tc.description, tc.expectSucceeded, tfJob.Status.Conditions)
 		}
-		updateJobReplicaStatuses(&tfJob.Status, rtype, pod)
-		index++
-	}
-	for i = 0; i < active; i++ {
-		pod := testutil.NewPod(tfJob, typ, index)
-		pod.Status.Phase = v1.PodRunning
-		if err := podIndexer.Add(pod); err != nil {
-			t.Errorf("%s: unexpected error when adding pod %v", tfJob.Name, err)
+		var expectDeleted []string
+		if tc.expectWorker1Deleted {
+			expectDeleted = []string{worker1.Name}
+		}
+		if !reflect.DeepEqual(fakePodControl.DeletePodName, expectDeleted) {
+			t.Errorf("%s: expected deleted pods %v, got %v", tc.description, expectDeleted, fakePodControl.DeletePodName)
 		}
-		updateJobReplicaStatuses(&tfJob.Status, rtype, pod)
-		index++
 	}
 }
 
-func filterOutConditionTest(status commonv1.JobStatus, t *testing.T) {
-	flag := isFailed(status) || isSucceeded(status)
-	for _, condition := range status.Conditions {
-		if flag && condition.Type == commonv1.JobRunning && condition.Status == v1.ConditionTrue {
-			t.Error("Error condition status when succeeded or failed")
-		}
+func TestMinReadyPeriod(t *testing.T) {
+	// Prepare the clientset and controller for the test.
+	kubeClientSet := kubeclientset.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &v1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	// Prepare the volcano clientset and controller for the test.
+	volcanoClientSet := volcanoclient.NewForConfigOrDie(&rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &batchv1beta1.SchemeGroupVersion,
+		},
+	},
+	)
+
+	config := &rest.Config{
+		Host: "",
+		ContentConfig: rest.ContentConfig{
+			GroupVersion: &tfv1.GroupVersion,
+		},
+	}
+	tfJobClientSet := tfjobclientset.NewForConfigOrDie(config)
+	ctr, kubeInformerFactory, _ := newTFController(config, kubeClientSet,
+		volcanoClientSet, tfJobClientSet, 0, options.ServerOption{MinReadyPeriod: time.Minute})
+	ctr.PodControl = &control.FakePodControl{}
+	ctr.ServiceControl = &control.FakeServiceControl{}
+	ctr.Recorder = &record.FakeRecorder{}
+	podIndexer := kubeInformerFactory.Core().V1().Pods().Informer().GetIndexer()
+
+	// The worker just started.
+	tfJob := testutil.NewTFJob(1, 0)
+	ctr.tfJobClientSet = tfjobfake.NewSimpleClientset(tfJob)
+	pod := testutil.NewPod(tfJob, testutil.LabelWorker, 0)
+	pod.Status.Phase = v1.PodRunning
+	pod.Status.ContainerStatuses = []v1.ContainerStatus{{
+		Name:  tfv1.DefaultContainerName,
+		State: v1.ContainerState{Running: &v1.ContainerStateRunning{StartedAt: metav1.Now()}},
+	}}
+	if err := podIndexer.Add(pod); err != nil {
+		t.Errorf("unexpected error when adding pod %v", err)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if active := tfJob.Status.ReplicaStatuses[tfv1.TFReplicaTypeWorker].Active; active != 0 {
+		t.Errorf("Expected no active workers within the min ready period, got %d", active)
+	}
+	if tfv1.IsConditionTrue(tfJob.Status, commonv1.JobRunning) {
+		t.Errorf("Expected the tfjob not to be running within the min ready period, got %v", tfJob.Status.Conditions)
+	}
+
+	// The worker has been up for longer than the min ready period.
+	pod = pod.DeepCopy()
+	pod.Status.ContainerStatuses[0].State.Running.StartedAt = metav1.NewTime(time.Now().Add(-2 * time.Minute))
+	if err := podIndexer.Update(pod); err != nil {
+		t.Errorf("unexpected error when updating pod %v", err)
+	}
+
+	_ = ctr.ReconcileJobs(tfJob, tfJob.Spec.TFReplicaSpecs, tfJob.Status, &tfJob.Spec.RunPolicy)
+
+	if active := tfJob.Status.ReplicaStatuses[tfv1.TFReplicaTypeWorker].Active; active != 1 {
+		t.Errorf("Expected 1 active worker past the min ready period, got %d", active)
+	}
+	if !tfv1.IsConditionTrue(tfJob.Status, commonv1.JobRunning) {
+		t.Errorf("Expected the tfjob to be running past the min ready period, got %v", tfJob.Status.Conditions)
 	}
 }
@@ -0,0 +1,74 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	commonutil "github.com/kubeflow/common/pkg/util"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+)
+
+// jobSummaryReason is the normal reason of the event summarizing a tfjob
+// when it finishes.
+const jobSummaryReason = "JobSummary"
+
+// recordJobSummary emits a single event summarizing the finished tfjob, for a
+// quick triage with kubectl describe: whether it succeeded, how long it ran,
+// and the succeeded and failed replicas and container restarts of each
+// replica type.
+func (tc *TFController) recordJobSummary(tfJob *tfv1.TFJob, jobStatus commonv1.JobStatus,
+	replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec, pods []*v1.Pod) {
+	outcome := "failed"
+	if isSucceeded(jobStatus) {
+		outcome = "succeeded"
+	}
+
+	start := tfJob.CreationTimestamp.Time
+	if jobStatus.StartTime != nil {
+		start = jobStatus.StartTime.Time
+	}
+	end := time.Now()
+	if jobStatus.CompletionTime != nil {
+		end = jobStatus.CompletionTime.Time
+	}
+
+	restarts, err := tc.getReplicaRestarts(replicas, pods)
+	if err != nil {
+		commonutil.LoggerForJob(tfJob).Warnf("Failed to count the restarts of the replicas: %v", err)
+	}
+	rtypes := make([]string, 0, len(replicas))
+	for rtype := range replicas {
+		rtypes = append(rtypes, string(rtype))
+	}
+	sort.Strings(rtypes)
+	counts := make([]string, 0, len(rtypes))
+	for _, rtype := range rtypes {
+		var succeeded, failed int32
+		if status := jobStatus.ReplicaStatuses[commonv1.ReplicaType(rtype)]; status != nil {
+			succeeded, failed = status.Succeeded, status.Failed
+		}
+		counts = append(counts, fmt.Sprintf("%s: %d succeeded, %d failed, %d restarts",
+			rtype, succeeded, failed, restarts[commonv1.ReplicaType(rtype)]))
+	}
+
+	tc.Recorder.Eventf(tfJob, v1.EventTypeNormal, jobSummaryReason, "TFJob %s after %v. %s",
+		outcome, end.Sub(start).Round(time.Second), strings.Join(counts, "; "))
+}
@@ -23,13 +23,27 @@ import (
 	"strings"
 
 	"github.com/kubeflow/common/pkg/controller.v1/common"
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
 )
 
 const (
 	// EnvCustomClusterDomain is the custom defined cluster domain, such as "svc.cluster.local".
 	// Ref: https://kubernetes.io/docs/concepts/services-networking/dns-pod-service/#a-records
 	EnvCustomClusterDomain = "CUSTOM_CLUSTER_DOMAIN"
+
+	// tfConfigFileEnv is the environment variable pointing to the mounted TF_CONFIG file.
+	tfConfigFileEnv = "TF_CONFIG_FILE"
+	// tfConfigAnnotation is the pod annotation holding TF_CONFIG, projected into
+	// the TF_CONFIG file by the downward API.
+	tfConfigAnnotation = "tf-operator.kubeflow.org/tf-config"
+	// tfConfigVolumeName is the name of the downward API volume holding TF_CONFIG.
+	tfConfigVolumeName = "tf-config"
+	// tfConfigMountPath is the directory the TF_CONFIG file is mounted into.
+	tfConfigMountPath = "/etc/tfjob"
+	// tfConfigFileName is the name of the TF_CONFIG file.
+	tfConfigFileName = "tf_config.json"
 )
 
 // TaskSpec is the specification for a task (PS or worker) of the TFJob.
@@ -95,13 +109,19 @@ func convertClusterSpecToSparseClusterSpec(clusterSpec ClusterSpec, rtype string
 //     }
 // }
 func genTFConfigJSONStr(tfjob *tfv1.TFJob, rtype, index string) (string, error) {
-	// Configure the TFCONFIG environment variable.
-	i, err := strconv.ParseInt(index, 0, 32)
+	cluster, err := genClusterSpec(tfjob)
 	if err != nil {
 		return "", err
 	}
+	return genTFConfigJSONStrFromClusterSpec(tfjob, cluster, nil, rtype, index)
+}
 
-	cluster, err := genClusterSpec(tfjob)
+// genTFConfigJSONStrFromClusterSpec generates TF_CONFIG from the given cluster
+// spec, with the replica types renamed after the given cluster spec keys. The
+// sparse cluster spec of dynamic workers always has the default keys.
+func genTFConfigJSONStrFromClusterSpec(tfjob *tfv1.TFJob, cluster ClusterSpec, keys options.ClusterSpecKeys, rtype, index string) (string, error) {
+	// Configure the TFCONFIG environment variable.
+	i, err := strconv.ParseInt(index, 0, 32)
 	if err != nil {
 		return "", err
 	}
@@ -119,9 +139,9 @@ func genTFConfigJSONStr(tfjob *tfv1.TFJob, rtype, index string) (string, error)
 		tfConfigJSONByteSlice, err = json.Marshal(sparseTFConfig)
 	} else {
 		tfConfig := TFConfig{
-			Cluster: cluster,
+			Cluster: renameClusterSpec(cluster, keys),
 			Task: TaskSpec{
-				Type:  strings.ToLower(rtype),
+				Type:  getClusterSpecKey(keys, rtype),
 				Index: int(i),
 			},
 			// We need to set environment to cloud  otherwise it will default to local which isn't what we want.
@@ -138,6 +158,30 @@ func genTFConfigJSONStr(tfjob *tfv1.TFJob, rtype, index string) (string, error)
 	return string(tfConfigJSONByteSlice), nil
 }
 
+// getClusterSpecKey returns the key of the replica type in the cluster spec,
+// which is the lower case replica type unless the keys map it to another one.
+func getClusterSpecKey(keys options.ClusterSpecKeys, rtype string) string {
+	for rt, key := range keys {
+		if strings.EqualFold(string(rt), rtype) {
+			return key
+		}
+	}
+	return strings.ToLower(rtype)
+}
+
+// renameClusterSpec returns the cluster spec with its replica types renamed
+// after the given cluster spec keys.
+func renameClusterSpec(cluster ClusterSpec, keys options.ClusterSpecKeys) ClusterSpec {
+	if len(keys) == 0 {
+		return cluster
+	}
+	renamed := make(ClusterSpec, len(cluster))
+	for rt, endpoints := range cluster {
+		renamed[getClusterSpecKey(keys, rt)] = endpoints
+	}
+	return renamed
+}
+
 // genClusterSpec will generate ClusterSpec.
 func genClusterSpec(tfjob *tfv1.TFJob) (ClusterSpec, error) {
 	clusterSpec := make(ClusterSpec)
@@ -146,11 +190,11 @@ func genClusterSpec(tfjob *tfv1.TFJob) (ClusterSpec, error) {
 		rt := strings.ToLower(string(rtype))
 		replicaNames := make([]string, 0, *spec.Replicas)
 
-		port, err := GetPortFromTFJob(tfjob, rtype)
-		if err != nil {
-			return nil, err
-		}
 		for i := int32(0); i < *spec.Replicas; i++ {
+			port, err := getReplicaPort(tfjob, rtype, i)
+			if err != nil {
+				return nil, err
+			}
 			// As described here: https://kubernetes.io/docs/concepts/services-networking/dns-pod-service/#a-records.
 			// Headless service assigned a DNS A record for a name of the form "my-svc.my-namespace.svc.cluster.local".
 			// And the last part "svc.cluster.local" is called cluster domain
@@ -169,5 +213,79 @@ func genClusterSpec(tfjob *tfv1.TFJob) (ClusterSpec, error) {
 		clusterSpec[rt] = replicaNames
 	}
 
+	// The endpoints provided out of the tfjob replace those of its services.
+	injected, err := getInjectedClusterSpec(tfjob)
+	if err != nil {
+		return nil, err
+	}
+	for rt, endpoints := range injected {
+		clusterSpec[rt] = endpoints
+	}
+
 	return clusterSpec, nil
 }
+
+// getInjectedClusterSpec returns the endpoints set in the ClusterSpec
+// annotation of the tfjob, if any, by lower case replica type.
+func getInjectedClusterSpec(tfjob *tfv1.TFJob) (ClusterSpec, error) {
+	value, ok := tfjob.Annotations[tfv1.ClusterSpecAnnotation]
+	if !ok {
+		return nil, nil
+	}
+	var injected ClusterSpec
+	if err := json.Unmarshal([]byte(value), &injected); err != nil {
+		return nil, fmt.Errorf("invalid %s annotation: %v", tfv1.ClusterSpecAnnotation, err)
+	}
+	cluster := make(ClusterSpec, len(injected))
+	for rt, endpoints := range injected {
+		cluster[strings.ToLower(rt)] = endpoints
+	}
+	return cluster, nil
+}
+
+// setTFConfigFile stores TF_CONFIG in a pod annotation, projects it into a file
+// through a downward API volume mounted in the tensorflow container, and sets
+// TF_CONFIG_FILE to the path of the file.
+func setTFConfigFile(podTemplate *v1.PodTemplateSpec, tfConfigStr string) {
+	if podTemplate.Annotations == nil {
+		podTemplate.Annotations = map[string]string{}
+	}
+	podTemplate.Annotations[tfConfigAnnotation] = tfConfigStr
+
+	mountTFConfigFile(podTemplate, v1.VolumeSource{
+		DownwardAPI: &v1.DownwardAPIVolumeSource{
+			Items: []v1.DownwardAPIVolumeFile{
+				{
+					Path: tfConfigFileName,
+					FieldRef: &v1.ObjectFieldSelector{
+						FieldPath: fmt.Sprintf("metadata.annotations['%s']", tfConfigAnnotation),
+					},
+				},
+			},
+		},
+	})
+}
+
+// mountTFConfigFile mounts the volume holding the TF_CONFIG file in the
+// tensorflow container, and sets TF_CONFIG_FILE to the path of the file.
+func mountTFConfigFile(podTemplate *v1.PodTemplateSpec, source v1.VolumeSource) {
+	podTemplate.Spec.Volumes = append(podTemplate.Spec.Volumes, v1.Volume{
+		Name:         tfConfigVolumeName,
+		VolumeSource: source,
+	})
+
+	for i := range podTemplate.Spec.Containers {
+		if podTemplate.Spec.Containers[i].Name == tfv1.DefaultContainerName {
+			podTemplate.Spec.Containers[i].VolumeMounts = append(podTemplate.Spec.Containers[i].VolumeMounts, v1.VolumeMount{
+				Name:      tfConfigVolumeName,
+				MountPath: tfConfigMountPath,
+				ReadOnly:  true,
+			})
+			podTemplate.Spec.Containers[i].Env = append(podTemplate.Spec.Containers[i].Env, v1.EnvVar{
+				Name:  tfConfigFileEnv,
+				Value: tfConfigMountPath + "/" + tfConfigFileName,
+			})
+			break
+		}
+	}
+}
@@ -16,8 +16,13 @@
 package tensorflow
 
 import (
+	"encoding/json"
 	"reflect"
 	"testing"
+
+	"github.com/kubeflow/tf-operator/cmd/tf-operator.v1/app/options"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
 )
 
 func TestConvertClusterSpecToSparseClusterSpec(t *testing.T) {
@@ -43,3 +48,108 @@ func TestConvertClusterSpecToSparseClusterSpec(t *testing.T) {
 		t.Error("sparseClusterSpec for worker is not correct!")
 	}
 }
+
+func TestInjectedClusterSpec(t *testing.T) {
+	tfJob := testutil.NewTFJob(2, 0)
+	tfJob.Annotations = map[string]string{
+		tfv1.ClusterSpecAnnotation: `{"PS": ["ps-0.example.com:2222", "ps-1.example.com:2222"]}`,
+	}
+
+	tfConfigStr, err := genTFConfigJSONStr(tfJob, "worker", "1")
+	if err != nil {
+		t.Fatalf("Failed to generate TF_CONFIG: %v", err)
+	}
+	tfConfig := TFConfig{}
+	if err := json.Unmarshal([]byte(tfConfigStr), &tfConfig); err != nil {
+		t.Fatalf("Failed to parse TF_CONFIG: %v", err)
+	}
+	expected := TFConfig{
+		Cluster: ClusterSpec{
+			"ps":     {"ps-0.example.com:2222", "ps-1.example.com:2222"},
+			"worker": {"test-tfjob-worker-0.default.svc:2222", "test-tfjob-worker-1.default.svc:2222"},
+		},
+		Task:        TaskSpec{Type: "worker", Index: 1},
+		Environment: "cloud",
+	}
+	if !reflect.DeepEqual(tfConfig, expected) {
+		t.Errorf("Expected TF_CONFIG %+v, got %+v", expected, tfConfig)
+	}
+
+	tfJob.Annotations[tfv1.ClusterSpecAnnotation] = "ps-0.example.com:2222"
+	if _, err := genTFConfigJSONStr(tfJob, "worker", "1"); err == nil {
+		t.Errorf("Expected an error for an invalid %s annotation", tfv1.ClusterSpecAnnotation)
+	}
+}
+
+func TestClusterSpecKeys(t *testing.T) {
+	tfJob := testutil.NewTFJob(2, 1)
+	keys := options.ClusterSpecKeys{tfv1.TFReplicaTypePS: "parameter_server"}
+
+	emitter, err := GetClusterSpecEmitter(ClusterSpecFormatTFConfig, false, keys)
+	if err != nil {
+		t.Fatalf("Failed to get the cluster spec emitter: %v", err)
+	}
+	cluster, err := genClusterSpec(tfJob)
+	if err != nil {
+		t.Fatalf("Failed to generate the cluster spec: %v", err)
+	}
+	podTemplate := tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS].Template.DeepCopy()
+	if err := emitter.EmitClusterSpec(tfJob, cluster, podTemplate, "ps", "0"); err != nil {
+		t.Fatalf("Failed to emit the cluster spec: %v", err)
+	}
+
+	var tfConfigStr string
+	for _, env := range podTemplate.Spec.Containers[0].Env {
+		if env.Name == tfConfig {
+			tfConfigStr = env.Value
+		}
+	}
+	tfConfig := TFConfig{}
+	if err := json.Unmarshal([]byte(tfConfigStr), &tfConfig); err != nil {
+		t.Fatalf("Failed to parse TF_CONFIG %q: %v", tfConfigStr, err)
+	}
+	expected := TFConfig{
+		Cluster: ClusterSpec{
+			"parameter_server": {"test-tfjob-ps-0.default.svc:2222"},
+			"worker":           {"test-tfjob-worker-0.default.svc:2222", "test-tfjob-worker-1.default.svc:2222"},
+		},
+		Task:        TaskSpec{Type: "parameter_server", Index: 0},
+		Environment: "cloud",
+	}
+	if !reflect.DeepEqual(tfConfig, expected) {
+		t.Errorf("Expected TF_CONFIG %+v, got %+v", expected, tfConfig)
+	}
+}
+
+func TestMasterClusterSpecKey(t *testing.T) {
+	testCases := []struct {
+		keys        options.ClusterSpecKeys
+		expectedKey string
+	}{
+		// The master is listed under its lower case replica type by default.
+		{expectedKey: "master"},
+		// The cluster spec keys list it under chief for later TF versions.
+		{keys: options.ClusterSpecKeys{tfv1.TFReplicaTypeMaster: "chief"}, expectedKey: "chief"},
+	}
+	for _, c := range testCases {
+		tfJob := testutil.NewTFJobV2(1, 0, 1, 0, 0)
+		cluster, err := genClusterSpec(tfJob)
+		if err != nil {
+			t.Fatalf("Failed to generate the cluster spec: %v", err)
+		}
+		tfConfigStr, err := genTFConfigJSONStrFromClusterSpec(tfJob, cluster, c.keys, "master", "0")
+		if err != nil {
+			t.Fatalf("Failed to generate TF_CONFIG: %v", err)
+		}
+		tfConfig := TFConfig{}
+		if err := json.Unmarshal([]byte(tfConfigStr), &tfConfig); err != nil {
+			t.Fatalf("Failed to parse TF_CONFIG %q: %v", tfConfigStr, err)
+		}
+		if len(tfConfig.Cluster[c.expectedKey]) != 1 || len(tfConfig.Cluster["worker"]) != 1 {
+			t.Errorf("Expected the master under %q in the cluster spec, got %v", c.expectedKey, tfConfig.Cluster)
+		}
+		if tfConfig.Task.Type != c.expectedKey {
+			t.Errorf("Expected the task type %q, got %q", c.expectedKey, tfConfig.Task.Type)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+
+	"github.com/kubeflow/common/pkg/controller.v1/common"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// tfConfigSecretSuffix is appended to the name of a pod to name the Secret
+// holding its TF_CONFIG.
+const tfConfigSecretSuffix = "-tf-config"
+
+// genTFConfigSecretName returns the name of the Secret holding the TF_CONFIG
+// of the given replica.
+func genTFConfigSecretName(tfjob *tfv1.TFJob, rtype, index string) string {
+	return common.GenGeneralName(tfjob.Name, rtype, index) + tfConfigSecretSuffix
+}
+
+// tfConfigSecretEmitter writes TF_CONFIG to a Secret owned by the tfjob, one
+// per replica, and mounts it as the TF_CONFIG file. Unlike the pod annotation
+// of tfConfigEmitter, the Secret is only readable by those allowed to, so the
+// cluster spec may carry credentials.
+type tfConfigSecretEmitter struct {
+	tc *TFController
+}
+
+func (e tfConfigSecretEmitter) EmitClusterSpec(tfjob *tfv1.TFJob, cluster ClusterSpec, podTemplate *v1.PodTemplateSpec, rtype, index string) error {
+	tfConfigStr, err := genTFConfigJSONStrFromClusterSpec(tfjob, cluster, e.tc.option.ClusterSpecKeys, rtype, index)
+	if err != nil {
+		return err
+	}
+	if tfConfigStr == "" {
+		return nil
+	}
+
+	secret := &v1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            genTFConfigSecretName(tfjob, rtype, index),
+			Namespace:       tfjob.Namespace,
+			Labels:          e.tc.GenLabels(tfjob.Name),
+			OwnerReferences: []metav1.OwnerReference{*e.tc.GenOwnerReference(tfjob)},
+		},
+		Data: map[string][]byte{tfConfigFileName: []byte(tfConfigStr)},
+	}
+	secrets := e.tc.KubeClientSet.CoreV1().Secrets(tfjob.Namespace)
+	if _, err := secrets.Create(context.TODO(), secret, metav1.CreateOptions{}); errors.IsAlreadyExists(err) {
+		// The cluster spec changed since the replica was last created.
+		if _, err := secrets.Update(context.TODO(), secret, metav1.UpdateOptions{}); err != nil {
+			return err
+		}
+	} else if err != nil {
+		return err
+	}
+
+	mountTFConfigFile(podTemplate, v1.VolumeSource{
+		Secret: &v1.SecretVolumeSource{
+			SecretName: secret.Name,
+			Items:      []v1.KeyToPath{{Key: tfConfigFileName, Path: tfConfigFileName}},
+		},
+	})
+	return nil
+}
+
+// deleteTFConfigSecrets deletes the TF_CONFIG Secrets owned by the tfjob.
+func (tc *TFController) deleteTFConfigSecrets(tfjob *tfv1.TFJob) error {
+	secrets := tc.KubeClientSet.CoreV1().Secrets(tfjob.Namespace)
+	list, err := secrets.List(context.TODO(), metav1.ListOptions{
+		LabelSelector: labels.SelectorFromSet(tc.GenLabels(tfjob.Name)).String(),
+	})
+	if err != nil {
+		return err
+	}
+	for i := range list.Items {
+		if !metav1.IsControlledBy(&list.Items[i], tfjob) {
+			continue
+		}
+		if err := secrets.Delete(context.TODO(), list.Items[i].Name, metav1.DeleteOptions{}); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -71,6 +71,12 @@ var (
 	defaultCleanPodPolicy = commonv1.CleanPodPolicyNone
 )
 
+const (
+	// tfControllerOnlyReason is added in an event when a TFJob uses features
+	// which the TFJobReconciler ignores.
+	tfControllerOnlyReason = "TFControllerOnlyFeatures"
+)
+
 func NewReconciler(mgr manager.Manager) *TFJobReconciler {
 	r := &TFJobReconciler{
 		Client:   mgr.GetClient(),
@@ -99,6 +105,11 @@ func NewReconciler(mgr manager.Manager) *TFJobReconciler {
 }
 
 // TFJobReconciler reconciles a TFJob object
+//
+// It reconciles the job level state through JobController.ReconcileJobs of
+// kubeflow/common, so the TFJob features implemented by the TFController of
+// the tf-operator binary are not available, e.g. the TFJob spec fields
+// returned by tfControllerOnlyFeatures. TFJobs using them get a warning event.
 type TFJobReconciler struct {
 	common.JobController
 	client.Client
@@ -148,6 +159,13 @@ func (r *TFJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	// Set default priorities to tfjob
 	r.Scheme.Default(tfjob)
 
+	if features := tfControllerOnlyFeatures(tfjob); len(features) > 0 {
+		msg := fmt.Sprintf("TFJob %s uses %s, which only the tf-operator controller implements; ignoring them",
+			tfjob.Name, strings.Join(features, ", "))
+		logger.Info(msg)
+		r.Recorder.Event(tfjob, corev1.EventTypeWarning, tfControllerOnlyReason, msg)
+	}
+
 	// Use common to reconcile the job related pod and service
 	err = r.ReconcileJobs(tfjob, tfjob.Spec.TFReplicaSpecs, tfjob.Status, &tfjob.Spec.RunPolicy)
 	if err != nil {
@@ -158,6 +176,58 @@ func (r *TFJobReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl
 	return ctrl.Result{}, nil
 }
 
+// tfControllerOnlyFeatures returns the fields and annotations set on the
+// TFJob which are only implemented by the TFController.
+func tfControllerOnlyFeatures(tfJob *tfv1.TFJob) []string {
+	var features []string
+	spec := &tfJob.Spec
+	if spec.PSFailurePolicy != nil {
+		features = append(features, "psFailurePolicy")
+	}
+	if spec.PSMinAvailable != nil {
+		features = append(features, "psMinAvailable")
+	}
+	if spec.ChiefFailurePolicy != nil {
+		features = append(features, "chiefFailurePolicy")
+	}
+	if spec.ChiefNodePreference != nil {
+		features = append(features, "chiefNodePreference")
+	}
+	if len(spec.TFReplicaPolicies) > 0 {
+		features = append(features, "tfReplicaPolicies")
+	}
+	if len(spec.CommonEnv) > 0 {
+		features = append(features, "commonEnv")
+	}
+	if len(spec.CommonSidecars) > 0 {
+		features = append(features, "commonSidecars")
+	}
+	if spec.DNSPolicy != "" {
+		features = append(features, "dnsPolicy")
+	}
+	if spec.DNSConfig != nil {
+		features = append(features, "dnsConfig")
+	}
+	if spec.PortRange != nil {
+		features = append(features, "portRange")
+	}
+	if len(spec.AdditionalOwnerReferences) > 0 {
+		features = append(features, "additionalOwnerReferences")
+	}
+	for _, annotation := range []string{
+		tfv1.ResetDeadlineOnUpdateAnnotation,
+		tfv1.ClusterSpecAnnotation,
+		tfv1.CleanPodPolicyAnnotation,
+		tfv1.ReconcileNonceAnnotation,
+		tfv1.ManagedAnnotation,
+	} {
+		if _, ok := tfJob.Annotations[annotation]; ok {
+			features = append(features, annotation)
+		}
+	}
+	return features
+}
+
 // SetupWithManager sets up the controller with the Manager.
 func (r *TFJobReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	c, err := controller.New(r.ControllerName(), mgr, controller.Options{
@@ -483,13 +553,13 @@ func (r *TFJobReconciler) UpdateJobStatus(job interface{}, replicas map[commonv1
 			} else {
 				msg := fmt.Sprintf("TFJob %s/%s has failed because %d %s replica(s) failed.",
 					tfJob.Namespace, tfJob.Name, failed, rtype)
-				r.recorder.Event(tfJob, corev1.EventTypeNormal, tfJobFailedReason, msg)
+				r.recorder.Event(tfJob, corev1.EventTypeNormal, TFJobFailedReasonPodFailure, msg)
 				if jobStatus.CompletionTime == nil {
 					now := metav1.Now()
 					jobStatus.CompletionTime = &now
 				}
 				err := commonutil.UpdateJobConditions(jobStatus,
-					commonv1.JobFailed, tfJobFailedReason, msg)
+					commonv1.JobFailed, TFJobFailedReasonPodFailure, msg)
 				if err != nil {
 					commonutil.LoggerForJob(tfJob).Infof("Append tfjob condition error: %v", err)
 					return err
@@ -522,6 +592,10 @@ func (r *TFJobReconciler) UpdateJobStatusInApiServer(job interface{}, jobStatus
 
 	tfJob = tfJob.DeepCopy()
 	tfJob.Status = *jobStatus.DeepCopy()
+	// The status is only written when the reconciliation changed it, so that
+	// the last reconcile time does not make the tfjob reconcile over and over.
+	now := metav1.Now()
+	tfJob.Status.LastReconcileTime = &now
 
 	result := r.Status().Update(context.Background(), tfJob)
 
@@ -779,6 +853,13 @@ func (r *TFJobReconciler) createNewPod(tfjob *tfv1.TFJob, rt, index string, spec
 	for key, value := range labels {
 		podTemplate.Labels[key] = value
 	}
+	setReplicaLabels(podTemplate, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
+
+	setCommonEnv(podTemplate, tfjob.Spec.CommonEnv)
+	setDNS(podTemplate, tfjob.Spec.DNSPolicy, tfjob.Spec.DNSConfig)
+	setSecurityContext(podTemplate, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
+	setTerminationGracePeriod(podTemplate, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
+	setPreStopHook(podTemplate, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
 
 	if err := r.SetClusterSpec(tfjob, podTemplate, rt, index); err != nil {
 		return err
@@ -791,7 +872,7 @@ func (r *TFJobReconciler) createNewPod(tfjob *tfv1.TFJob, rt, index string, spec
 		logger.Warning(errMsg)
 		r.Recorder.Event(tfjob, v1.EventTypeWarning, podTemplateRestartPolicyReason, errMsg)
 	}
-	setRestartPolicy(podTemplate, spec)
+	setRestartPolicy(podTemplate, spec, getReplicaPolicy(tfjob, commonv1.ReplicaType(rt)))
 
 	// if gang-scheduling is enabled:
 	// 1. if user has specified other scheduler, we report a warning without overriding any fields.
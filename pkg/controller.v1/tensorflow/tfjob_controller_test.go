@@ -0,0 +1,67 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"reflect"
+	"testing"
+
+	v1 "k8s.io/api/core/v1"
+
+	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	"github.com/kubeflow/tf-operator/pkg/common/util/v1/testutil"
+)
+
+func TestTFControllerOnlyFeatures(t *testing.T) {
+	testCases := []struct {
+		description string
+		update      func(tfJob *tfv1.TFJob)
+		expected    []string
+	}{
+		{
+			description: "A TFJob using the common features only",
+			update:      func(tfJob *tfv1.TFJob) {},
+			expected:    nil,
+		},
+		{
+			description: "A TFJob with replica policies and a PS failure policy",
+			update: func(tfJob *tfv1.TFJob) {
+				policy := tfv1.PSFailurePolicyFailJob
+				tfJob.Spec.PSFailurePolicy = &policy
+				tfJob.Spec.TFReplicaPolicies = map[commonv1.ReplicaType]*tfv1.TFReplicaPolicy{
+					tfv1.TFReplicaTypeWorker: {KeepAliveAfterCompletion: true},
+				}
+			},
+			expected: []string{"psFailurePolicy", "tfReplicaPolicies"},
+		},
+		{
+			description: "A TFJob with a DNS policy and the clean pod policy annotation",
+			update: func(tfJob *tfv1.TFJob) {
+				tfJob.Spec.DNSPolicy = v1.DNSClusterFirst
+				tfJob.Annotations = map[string]string{tfv1.CleanPodPolicyAnnotation: "None"}
+			},
+			expected: []string{"dnsPolicy", tfv1.CleanPodPolicyAnnotation},
+		},
+	}
+
+	for _, c := range testCases {
+		tfJob := testutil.NewTFJob(1, 1)
+		c.update(tfJob)
+		if actual := tfControllerOnlyFeatures(tfJob); !reflect.DeepEqual(actual, c.expected) {
+			t.Errorf("%s: expected %v, got %v", c.description, c.expected, actual)
+		}
+	}
+}
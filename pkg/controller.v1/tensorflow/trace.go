@@ -0,0 +1,51 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"context"
+
+	log "github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/util/uuid"
+)
+
+// traceIDField is the log field holding the trace ID of a reconcile.
+const traceIDField = "trace"
+
+// traceIDKey is the context key of the trace ID of a reconcile.
+type traceIDKey struct{}
+
+// newTraceContext returns a copy of the context carrying a new trace ID, which
+// correlates the logs of a reconcile of a tfjob down to the creation of its
+// pods.
+func newTraceContext(ctx context.Context) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, string(uuid.NewUUID()))
+}
+
+// traceIDFrom returns the trace ID carried by the context, or "" if it carries
+// none.
+func traceIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
+
+// traceLogger returns the logger with the trace ID carried by the context, if
+// any.
+func traceLogger(ctx context.Context, logger *log.Entry) *log.Entry {
+	if id := traceIDFrom(ctx); id != "" {
+		return logger.WithField(traceIDField, id)
+	}
+	return logger
+}
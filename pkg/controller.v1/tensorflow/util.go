@@ -16,6 +16,9 @@ package tensorflow
 
 import (
 	"fmt"
+	"sort"
+	"strings"
+	"time"
 
 	commonv1 "github.com/kubeflow/common/pkg/apis/common/v1"
 	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
@@ -41,6 +44,39 @@ func GetPortFromTFJob(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType) (int32, err
 	return tfv1.DefaultPort, nil
 }
 
+// getReplicaPort returns the port of the replica of the given type and index.
+// It is the port of the replica type, unless the tfjob has a port range: its
+// replicas then get distinct ports of the range, numbered by replica type in
+// alphabetical order, then by index.
+func getReplicaPort(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType, index int32) (int32, error) {
+	portRange := tfJob.Spec.PortRange
+	if portRange == nil {
+		return GetPortFromTFJob(tfJob, rtype)
+	}
+	rtypes := make([]string, 0, len(tfJob.Spec.TFReplicaSpecs))
+	for rt := range tfJob.Spec.TFReplicaSpecs {
+		rtypes = append(rtypes, string(rt))
+	}
+	sort.Strings(rtypes)
+
+	port := portRange.Min
+	for _, rt := range rtypes {
+		if strings.EqualFold(rt, string(rtype)) {
+			port += index
+			if port > portRange.Max {
+				return 0, fmt.Errorf("port range %d-%d is too small for %s %d", portRange.Min, portRange.Max, rtype, index)
+			}
+			return port, nil
+		}
+		spec := tfJob.Spec.TFReplicaSpecs[commonv1.ReplicaType(rt)]
+		if spec == nil || spec.Replicas == nil {
+			return 0, fmt.Errorf("replicas of %s are not set in the tfjob", rt)
+		}
+		port += *spec.Replicas
+	}
+	return 0, fmt.Errorf("replica type %s is not found in the tfjob", rtype)
+}
+
 // ContainChieforMasterSpec returns true if the tfjob contains chief or master spec.
 func ContainChieforMasterSpec(replicas map[commonv1.ReplicaType]*commonv1.ReplicaSpec) bool {
 	if _, ok := replicas[tfv1.TFReplicaTypeChief]; ok {
@@ -50,3 +86,87 @@ func ContainChieforMasterSpec(replicas map[commonv1.ReplicaType]*commonv1.Replic
 	}
 	return false
 }
+
+// isFinishedPastTTL returns true if the job succeeded or failed, and its
+// TTLSecondsAfterFinished has passed since it completed.
+func isFinishedPastTTL(runPolicy *commonv1.RunPolicy, jobStatus commonv1.JobStatus) bool {
+	if !isSucceeded(jobStatus) && !isFailed(jobStatus) {
+		return false
+	}
+	if runPolicy.TTLSecondsAfterFinished == nil || jobStatus.CompletionTime == nil {
+		return false
+	}
+	ttl := time.Duration(*runPolicy.TTLSecondsAfterFinished) * time.Second
+	return time.Now().After(jobStatus.CompletionTime.Add(ttl))
+}
+
+// getReplicaPolicy returns the TFReplicaPolicy of the given replica type, or nil
+// if there is none. The replica type is matched case-insensitively, so that the
+// lower case replica type labels of pods and services can be used.
+func getReplicaPolicy(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType) *tfv1.TFReplicaPolicy {
+	for t, policy := range tfJob.Spec.TFReplicaPolicies {
+		if strings.EqualFold(string(t), string(rtype)) {
+			return policy
+		}
+	}
+	return nil
+}
+
+// keepAliveAfterCompletion returns true if the pods of the given replica type
+// should keep running after the tfjob completes.
+func keepAliveAfterCompletion(tfJob *tfv1.TFJob, rtype commonv1.ReplicaType) bool {
+	policy := getReplicaPolicy(tfJob, rtype)
+	return policy != nil && policy.KeepAliveAfterCompletion
+}
+
+// replicaDeadlineExceeded returns true if the ActiveDeadlineSeconds of the
+// given replica type has passed. Otherwise it returns the time left until the
+// deadline, or zero if the replica type has no deadline.
+func replicaDeadlineExceeded(tfJob *tfv1.TFJob, jobStatus *commonv1.JobStatus, rtype commonv1.ReplicaType) (bool, time.Duration) {
+	policy := getReplicaPolicy(tfJob, rtype)
+	if policy == nil || policy.ActiveDeadlineSeconds == nil || jobStatus.StartTime == nil {
+		return false, 0
+	}
+	deadline := jobStatus.StartTime.Add(time.Duration(*policy.ActiveDeadlineSeconds) * time.Second)
+	remaining := time.Until(deadline)
+	if remaining <= 0 {
+		return true, 0
+	}
+	return false, remaining
+}
+
+// createBudget limits the number of pods or services created in a reconcile
+// pass. A nil budget is unlimited.
+type createBudget struct {
+	// remaining is the number of creations still allowed.
+	remaining int
+	// deferred is set once a creation has been refused.
+	deferred bool
+}
+
+// newCreateBudget returns a budget of the given size, or nil if size is not
+// positive.
+func newCreateBudget(size int) *createBudget {
+	if size <= 0 {
+		return nil
+	}
+	return &createBudget{remaining: size}
+}
+
+// take consumes one creation from the budget, returning false if it is exhausted.
+func (b *createBudget) take() bool {
+	if b == nil {
+		return true
+	}
+	if b.remaining <= 0 {
+		b.deferred = true
+		return false
+	}
+	b.remaining--
+	return true
+}
+
+// hasDeferred returns true if any creation has been refused by the budget.
+func (b *createBudget) hasDeferred() bool {
+	return b != nil && b.deferred
+}
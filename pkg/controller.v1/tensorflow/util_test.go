@@ -79,3 +79,18 @@ func TestConvertTFJobToUnstructured(t *testing.T) {
 		t.Errorf("Expected error to be nil while got %v", err)
 	}
 }
+
+func TestGetReplicaPortWithoutReplicas(t *testing.T) {
+	// The PS comes before the workers in the port range, so the ports of the
+	// workers depend on the replicas of the PS.
+	tfJob := testutil.NewTFJob(2, 1)
+	tfJob.Spec.PortRange = &tfv1.PortRange{Min: 3000, Max: 3009}
+	tfJob.Spec.TFReplicaSpecs[tfv1.TFReplicaTypePS].Replicas = nil
+
+	if _, err := getReplicaPort(tfJob, tfv1.TFReplicaTypeWorker, 0); err == nil {
+		t.Error("Expected an error when the replicas of the PS are not set")
+	}
+	if port, err := getReplicaPort(tfJob, tfv1.TFReplicaTypePS, 0); err != nil || port != 3000 {
+		t.Errorf("Expected the PS port 3000, got %d and error %v", port, err)
+	}
+}
@@ -0,0 +1,60 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"fmt"
+
+	tfv1 "github.com/kubeflow/tf-operator/pkg/apis/tensorflow/v1"
+	"github.com/kubeflow/tf-operator/pkg/apis/tensorflow/validation"
+	"sigs.k8s.io/yaml"
+)
+
+// ValidateFromYAML decodes a TFJob from the given YAML and validates it the
+// way the controller does, without a connection to a cluster. The TFJob is
+// defaulted, its spec is validated and the TensorFlow cluster spec is generated
+// to catch topology errors. It returns all the errors found.
+func ValidateFromYAML(data []byte) []error {
+	tfJob := &tfv1.TFJob{}
+	if err := yaml.UnmarshalStrict(data, tfJob); err != nil {
+		return []error{fmt.Errorf("failed to decode TFJob: %v", err)}
+	}
+
+	var errs []error
+	if tfJob.Kind != tfv1.Kind {
+		errs = append(errs, fmt.Errorf("kind %q is not %s", tfJob.Kind, tfv1.Kind))
+	}
+	if tfJob.Name == "" {
+		errs = append(errs, fmt.Errorf("TFJob name is empty"))
+	}
+
+	tfv1.SetObjectDefaults_TFJob(tfJob)
+	if err := validation.ValidateV1TFJobSpec(&tfJob.Spec); err != nil {
+		// The cluster spec can not be generated from an invalid spec.
+		return append(errs, err)
+	}
+	for rtype, spec := range tfJob.Spec.TFReplicaSpecs {
+		if *spec.Replicas < 0 {
+			errs = append(errs, fmt.Errorf("replicas of %v must not be negative, got %d", rtype, *spec.Replicas))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	if _, err := genClusterSpec(tfJob); err != nil {
+		errs = append(errs, fmt.Errorf("failed to generate the cluster spec: %v", err))
+	}
+	return errs
+}
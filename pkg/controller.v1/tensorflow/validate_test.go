@@ -0,0 +1,163 @@
+// Copyright 2021 The Kubeflow Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tensorflow
+
+import (
+	"testing"
+)
+
+func TestValidateFromYAML(t *testing.T) {
+	type tc struct {
+		name        string
+		yaml        string
+		expectedErr bool
+	}
+	testCases := []tc{
+		{
+			name: "valid",
+			yaml: `
+apiVersion: kubeflow.org/v1
+kind: TFJob
+metadata:
+  name: mnist
+spec:
+  tfReplicaSpecs:
+    PS:
+      replicas: 1
+      template:
+        spec:
+          containers:
+          - name: tensorflow
+            image: kubeflow/tf-dist-mnist-test:1.0
+    Worker:
+      replicas: 2
+      template:
+        spec:
+          containers:
+          - name: tensorflow
+            image: kubeflow/tf-dist-mnist-test:1.0
+`,
+			expectedErr: false,
+		},
+		{
+			name:        "malformed yaml",
+			yaml:        "kind: TFJob\nspec: [",
+			expectedErr: true,
+		},
+		{
+			name: "unknown field",
+			yaml: `
+apiVersion: kubeflow.org/v1
+kind: TFJob
+metadata:
+  name: mnist
+spec:
+  tfReplicaSpec:
+    Worker:
+      replicas: 1
+`,
+			expectedErr: true,
+		},
+		{
+			name: "wrong kind",
+			yaml: `
+apiVersion: kubeflow.org/v1
+kind: PyTorchJob
+metadata:
+  name: mnist
+spec:
+  tfReplicaSpecs:
+    Worker:
+      template:
+        spec:
+          containers:
+          - name: tensorflow
+            image: kubeflow/tf-dist-mnist-test:1.0
+`,
+			expectedErr: true,
+		},
+		{
+			name: "no tensorflow container",
+			yaml: `
+apiVersion: kubeflow.org/v1
+kind: TFJob
+metadata:
+  name: mnist
+spec:
+  tfReplicaSpecs:
+    Worker:
+      template:
+        spec:
+          containers:
+          - name: main
+            image: kubeflow/tf-dist-mnist-test:1.0
+`,
+			expectedErr: true,
+		},
+		{
+			name: "two chiefs",
+			yaml: `
+apiVersion: kubeflow.org/v1
+kind: TFJob
+metadata:
+  name: mnist
+spec:
+  tfReplicaSpecs:
+    Chief:
+      template:
+        spec:
+          containers:
+          - name: tensorflow
+            image: kubeflow/tf-dist-mnist-test:1.0
+    Master:
+      template:
+        spec:
+          containers:
+          - name: tensorflow
+            image: kubeflow/tf-dist-mnist-test:1.0
+`,
+			expectedErr: true,
+		},
+		{
+			name: "negative replicas",
+			yaml: `
+apiVersion: kubeflow.org/v1
+kind: TFJob
+metadata:
+  name: mnist
+spec:
+  tfReplicaSpecs:
+    Worker:
+      replicas: -1
+      template:
+        spec:
+          containers:
+          - name: tensorflow
+            image: kubeflow/tf-dist-mnist-test:1.0
+`,
+			expectedErr: true,
+		},
+	}
+
+	for _, c := range testCases {
+		errs := ValidateFromYAML([]byte(c.yaml))
+		if c.expectedErr && len(errs) == 0 {
+			t.Errorf("%s: expected errors, got none", c.name)
+		}
+		if !c.expectedErr && len(errs) != 0 {
+			t.Errorf("%s: expected no errors, got %v", c.name, errs)
+		}
+	}
+}